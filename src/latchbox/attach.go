@@ -0,0 +1,298 @@
+/*-
+ * Copyright (C) 2014-2017, Vi Grey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY AUTHOR AND CONTRIBUTORS "AS IS" AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL AUTHOR OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+/*
+ * Commands to attach files to entries of a password file, list them,
+ * extract them and remove them again.  Attachments are saved encrypted
+ * inside the password file with the entry they belong to.
+ */
+
+package main
+
+import (
+  "errors"
+  "fmt"
+  "io/ioutil"
+  "latchbox/vault"
+  "os"
+  "path/filepath"
+  "time"
+)
+
+/*
+ * latchbox attach [--name NAME] [--replace] FILE ENTRY PATH
+ *
+ * Attaches the file in PATH to the entry ENTRY (its name/group combination)
+ * of the password file FILE as NAME, which is the base name of PATH unless
+ * --name is used.  An attachment with the same name is only replaced if
+ * --replace is used.  Files bigger than attachmentSizeLimit in the config
+ * file can't be attached.  Exits with 0 if the file was attached and 2 if
+ * it wasn't.
+ */
+func attachCommand(args []string) int {
+  opts, operands := commandArgs("attach", args, []string{"replace"},
+                                []string{"name"})
+  if len(operands) != 3 {
+    fmt.Printf("Usage: latchbox attach [ --name NAME ] [ --replace ] FILE " +
+               "ENTRY PATH\n")
+    return 2
+  }
+  path, nameGroup, attachPath := operands[0], operands[1], operands[2]
+  tildeHome(&path)
+  tildeHome(&attachPath)
+  name := opts["name"]
+  if name == "" {
+    name = filepath.Base(attachPath)
+  }
+  makeConfig()
+  configParse()
+  info, err := os.Stat(attachPath)
+  if err == nil && info.IsDir() {
+    err = errors.New("Is a Directory")
+  } else if err == nil && info.Size() > int64(attachmentSizeLimit) {
+    err = fmt.Errorf("File Bigger Than Attachment Size Limit of %s",
+                     humanSize(int64(attachmentSizeLimit)))
+  }
+  var data []byte
+  if err == nil {
+    data, err = ioutil.ReadFile(attachPath)
+  }
+  if err != nil {
+    fmt.Fprintf(os.Stderr, "latchbox attach: %s: %s\n", attachPath, err)
+    return 2
+  }
+  v, e, ok := unlockEntry("attach", path, nameGroup)
+  if !ok {
+    return 2
+  }
+  if a := e.Attachment(name); a != nil {
+    if opts["replace"] == "" {
+      fmt.Fprintf(os.Stderr, "latchbox attach: %s: Attachment %s Already " +
+                  "Exists\n", nameGroup, name)
+      return 2
+    }
+    a.Data = data
+  } else {
+    e.Attachments = append(e.Attachments, vault.Attachment{Name: name,
+                                                           Data: data})
+  }
+  if err := saveAttachments(v, e); err != nil {
+    fmt.Fprintf(os.Stderr, "latchbox attach: %s: %s\n", path, err)
+    return 2
+  }
+  fmt.Printf("%s: Attached %s (%s)\n", nameGroup, name,
+             humanSize(int64(len(data))))
+  return 0
+}
+
+/*
+ * latchbox attachments FILE [ENTRY]
+ *
+ * Lists the attachments of every entry of the password file FILE, or only
+ * of the entry ENTRY if it's given, along with their sizes.  Exits with 0
+ * if the attachments were listed and 2 if they couldn't be.
+ */
+func attachmentsCommand(args []string) int {
+  _, operands := commandArgs("attachments", args, nil, nil)
+  if len(operands) < 1 || len(operands) > 2 {
+    fmt.Printf("Usage: latchbox attachments FILE [ ENTRY ]\n")
+    return 2
+  }
+  path := operands[0]
+  tildeHome(&path)
+  var entries []*vault.Entry
+  if len(operands) == 2 {
+    _, e, ok := unlockEntry("attachments", path, operands[1])
+    if !ok {
+      return 2
+    }
+    entries = []*vault.Entry{e}
+  } else {
+    v, err := unlockPrompted(path)
+    if err != nil {
+      fmt.Fprintf(os.Stderr, "latchbox attachments: %s: %s\n", path, err)
+      return 2
+    }
+    entries = v.Sorted()
+  }
+  for _, e := range entries {
+    for _, a := range e.Attachments {
+      fmt.Printf("%s: %s (%s)\n", e.NameGroup(), a.Name,
+                 humanSize(int64(len(a.Data))))
+    }
+  }
+  return 0
+}
+
+/*
+ * latchbox extract [--force] FILE ENTRY NAME DEST
+ *
+ * Writes the attachment NAME of the entry ENTRY of the password file FILE
+ * to DEST, which is only readable and writable by its owner.  DEST is only
+ * overwritten if --force is used.  If DEST is -, the attachment is written
+ * to stdout instead.  Exits with 0 if the attachment was written and 2 if
+ * it wasn't.
+ */
+func extractCommand(args []string) int {
+  opts, operands := commandArgs("extract", args, []string{"force"}, nil)
+  if len(operands) != 4 {
+    fmt.Printf("Usage: latchbox extract [ --force ] FILE ENTRY NAME DEST\n")
+    return 2
+  }
+  path, nameGroup, name, dest := operands[0], operands[1], operands[2],
+      operands[3]
+  tildeHome(&path)
+  tildeHome(&dest)
+  _, e, ok := unlockEntry("extract", path, nameGroup)
+  if !ok {
+    return 2
+  }
+  a := e.Attachment(name)
+  if a == nil {
+    fmt.Fprintf(os.Stderr, "latchbox extract: %s: Attachment %s Not " +
+                "Found\n", nameGroup, name)
+    return 2
+  }
+  if dest == "-" {
+    os.Stdout.Write(a.Data)
+    return 0
+  }
+  if err := writeAttachment(dest, a.Data, opts["force"] != ""); err != nil {
+    fmt.Fprintf(os.Stderr, "latchbox extract: %s: %s\n", dest, err)
+    return 2
+  }
+  fmt.Fprintf(os.Stderr, "%s: Extracted %s to %s\n", nameGroup, name, dest)
+  return 0
+}
+
+/*
+ * latchbox detach FILE ENTRY NAME
+ *
+ * Removes the attachment NAME from the entry ENTRY of the password file
+ * FILE.  Exits with 0 if the attachment was removed and 2 if it wasn't.
+ */
+func detachCommand(args []string) int {
+  _, operands := commandArgs("detach", args, nil, nil)
+  if len(operands) != 3 {
+    fmt.Printf("Usage: latchbox detach FILE ENTRY NAME\n")
+    return 2
+  }
+  path, nameGroup, name := operands[0], operands[1], operands[2]
+  tildeHome(&path)
+  makeConfig()
+  configParse()
+  v, e, ok := unlockEntry("detach", path, nameGroup)
+  if !ok {
+    return 2
+  }
+  if !e.Detach(name) {
+    fmt.Fprintf(os.Stderr, "latchbox detach: %s: Attachment %s Not " +
+                "Found\n", nameGroup, name)
+    return 2
+  }
+  if err := saveAttachments(v, e); err != nil {
+    fmt.Fprintf(os.Stderr, "latchbox detach: %s: %s\n", path, err)
+    return 2
+  }
+  fmt.Printf("%s: Detached %s\n", nameGroup, name)
+  return 0
+}
+
+/*
+ * Unlocks the password file in path for command and finds the entry with
+ * the name/group combination nameGroup in it.  Prints an error and returns
+ * false if either can't be done.
+ */
+func unlockEntry(command, path, nameGroup string) (*vault.Vault,
+    *vault.Entry, bool) {
+  v, err := unlockPrompted(path)
+  if err != nil {
+    fmt.Fprintf(os.Stderr, "latchbox %s: %s: %s\n", command, path, err)
+    return nil, nil, false
+  }
+  e := v.Find(nameGroup)
+  if e == nil {
+    fmt.Fprintf(os.Stderr, "latchbox %s: %s: %s\n", command, nameGroup,
+                vault.ErrNotFound)
+    return nil, nil, false
+  }
+  return v, e, true
+}
+
+/*
+ * Saves the password file v after the attachments of e were changed,
+ * keeping its cipher and PBKDF2 iterations.  A backup of the password file
+ * as it was is made first if backups are allowed in the config file.
+ */
+func saveAttachments(v *vault.Vault, e *vault.Entry) error {
+  if err := e.Validate(); err != nil {
+    return err
+  }
+  e.Modified = time.Unix(time.Now().Unix(), 0)
+  return saveVault(v)
+}
+
+/*
+ * Saves the password file v like saveAttachments, but without marking any
+ * entry as modified.
+ */
+func saveVault(v *vault.Vault) error {
+  ciphertext := v.Ciphertext()
+  lock()
+  vlt = v
+  fPath = v.Path
+  cipherType = v.Cipher
+  iterations = v.Iterations
+  backupContents = ciphertext
+  return writeData()
+}
+
+/*
+ * Writes the attachment data to path so only its owner can read and write
+ * it.  path is only replaced if it already exists when force is true.
+ */
+func writeAttachment(path string, data []byte, force bool) error {
+  flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+  if force {
+    flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+  }
+  f, err := os.OpenFile(path, flags, 0600)
+  if os.IsExist(err) {
+    return errors.New("File Already Exists (Use --force to Replace It)")
+  } else if err != nil {
+    return err
+  }
+  if err := f.Chmod(0600); err != nil {
+    f.Close()
+    return err
+  }
+  if _, err := f.Write(data); err != nil {
+    f.Close()
+    return err
+  }
+  return f.Close()
+}
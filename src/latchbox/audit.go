@@ -0,0 +1,452 @@
+/*-
+ * Copyright (C) 2014-2017, Vi Grey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY AUTHOR AND CONTRIBUTORS "AS IS" AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL AUTHOR OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+/*
+ * Security audit of the entries of a password file, which finds reused,
+ * weak, empty and old passwords, plain http URLs, near-duplicate entries
+ * and entries running out of recovery codes, along with the audit command.
+ */
+
+package main
+
+import (
+  "encoding/json"
+  "fmt"
+  "github.com/nsf/termbox-go"
+  "latchbox/vault"
+  "math"
+  "net/url"
+  "os"
+  "sort"
+  "strconv"
+  "strings"
+  "time"
+  "unicode"
+)
+
+const (
+  lowSeverity = iota
+  mediumSeverity
+  highSeverity
+)
+
+/* Names of the severities of findings, from lowSeverity to highSeverity. */
+var severityNames = []string{"Low", "Medium", "High"}
+
+const (
+  /* Passwords with fewer bits of entropy than this are very weak. */
+  veryWeakBits = 40
+  /* Passwords with fewer bits of entropy than this are weak. */
+  weakBits = 60
+)
+
+/* A problem the audit found with an entry. */
+type finding struct {
+  severity int
+  check, detail string
+  entry *vault.Entry
+}
+
+var (
+  /* Findings of the AUDIT menu, which are only worked out again if !audited. */
+  auditFindings []finding
+  auditErr error
+  audited bool
+)
+
+/* A finding as it is written by latchbox audit --json. */
+type jsonFinding struct {
+  Severity string `json:"severity"`
+  Check string `json:"check"`
+  Entry string `json:"entry"`
+  Detail string `json:"detail"`
+}
+
+/*
+ * Returns an estimate of the bits of entropy of password, which assumes
+ * every character was picked at random from the kinds of characters it
+ * uses.
+ */
+func passwordEntropy(password string) float64 {
+  var pool int
+  var upper, lower, digit, punct, other bool
+  for _, r := range password {
+    if r > unicode.MaxASCII {
+      other = true
+    } else if strings.ContainsRune(uppercase, r) {
+      upper = true
+    } else if strings.ContainsRune(lowercase, r) {
+      lower = true
+    } else if strings.ContainsRune(digits, r) {
+      digit = true
+    } else {
+      punct = true
+    }
+  }
+  if upper {
+    pool += len(uppercase)
+  }
+  if lower {
+    pool += len(lowercase)
+  }
+  if digit {
+    pool += len(digits)
+  }
+  if punct {
+    /* Spaces count as punctuation. */
+    pool += len(punctuation) + 1
+  }
+  if other {
+    pool += 100
+  }
+  if pool == 0 {
+    return 0
+  }
+  return float64(len([]rune(password))) * math.Log2(float64(pool))
+}
+
+/*
+ * Returns true if e is meant to have a password, which is every entry
+ * except ones made with a template that doesn't ask for one.
+ */
+func wantsPassword(e *vault.Entry) bool {
+  t := templateNamed(e.Type)
+  return t == nil || t.standard["password"]
+}
+
+/*
+ * Returns the keys near-duplicate entries share, which are the name of the
+ * entry ignoring case and spaces, and the host of its URL along with its
+ * username.
+ */
+func duplicateKeys(e *vault.Entry) []string {
+  var keys []string
+  name := strings.ToLower(strings.Join(strings.Fields(e.Name), ""))
+  if name != "" {
+    keys = append(keys, "name:" + name)
+  }
+  if e.URL != "" && e.Username != "" {
+    link := e.URL
+    if !strings.Contains(link, "://") {
+      link = "http://" + link
+    }
+    if u, err := url.Parse(link); err == nil && u.Hostname() != "" {
+      host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+      keys = append(keys, "login:" + host + " " + e.Username)
+    }
+  }
+  return keys
+}
+
+/*
+ * Returns the entries that share a key in keys of every entry of entries,
+ * not counting the entry itself.
+ */
+func sharing(entries []*vault.Entry,
+    keys func(*vault.Entry) []string) map[*vault.Entry][]*vault.Entry {
+  byKey := make(map[string][]*vault.Entry)
+  for _, e := range entries {
+    for _, k := range keys(e) {
+      byKey[k] = append(byKey[k], e)
+    }
+  }
+  shared := make(map[*vault.Entry][]*vault.Entry)
+  for _, e := range entries {
+    for _, k := range keys(e) {
+      for _, other := range byKey[k] {
+        if other != e && !containsEntry(shared[e], other) {
+          shared[e] = append(shared[e], other)
+        }
+      }
+    }
+  }
+  return shared
+}
+
+/* Returns true if e is in entries. */
+func containsEntry(entries []*vault.Entry, e *vault.Entry) bool {
+  for _, listed := range entries {
+    if listed == e {
+      return true
+    }
+  }
+  return false
+}
+
+/* Returns the name/group combinations of entries separated by commas. */
+func nameGroups(entries []*vault.Entry) string {
+  var names []string
+  for _, e := range vault.Sort(entries) {
+    names = append(names, e.NameGroup())
+  }
+  return strings.Join(names, ", ")
+}
+
+/*
+ * Audits entries and returns what was found, most severe first and then in
+ * the order entries are listed in.  Passwords that weren't modified for
+ * maxAge days are reported as old, unless maxAge is 0.  Passwords are
+ * checked against the breached passwords file in breached unless it is "",
+ * and an error is returned along with the other findings if that fails.
+ */
+func audit(entries []*vault.Entry, maxAge int, breached string,
+    now time.Time) ([]finding, error) {
+  var findings []finding
+  var breaches map[*vault.Entry]int
+  var err error
+  if breached != "" {
+    breaches, err = breachCounts(entries, breached)
+  }
+  reused := sharing(entries, func(e *vault.Entry) []string {
+    if e.Password == "" {
+      return nil
+    }
+    return []string{e.Password}
+  })
+  duplicates := sharing(entries, duplicateKeys)
+  for _, e := range vault.Sort(entries) {
+    if count := breaches[e]; count > 0 {
+      findings = append(findings, finding{highSeverity, "breached",
+                                          "Found " + strconv.Itoa(count) +
+                                          " Times in Breached Passwords", e})
+    }
+    if others := reused[e]; len(others) > 0 {
+      findings = append(findings, finding{highSeverity, "reused",
+                                          "Password Also Used by " +
+                                          nameGroups(others), e})
+    }
+    if e.Password == "" {
+      if wantsPassword(e) {
+        findings = append(findings, finding{highSeverity, "empty",
+                                            "Empty Password", e})
+      }
+    } else if bits := passwordEntropy(e.Password); bits < veryWeakBits {
+      findings = append(findings, finding{highSeverity, "weak",
+                                          "Very Weak Password (About " +
+                                          strconv.Itoa(int(bits)) +
+                                          " Bits)", e})
+    } else if bits < weakBits {
+      findings = append(findings, finding{mediumSeverity, "weak",
+                                          "Weak Password (About " +
+                                          strconv.Itoa(int(bits)) +
+                                          " Bits)", e})
+    }
+    if strings.HasPrefix(strings.ToLower(e.URL), "http://") {
+      findings = append(findings, finding{mediumSeverity, "http",
+                                          "URL Uses Plain http", e})
+    }
+    if lowRecoveryCodes(e) {
+      findings = append(findings, finding{mediumSeverity, "recovery",
+                                          "Only " +
+                                          strconv.Itoa(e.RecoveryCodesLeft()) +
+                                          " Recovery Codes Left", e})
+    }
+    if days := -daysUntil(e.Modified, now); maxAge > 0 && days >= maxAge {
+      findings = append(findings, finding{lowSeverity, "old",
+                                          "Not Modified for " +
+                                          strconv.Itoa(days) + " Days", e})
+    }
+    if others := duplicates[e]; len(others) > 0 {
+      findings = append(findings, finding{lowSeverity, "duplicate",
+                                          "Looks Like a Duplicate of " +
+                                          nameGroups(others), e})
+    }
+  }
+  sort.SliceStable(findings, func(x, y int) bool {
+    return findings[x].severity > findings[y].severity
+  })
+  return findings, err
+}
+
+/*
+ * Returns findings as lines grouped under a heading for each severity.  If
+ * numbered is true, every finding is numbered so it can be picked.
+ */
+func auditReport(findings []finding, numbered bool) string {
+  var report string
+  for x, f := range findings {
+    if x == 0 || f.severity != findings[x - 1].severity {
+      var count int
+      for _, other := range findings {
+        if other.severity == f.severity {
+          count++
+        }
+      }
+      if x > 0 {
+        report += "\n"
+      }
+      report += strings.ToUpper(severityNames[f.severity]) + " (" +
+        strconv.Itoa(count) + ")\n"
+    }
+    report += "  "
+    if numbered {
+      report += "[" + strconv.Itoa(x + 1) + "] "
+    }
+    report += f.entry.NameGroup() + ": " + f.detail + "\n"
+  }
+  return report
+}
+
+/* AUDIT (of every entry of the password file) */
+func auditSettings() {
+  ctrlC = true
+  locationTitle = "AUDIT"
+  options = "Enter:VIEW ENTRY"
+  if !audited {
+    auditFindings, auditErr = audit(vlt.Entries, auditDays, breachFile,
+                                    time.Now())
+    audited = true
+  }
+  contentString = ""
+  if auditErr != nil {
+    contentString = "Unable to Check Breached Passwords (" +
+      auditErr.Error() + ")\n\n"
+  }
+  if len(auditFindings) == 0 {
+    termbox.HideCursor()
+    options = "Ctrl-C:BACK"
+    bottomCaption = ""
+    contentString += "No Problems Found in " + fPath
+    return
+  }
+  contentString += strconv.Itoa(len(auditFindings)) + " Problems Found in " +
+    fPath + "\n\n" + auditReport(auditFindings, true)
+  if len(contentExtra) > 0 {
+    contentString += "\n" + contentExtra
+  }
+  bottomCaption = "Input Problem Number: "
+  termbox.SetCursor(len(bottomCaption) + edit_box.CursorX(), h - 1)
+}
+
+func auditOptions(ev termbox.Event) {
+  if ev.Key != termbox.KeyEnter {
+    textEdit(ev)
+    return
+  }
+  value = string(edit_box.text)
+  edit_box.text = make([]byte, 0)
+  edit_box.MoveCursorTo(0)
+  intVal, err := strconv.Atoi(value)
+  if err != nil || intVal < 1 || intVal > len(auditFindings) {
+    return
+  }
+  e := auditFindings[intVal - 1].entry
+  /* Stop filtering the entry list if the entry isn't in it. */
+  if !containsEntry(listedEntries(), e) {
+    tagFilter = ""
+    favoritesOnly = false
+  }
+  for x, listed := range listedEntries() {
+    if listed == e {
+      entryNumber = x + 1
+    }
+  }
+  show = false
+  /* Audit again when coming back in case the entry was changed. */
+  audited = false
+  addToMenu("View Content")
+}
+
+/*
+ * latchbox audit [--json] [--days DAYS] [--breached HASHFILE] [ FILE ]
+ *
+ * Audits the entries of the password file FILE, which is the
+ * defaultPasswordFile in the config file if FILE isn't given, and prints
+ * what was found grouped by severity.  Passwords not modified for DAYS
+ * days (auditDays in the config file if --days isn't used) are reported as
+ * old.  Passwords are checked against the Pwned Passwords file HASHFILE
+ * (breachedPasswordsFile in the config file if --breached isn't used).
+ * --json prints the findings as a JSON object instead.  Exits with 0 if
+ * nothing was found, 1 if something was and 2 if the password file
+ * couldn't be unlocked or the Pwned Passwords file couldn't be searched.
+ */
+func auditCommand(args []string) int {
+  opts, operands := commandArgs("audit", args, []string{"json"},
+                                []string{"days", "breached"})
+  if len(operands) > 1 {
+    fmt.Printf("Usage: latchbox audit [ --json ] [ --days DAYS ] " +
+               "[ --breached HASHFILE ] [ FILE ]\n")
+    return 2
+  }
+  makeConfig()
+  configParse()
+  maxAge := auditDays
+  if days, ok := opts["days"]; ok {
+    var err error
+    if maxAge, err = parseDays(days); err != nil {
+      fmt.Fprintf(os.Stderr, "latchbox audit: %s: %s\n", days, err)
+      return 2
+    }
+  }
+  breached := breachFile
+  if hashFile, ok := opts["breached"]; ok {
+    breached = hashFile
+  }
+  var path string
+  if len(operands) == 1 {
+    path = operands[0]
+  }
+  path, ok := commandFile("audit", path)
+  if !ok {
+    return 2
+  }
+  v, err := unlockPrompted(path)
+  if err != nil {
+    fmt.Fprintf(os.Stderr, "latchbox audit: %s: %s\n", path, err)
+    return 2
+  }
+  findings, err := audit(v.Entries, maxAge, breached, time.Now())
+  if err != nil {
+    fmt.Fprintf(os.Stderr, "latchbox audit: %s: %s\n", breached, err)
+  }
+  if opts["json"] != "" {
+    report := struct {
+      File string `json:"file"`
+      Findings []jsonFinding `json:"findings"`
+    }{path, []jsonFinding{}}
+    for _, f := range findings {
+      report.Findings = append(report.Findings, jsonFinding{
+        Severity: strings.ToLower(severityNames[f.severity]),
+        Check: f.check,
+        Entry: f.entry.NameGroup(),
+        Detail: f.detail,
+      })
+    }
+    out, _ := json.MarshalIndent(report, "", "  ")
+    fmt.Println(string(out))
+  } else if len(findings) == 0 {
+    fmt.Printf("No Problems Found in %s\n", path)
+  } else {
+    fmt.Print(auditReport(findings, false))
+  }
+  if err != nil {
+    return 2
+  } else if len(findings) > 0 {
+    return 1
+  }
+  return 0
+}
@@ -0,0 +1,491 @@
+/*-
+ * Copyright (C) 2014-2017, Vi Grey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY AUTHOR AND CONTRIBUTORS "AS IS" AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL AUTHOR OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+/*
+ * Makes backups of password files, keeps a checksum manifest of the backups
+ * and prunes backups that aren't kept by the backup retention settings.
+ */
+
+package main
+
+import (
+  "bytes"
+  "crypto/sha256"
+  "encoding/hex"
+  "errors"
+  "fmt"
+  "io/ioutil"
+  "latchbox/vault"
+  "os"
+  "path/filepath"
+  "sort"
+  "strings"
+  "time"
+)
+
+/* Name of the checksum manifest file inside of the backup directory. */
+const manifestName = "SHA256SUMS"
+
+/* A backup file of a password file, the time it was made and its size. */
+type backupFile struct {
+  name string
+  made time.Time
+  size int64
+}
+
+/*
+ * Makes backup files (and the backup directory if it doesn't exist) and
+ * makes a copy of the password file as it was when it was opened on the
+ * first time it is saved after opening it.  The checksum of the backup is
+ * added to the manifest and old backups of the password file are pruned.
+ */
+func doBackup() {
+  if !backupSaved {
+    if backup && len(backupContents) > 0 {
+      if saveBackup(backupContents) == nil {
+        backupSaved = true
+      }
+    }
+  }
+}
+
+/*
+ * Makes a backup of the password file as it currently is on disk, even if
+ * backups aren't allowed in the config file.  Used before the password file
+ * is replaced by a backup.
+ */
+func backupNow() error {
+  content, err := ioutil.ReadFile(fPath)
+  if err != nil {
+    return err
+  }
+  if err := saveBackup(content); err != nil {
+    return err
+  }
+  /* Don't make the same backup again when the password file is saved. */
+  if bytes.Equal(content, backupContents) {
+    backupSaved = true
+  }
+  return nil
+}
+
+/*
+ * Writes content to a new backup of the password file, adds it to the
+ * manifest and prunes old backups of the password file.
+ */
+func saveBackup(content []byte) error {
+  base := backupBase(fPath)
+  made := time.Now().Local()
+  backupFile := base + "-" + made.Format(backupLayout) + ".lbp"
+  /* Never overwrite a backup made in the same second. */
+  for {
+    if _, err := os.Stat(backupDir + backupFile); err != nil {
+      break
+    }
+    made = made.Add(time.Second)
+    backupFile = base + "-" + made.Format(backupLayout) + ".lbp"
+  }
+  os.MkdirAll(backupDir, 0755)
+  err := writeFileAtomic(backupDir + backupFile, content, 0644)
+  if err != nil {
+    return err
+  }
+  manifest := readManifest()
+  manifest[backupFile] = checksumHex(content)
+  if err := writeManifest(manifest); err != nil {
+    return err
+  }
+  pruneBackups(base)
+  return nil
+}
+
+/*
+ * Returns the name backups of the password file in path start with, which
+ * is the file name up to the first period.
+ */
+func backupBase(path string) string {
+  backSlashSplit := strings.Split(path, "\\")
+  slashSplit := strings.Split(path, "/")
+  fileName := slashSplit[len(slashSplit) - 1]
+  if len(backSlashSplit) > 1 {
+    fileName = backSlashSplit[len(backSlashSplit) - 1]
+  }
+  return strings.Split(fileName, ".")[0]
+}
+
+/*
+ * Returns the backups in the backup directory made from password files
+ * named base, newest first.
+ */
+func listBackups(base string) []backupFile {
+  var backups []backupFile
+  files, err := ioutil.ReadDir(backupDir)
+  if err != nil {
+    return backups
+  }
+  for _, file := range files {
+    name := file.Name()
+    if len(name) != len(base) + len(backupLayout) + 5 ||
+        !strings.HasPrefix(name, base + "-") ||
+        !strings.HasSuffix(name, ".lbp") {
+      continue
+    }
+    stamp := name[len(base) + 1: len(name) - 4]
+    made, err := time.ParseInLocation(backupLayout, stamp, time.Local)
+    if err != nil {
+      continue
+    }
+    backups = append(backups, backupFile{name, made, file.Size()})
+  }
+  sort.Slice(backups, func(x, y int) bool {
+    return backups[x].made.After(backups[y].made)
+  })
+  return backups
+}
+
+/*
+ * Removes the backups of password files named base that aren't kept by
+ * backupKeepLast, backupKeepDaily, backupKeepWeekly or backupKeepMonthly.
+ * Backups are never pruned if all of those are 0.  Backups that don't match
+ * their checksum in the manifest don't count as kept backups and are left
+ * alone so they can be looked at.
+ */
+func pruneBackups(base string) {
+  if backupKeepLast + backupKeepDaily + backupKeepWeekly +
+      backupKeepMonthly == 0 {
+    return
+  }
+  manifest := readManifest()
+  var backups []backupFile
+  for _, b := range listBackups(base) {
+    if verifyBackup(b.name, manifest) != "FAILED" {
+      backups = append(backups, b)
+    }
+  }
+  keep := make(map[string]bool)
+  for x := 0; x < len(backups) && x < backupKeepLast; x++ {
+    keep[backups[x].name] = true
+  }
+  keepBuckets(backups, keep, backupKeepDaily, func(t time.Time) string {
+    return t.Format("2006-01-02")
+  })
+  keepBuckets(backups, keep, backupKeepWeekly, func(t time.Time) string {
+    year, week := t.ISOWeek()
+    return fmt.Sprintf("%d-%d", year, week)
+  })
+  keepBuckets(backups, keep, backupKeepMonthly, func(t time.Time) string {
+    return t.Format("2006-01")
+  })
+  for _, b := range backups {
+    if !keep[b.name] {
+      if os.Remove(backupDir + b.name) == nil {
+        delete(manifest, b.name)
+      }
+    }
+  }
+  writeManifest(manifest)
+}
+
+/*
+ * Marks the newest backup of the newest count buckets of backups as kept,
+ * where bucket returns the bucket a backup time falls in.  backups must be
+ * sorted newest first.
+ */
+func keepBuckets(backups []backupFile, keep map[string]bool, count int,
+                 bucket func(time.Time) string) {
+  seen := make(map[string]bool)
+  for _, b := range backups {
+    if len(seen) >= count {
+      break
+    }
+    if key := bucket(b.made); !seen[key] {
+      seen[key] = true
+      keep[b.name] = true
+    }
+  }
+}
+
+/* Returns the hex encoded SHA256 checksum of content. */
+func checksumHex(content []byte) string {
+  sum := sha256.Sum256(content)
+  return hex.EncodeToString(sum[:])
+}
+
+/*
+ * Returns the checksum of every backup in the manifest of the backup
+ * directory.  The manifest uses the same format as sha256sum, so the
+ * backups can also be checked with sha256sum -c.
+ */
+func readManifest() map[string]string {
+  manifest := make(map[string]string)
+  content, err := ioutil.ReadFile(backupDir + manifestName)
+  if err != nil {
+    return manifest
+  }
+  for _, line := range strings.Split(string(content), "\n") {
+    fields := strings.SplitN(line, "  ", 2)
+    if len(fields) == 2 {
+      manifest[fields[1]] = fields[0]
+    }
+  }
+  return manifest
+}
+
+/* Writes manifest to the manifest file of the backup directory. */
+func writeManifest(manifest map[string]string) error {
+  var backupNames []string
+  for name := range manifest {
+    backupNames = append(backupNames, name)
+  }
+  sort.Strings(backupNames)
+  var content string
+  for _, name := range backupNames {
+    content += manifest[name] + "  " + name + "\n"
+  }
+  return writeFileAtomic(backupDir + manifestName, []byte(content), 0644)
+}
+
+/*
+ * Returns OK if the backup called name matches its checksum in manifest,
+ * FAILED if it doesn't or can't be read, MISSING if it no longer exists and
+ * UNLISTED if it isn't in the manifest.
+ */
+func verifyBackup(name string, manifest map[string]string) string {
+  content, err := ioutil.ReadFile(backupDir + name)
+  if os.IsNotExist(err) {
+    return "MISSING"
+  }
+  sum, listed := manifest[name]
+  if !listed {
+    return "UNLISTED"
+  }
+  if err != nil || checksumHex(content) != sum {
+    return "FAILED"
+  }
+  return "OK"
+}
+
+/*
+ * Writes content to path by writing to a temporary file in the same
+ * directory first and renaming it, so path is never left half written.
+ */
+func writeFileAtomic(path string, content []byte, perm os.FileMode) error {
+  tmp, err := ioutil.TempFile(filepath.Dir(path), ".latchbox-")
+  if err != nil {
+    return err
+  }
+  _, err = tmp.Write(content)
+  if err == nil {
+    err = tmp.Sync()
+  }
+  if closeErr := tmp.Close(); err == nil {
+    err = closeErr
+  }
+  if err == nil {
+    err = os.Chmod(tmp.Name(), perm)
+  }
+  if err == nil {
+    err = os.Rename(tmp.Name(), path)
+  }
+  if err != nil {
+    os.Remove(tmp.Name())
+  }
+  return err
+}
+
+/*
+ * latchbox verify-backups
+ *
+ * Checks every backup in the backup directory against its checksum in the
+ * manifest.  Exits with 1 if any backup is corrupted or missing.
+ */
+func verifyBackupsCommand(args []string) int {
+  _, operands := commandArgs("verify-backups", args, nil, nil)
+  if len(operands) != 0 {
+    fmt.Printf("Usage: latchbox verify-backups\n")
+    return 2
+  }
+  makeConfig()
+  configParse()
+  manifest := readManifest()
+  var backupNames []string
+  for name := range manifest {
+    backupNames = append(backupNames, name)
+  }
+  files, _ := ioutil.ReadDir(backupDir)
+  for _, file := range files {
+    name := file.Name()
+    if _, listed := manifest[name]; !listed &&
+        strings.HasSuffix(name, ".lbp") {
+      backupNames = append(backupNames, name)
+    }
+  }
+  sort.Strings(backupNames)
+  status := 0
+  for _, name := range backupNames {
+    result := verifyBackup(name, manifest)
+    if result == "FAILED" || result == "MISSING" {
+      status = 1
+    }
+    fmt.Printf("%-8s %s\n", result, name)
+  }
+  return status
+}
+
+/*
+ * Decrypts the backup called name with pass and returns its entries.  The
+ * returned error is the message to show if the backup couldn't be opened.
+ */
+func openBackup(name, pass string) ([]*vault.Entry, error) {
+  v, err := vault.Open(backupDir + name)
+  if err == vault.ErrInvalid {
+    return nil, errors.New("Backup " + name + " is Corrupted")
+  } else if err != nil {
+    return nil, errors.New("Unable to Read Backup " + name)
+  }
+  err = v.Unlock(pass)
+  if err == vault.ErrCorrupted {
+    return nil, errors.New("Backup " + name + " is Corrupted")
+  } else if err != nil {
+    return nil, err
+  }
+  return v.Entries, nil
+}
+
+/*
+ * Replaces the entry with the same name/group combination as e with e, or
+ * adds e if there is no such entry, then saves the password file.  Returns
+ * true if an entry was replaced.
+ */
+func restoreEntry(e *vault.Entry) (bool, error) {
+  restored := *e
+  before := vlt.Snapshot()
+  label := "Restore of " + e.NameGroup() + " From Backup"
+  if old := vlt.Find(e.NameGroup()); old != nil {
+    *old = restored
+    addUndo(label, before)
+    return true, writeData()
+  }
+  if err := vlt.Add(&restored); err != nil {
+    return false, err
+  }
+  addUndo(label, before)
+  return false, writeData()
+}
+
+/* Returns size as a human readable size in bytes, KiB or MiB. */
+func humanSize(size int64) string {
+  if size < 1024 {
+    return fmt.Sprintf("%d B", size)
+  } else if size < 1024 * 1024 {
+    return fmt.Sprintf("%.1f KiB", float64(size) / 1024)
+  }
+  return fmt.Sprintf("%.1f MiB", float64(size) / 1024 / 1024)
+}
+
+/*
+ * Re-encrypts every backup of the password files named base that can be
+ * decrypted with oldPass so they are encrypted with newPass instead.  Each
+ * backup is replaced atomically and its checksum in the manifest updated.
+ * Returns a line for every backup saying what was done with it.
+ */
+func reencryptBackups(base, oldPass, newPass string) []string {
+  var report []string
+  manifest := readManifest()
+  for _, b := range listBackups(base) {
+    ciphertext, err := ioutil.ReadFile(backupDir + b.name)
+    if err != nil {
+      report = append(report, "Failed:       " + b.name + " (Unable to " +
+                      "Read Backup)")
+      continue
+    }
+    plaintext, err := vault.Decrypt(ciphertext, oldPass)
+    if err != nil {
+      report = append(report, "Skipped:      " + b.name + " (Not " +
+                      "Encrypted With the Old Passphrase/Keyfile)")
+      continue
+    }
+    ciphertext = vault.Encrypt(plaintext, newPass, cipherType, iterations)
+    err = writeFileAtomic(backupDir + b.name, ciphertext, 0644)
+    if err != nil {
+      report = append(report, "Failed:       " + b.name + " (Write Error)")
+      continue
+    }
+    manifest[b.name] = checksumHex(ciphertext)
+    writeManifest(manifest)
+    report = append(report, "Re-encrypted: " + b.name)
+  }
+  return report
+}
+
+/*
+ * Overwrites every backup of the password files named base with random
+ * bytes before deleting it and removing it from the manifest.  Returns a
+ * line for every backup saying what was done with it.
+ */
+func shredBackups(base string) []string {
+  var report []string
+  manifest := readManifest()
+  for _, b := range listBackups(base) {
+    if err := shredFile(backupDir + b.name); err != nil {
+      report = append(report, "Failed:  " + b.name + " (" + err.Error() +
+                      ")")
+      continue
+    }
+    delete(manifest, b.name)
+    writeManifest(manifest)
+    report = append(report, "Deleted: " + b.name)
+  }
+  return report
+}
+
+/*
+ * Overwrites the file in path with random bytes, syncs it to disk and
+ * removes it.  Filesystems that don't overwrite in place, like copy on write
+ * filesystems, may still keep the old content.
+ */
+func shredFile(path string) error {
+  info, err := os.Stat(path)
+  if err != nil {
+    return err
+  }
+  file, err := os.OpenFile(path, os.O_WRONLY, 0)
+  if err != nil {
+    return err
+  }
+  _, err = file.Write(vault.RandomBytes(int(info.Size())))
+  if err == nil {
+    err = file.Sync()
+  }
+  if closeErr := file.Close(); err == nil {
+    err = closeErr
+  }
+  if err != nil {
+    return err
+  }
+  return os.Remove(path)
+}
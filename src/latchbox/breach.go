@@ -0,0 +1,266 @@
+/*-
+ * Copyright (C) 2014-2017, Vi Grey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY AUTHOR AND CONTRIBUTORS "AS IS" AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL AUTHOR OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+/*
+ * Offline check of passwords against a downloaded Pwned Passwords file of
+ * SHA-1 or NTLM hashes ordered by hash, which is binary searched on disk so
+ * no password or hash ever leaves the computer.
+ */
+
+package main
+
+import (
+  "bytes"
+  "crypto/sha1"
+  "encoding/binary"
+  "encoding/hex"
+  "errors"
+  "io"
+  "latchbox/vault"
+  "math/bits"
+  "os"
+  "strconv"
+  "strings"
+  "unicode/utf16"
+)
+
+/*
+ * Longest line of a Pwned Passwords file, which is a hash, a colon, a count
+ * and a line ending.
+ */
+const maxBreachLine = 64
+
+/*
+ * Path of the Pwned Passwords file set with breachedPasswordsFile in the
+ * config file, or "" if passwords aren't checked.
+ */
+var breachFile string
+
+/* Breached password that was last warned about when it was entered. */
+var breachWarned string
+
+/* Returns the MD4 hash of data (RFC 1320), which NTLM hashes are made with. */
+func md4Sum(data []byte) []byte {
+  msg := append(append([]byte{}, data...), 0x80)
+  for len(msg) % 64 != 56 {
+    msg = append(msg, 0)
+  }
+  length := make([]byte, 8)
+  binary.LittleEndian.PutUint64(length, uint64(len(data)) * 8)
+  msg = append(msg, length...)
+  state := []uint32{0x67452301, 0xefcdab89, 0x98badcfe, 0x10325476}
+  order := [][]int{
+    {0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15},
+    {0, 4, 8, 12, 1, 5, 9, 13, 2, 6, 10, 14, 3, 7, 11, 15},
+    {0, 8, 4, 12, 2, 10, 6, 14, 1, 9, 5, 13, 3, 11, 7, 15},
+  }
+  shifts := [][]int{{3, 7, 11, 19}, {3, 5, 9, 13}, {3, 9, 11, 15}}
+  added := []uint32{0, 0x5a827999, 0x6ed9eba1}
+  for block := 0; block < len(msg); block += 64 {
+    var x [16]uint32
+    for y := range x {
+      x[y] = binary.LittleEndian.Uint32(msg[block + y * 4:])
+    }
+    a, b, c, d := state[0], state[1], state[2], state[3]
+    for round := 0; round < 3; round++ {
+      for step := 0; step < 16; step++ {
+        var f uint32
+        if round == 0 {
+          f = b & c | ^b & d
+        } else if round == 1 {
+          f = b & c | b & d | c & d
+        } else {
+          f = b ^ c ^ d
+        }
+        a = bits.RotateLeft32(a + f + x[order[round][step]] + added[round],
+                              shifts[round][step % 4])
+        /* The next step changes the word before this one. */
+        a, b, c, d = d, a, b, c
+      }
+    }
+    state[0] += a
+    state[1] += b
+    state[2] += c
+    state[3] += d
+  }
+  sum := make([]byte, 16)
+  for x, word := range state {
+    binary.LittleEndian.PutUint32(sum[x * 4:], word)
+  }
+  return sum
+}
+
+/*
+ * Returns the hash of password the way it is written in a Pwned Passwords
+ * file, which is uppercase hex of its SHA-1 hash, or of its NTLM hash (the
+ * MD4 hash of the UTF-16LE password) if ntlm is true.
+ */
+func breachHash(password string, ntlm bool) string {
+  if ntlm {
+    var utf16le []byte
+    for _, unit := range utf16.Encode([]rune(password)) {
+      utf16le = append(utf16le, byte(unit), byte(unit >> 8))
+    }
+    return strings.ToUpper(hex.EncodeToString(md4Sum(utf16le)))
+  }
+  sum := sha1.Sum([]byte(password))
+  return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+/*
+ * Returns the line of f that byte offset is in, where f is size bytes long,
+ * along with the offsets of the first byte of the line and the byte after
+ * its line ending.
+ */
+func lineAt(f *os.File, offset, size int64) (string, int64, int64, error) {
+  start := offset - maxBreachLine
+  if start < 0 {
+    start = 0
+  }
+  window := make([]byte, maxBreachLine * 2)
+  n, err := f.ReadAt(window, start)
+  if err != nil && err != io.EOF {
+    return "", 0, 0, err
+  }
+  window = window[:n]
+  position := int(offset - start)
+  first := bytes.LastIndexByte(window[:position], '\n') + 1
+  if first == 0 && start > 0 {
+    return "", 0, 0, errors.New("Line Too Long in Breached Passwords File")
+  }
+  end := len(window)
+  if newline := bytes.IndexByte(window[position:], '\n'); newline > -1 {
+    end = position + newline + 1
+  } else if start + int64(end) < size {
+    return "", 0, 0, errors.New("Line Too Long in Breached Passwords File")
+  }
+  line := strings.TrimSpace(string(window[first:end]))
+  return line, start + int64(first), start + int64(end), nil
+}
+
+/*
+ * Returns how many times password appears in the Pwned Passwords file in
+ * path, or 0 if it doesn't.  Whether the file has SHA-1 or NTLM hashes is
+ * worked out from the length of its first hash.
+ */
+func breachCount(path, password string) (int, error) {
+  tildeHome(&path)
+  f, err := os.Open(path)
+  if err != nil {
+    return 0, err
+  }
+  defer f.Close()
+  info, err := f.Stat()
+  if err != nil {
+    return 0, err
+  }
+  size := info.Size()
+  first, _, _, err := lineAt(f, 0, size)
+  if err != nil {
+    return 0, err
+  }
+  hashLen := strings.Index(first, ":")
+  if hashLen != 32 && hashLen != 40 {
+    return 0, errors.New("Invalid Breached Passwords File")
+  }
+  hash := breachHash(password, hashLen == 32)
+  low, high := int64(0), size - 1
+  for low <= high {
+    line, start, end, err := lineAt(f, low + (high - low) / 2, size)
+    if err != nil {
+      return 0, err
+    }
+    if line == "" {
+      /* Skip blank lines at the end of the file. */
+      high = start - 1
+      continue
+    }
+    colon := strings.Index(line, ":")
+    if colon != hashLen {
+      return 0, errors.New("Invalid Breached Passwords File")
+    }
+    lineHash := strings.ToUpper(line[:colon])
+    if hash == lineHash {
+      count, err := strconv.Atoi(line[colon + 1:])
+      if err != nil {
+        return 0, errors.New("Invalid Breached Passwords File")
+      }
+      return count, nil
+    } else if hash < lineHash {
+      high = start - 1
+    } else {
+      low = end
+    }
+  }
+  return 0, nil
+}
+
+/*
+ * Returns a warning if password is in the breached passwords file, or "" if
+ * it isn't or it can't be checked.  A password that was just warned about
+ * doesn't get a warning again, so entering it twice uses it anyway.
+ */
+func breachWarning(password string) string {
+  if breachFile == "" || password == breachWarned {
+    breachWarned = ""
+    return ""
+  }
+  count, err := breachCount(breachFile, password)
+  if err != nil || count == 0 {
+    return ""
+  }
+  breachWarned = password
+  return "This Password Was Found " + strconv.Itoa(count) + " Times in " +
+    "Breached Passwords.  Repeat It Again to Use It Anyway"
+}
+
+/*
+ * Returns how many times the password of every entry of entries appears in
+ * the breached passwords file in path, leaving out the ones that don't.
+ */
+func breachCounts(entries []*vault.Entry,
+    path string) (map[*vault.Entry]int, error) {
+  breached := make(map[*vault.Entry]int)
+  counts := make(map[string]int)
+  for _, e := range entries {
+    if e.Password == "" {
+      continue
+    }
+    count, checked := counts[e.Password]
+    if !checked {
+      var err error
+      if count, err = breachCount(path, e.Password); err != nil {
+        return nil, err
+      }
+      counts[e.Password] = count
+    }
+    if count > 0 {
+      breached[e] = count
+    }
+  }
+  return breached, nil
+}
@@ -38,9 +38,11 @@ import (
   "github.com/mattn/go-runewidth"
   "github.com/nsf/termbox-go"
   "io/ioutil"
+  "latchbox/vault"
   "os"
   "strconv"
   "strings"
+  "sync/atomic"
   "time"
   "unicode/utf8"
 )
@@ -55,6 +57,9 @@ func draw() {
   }
   topTitle = title + ctrlCValue + ")"
   titleSlice := multiLine(topTitle, w - 2)
+  if len(tabs) > 1 && menu != "Welcome" {
+    titleSlice = append(titleSlice, multiLine(tabBar(), w - 2)...)
+  }
   contentSlice := multiLine(contentString, w)
   optionsSlice := multiLine(options, w - 2)
   for x := range titleSlice {
@@ -238,16 +243,43 @@ func welcomeSettings() {
   ctrlC = false
   termbox.HideCursor()
   locationTitle = "WELCOME TO LATCHBOX"
-  options = "n:NEW  o:OPEN"
+  if readOnlyFlag {
+    options = "o:OPEN"
+  } else {
+    options = "n:NEW  o:OPEN  r:READ-ONLY"
+  }
   contentString = ""
+  recentFiles = readRecent()
+  if len(recentFiles) > 0 {
+    options += "  1-" + strconv.Itoa(len(recentFiles)) + ":RECENT"
+    contentString = "Recently Opened Password Files:"
+    for x, path := range recentFiles {
+      contentString += "\n[" + strconv.Itoa(x + 1) + "] " + path
+    }
+  }
+  if contentExtra != "" {
+    contentString += "\n\n" + contentExtra
+  }
 }
 
 func welcomeOptions(ev termbox.Event) {
   if ev.Ch != 0 {
-    if ev.Ch == 'n' {
+    if ev.Ch == 'n' && !readOnlyFlag {
+      contentExtra = ""
       addToMenu("New Password")
-    } else if ev.Ch == 'o' {
+    } else if ev.Ch == 'o' || ev.Ch == 'r' && !readOnlyFlag {
+      contentExtra = ""
+      openReadOnly = ev.Ch == 'r'
       addToMenu("Open Password")
+    } else if ev.Ch >= '1' && int(ev.Ch - '0') <= len(recentFiles) {
+      path := recentFiles[ev.Ch - '1']
+      openReadOnly = false
+      err := openFile(path)
+      if err != nil {
+        contentExtra = err.Error()
+      } else {
+        contentExtra = ""
+      }
     }
   }
 }
@@ -298,25 +330,13 @@ func newPOptions(ev termbox.Event) {
       value = defaultFile
     }
     if value != "" {
-      if _, err := os.Stat(value); err == nil {
-        contents, err := ioutil.ReadFile(value)
-        if err != nil {
-          contentExtra = "Unable to Read File"
-        } else if string(contents) == "" {
-          fPath = value
-        } else {
-          contentExtra = "Contents Exist in File"
-        }
+      if errMsg := newFileError(value); errMsg != "" {
+        contentExtra = errMsg
       } else {
-        err := ioutil.WriteFile(value, []byte(""), 0644)
-        if err != nil {
-          contentExtra = "Unable to Write to File"
-        } else {
-          os.Remove(value)
-          fPath = value
-        }
+        fPath = value
       }
       if fPath != "" {
+        contentExtra = ""
         step[0] = true
         omit = true
         addToMenu("Secure Password")
@@ -327,23 +347,55 @@ func newPOptions(ev termbox.Event) {
   }
 }
 
+/*
+ * Returns why a new password file can't be made in path, or an empty string
+ * if path is empty or doesn't exist and can be written to.
+ */
+func newFileError(path string) string {
+  if _, err := os.Stat(path); err == nil {
+    contents, err := ioutil.ReadFile(path)
+    if err != nil {
+      return "Unable to Read File"
+    } else if string(contents) != "" {
+      return "Contents Exist in File"
+    }
+  } else {
+    err := ioutil.WriteFile(path, []byte(""), 0644)
+    if err != nil {
+      return "Unable to Write to File"
+    }
+    os.Remove(path)
+  }
+  return ""
+}
+
 /* SECURE NEW PASSWORD FILE */
 func securePSettings() {
   ctrlC = true
   passwordInput = true
   locationTitle = "SECURE NEW PASSWORD FILE"
   options = "Enter:CONFIRM  Ctrl-T:"
+  contentString = ""
   if step[0] {
     bottomCaption = "Input New Passphrase: "
+    contentString = strengthLine(string(edit_box.text),
+                                 passphraseInputs(fPath)...)
   } else {
     bottomCaption = "Repeat New Passphrase: "
   }
+  if contentString != "" && contentExtra != "" {
+    contentString += "\n\n"
+  }
+  contentString += contentExtra
   if omit {
     options += "INCLUDE"
   } else {
     options += "OMIT"
   }
   options += " KEYFILE"
+  if step[0] {
+    options += "  Ctrl-G:GENERATE WORDS"
+  }
   termbox.SetCursor(len(bottomCaption) + edit_box.CursorX(), h - 1)
 }
 
@@ -360,25 +412,29 @@ func securePOptions(ev termbox.Event) {
     } else {
       omit = true
     }
+  } else if ev.Key == termbox.KeyCtrlG && step[0] {
+    startWords()
   } else {
     textEdit(ev)
   }
   if valueEntered {
     if step[0] {
-      key1 = value
-      contentString = ""
-      step[0] = false
+      contentExtra = weakPassphrase(value, fPath)
+      if contentExtra == "" {
+        key1 = value
+        step[0] = false
+      }
     } else {
       if value == key1 {
+        contentExtra = ""
         if !omit {
           tmpPassphrase = value
           addToMenu("Keyfile")
         } else {
-          passphrase = value
-          nonce = bytesToNum(randByteArray(8))
+          vlt = vault.New(fPath, value)
           err := writeData()
           if err != nil {
-            contentString = "Unable to Create Password File"
+            contentExtra = "Unable to Create Password File"
           } else {
             contentString = "Your Password File Was Created " +
               "Successfully!"
@@ -386,7 +442,7 @@ func securePOptions(ev termbox.Event) {
           }
         }
       } else {
-        contentString = "New Passphrases Do Not Match"
+        contentExtra = "New Passphrases Do Not Match"
         step[0] = true
       }
       key1 = ""
@@ -400,25 +456,19 @@ func openPSettings() {
   ctrlC = true
   passwordInput = false
   locationTitle = "OPEN PASSWORD FILE"
+  if readOnlyFlag || openReadOnly {
+    locationTitle += " (READ-ONLY)"
+  }
   options = "Enter:CONFIRM"
   bottomCaption = "Path to Password File: "
   termbox.SetCursor(len(bottomCaption) + edit_box.CursorX(), h - 1)
   tmpDefault = defaultFile
   if defaultFile != "" {
     tildeHome(&defaultFile)
-    ciphertext, err := ioutil.ReadFile(defaultFile)
-    if backup {
-      backupContents = ciphertext
-    }
-    if err != nil {
+    if _, err := vault.Open(defaultFile); err != nil {
       tmpDefault = ""
     } else {
-      _, _, _, _, _, err := parseCt(ciphertext)
-      if err != nil {
-        tmpDefault = ""
-      } else {
-        contentString = "Press Enter to Use " + defaultFile
-      }
+      contentString = "Press Enter to Use " + defaultFile
     }
   }
   if tmpDefault != "" && contentExtra != "" {
@@ -448,24 +498,40 @@ func openPOptions(ev termbox.Event) {
       }
     }
     tildeHome(&value)
-    ciphertext, err := ioutil.ReadFile(value)
-    if err != nil {
-      contentExtra = "Unable to Read File \"" + value + "\""
-    } else {
-      _, _, _, _, _, err := parseCt(ciphertext)
-      if err != nil {
-        contentExtra = "Password File Invalid/Corrupted"
-      } else {
-        fPath = value
-        step[0] = true
-        contentString = ""
-        omit = true
-        addToMenu("Unlock Password")
-      }
+    if x := tabOpen(value); openingTab && x != -1 {
+      openingTab = false
+      loadTab(x)
+      contentExtra = ""
+      contentString = value + " Is Already Open"
+      subtractFromMenu(1)
+    } else if err := openFile(value); err != nil {
+      contentExtra = err.Error()
     }
   }
 }
 
+/*
+ * Opens the password file in path and goes to the UNLOCK menu.  The password
+ * file is opened read-only if --read-only was used or READ-ONLY was chosen.
+ */
+func openFile(path string) error {
+  v, err := vault.Open(path)
+  if err != nil {
+    return err
+  }
+  fPath = path
+  vlt = v
+  readOnly = readOnlyFlag || openReadOnly
+  if backup && !readOnly {
+    backupContents = v.Ciphertext()
+  }
+  step[0] = true
+  contentString = ""
+  omit = true
+  addToMenu("Unlock Password")
+  return nil
+}
+
 /* UNLOCK PASSWORD FILE */
 func unlockPSettings() {
   ctrlC = true
@@ -483,7 +549,6 @@ func unlockPSettings() {
 }
 
 func unlockPOptions(ev termbox.Event) {
-  ciphertext, _ := ioutil.ReadFile(fPath)
   var valueEntered bool
   if ev.Key == termbox.KeyEnter {
     value = string(edit_box.text)
@@ -504,22 +569,129 @@ func unlockPOptions(ev termbox.Event) {
       tmpPassphrase = value
       addToMenu("Keyfile")
     } else {
-      ciph, iter, salt, strippedCtext, old, _ := parseCt(ciphertext)
-      hashedPassphrase := generatePBKDF2Key([]byte(value), salt, iter)
-      if plaintext, decrypted := decrypt(strippedCtext, hashedPassphrase,
-                                         ciph, old); decrypted {
-        passphrase = value
-        fileContents = plaintext
-        err := parseFile()
-        if err == nil {
-          contentString = ""
-          addToMenu("Main Menu")
-        }
+      openUnlocked(value)
+    }
+  }
+}
+
+/*
+ * Unlocks the opened password file with pass and goes to the main menu, or
+ * to the SALVAGE menu if the password file is corrupted.  Returns false if
+ * pass didn't unlock the password file.
+ */
+func openUnlocked(pass string) bool {
+  err := vlt.Unlock(pass)
+  if err == vault.ErrCorrupted {
+    salvageProblems = vlt.Salvage()
+    contentString = ""
+    contentExtra = ""
+    addToMenu("Salvage")
+    return true
+  } else if err != nil {
+    return false
+  }
+  contentString = purgeOldTrash()
+  if banner := expiryBanner(); banner != "" {
+    if contentString != "" {
+      contentString += "\n\n"
+    }
+    contentString += banner
+  }
+  addToMenu("Main Menu")
+  return true
+}
+
+/* SALVAGE CORRUPTED PASSWORD FILE */
+func salvageSettings() {
+  ctrlC = true
+  passwordInput = false
+  locationTitle = "SALVAGE CORRUPTED PASSWORD FILE"
+  contentString = fPath + " Is Corrupted.  " +
+    strconv.Itoa(len(vlt.Entries)) + " Entries Were Salvaged.\n\n" +
+    "Problems Found:\n" + strings.Join(salvageProblems, "\n")
+  if len(vlt.Entries) > 0 {
+    contentString += "\n\nSalvaged Entries:"
+    for x, e := range vlt.Sorted() {
+      contentString += "\n[" + strconv.Itoa(x + 1) + "] " + e.NameGroup()
+    }
+  }
+  if contentExtra != "" {
+    contentString += "\n\n" + contentExtra
+  }
+  if len(vlt.Entries) == 0 || readOnly {
+    termbox.HideCursor()
+    options = "Ctrl-C:BACK"
+    bottomCaption = ""
+    return
+  }
+  options = "Enter:CONFIRM"
+  bottomCaption = "Path for Salvaged Password File: "
+  termbox.SetCursor(len(bottomCaption) + edit_box.CursorX(), h - 1)
+}
+
+func salvageOptions(ev termbox.Event) {
+  var valueEntered bool
+  if len(vlt.Entries) == 0 || readOnly {
+    return
+  }
+  if ev.Key == termbox.KeyEnter {
+    value = string(edit_box.text)
+    valueEntered = true
+    edit_box.text = make([]byte, 0)
+    edit_box.MoveCursorTo(0)
+  } else {
+    textEdit(ev)
+  }
+  if valueEntered {
+    tildeHome(&value)
+    if value == "" {
+      contentExtra = "File Name Required"
+    } else if errMsg := newFileError(value); errMsg != "" {
+      contentExtra = errMsg
+    } else {
+      fPath = value
+      backupContents = make([]byte, 0)
+      err := writeData()
+      if err != nil {
+        contentExtra = "Unable to Write to File"
+      } else {
+        contentString = strconv.Itoa(len(vlt.Entries)) +
+          " Salvaged Entries Were Saved to " + fPath
+        contentExtra = ""
+        salvageProblems = make([]string, 0)
+        addToMenu("Main Menu")
       }
     }
   }
 }
 
+/*
+ * Leaves the SALVAGE menu without saving the salvaged entries, forgetting
+ * the corrupted password file and going back to the menu it was opened
+ * from.
+ */
+func leaveSalvage() {
+  vlt.Lock()
+  vlt = nil
+  fPath = ""
+  tmpPassphrase = ""
+  backupContents = make([]byte, 0)
+  salvageProblems = make([]string, 0)
+  for menu != "Unlock Password" {
+    subtractFromMenu(1)
+  }
+  subtractFromMenu(1)
+  if menu == "Main Menu" && openingTab {
+    cancelOpenTab()
+  }
+  contentString = ""
+  contentExtra = "Corrupted Password File Was NOT Salvaged"
+  bottomCaption = ""
+  step = make([]bool, 13)
+  edit_box.text = make([]byte, 0)
+  edit_box.MoveCursorTo(0)
+}
+
 /* If INCLUDE KEYFILE was selected. */
 func keyfileSettings() {
   ctrlC = true
@@ -547,10 +719,9 @@ func keyfileOptions(ev termbox.Event) {
     if err != nil {
       contentString = "Cannot Open Keyfile"
     } else {
-      tmpPassphrase = newHMAC(tmpPassphrase, keyfileContent)
+      tmpPassphrase = vault.KeyfilePassphrase(tmpPassphrase, keyfileContent)
       if menuList[len(menuList) - 2] == "Secure Password" {
-        passphrase = tmpPassphrase
-        nonce = bytesToNum(randByteArray(8))
+        vlt = vault.New(fPath, tmpPassphrase)
         err := writeData()
         if err != nil {
           contentString = "Unable to Create Password File"
@@ -561,23 +732,15 @@ func keyfileOptions(ev termbox.Event) {
         }
         tmpPassphrase = ""
       } else if menuList[len(menuList) - 2] == "Unlock Password" {
-        ciphertext, _ := ioutil.ReadFile(fPath)
-        ciph, iter, salt, strippedCtext, old, _ := parseCt(ciphertext)
-        hashedPassphrase := generatePBKDF2Key([]byte(tmpPassphrase), salt,
-                                              iter)
-        if plaintext, decrypted := decrypt(strippedCtext, hashedPassphrase,
-                                           ciph, old); decrypted {
-          passphrase = tmpPassphrase
-          fileContents = plaintext
-          err := parseFile()
-          if err == nil {
-            contentString = ""
-            tmpPassphrase = ""
-            addToMenu("Main Menu")
-          }
+        if openUnlocked(tmpPassphrase) {
+          tmpPassphrase = ""
         }
+      } else if menuList[len(menuList) - 2] == "Backup Passphrase" {
+        unlockBackup(tmpPassphrase)
+        tmpPassphrase = ""
+        omit = true
       } else if menuList[len(menuList) - 2] == "Export" {
-        if passphrase == tmpPassphrase {
+        if vlt.CheckPassphrase(tmpPassphrase) {
           contentString = ""
           tmpPassphrase = ""
           omit = true
@@ -600,29 +763,28 @@ func keyfileOptions(ev termbox.Event) {
         }
       } else {
         if step[0] {
-          if passphrase == tmpPassphrase {
-            contentString = ""
+          if vlt.CheckPassphrase(tmpPassphrase) {
+            contentExtra = ""
             step[0], step[1] = false, true
             tmpPassphrase = ""
             omit = true
             subtractFromMenu(1)
           } else {
-            contentString = "Incorrect Passphrase/Keyfile " +
+            contentExtra = "Incorrect Passphrase/Keyfile " +
               "Combination"
             tmpPassphrase = ""
             omit = true
             subtractFromMenu(1)
           }
         } else {
-          passphrase = tmpPassphrase
+          oldPass := vlt.Passphrase()
+          vlt.SetPassphrase(tmpPassphrase)
           err := writeData()
           if err != nil {
             contentString = "Unable to Modify Password File " +
               "(Write Error)"
           } else {
-            contentString = "Your Passphrase/Keyfile Was " +
-              "Successfully Changed!"
-            addToMenu("Main Menu")
+            passphraseChanged(oldPass)
           }
           tmpPassphrase = ""
         }
@@ -650,36 +812,100 @@ func mainSettings() {
   termbox.HideCursor()
   bottomCaption = ""
   locationTitle = "MAIN MENU"
-  if len(names) > 0 {
+  if openingTab || len(tabs) == 0 {
+    addTab()
+  }
+  if readOnly {
+    locationTitle += " (READ-ONLY)"
+    if len(vlt.Entries) > 0 {
+      options = "c:COPY  v:VIEW  l:LOCK  ?:MORE OPTIONS"
+    } else {
+      options = "l:LOCK  ?:MORE OPTIONS"
+    }
+  } else if len(vlt.Entries) > 0 {
     options = "c:COPY  v:VIEW  n:NEW  d:DELETE  e:EDIT  l:LOCK  " +
       "?:MORE OPTIONS"
   } else {
     options = "n:NEW  l:LOCK  ?:MORE OPTIONS"
   }
+  if len(undoSteps) > 0 {
+    options += "  u:UNDO"
+  }
+  if len(redoSteps) > 0 {
+    options += "  Ctrl-R:REDO"
+  }
+  if len(tabs) > 1 {
+    options += "  Tab:NEXT"
+  }
+  if tagFilter != "" || favoritesOnly {
+    locationTitle += " (" + strings.ToUpper(filterLabel()) + ")"
+    options += "  f:FILTER"
+  }
 }
 
 func mainOptions(ev termbox.Event) {
+  confirmLock := lockPressed
+  lockPressed = false
+  if ev.Key == termbox.KeyTab && len(tabs) > 1 {
+    storeTab()
+    loadTab((currentTab + 1) % len(tabs))
+    contentString = ""
+  }
+  if ev.Key == termbox.KeyCtrlR && !readOnly {
+    contentString = redo()
+  }
   if ev.Ch != 0 {
-    if len(names) > 0 {
+    if len(vlt.Entries) > 0 {
+      if ev.Ch == 'm' && len(tabs) > 1 {
+        addToMenu("Transfer")
+      }
       if ev.Ch == 'c' {
         addToMenu("Copy")
       } else if ev.Ch == 'v' {
         addToMenu("View")
-      } else if ev.Ch == 'd' {
+      } else if ev.Ch == 'd' && !readOnly {
         addToMenu("Delete")
-      } else if ev.Ch == 'e' {
+      } else if ev.Ch == 'e' && !readOnly {
         step[0] = true
         addToMenu("Edit")
       }
     }
-    if ev.Ch == 'n' {
-      step[0] = true
-      addToMenu("New")
+    if ev.Ch == 'u' && !readOnly {
+      contentString = undo()
+    } else if ev.Ch == 'n' && !readOnly {
+      addToMenu("New Type")
     } else if ev.Ch == 'l' {
-      lock()
-    } else if ev.Ch == 'p' {
+      if dirty && !confirmLock {
+        lockPressed = true
+        contentString = fPath + " Has Unsaved Changes.  Press l Again to " +
+          "Lock It Anyway"
+      } else {
+        lockTab()
+      }
+    } else if ev.Ch == 'o' || ev.Ch == 'r' {
+      contentString = ""
+      openAnotherTab()
+      openReadOnly = ev.Ch == 'r'
+      addToMenu("Open Password")
+    } else if ev.Ch == 'p' && !readOnly {
       addToMenu("Change Passphrase")
-    } else if ev.Ch == 'i' {
+    } else if ev.Ch == 'h' && !readOnly {
+      addToMenu("Purge History")
+    } else if ev.Ch == 't' {
+      contentString = ""
+      addToMenu("Trash")
+    } else if ev.Ch == '/' && len(vlt.Entries) + len(vlt.Trash) > 0 {
+      contentString = ""
+      searchQuery = ""
+      addToMenu("Search")
+    } else if ev.Ch == 'f' && len(vlt.Entries) > 0 {
+      contentString = ""
+      addToMenu("Filter")
+    } else if ev.Ch == 'a' {
+      contentString = ""
+      audited = false
+      addToMenu("Audit")
+    } else if ev.Ch == 'i' && !readOnly {
       contentString = ""
       addToMenu("Import")
     } else if ev.Ch == 'x' {
@@ -687,6 +913,10 @@ func mainOptions(ev termbox.Event) {
       step[0] = true
       omit = true
       addToMenu("Export")
+    } else if ev.Ch == 'b' {
+      contentString = ""
+      loadBackupList()
+      addToMenu("Backups")
     } else if ev.Ch == '?' {
       addToMenu("Options")
     }
@@ -718,7 +948,7 @@ func copyEOptions(ev termbox.Event) {
   if valueEntered {
     intVal, err := strconv.Atoi(value)
     if err == nil {
-      if intVal > 0 && intVal <= len(names) {
+      if intVal > 0 && intVal <= len(listedEntries()) {
         entryNumber = intVal
         entryData = ""
         addToMenu("Copy Content")
@@ -735,8 +965,26 @@ func copyContentSettings() {
   if entryData == "" {
     contentString = "Choose What You Want to Copy"
     options = "u:USERNAME  p:PASSWORD  e:EMAIL  w:URL"
+    if len(selectedEntry().Fields) > 0 {
+      options += "  f:FIELD"
+    }
+    if selectedEntry().OTP != nil {
+      options += "  o:OTP"
+    }
+    if selectedEntry().NextRecoveryCode() > -1 {
+      options += "  r:RECOVERY CODE"
+    }
+  } else if entryData == "Field" && fieldNumber == 0 {
+    contentString = displayFields(selectedEntry())
+    options = "Enter:CONFIRM"
+    bottomCaption = "Input Field Number: "
+    termbox.SetCursor(len(bottomCaption) + edit_box.CursorX(), h - 1)
   } else {
-    contentString = entryData  + " Copied.  Press Ctrl-C to Clear the " +
+    copied := entryData
+    if entryData == "Field" {
+      copied = selectedEntry().Fields[fieldNumber - 1].Name
+    }
+    contentString = copied  + " Copied.  Press Ctrl-C to Clear the " +
       "Clipboard"
     options = "Ctrl-C:CLEAR CLIPBOARD/BACK"
     contentCopied = true
@@ -754,24 +1002,166 @@ func copyContentOptions(ev termbox.Event) {
         entryData = "Email"
       } else if ev.Ch == 'w' {
         entryData = "URL"
+      } else if ev.Ch == 'o' && selectedEntry().OTP != nil {
+        entryData = "OTP"
+      } else if ev.Ch == 'r' && selectedEntry().NextRecoveryCode() > -1 {
+        entryData = "Recovery Code"
+      } else if ev.Ch == 'f' && len(selectedEntry().Fields) > 0 {
+        entryData = "Field"
+        fieldNumber = 0
+        return
       }
     }
     if entryData != "" {
       var data string
+      e := selectedEntry()
       if entryData == "Username" {
-        data = usernames[orderList[entryNumber - 1]]
+        data = e.Username
       } else if entryData == "Password" {
-        data = passwords[orderList[entryNumber - 1]]
+        data = e.Password
       } else if entryData == "Email" {
-        data = emails[orderList[entryNumber - 1]]
+        data = e.Email
       } else if entryData == "URL" {
-        data = urls[orderList[entryNumber - 1]]
+        data = e.URL
+      } else if entryData == "OTP" {
+        data = e.OTP.Code(time.Now())
+      } else if entryData == "Recovery Code" {
+        data = e.RecoveryCodes[e.NextRecoveryCode()].Code
       }
-      err := clipboard.WriteAll(data)
-      if err != nil {
+      if err := clipboard.WriteAll(data); err != nil {
         subtractFromMenu(2)
         contentString = "Unable to Copy Content to Clipboard"
+      } else if err := useCode(e, entryData); err != nil {
+        subtractFromMenu(2)
+        contentString = "Code Copied, but Unable to Mark It as Used: " +
+          err.Error()
+      }
+    }
+  } else if entryData == "Field" && fieldNumber == 0 {
+    var valueEntered bool
+    if ev.Key == termbox.KeyEnter {
+      value = string(edit_box.text)
+      valueEntered = true
+      edit_box.text = make([]byte, 0)
+      edit_box.MoveCursorTo(0)
+    } else {
+      textEdit(ev)
+    }
+    if valueEntered {
+      e := selectedEntry()
+      intVal, err := strconv.Atoi(value)
+      if err == nil && intVal > 0 && intVal <= len(e.Fields) {
+        bottomCaption = ""
+        termbox.HideCursor()
+        fieldNumber = intVal
+        err := clipboard.WriteAll(e.Fields[intVal - 1].Value)
+        if err != nil {
+          subtractFromMenu(2)
+          contentString = "Unable to Copy Content to Clipboard"
+        }
+      }
+    }
+  }
+}
+
+/* TRANSFER ENTRY (first menu) */
+func transferESettings() {
+  ctrlC = true
+  passwordInput = false
+  locationTitle = "TRANSFER ENTRY"
+  options = "Enter:CONFIRM"
+  bottomCaption = "Input Entry Number: "
+  contentString = displayNameGroups()
+  termbox.SetCursor(len(bottomCaption) + edit_box.CursorX(), h - 1)
+}
+
+func transferEOptions(ev termbox.Event) {
+  var valueEntered bool
+  entryNumber = 0
+  if ev.Key == termbox.KeyEnter {
+    value = string(edit_box.text)
+    valueEntered = true
+    edit_box.text = make([]byte, 0)
+    edit_box.MoveCursorTo(0)
+  } else {
+    textEdit(ev)
+  }
+  if valueEntered {
+    intVal, err := strconv.Atoi(value)
+    if err == nil {
+      if intVal > 0 && intVal <= len(listedEntries()) {
+        entryNumber = intVal
+        entryData = ""
+        addToMenu("Transfer Content")
+      }
+    }
+  }
+}
+
+/* TRANSFER ENTRY (second menu) */
+func transferContentSettings() {
+  ctrlC = true
+  passwordInput = false
+  nameGroup := selectedEntry().NameGroup()
+  if entryData == "" {
+    termbox.HideCursor()
+    bottomCaption = ""
+    if readOnly {
+      contentString = "Copy " + nameGroup + " to Another Open Password " +
+        "File?"
+      options = "c:COPY"
+    } else {
+      contentString = "Copy or Move " + nameGroup + " to Another Open " +
+        "Password File?"
+      options = "c:COPY  m:MOVE"
+    }
+    return
+  }
+  contentString = entryData + " " + nameGroup + " to:"
+  for x, t := range tabs {
+    if x != currentTab {
+      contentString += "\n[" + strconv.Itoa(x + 1) + "] " + t.path
+    }
+  }
+  options = "Enter:CONFIRM"
+  bottomCaption = "Input Password File Number: "
+  termbox.SetCursor(len(bottomCaption) + edit_box.CursorX(), h - 1)
+}
+
+func transferContentOptions(ev termbox.Event) {
+  if entryData == "" {
+    if ev.Ch == 'c' {
+      entryData = "Copy"
+    } else if ev.Ch == 'm' && !readOnly {
+      entryData = "Move"
+    }
+    return
+  }
+  var valueEntered bool
+  if ev.Key == termbox.KeyEnter {
+    value = string(edit_box.text)
+    valueEntered = true
+    edit_box.text = make([]byte, 0)
+    edit_box.MoveCursorTo(0)
+  } else {
+    textEdit(ev)
+  }
+  if valueEntered {
+    intVal, err := strconv.Atoi(value)
+    if err == nil && intVal > 0 && intVal <= len(tabs) &&
+        intVal - 1 != currentTab {
+      e := selectedEntry()
+      nameGroup := e.NameGroup()
+      err = transferEntry(e, intVal - 1, entryData == "Move")
+      if err != nil {
+        contentString = err.Error()
+      } else if entryData == "Move" {
+        contentString = nameGroup + " Was Moved to " + tabs[intVal - 1].path
+      } else {
+        contentString = nameGroup + " Was Copied to " + tabs[intVal - 1].path
       }
+      entryData = ""
+      subtractFromMenu(2)
     }
   }
 }
@@ -801,7 +1191,7 @@ func viewEOptions(ev termbox.Event) {
   if valueEntered {
     intVal, err := strconv.Atoi(value)
     if err == nil {
-      if intVal > 0 && intVal <= len(names) {
+      if intVal > 0 && intVal <= len(listedEntries()) {
         entryNumber = intVal
         show = false
         addToMenu("View Content")
@@ -813,55 +1203,397 @@ func viewEOptions(ev termbox.Event) {
 /* VIEW ENTRY (second menu) */
 func viewContentSettings() {
   ctrlC = true
+  locationTitle = "VIEW ENTRY"
   termbox.HideCursor()
   bottomCaption = ""
   var password string
-  name := names[orderList[entryNumber - 1]]
-  username := usernames[orderList[entryNumber - 1]]
+  e := selectedEntry()
   if show {
-    password = passwords[orderList[entryNumber - 1]]
+    password = e.Password
     options = "s:HIDE PASSWORD"
   } else {
-    for _ = range passwords[orderList[entryNumber - 1]] {
-      password += "*"
-    }
+    password = concealed(e.Password)
     options = "s:SHOW PASSWORD"
   }
-  email := emails[orderList[entryNumber - 1]]
-  url := urls[orderList[entryNumber - 1]]
-  group := groups[orderList[entryNumber - 1]]
-  comment := comments[orderList[entryNumber - 1]]
-  made := created[orderList[entryNumber - 1]]
-  edited := modified[orderList[entryNumber - 1]]
-  contentString = "Name: " + name + "\n"
-  contentString += "Username: " + username + "\n"
-  contentString += "Password: " + password + "\n"
-  contentString += "Email: " + email + "\n"
-  contentString += "URL: " + url + "\n"
-  contentString += "Group: " + group + "\n"
-  contentString += "Comment: " + comment + "\n\n"
-  contentString += "Created: " + made + "\n"
-  contentString += "Modified: " + edited + "\n"
-}
-
-func viewContentOptions(ev termbox.Event) {
-  if ev.Ch != 0 {
-    if ev.Ch == 's' {
-      if show {
+  for _, f := range e.Fields {
+    if f.Concealed {
+      options += "/CONCEALED FIELDS"
+      break
+    }
+  }
+  if len(e.History) > 0 {
+    options += "  h:PASSWORD HISTORY"
+  }
+  if !readOnly {
+    options += "  r:ROTATE PASSWORD"
+  }
+  contentString = "Name: " + e.Name + "\n"
+  contentString += "Type: " + typeName(e) + "\n"
+  t := templateNamed(e.Type)
+  values := []string{e.Username, password, e.Email, e.URL}
+  for x := range values {
+    /* Skip empty values the template of the entry doesn't ask for. */
+    if t == nil || t.standard[standardValues[x]] || values[x] != "" {
+      contentString += standardLabels[x] + ": " + values[x] + "\n"
+    }
+  }
+  contentString += "Group: " + e.Group + "\n"
+  if len(e.Tags) > 0 {
+    contentString += "Tags: " + strings.Join(e.Tags, ", ") + "\n"
+  }
+  if e.Favorite {
+    contentString += "Favorite: Yes\n"
+  }
+  if expires := expiresAt(e); !expires.IsZero() {
+    contentString += "Expires: " + expires.Format(dateLayout) + " (" +
+      expiryStatus(e, time.Now())
+    if e.Expires.IsZero() {
+      contentString += ", Rotated Every " +
+        strconv.Itoa(rotationDays(e.Group)) + " Days"
+    }
+    contentString += ")\n"
+  }
+  if e.Generator != nil && e.Generator.Profile != "" {
+    contentString += "Generator Profile: " + e.Generator.Profile + "\n"
+  }
+  contentString += "Comment: " + e.Comment + "\n"
+  for _, f := range e.Fields {
+    if f.Concealed && !show {
+      contentString += f.Name + ": " + concealed(f.Value) + "\n"
+    } else {
+      contentString += f.Name + ": " + f.Value + "\n"
+    }
+  }
+  if len(e.Attachments) > 0 {
+    contentString += "\nAttachments:\n"
+    for _, a := range e.Attachments {
+      contentString += "  " + a.Name + " (" +
+          humanSize(int64(len(a.Data))) + ")\n"
+    }
+  }
+  if e.OTP != nil {
+    contentString += "\n" + displayOTP(e.OTP) + "\n"
+  }
+  if len(e.RecoveryCodes) > 0 {
+    contentString += "\nRecovery Codes: " +
+      strconv.Itoa(e.RecoveryCodesLeft()) + " of " +
+      strconv.Itoa(len(e.RecoveryCodes)) + " Left"
+    if lowRecoveryCodes(e) {
+      contentString += " (Get New Recovery Codes Soon)"
+    }
+    contentString += "\n"
+  }
+  if len(e.History) > 0 {
+    contentString += "\nPassword History: " + strconv.Itoa(len(e.History)) +
+      " Old Passwords\n"
+  }
+  contentString += "\nCreated: " + e.Created.Format(timeLayout) + "\n"
+  contentString += "Modified: " + e.Modified.Format(timeLayout) + "\n"
+}
+
+/*
+ * Returns the current TOTP code of o and how many seconds it is good for,
+ * or the counter of the next HOTP code of o, which is only made when it is
+ * copied.  Keeps the screen redrawing every second while a TOTP code is
+ * shown.
+ */
+func displayOTP(o *vault.OTP) string {
+  if o.Kind == "hotp" {
+    return "OTP: HOTP Counter " + strconv.FormatUint(o.Counter, 10) +
+      " (Copy to Use the Next Code)"
+  }
+  atomic.StoreInt32(&otpTicking, 1)
+  now := time.Now()
+  code := o.Code(now)
+  /* Split the code in half to make it easier to read. */
+  code = code[:len(code) / 2] + " " + code[len(code) / 2:]
+  return "OTP: " + code + "  (" + strconv.Itoa(o.Remaining(now)) +
+    " Seconds Left)"
+}
+
+/*
+ * Uses up the code of e that was just copied if entryData is an HOTP code
+ * or a recovery code, by advancing the HOTP counter or marking the recovery
+ * code as used, and saves the password file so it won't be given again.
+ * Nothing is changed if the password file can't be saved.
+ */
+func useCode(e *vault.Entry, entryData string) error {
+  if entryData == "OTP" && e.OTP.Kind == "hotp" {
+    e.OTP.Advance()
+    if err := writeData(); err != nil {
+      e.OTP.Counter--
+      return err
+    }
+  } else if entryData == "Recovery Code" {
+    x := e.NextRecoveryCode()
+    e.RecoveryCodes[x].Used = time.Unix(time.Now().Unix(), 0)
+    if err := writeData(); err != nil {
+      e.RecoveryCodes[x].Used = time.Time{}
+      return err
+    }
+  }
+  return nil
+}
+
+/*
+ * Checks if e has recovery codes and no more than recoveryCodesWarning of
+ * them are left unused.
+ */
+func lowRecoveryCodes(e *vault.Entry) bool {
+  return len(e.RecoveryCodes) > 0 &&
+    e.RecoveryCodesLeft() <= recoveryCodesWarning
+}
+
+/* Returns value with every character replaced by an asterisk. */
+func concealed(value string) string {
+  var hidden string
+  for _ = range value {
+    hidden += "*"
+  }
+  return hidden
+}
+
+/* Returns the numbered custom fields of e with concealed values hidden. */
+func displayFields(e *vault.Entry) string {
+  var fields string
+  for x, f := range e.Fields {
+    fields += "[" + strconv.Itoa(x + 1) + "] " + f.Name + ": "
+    if f.Concealed {
+      fields += concealed(f.Value) + " (Concealed)\n"
+    } else {
+      fields += f.Value + "\n"
+    }
+  }
+  return fields
+}
+
+func viewContentOptions(ev termbox.Event) {
+  if ev.Ch != 0 {
+    if ev.Ch == 's' {
+      if show {
         show = false
       } else {
         show = true
       }
+    } else if ev.Ch == 'h' && len(selectedEntry().History) > 0 {
+      fieldNumber = 0
+      addToMenu("Password History")
+    } else if ev.Ch == 'r' && !readOnly {
+      startRotation(selectedEntry())
+      addToMenu("Rotate Password")
+    }
+  }
+}
+
+/* PASSWORD HISTORY (old passwords of the viewed entry) */
+func historySettings() {
+  ctrlC = true
+  termbox.HideCursor()
+  bottomCaption = ""
+  e := selectedEntry()
+  if entryData == "Purge" {
+    contentString = "Purge the Password History of " + e.NameGroup() + "?"
+    options = "y:YES  n:NO"
+  } else if entryData == "Purged" {
+    contentString = "Password History of " + e.NameGroup() + " Purged"
+    options = "Ctrl-C:BACK"
+  } else if fieldNumber > 0 {
+    contentString = "Old Password " + strconv.Itoa(fieldNumber) +
+      " Copied.  Press Ctrl-C to Clear the Clipboard"
+    options = "Ctrl-C:CLEAR CLIPBOARD/BACK"
+    contentCopied = true
+  } else {
+    contentString = "Old Passwords of " + e.NameGroup() + "\n\n"
+    for x, old := range e.History {
+      password := old.Password
+      if !show {
+        password = concealed(password)
+      }
+      contentString += "[" + strconv.Itoa(x + 1) + "] " + password +
+        "  (Replaced " + old.Replaced.Format(timeLayout) + ")\n"
+    }
+    if show {
+      options = "Enter:COPY  s:HIDE PASSWORDS"
+    } else {
+      options = "Enter:COPY  s:SHOW PASSWORDS"
+    }
+    if !readOnly {
+      options += "  p:PURGE"
+    }
+    if len(contentExtra) > 0 {
+      contentString += "\n" + contentExtra
+    }
+    bottomCaption = "Input Old Password Number: "
+    termbox.SetCursor(len(bottomCaption) + edit_box.CursorX(), h - 1)
+  }
+}
+
+func historyOptions(ev termbox.Event) {
+  e := selectedEntry()
+  if entryData == "Purge" {
+    if ev.Ch == 'y' {
+      recordUndo("Password History Purge of " + e.NameGroup())
+      e.History = nil
+      entryData = "Purged"
+      if err := writeData(); err != nil {
+        entryData = ""
+        subtractFromMenu(1)
+        contentString = "Unable to Purge Password History (" +
+          err.Error() + ")"
+      }
+    } else if ev.Ch == 'n' {
+      entryData = ""
+    }
+  } else if entryData == "" && fieldNumber == 0 {
+    if ev.Ch == 's' {
+      show = !show
+    } else if ev.Ch == 'p' && !readOnly {
+      edit_box.text = make([]byte, 0)
+      edit_box.MoveCursorTo(0)
+      entryData = "Purge"
+    } else if ev.Key == termbox.KeyEnter {
+      value = string(edit_box.text)
+      edit_box.text = make([]byte, 0)
+      edit_box.MoveCursorTo(0)
+      contentExtra = ""
+      intVal, err := strconv.Atoi(value)
+      if err == nil && intVal > 0 && intVal <= len(e.History) {
+        fieldNumber = intVal
+        err := clipboard.WriteAll(e.History[intVal - 1].Password)
+        if err != nil {
+          fieldNumber = 0
+          contentExtra = "Unable to Copy Content to Clipboard"
+        }
+      }
+    } else {
+      textEdit(ev)
+    }
+  }
+}
+
+/* PURGE PASSWORD HISTORY (every entry of the password file) */
+func purgeHistorySettings() {
+  ctrlC = true
+  termbox.HideCursor()
+  locationTitle = "PURGE PASSWORD HISTORY"
+  options = "y:YES  n:NO"
+  bottomCaption = ""
+  var count, entries int
+  for _, e := range vlt.Entries {
+    if len(e.History) > 0 {
+      count += len(e.History)
+      entries++
+    }
+  }
+  contentString = "Purge the Password History of Every Entry in " + fPath +
+    "?\n\n" + strconv.Itoa(count) + " Old Passwords of " +
+    strconv.Itoa(entries) + " Entries Will Be Deleted"
+}
+
+func purgeHistoryOptions(ev termbox.Event) {
+  if ev.Ch == 'y' {
+    recordUndo("Password History Purge")
+    purged := vlt.PurgeHistory()
+    subtractFromMenu(1)
+    contentString = "Purged " + strconv.Itoa(purged) + " Old Passwords"
+    if err := writeData(); err != nil {
+      contentString = "Unable to Purge Password History (" + err.Error() +
+        ")"
+    }
+  } else if ev.Ch == 'n' {
+    subtractFromMenu(1)
+    contentString = "Password History Was NOT Purged"
+  }
+}
+
+/* NEW ENTRY (entry type) */
+func newTypeSettings() {
+  ctrlC = true
+  passwordInput = false
+  locationTitle = "NEW ENTRY"
+  options = "Enter:CONFIRM"
+  bottomCaption = "Input Entry Type Number (Enter for Login): "
+  contentString = "Choose the Type of the New Entry\n"
+  for x, t := range templates {
+    contentString += "\n[" + strconv.Itoa(x + 1) + "] " + t.describe()
+  }
+  if len(contentExtra) > 0 {
+    contentString += "\n\n" + contentExtra
+  }
+  termbox.SetCursor(len(bottomCaption) + edit_box.CursorX(), h - 1)
+}
+
+func newTypeOptions(ev termbox.Event) {
+  var valueEntered bool
+  if ev.Key == termbox.KeyEnter {
+    value = string(edit_box.text)
+    valueEntered = true
+    edit_box.text = make([]byte, 0)
+    edit_box.MoveCursorTo(0)
+  } else {
+    textEdit(ev)
+  }
+  if valueEntered {
+    intVal, err := strconv.Atoi(value)
+    if value == "" {
+      newTemplate = templateNamed("")
+    } else if err == nil && intVal > 0 && intVal <= len(templates) {
+      newTemplate = templates[intVal - 1]
+    } else {
+      contentExtra = "Entry Type Number Must be Between 1 and " +
+        strconv.Itoa(len(templates))
+      return
+    }
+    contentExtra = ""
+    newValue = make([]string, 0)
+    newFields = make([]vault.Field, 0)
+    newFieldIndex = 0
+    step[0] = true
+    subtractFromMenu(1)
+    addToMenu("New")
+  }
+}
+
+/*
+ * Skips the steps of the NEW menu for values the template of the new entry
+ * doesn't ask for, leaving those values empty.
+ */
+func skipNewSteps() {
+  if step[1] && !newTemplate.standard["username"] {
+    newValue = append(newValue, "")
+    step[1], step[2] = false, true
+  }
+  if step[2] && !newTemplate.standard["password"] {
+    newValue = append(newValue, "")
+    step[2], step[10] = false, true
+  }
+  if step[10] && !newTemplate.standard["email"] {
+    newValue = append(newValue, "")
+    step[10], step[11] = false, true
+  }
+  if step[11] && !newTemplate.standard["url"] {
+    newValue = append(newValue, "")
+    step[11], step[12] = false, true
+  }
+}
+
+/* Returns true if the NEW menu is asking for a custom field. */
+func newFieldStep() bool {
+  for _, s := range step {
+    if s {
+      return false
     }
   }
+  return newFieldIndex < len(newTemplate.fields)
 }
 
 /* NEW ENTRY */
 func newESettings() {
   ctrlC = true
   passwordInput = false
-  locationTitle = "NEW ENTRY"
+  locationTitle = "NEW " + strings.ToUpper(newTemplate.name)
   options = "Enter:CONFIRM"
+  skipNewSteps()
   if step[0] {
     contentString = "Input New Name (Required)"
     bottomCaption = "Input New Name: "
@@ -883,6 +1615,10 @@ func newESettings() {
     contentString = "Include Symbols In Password?"
   } else if step[8] {
     contentString = "Input New Password"
+    meter := strengthLine(string(edit_box.text), newValue...)
+    if meter != "" {
+      contentString += "\n\n" + meter
+    }
     bottomCaption = "Input New Password: "
   } else if step[9] {
     contentString = "Repeat New Password"
@@ -896,17 +1632,28 @@ func newESettings() {
   } else if step[12] {
     contentString = "Input New Group"
     bottomCaption = "Input New Group: "
+  } else if newFieldStep() {
+    f := newTemplate.fields[newFieldIndex]
+    contentString = "Input " + f.name
+    if f.required {
+      contentString += " (Required)"
+    }
+    bottomCaption = "Input " + f.name + ": "
   } else {
     contentString = "Input New Comment"
     bottomCaption = "Input New Comment: "
   }
-  if step[8] || step[9] {
+  if step[8] || step[9] || newFieldStep() &&
+      newTemplate.fields[newFieldIndex].concealed {
     passwordInput = true
   } else {
     passwordInput = false
   }
   if step[2] || step[4] || step[5] || step[6] || step[7] {
     options = "y:YES  n:NO"
+    if step[2] {
+      options = "y:YES  w:WORDS  r:RULES  n:NO"
+    }
     bottomCaption = ""
     termbox.HideCursor()
   } else {
@@ -982,9 +1729,11 @@ func newEOptions(ev termbox.Event) {
         }
       } else if step[9] {
         if value == key1 {
-          contentExtra = ""
-          newValue = append(newValue, value)
-          step[9], step[10] = false, true
+          contentExtra = breachWarning(value)
+          if contentExtra == "" {
+            newValue = append(newValue, value)
+            step[9], step[10] = false, true
+          }
         } else {
           contentExtra = "New Passwords Do Not Match"
           step[9], step[8] = false, true
@@ -1007,56 +1756,56 @@ func newEOptions(ev termbox.Event) {
         }
       } else if step[12] {
         if len(value) < 256 {
-          nameGroupsList := nameGroups()
-          if len(value) > 0 {
-            nameGroupsList = append(nameGroupsList, value + "/" +
-              newValue[0])
-          } else {
-            nameGroupsList = append(nameGroupsList, newValue[0])
-          }
-          if duplicateNameGroups(nameGroupsList) {
+          if vlt.Find(vault.NameGroup(newValue[0], value)) != nil {
             contentExtra = "Duplicate Name/Group Combination " +
               "(Staring Over)"
             newValue = make([]string, 0)
             step[12], step[0] = false, true
+          } else if vault.ValidGroup(value) && !inString(value, "\\") {
+            contentExtra = ""
+            newValue = append(newValue, value)
+            step[12] = false
           } else {
-            if len(value) > 0 {
-              if value[0] != '/' && value[len(value) - 1] !=
-                  '/' && !inString(value, "//") &&
-                  !inString(value, "/ ") &&
-                  !inString(value, "\\") {
-                contentExtra = ""
-                newValue = append(newValue, value)
-                step[12] = false
-              } else {
-                contentExtra = "Invalid Group Name"
-              }
-            } else {
-              contentExtra = ""
-              newValue = append(newValue, value)
-              step[12] = false
-            }
+            contentExtra = "Invalid Group Name"
           }
         } else {
           contentExtra = "Group Name Too Long"
         }
+      } else if newFieldStep() {
+        f := newTemplate.fields[newFieldIndex]
+        if err := f.checkValue(value); err != nil {
+          contentExtra = err.Error()
+        } else {
+          contentExtra = ""
+          if value != "" {
+            newFields = append(newFields, vault.Field{Name: f.name,
+                                                      Value: value,
+                                                      Concealed: f.concealed})
+          }
+          newFieldIndex++
+        }
       } else {
         if len(value) < 65536 {
           contentExtra = ""
-          names = append(names, newValue[0])
-          usernames = append(usernames, newValue[1])
-          passwords = append(passwords, newValue[2])
-          emails = append(emails, newValue[3])
-          urls = append(urls, newValue[4])
-          groups = append(groups, newValue[5])
-          comments = append(comments, value)
-          create := time.Now().Format(timeLayout)
-          created = append(created, create)
-          modified = append(modified, create)
+          create := time.Now()
+          e := &vault.Entry{Name: newValue[0], Username: newValue[1],
+                            Password: newValue[2], Email: newValue[3],
+                            URL: newValue[4], Group: newValue[5],
+                            Comment: value, Type: newTemplate.entryType(),
+                            Fields: newFields, Created: create,
+                            Modified: create}
+          e.Generator = newGenerator
+          newGenerator = nil
           passChars = make([]bool, 0)
           newValue = make([]string, 0)
+          newFields = make([]vault.Field, 0)
           passLen = 0
-          err := writeData()
+          before := vlt.Snapshot()
+          err := vlt.Add(e)
+          if err == nil {
+            addUndo("New Entry " + e.NameGroup(), before)
+            err = writeData()
+          }
           if err != nil {
             contentString = "Unable to Modify Password File " +
               "(Write Error)"
@@ -1084,10 +1833,16 @@ func newEOptions(ev termbox.Event) {
         step[6], step[7] = false, true
       } else {
         passChars = append(passChars, true)
-        password := genPass(uint16(passLen), passChars)
+        newGenerator = vault.NewGenerator(uint16(passLen), passChars)
+        /* Can't fail, the length is at least 4 and there are no rules. */
+        password, _ := genPass(newGenerator)
         newValue = append(newValue, password)
         step[7], step[10] = false, true
       }
+    } else if ev.Ch == 'w' && step[2] {
+      startWords()
+    } else if ev.Ch == 'r' && step[2] {
+      startRules()
     } else if ev.Ch == 'n' {
       if step[2] {
         step[2], step[8] = false, true
@@ -1102,7 +1857,9 @@ func newEOptions(ev termbox.Event) {
         step[6], step[7] = false, true
       } else {
         passChars = append(passChars, false)
-        password := genPass(uint16(passLen), passChars)
+        newGenerator = vault.NewGenerator(uint16(passLen), passChars)
+        /* Can't fail, the length is at least 4 and there are no rules. */
+        password, _ := genPass(newGenerator)
         newValue = append(newValue, password)
         step[7], step[10] = false, true
       }
@@ -1135,7 +1892,7 @@ func deleteEOptions(ev termbox.Event) {
   if valueEntered {
     intVal, err := strconv.Atoi(value)
     if err == nil {
-      if intVal > 0 && intVal <= len(names) {
+      if intVal > 0 && intVal <= len(listedEntries()) {
         entryNumber = intVal
         addToMenu("Delete Content")
       }
@@ -1152,42 +1909,318 @@ func deleteContentSettings() {
     menuList = append(menuList[:len(menuList) - 2],
       menuList[len(menuList) - 1])
   }
-  nameGroupsList := nameGroups()
   contentString = "Are You Sure You Want to Delete " +
-    nameGroupsList[entryNumber - 1] + "?"
+    selectedEntry().NameGroup() + "?\n\nIt Will Be Moved to the Trash, " +
+    "Where It Can be Restored"
   options = "y:YES  n:NO"
 }
 
 func deleteContentOptions(ev termbox.Event) {
-  num := orderList[entryNumber - 1]
-  nameGroupsList := nameGroups()
+  e := selectedEntry()
   if ev.Ch != 0 {
     if ev.Ch == 'y' {
-      names = append(names[:num], names[num + 1:]...)
-      usernames = append(usernames[:num], usernames[num + 1:]...)
-      passwords = append(passwords[:num], passwords[num + 1:]...)
-      emails = append(emails[:num], emails[num + 1:]...)
-      urls = append(urls[:num], urls[num + 1:]...)
-      groups = append(groups[:num], groups[num + 1:]...)
-      comments = append(comments[:num], comments[num + 1:]...)
-      created = append(created[:num], created[num + 1:]...)
-      modified = append(modified[:num], modified[num + 1:]...)
-      err := writeData()
+      recordUndo("Deletion of " + e.NameGroup())
+      err := vlt.Delete(e, time.Now())
+      if err == nil {
+        err = writeData()
+      }
       if err != nil {
         contentString = "Unable to Modify Password File (Write Error)"
       } else {
-        contentString = nameGroupsList[entryNumber - 1] +
-          " Was Successfully Deleted"
+        contentString = e.NameGroup() + " Was Moved to the Trash"
       }
       subtractFromMenu(1)
     } else if ev.Ch == 'n' {
-      contentString = nameGroupsList[entryNumber - 1] +
-        " Was NOT Deleted"
+      contentString = e.NameGroup() + " Was NOT Deleted"
       subtractFromMenu(1)
     }
   }
 }
 
+/* TRASH (first menu) */
+func trashSettings() {
+  ctrlC = true
+  passwordInput = false
+  locationTitle = "TRASH"
+  trash := sortedTrash()
+  if entryData == "Empty" {
+    termbox.HideCursor()
+    options = "y:YES  n:NO"
+    bottomCaption = ""
+    contentString = "Are You Sure You Want to Purge All " +
+      strconv.Itoa(len(trash)) + " Entries in the Trash for Good?"
+    return
+  }
+  contentString = ""
+  if len(trash) == 0 {
+    termbox.HideCursor()
+    options = "Ctrl-C:BACK"
+    bottomCaption = ""
+    contentString = "The Trash of " + fPath + " is Empty"
+  } else {
+    options = "Enter:CONFIRM"
+    if !readOnly {
+      options += "  e:EMPTY TRASH"
+    }
+    bottomCaption = "Input Entry Number: "
+    for x, e := range trash {
+      contentString += "[" + strconv.Itoa(x + 1) + "] " + entryLabel(e) +
+        "  (Deleted " + e.Deleted.Format(timeLayout) + ")\n"
+    }
+    if trashPurgeDays > 0 {
+      contentString += "\nEntries Are Purged " +
+        strconv.Itoa(trashPurgeDays) + " Days After They Are Deleted"
+    }
+    termbox.SetCursor(len(bottomCaption) + edit_box.CursorX(), h - 1)
+  }
+  if contentExtra != "" {
+    contentString += "\n\n" + contentExtra
+  }
+}
+
+func trashOptions(ev termbox.Event) {
+  var valueEntered bool
+  if entryData == "Empty" {
+    if ev.Ch == 'y' {
+      entryData = ""
+      contentExtra = "Purged " + strconv.Itoa(len(vlt.Trash)) + " Entries"
+      recordUndo("Emptying of the Trash")
+      vlt.Trash = nil
+      if err := writeData(); err != nil {
+        contentExtra = "Unable to Modify Password File (Write Error)"
+      }
+    } else if ev.Ch == 'n' {
+      entryData = ""
+      contentExtra = "The Trash Was NOT Emptied"
+    }
+    return
+  }
+  if len(vlt.Trash) == 0 {
+    return
+  }
+  if ev.Ch == 'e' && !readOnly {
+    edit_box.text = make([]byte, 0)
+    edit_box.MoveCursorTo(0)
+    contentExtra = ""
+    entryData = "Empty"
+    return
+  }
+  if ev.Key == termbox.KeyEnter {
+    value = string(edit_box.text)
+    valueEntered = true
+    edit_box.text = make([]byte, 0)
+    edit_box.MoveCursorTo(0)
+  } else {
+    textEdit(ev)
+  }
+  if valueEntered {
+    intVal, err := strconv.Atoi(value)
+    if err == nil && intVal > 0 && intVal <= len(vlt.Trash) {
+      contentExtra = ""
+      trashNumber = intVal
+      addToMenu("Trash Content")
+    }
+  }
+}
+
+/* TRASH (second menu) */
+func trashContentSettings() {
+  ctrlC = true
+  locationTitle = "TRASH"
+  termbox.HideCursor()
+  bottomCaption = ""
+  e := selectedTrash()
+  if entryData == "Purge" {
+    contentString = "Are You Sure You Want to Purge " + e.NameGroup() +
+      " From the Trash for Good?"
+    options = "y:YES  n:NO"
+    return
+  }
+  contentString = trashContent(e)
+  if readOnly {
+    options = "Ctrl-C:BACK"
+  } else {
+    options = "r:RESTORE  p:PURGE"
+  }
+}
+
+func trashContentOptions(ev termbox.Event) {
+  e := selectedTrash()
+  if entryData == "Purge" {
+    if ev.Ch == 'y' {
+      entryData = ""
+      contentExtra = e.NameGroup() + " Was Purged From the Trash"
+      recordUndo("Purge of " + e.NameGroup())
+      err := vlt.Purge(e)
+      if err == nil {
+        err = writeData()
+      }
+      if err != nil {
+        contentExtra = "Unable to Modify Password File (Write Error)"
+      }
+      subtractFromMenu(1)
+    } else if ev.Ch == 'n' {
+      entryData = ""
+    }
+  } else if ev.Ch == 'p' && !readOnly {
+    entryData = "Purge"
+  } else if ev.Ch == 'r' && !readOnly {
+    contentExtra = e.NameGroup() + " Was Restored"
+    before := vlt.Snapshot()
+    err := vlt.Restore(e)
+    if err == vault.ErrDuplicate {
+      contentExtra = "Unable to Restore " + e.NameGroup() +
+        " (Duplicate Name/Group Combination)"
+    } else if err == nil {
+      addUndo("Restore of " + e.NameGroup(), before)
+      err = writeData()
+      if err != nil {
+        contentExtra = "Unable to Modify Password File (Write Error)"
+      }
+    }
+    subtractFromMenu(1)
+  }
+}
+
+/* SEARCH (entries and optionally the trash) */
+func searchSettings() {
+  ctrlC = true
+  passwordInput = false
+  locationTitle = "SEARCH"
+  if searchTrash {
+    options = "Ctrl-T:EXCLUDE TRASH"
+  } else {
+    options = "Ctrl-T:INCLUDE TRASH"
+  }
+  if searchQuery == "" {
+    options = "Enter:SEARCH  " + options
+    bottomCaption = "Search: "
+    contentString = "Search the Names, Groups, Usernames, Emails, URLs " +
+      "and Tags of " + filterLabel()
+    if searchTrash {
+      contentString += " (Including the Trash)"
+    }
+  } else {
+    options = "Enter:CONFIRM  " + options
+    bottomCaption = "Input Entry Number: "
+    contentString = "Entries Matching \"" + searchQuery + "\"\n\n"
+    results := searchResults()
+    for x, e := range results {
+      contentString += "[" + strconv.Itoa(x + 1) + "] " + entryLabel(e)
+      if !e.Deleted.IsZero() {
+        contentString += "  (In Trash, Deleted " +
+          e.Deleted.Format(timeLayout) + ")"
+      }
+      contentString += "\n"
+    }
+    if len(results) == 0 {
+      contentString += "No Entries Found"
+    }
+  }
+  if contentExtra != "" {
+    contentString += "\n\n" + contentExtra
+  }
+  termbox.SetCursor(len(bottomCaption) + edit_box.CursorX(), h - 1)
+}
+
+/*
+ * Returns the entries matching searchQuery that also match the filter of
+ * the entry lists, in the order of vlt.Search.
+ */
+func searchResults() []*vault.Entry {
+  var results []*vault.Entry
+  for _, e := range vlt.Search(searchQuery, searchTrash) {
+    if e.Matches(tagFilter, favoritesOnly) {
+      results = append(results, e)
+    }
+  }
+  return results
+}
+
+func searchOptions(ev termbox.Event) {
+  var valueEntered bool
+  if ev.Key == termbox.KeyEnter {
+    value = string(edit_box.text)
+    valueEntered = true
+    edit_box.text = make([]byte, 0)
+    edit_box.MoveCursorTo(0)
+  } else if ev.Key == termbox.KeyCtrlT {
+    searchTrash = !searchTrash
+  } else {
+    textEdit(ev)
+  }
+  if !valueEntered {
+    return
+  }
+  if searchQuery == "" {
+    searchQuery = value
+    return
+  }
+  results := searchResults()
+  intVal, err := strconv.Atoi(value)
+  if err != nil || intVal < 1 || intVal > len(results) {
+    return
+  }
+  e := results[intVal - 1]
+  if e.Deleted.IsZero() {
+    for x, listed := range listedEntries() {
+      if listed == e {
+        entryNumber = x + 1
+      }
+    }
+    show = false
+    addToMenu("View Content")
+  } else {
+    for x, trashed := range sortedTrash() {
+      if trashed == e {
+        trashNumber = x + 1
+      }
+    }
+    addToMenu("Trash Content")
+  }
+}
+
+/* FILTER (entries listed by tag and favorites) */
+func filterSettings() {
+  ctrlC = true
+  passwordInput = false
+  locationTitle = "FILTER"
+  if favoritesOnly {
+    options = "Enter:CONFIRM  Ctrl-F:EVERY ENTRY"
+  } else {
+    options = "Enter:CONFIRM  Ctrl-F:FAVORITES ONLY"
+  }
+  bottomCaption = "Tag: "
+  contentString = "Input a Tag to Only List Entries With That Tag (Empty to " +
+    "List Entries With Any Tag)\n\n"
+  if tagFilter != "" || favoritesOnly {
+    contentString += "Listing " + filterLabel()
+  } else {
+    contentString += "Listing Every Entry"
+  }
+  if tags := vlt.Tags(); len(tags) > 0 {
+    contentString += "\n\nTags: " + strings.Join(tags, ", ")
+  }
+  termbox.SetCursor(len(bottomCaption) + edit_box.CursorX(), h - 1)
+}
+
+func filterOptions(ev termbox.Event) {
+  if ev.Key == termbox.KeyCtrlF {
+    favoritesOnly = !favoritesOnly
+    return
+  } else if ev.Key != termbox.KeyEnter {
+    textEdit(ev)
+    return
+  }
+  tagFilter = strings.TrimSpace(string(edit_box.text))
+  edit_box.text = make([]byte, 0)
+  edit_box.MoveCursorTo(0)
+  termbox.HideCursor()
+  contentString = "Listing " + strconv.Itoa(len(listedEntries())) + " " +
+    filterLabel()
+  subtractFromMenu(1)
+}
+
 /* EDIT ENTRY (first menu) */
 func editESettings() {
   ctrlC = true
@@ -1213,8 +2246,9 @@ func editEOptions(ev termbox.Event) {
   if valueEntered {
     intVal, err := strconv.Atoi(value)
     if err == nil {
-      if intVal > 0 && intVal <= len(names) {
+      if intVal > 0 && intVal <= len(listedEntries()) {
         entryNumber = intVal
+        editSnapshot = vlt.Snapshot()
         addToMenu("Edit Content")
       }
     }
@@ -1225,17 +2259,20 @@ func editEOptions(ev termbox.Event) {
 func editContentSettings() {
   ctrlC = true
   termbox.HideCursor()
+  locationTitle = "EDIT ENTRY"
   if menuList[len(menuList) - 2] == "Edit" {
     menuList = append(menuList[:len(menuList) - 2],
       menuList[len(menuList) - 1])
   }
   bottomCaption = ""
   if entryData == "" {
-    nameGroupsList := nameGroups()
     contentString = "Choose What You Want to Edit for " +
-      nameGroupsList[entryNumber - 1]
+      selectedEntry().NameGroup()
     options = "n:NAME  u:USERNAME  p:PASSWORD  e:EMAIL  w:URL  g:GROUP  " +
-      "c:COMMENT"
+      "c:COMMENT  f:FIELDS  o:OTP  r:RECOVERY CODES  t:TAGS  *:FAVORITE  " +
+      "x:EXPIRY"
+  } else if entryData == "Field" {
+    editFieldSettings()
   } else {
     options = "Enter:CONFIRM"
     if entryData == "Name" {
@@ -1250,6 +2287,9 @@ func editContentSettings() {
         contentString = ""
         termbox.HideCursor()
         options = "y:YES  n:NO"
+        if step[0] {
+          options = "y:YES  w:WORDS  r:RULES  n:NO"
+        }
       }
       if step[0] {
         contentString = "Generate Password?"
@@ -1269,6 +2309,12 @@ func editContentSettings() {
         contentString = "Include Symbols in Password?"
       } else if step[6] {
         contentString = "Input New Password"
+        e := selectedEntry()
+        meter := strengthLine(string(edit_box.text), e.Name, e.Username,
+                              e.Email, e.URL)
+        if meter != "" {
+          contentString += "\n\n" + meter
+        }
         bottomCaption = "Input New Password: "
         termbox.SetCursor(len(bottomCaption) + edit_box.CursorX(),
           h - 1)
@@ -1290,6 +2336,30 @@ func editContentSettings() {
     } else if entryData == "Comment" {
       contentString = "Input New Comment"
       bottomCaption = "Input New Comment: "
+    } else if entryData == "OTP" {
+      contentString = "Input an otpauth:// URI or a Base32 Secret for " +
+        "TOTP Codes (Empty to Remove the OTP)"
+      bottomCaption = "Input New OTP: "
+    } else if entryData == "Recovery Codes" {
+      contentString = "Input the New Recovery Codes Separated by Spaces " +
+        "or Commas (Empty to Remove the Recovery Codes)"
+      bottomCaption = "Input New Recovery Codes: "
+    } else if entryData == "Tags" {
+      contentString = "Input the New Tags Separated by Spaces or Commas " +
+        "(Empty to Remove the Tags)"
+      if tags := selectedEntry().Tags; len(tags) > 0 {
+        contentString += "\n\nCurrent Tags: " + strings.Join(tags, ", ")
+      }
+      bottomCaption = "Input New Tags: "
+    } else if entryData == "Expiry" {
+      contentString = "Input the Date the Password Expires as YYYY-MM-DD " +
+        "or a Number of Days (Like 90d) or Weeks (Like 12w) From Today " +
+        "(Empty to Remove the Expiry Date)"
+      if days := rotationDays(selectedEntry().Group); days > 0 {
+        contentString += "\n\nWithout an Expiry Date, the Password " +
+          "Expires " + strconv.Itoa(days) + " Days After It Was Set"
+      }
+      bottomCaption = "Input New Expiry Date: "
     }
     if entryData != "Password" {
       passwordInput = false
@@ -1303,8 +2373,7 @@ func editContentSettings() {
 
 func editContentOptions(ev termbox.Event) {
   var valueEntered bool
-  num := orderList[entryNumber - 1]
-  nameGroupsList := nameGroups()
+  e := selectedEntry()
   if entryData == "" {
     if ev.Ch != 0 {
       if ev.Ch == 'n' {
@@ -1321,8 +2390,27 @@ func editContentOptions(ev termbox.Event) {
         entryData = "Group"
       } else if ev.Ch == 'c' {
         entryData = "Comment"
+      } else if ev.Ch == 'f' {
+        entryData = "Field"
+      } else if ev.Ch == 'o' {
+        entryData = "OTP"
+      } else if ev.Ch == 'r' {
+        entryData = "Recovery Codes"
+      } else if ev.Ch == 't' {
+        entryData = "Tags"
+      } else if ev.Ch == 'x' {
+        entryData = "Expiry"
+      } else if ev.Ch == '*' {
+        e.Favorite = !e.Favorite
+        contentString = "Removed From Favorites"
+        if e.Favorite {
+          contentString = "Added to Favorites"
+        }
+        subtractFromMenu(1)
       }
     }
+  } else if entryData == "Field" {
+    editFieldOptions(ev, e)
   } else if entryData == "Password" {
     if step[0] || step[2] || step[3] || step[4] || step[5] {
       if ev.Ch == 'y' {
@@ -1341,11 +2429,19 @@ func editContentOptions(ev termbox.Event) {
         } else {
           contentString = "Password Changed"
           passChars = append(passChars, true)
-          password := genPass(uint16(passLen), passChars)
-          passwords[num] = password
+          e.Generator = vault.NewGenerator(uint16(passLen), passChars)
+          /* Can't fail, the length is at least 4 and there are no rules. */
+          password, _ := genPass(e.Generator)
+          e.ChangePassword(password, time.Now(), passwordHistoryDepth)
+          passChars = make([]bool, 0)
+          passLen = 0
           subtractFromMenu(1)
           step[5] = false
         }
+      } else if ev.Ch == 'w' && step[0] {
+        startWords()
+      } else if ev.Ch == 'r' && step[0] {
+        startRules()
       } else if ev.Ch == 'n' {
         contentExtra = ""
         if step[0] {
@@ -1362,8 +2458,12 @@ func editContentOptions(ev termbox.Event) {
         } else {
           contentString = "Password Changed"
           passChars = append(passChars, false)
-          password := genPass(uint16(passLen), passChars)
-          passwords[num] = password
+          e.Generator = vault.NewGenerator(uint16(passLen), passChars)
+          /* Can't fail, the length is at least 4 and there are no rules. */
+          password, _ := genPass(e.Generator)
+          e.ChangePassword(password, time.Now(), passwordHistoryDepth)
+          passChars = make([]bool, 0)
+          passLen = 0
           subtractFromMenu(1)
           step[5] = false
         }
@@ -1406,10 +2506,13 @@ func editContentOptions(ev termbox.Event) {
           }
         } else {
           if key1 == value {
-            contentString = "Password Changed"
-            passwords[num] = value
-            subtractFromMenu(1)
-            step[5] = false
+            contentExtra = breachWarning(value)
+            if contentExtra == "" {
+              contentString = "Password Changed"
+              e.ChangePassword(value, time.Now(), passwordHistoryDepth)
+              subtractFromMenu(1)
+              step[5] = false
+            }
           } else {
             contentExtra = "New Passwords Do Not Match"
           }
@@ -1431,17 +2534,10 @@ func editContentOptions(ev termbox.Event) {
         if len(value) < 256 && len(value) > 0 &&
             !inString(value, "/") &&
             !inString(value, "\\") {
-          name := value
-          group := groups[entryNumber - 1]
-          if group == "" {
-            nameGroupsList[entryNumber - 1] = name
-          } else {
-            nameGroupsList[entryNumber - 1] = group +
-              "/" + name
-          }
-          if !duplicateNameGroups(nameGroupsList) {
+          other := vlt.Find(vault.NameGroup(value, e.Group))
+          if other == nil || other == e {
             contentString = "Name Changed"
-            names[num] = value
+            e.Name = value
             subtractFromMenu(1)
           } else {
             contentExtra = "Duplicate Name/Group Combination"
@@ -1456,7 +2552,7 @@ func editContentOptions(ev termbox.Event) {
       } else if entryData == "Username" {
         if len(value) < 256 {
           contentString = "Username Changed"
-          usernames[num] = value
+          e.Username = value
           subtractFromMenu(1)
         } else {
           contentExtra = "Username Too Long"
@@ -1464,31 +2560,22 @@ func editContentOptions(ev termbox.Event) {
       } else if entryData == "Email" {
         if len(value) < 256 {
           contentString = "Email Changed"
-          emails[num] = value
+          e.Email = value
           subtractFromMenu(1)
         }
       } else if entryData == "URL" {
         if len(value) < 256 {
           contentString = "URL Changed"
-          urls[num] = value
+          e.URL = value
           subtractFromMenu(1)
         }
       } else if entryData == "Group" {
         if len(value) < 256{
-          group := value
-          name := names[num]
-          if group == "" {
-            nameGroupsList[entryNumber - 1] = name
-          } else {
-            nameGroupsList[entryNumber - 1] = group +
-              "/" + name
-          }
-          if group[0] != '/' && group[len(group) - 1] != '/' &&
-              !inString(group, "//") && !inString(group, "/ ") &&
-              !inString(group, "\\") {
-            if !duplicateNameGroups(nameGroupsList) {
+          if vault.ValidGroup(value) && !inString(value, "\\") {
+            other := vlt.Find(vault.NameGroup(e.Name, value))
+            if other == nil || other == e {
               contentString = "Group Name Changed"
-              groups[num] = value
+              e.Group = value
               subtractFromMenu(1)
             } else {
               contentExtra = "Duplicate Name/Group Combination"
@@ -1502,19 +2589,235 @@ func editContentOptions(ev termbox.Event) {
       } else if entryData == "Comment" {
         if len(value) < 65536 {
           contentString = "Comment Changed"
-          comments[num] = value
+          e.Comment = value
           subtractFromMenu(1)
         }
+      } else if entryData == "OTP" {
+        if value == "" {
+          contentString = "OTP Removed"
+          e.OTP = nil
+          subtractFromMenu(1)
+        } else if o, err := vault.ParseOTP(value); err == nil {
+          contentString = "OTP Changed"
+          e.OTP = o
+          subtractFromMenu(1)
+        } else {
+          contentExtra = err.Error()
+        }
+      } else if entryData == "Expiry" {
+        if expires, err := parseExpiry(value, time.Now()); err == nil {
+          contentString = "Expiry Date Changed"
+          if expires.IsZero() {
+            contentString = "Expiry Date Removed"
+          }
+          e.Expires = expires
+          subtractFromMenu(1)
+        } else {
+          contentExtra = err.Error()
+        }
+      } else if entryData == "Tags" {
+        if err := e.SetTags(value); err == nil {
+          contentString = "Tags Changed"
+          if len(e.Tags) == 0 {
+            contentString = "Tags Removed"
+          }
+          subtractFromMenu(1)
+        } else {
+          contentExtra = err.Error()
+        }
+      } else if entryData == "Recovery Codes" {
+        if err := e.SetRecoveryCodes(value); err == nil {
+          contentString = "Recovery Codes Changed"
+          if len(e.RecoveryCodes) == 0 {
+            contentString = "Recovery Codes Removed"
+          }
+          subtractFromMenu(1)
+        } else {
+          contentExtra = err.Error()
+        }
+      }
+    }
+  }
+  if menu == "Main Menu" {
+    saveEdit(e)
+  }
+}
+
+/* Saves the changes made to e in the EDIT ENTRY menu. */
+func saveEdit(e *vault.Entry) {
+  contentExtra = ""
+  entryData = ""
+  e.Modified = time.Now()
+  addUndo("Edit of " + e.NameGroup(), editSnapshot)
+  err := writeData()
+  if err != nil  {
+    contentString = "Unable to Modify Password File (Write Error)"
+  }
+}
+
+/* EDIT ENTRY (custom fields) */
+func editFieldSettings() {
+  e := selectedEntry()
+  options = "Enter:CONFIRM"
+  passwordInput = false
+  if step[0] {
+    if len(e.Fields) > 0 {
+      contentString = "Custom Fields of " + e.NameGroup() + "\n\n" +
+        displayFields(e)
+    } else {
+      contentString = e.NameGroup() + " Has No Custom Fields\n"
+    }
+    bottomCaption = "Input Field Number (0 for New Field): "
+  } else if step[8] {
+    f := e.Fields[fieldNumber - 1]
+    contentString = "Choose What You Want to Edit for Field " + f.Name
+    options = "v:VALUE  r:RENAME  "
+    if f.Concealed {
+      options += "c:REVEAL"
+    } else {
+      options += "c:CONCEAL"
+    }
+    options += "  d:DELETE"
+  } else if step[9] {
+    contentString = "Input Field Name (Required)"
+    bottomCaption = "Input Field Name: "
+  } else if step[10] {
+    contentString = "Input Field Value"
+    bottomCaption = "Input Field Value: "
+    if fieldNumber == 0 {
+      passwordInput = newField.Concealed
+    } else {
+      passwordInput = e.Fields[fieldNumber - 1].Concealed
+    }
+  } else if step[11] {
+    contentString = "Conceal Value of " + newField.Name + "?"
+    options = "y:YES  n:NO"
+  } else if step[12] {
+    contentString = "Are You Sure You Want to Delete Field " +
+      e.Fields[fieldNumber - 1].Name + "?"
+    options = "y:YES  n:NO"
+  }
+  if step[8] || step[11] || step[12] {
+    termbox.HideCursor()
+  } else {
+    termbox.SetCursor(len(bottomCaption) + edit_box.CursorX(), h - 1)
+  }
+  if len(contentExtra) > 0 {
+    contentString += "\n" + contentExtra
+  }
+}
+
+/*
+ * Adds, changes and deletes the custom fields of e.  Goes back to the main
+ * menu, which saves the password file, once a custom field was changed.
+ */
+func editFieldOptions(ev termbox.Event, e *vault.Entry) {
+  var valueEntered bool
+  if step[8] {
+    f := &e.Fields[fieldNumber - 1]
+    if ev.Ch == 'v' {
+      step[8], step[10] = false, true
+    } else if ev.Ch == 'r' {
+      step[8], step[9] = false, true
+    } else if ev.Ch == 'c' {
+      f.Concealed = !f.Concealed
+      if f.Concealed {
+        contentString = "Field " + f.Name + " Concealed"
+      } else {
+        contentString = "Field " + f.Name + " Revealed"
       }
+      step[8] = false
+      subtractFromMenu(1)
+    } else if ev.Ch == 'd' {
+      step[8], step[12] = false, true
     }
+    return
+  } else if step[11] {
+    if ev.Ch == 'y' || ev.Ch == 'n' {
+      newField.Concealed = ev.Ch == 'y'
+      step[11], step[10] = false, true
+    }
+    return
+  } else if step[12] {
+    name := e.Fields[fieldNumber - 1].Name
+    if ev.Ch == 'y' {
+      contentString = "Field " + name + " Deleted"
+      e.Fields = append(e.Fields[:fieldNumber - 1], e.Fields[fieldNumber:]...)
+      step[12] = false
+      subtractFromMenu(1)
+    } else if ev.Ch == 'n' {
+      contentExtra = "Field " + name + " Was NOT Deleted"
+      step[12], step[8] = false, true
+    }
+    return
   }
-  if menu == "Main Menu" {
-    contentExtra = ""
-    entryData = ""
-    modified[num] = time.Now().Format(timeLayout)
-    err := writeData()
-    if err != nil  {
-      contentString = "Unable to Modify Password File (Write Error)"
+  if ev.Key == termbox.KeyEnter {
+    value = string(edit_box.text)
+    valueEntered = true
+    edit_box.text = make([]byte, 0)
+    edit_box.MoveCursorTo(0)
+  } else {
+    textEdit(ev)
+  }
+  if !valueEntered {
+    return
+  }
+  contentExtra = ""
+  if step[0] {
+    intVal, err := strconv.Atoi(value)
+    if err != nil || intVal < 0 || intVal > len(e.Fields) {
+      contentExtra = "Field Number Must be Between 0 and " +
+        strconv.Itoa(len(e.Fields))
+    } else if intVal == 0 && len(e.Fields) >= vault.MaxFields {
+      contentExtra = "Too Many Custom Fields"
+    } else {
+      fieldNumber = intVal
+      newField = vault.Field{}
+      step[0] = false
+      if fieldNumber == 0 {
+        step[9] = true
+      } else {
+        step[8] = true
+      }
+    }
+  } else if step[9] {
+    other := e.Field(value)
+    if err := (vault.Field{Name: value}).Validate(); err != nil {
+      contentExtra = err.Error()
+    } else if other != nil && (fieldNumber == 0 ||
+        other != &e.Fields[fieldNumber - 1]) {
+      contentExtra = "Duplicate Field Name"
+    } else if fieldNumber == 0 {
+      newField.Name = value
+      step[9], step[11] = false, true
+    } else {
+      contentString = "Field " + e.Fields[fieldNumber - 1].Name +
+        " Renamed to " + value
+      e.Fields[fieldNumber - 1].Name = value
+      step[9] = false
+      subtractFromMenu(1)
+    }
+  } else if step[10] {
+    name := newField.Name
+    if fieldNumber > 0 {
+      name = e.Fields[fieldNumber - 1].Name
+    }
+    if len(value) > 65535 {
+      contentExtra = "Field Value Too Long"
+    } else if err := checkTemplateField(e.Type, name, value); err != nil {
+      contentExtra = err.Error()
+    } else if fieldNumber == 0 {
+      newField.Value = value
+      e.Fields = append(e.Fields, newField)
+      contentString = "Field " + newField.Name + " Added"
+      newField = vault.Field{}
+      step[10] = false
+      subtractFromMenu(1)
+    } else {
+      e.Fields[fieldNumber - 1].Value = value
+      contentString = "Field " + e.Fields[fieldNumber - 1].Name + " Changed"
+      step[10] = false
+      subtractFromMenu(1)
     }
   }
 }
@@ -1532,6 +2835,7 @@ func cPassphraseOptions(ev termbox.Event) {
   if ev.Ch == 'y' {
     omit = true
     step[0] = true
+    contentExtra = ""
     addToMenu("Passphrase")
   } else if ev.Ch == 'n' {
     subtractFromMenu(1)
@@ -1552,15 +2856,24 @@ func passphraseSettings() {
     bottomCaption = "Input Passphrase: "
   } else if step[1] {
     bottomCaption = "Input New Password: "
+    contentString = strengthLine(string(edit_box.text),
+                                 passphraseInputs(fPath)...)
   } else {
     bottomCaption = "Repeat New Password: "
   }
+  if contentString != "" && contentExtra != "" {
+    contentString += "\n\n"
+  }
+  contentString += contentExtra
   if omit {
     options += "INCLUDE"
   } else {
     options += "OMIT"
   }
   options += " KEYFILE"
+  if step[1] {
+    options += "  Ctrl-G:GENERATE WORDS"
+  }
   termbox.SetCursor(len(bottomCaption) + edit_box.CursorX(), h - 1)
 }
 
@@ -1577,47 +2890,52 @@ func passphraseOptions(ev termbox.Event) {
     } else {
       omit = true
     }
+  } else if ev.Key == termbox.KeyCtrlG && step[1] {
+    startWords()
   } else {
     textEdit(ev)
   }
   if valueEntered {
     if step[0] {
       if !omit {
-        contentString = ""
+        contentExtra = ""
         tmpPassphrase = value
         addToMenu("Keyfile")
       } else {
-        if value == passphrase {
-          contentString = ""
+        if vlt.CheckPassphrase(value) {
+          contentExtra = ""
           step[0], step[1] = false, true
         } else {
-          contentString = "Incorrect Passphrase/Keyfile " +
+          contentExtra = "Incorrect Passphrase/Keyfile " +
             "Combination"
         }
       }
     } else if step[1] {
-      key1 = value
-      contentString = ""
-      step[1] = false
+      contentExtra = weakPassphrase(value, fPath)
+      if contentExtra == "" {
+        key1 = value
+        step[1] = false
+      }
     } else {
       if value == key1 {
+        contentExtra = ""
         if !omit {
           tmpPassphrase = value
           addToMenu("Keyfile")
         } else {
-          passphrase = value
+          oldPass := vlt.Passphrase()
+          vlt.SetPassphrase(value)
           err := writeData()
           if err != nil {
             contentString = "Unable to Modify Password File " +
               "(Write Error)"
+            addToMenu("Main Menu")
           } else {
-            contentString = "Your Passphrase/Keyfile Was " +
-              "Successfully Changed!"
+            passphraseChanged(oldPass)
           }
-          addToMenu("Main Menu")
         }
       } else {
-        contentString = "New Passphrases Do Not Match"
+        contentExtra = "New Passphrases Do Not Match"
         step[1] = true
       }
       key1 = ""
@@ -1625,6 +2943,76 @@ func passphraseOptions(ev termbox.Event) {
   }
 }
 
+/*
+ * Called after the passphrase/keyfile was changed from oldPass.  If the
+ * password file has backups, asks what to do with them since they are still
+ * encrypted with the old passphrase/keyfile.
+ */
+func passphraseChanged(oldPass string) {
+  contentString = "Your Passphrase/Keyfile Was Successfully Changed!"
+  if len(listBackups(backupBase(fPath))) > 0 {
+    oldPassphrase = oldPass
+    entryData = ""
+    addToMenu("Rotate Backups")
+  } else {
+    addToMenu("Main Menu")
+  }
+}
+
+/* ROTATE BACKUPS (after changing the passphrase/keyfile) */
+func rotateBackupsSettings() {
+  ctrlC = false
+  termbox.HideCursor()
+  bottomCaption = ""
+  locationTitle = "ROTATE BACKUPS"
+  count := strconv.Itoa(len(listBackups(backupBase(fPath))))
+  if entryData == "" {
+    options = "r:RE-ENCRYPT  d:DELETE  k:KEEP"
+    contentString = "Your Passphrase/Keyfile Was Successfully Changed!\n\n" +
+      "The " + count + " Backups of " + fPath + " in " + backupDir +
+      " Are Still Encrypted With Older Passphrases/Keyfiles.\n\n" +
+      "r:RE-ENCRYPT    Re-encrypt Backups With the New Passphrase/Keyfile " +
+      "(Backups Made With Even Older Passphrases/Keyfiles are Skipped)\n\n" +
+      "d:DELETE        Overwrite and Delete All " + count + " Backups\n\n" +
+      "k:KEEP          Keep Backups As They Are"
+  } else {
+    options = "y:YES  n:NO"
+    contentString = "Are You Sure You Want to Overwrite and Delete All " +
+      count + " Backups of " + fPath + "?"
+  }
+}
+
+func rotateBackupsOptions(ev termbox.Event) {
+  var report []string
+  if entryData == "" {
+    if ev.Ch == 'r' {
+      report = reencryptBackups(backupBase(fPath), oldPassphrase,
+                                vlt.Passphrase())
+    } else if ev.Ch == 'd' {
+      entryData = "Delete"
+      return
+    } else if ev.Ch == 'k' {
+      report = []string{"Backups Were Kept As They Are"}
+    } else {
+      return
+    }
+  } else {
+    if ev.Ch == 'y' {
+      report = shredBackups(backupBase(fPath))
+    } else if ev.Ch == 'n' {
+      entryData = ""
+    }
+    if report == nil {
+      return
+    }
+  }
+  entryData = ""
+  oldPassphrase = ""
+  contentString = "Your Passphrase/Keyfile Was Successfully Changed!\n\n" +
+    strings.Join(report, "\n")
+  addToMenu("Main Menu")
+}
+
 /* IMPORT CSV FILE */
 func importSettings() {
   ctrlC = true
@@ -1650,26 +3038,13 @@ func importOptions(ev termbox.Event) {
     if _, err := os.Stat(value); err != nil {
       contentString = "File " + value + " Does Not Exist"
     } else {
-      namesBackup := names[:]
-      usernamesBackup := usernames[:]
-      passwordsBackup := passwords[:]
-      emailsBackup := emails[:]
-      urlsBackup := urls[:]
-      groupsBackup := groups[:]
-      commentsBackup := comments[:]
-      createdBackup := created[:]
-      modifiedBackup := modified[:]
+      entriesBackup := vlt.Entries
+      before := vlt.Snapshot()
       err := importCSV(value)
       if err != nil {
-        names = namesBackup[:]
-        usernames = usernamesBackup[:]
-        passwords = passwordsBackup[:]
-        emails = emailsBackup[:]
-        urls = urlsBackup[:]
-        groups = groupsBackup[:]
-        comments = commentsBackup[:]
-        created = createdBackup[:]
-        modified = modifiedBackup[:]
+        vlt.Entries = entriesBackup
+      } else {
+        addUndo("Import of " + value, before)
       }
       subtractFromMenu(1)
     }
@@ -1730,7 +3105,7 @@ func exportOptions(ev termbox.Event) {
         tmpPassphrase = value
         addToMenu("Keyfile")
       } else {
-        if value == passphrase {
+        if vlt.CheckPassphrase(value) {
           contentString = ""
           err := exportCSV(csvFile)
           if err != nil {
@@ -1749,6 +3124,290 @@ func exportOptions(ev termbox.Event) {
   }
 }
 
+/*
+ * Lists the backups of the open password file and tries to unlock each of
+ * them with the current passphrase/keyfile to count their entries.  Backups
+ * that can't be unlocked have an entry count of -1.
+ */
+func loadBackupList() {
+  backupList = listBackups(backupBase(fPath))
+  backupCounts = make([]int, 0)
+  backupTaken = false
+  for _, b := range backupList {
+    entries, err := openBackup(b.name, vlt.Passphrase())
+    if err != nil {
+      backupCounts = append(backupCounts, -1)
+    } else {
+      backupCounts = append(backupCounts, len(entries))
+    }
+  }
+}
+
+/* BACKUPS (first menu) */
+func backupsSettings() {
+  ctrlC = true
+  passwordInput = false
+  locationTitle = "BACKUPS"
+  if len(backupList) == 0 {
+    termbox.HideCursor()
+    options = "Ctrl-C:BACK"
+    bottomCaption = ""
+    contentString = "No Backups of " + fPath + " in " + backupDir
+    return
+  }
+  options = "Enter:CONFIRM"
+  bottomCaption = "Input Backup Number: "
+  contentString = ""
+  for x, b := range backupList {
+    count := "Locked"
+    if backupCounts[x] >= 0 {
+      count = strconv.Itoa(backupCounts[x]) + " Entries"
+    }
+    contentString += "[" + strconv.Itoa(x + 1) + "] " +
+      b.made.Format(timeLayout) + "  " + humanSize(b.size) + "  " + count +
+      "\n"
+  }
+  contentString = contentString[:len(contentString) - 1]
+  if contentExtra != "" {
+    contentString += "\n\n" + contentExtra
+  }
+  termbox.SetCursor(len(bottomCaption) + edit_box.CursorX(), h - 1)
+}
+
+func backupsOptions(ev termbox.Event) {
+  var valueEntered bool
+  if len(backupList) == 0 {
+    return
+  }
+  if ev.Key == termbox.KeyEnter {
+    value = string(edit_box.text)
+    valueEntered = true
+    edit_box.text = make([]byte, 0)
+    edit_box.MoveCursorTo(0)
+  } else {
+    textEdit(ev)
+  }
+  if valueEntered {
+    intVal, err := strconv.Atoi(value)
+    if err == nil && intVal > 0 && intVal <= len(backupList) {
+      contentExtra = ""
+      backupNumber = intVal
+      entryData = ""
+      entries, err := openBackup(backupList[intVal - 1].name,
+                                 vlt.Passphrase())
+      if err == nil {
+        backupEntries = vault.Sort(entries)
+        addToMenu("Backup Content")
+      } else {
+        omit = true
+        contentString = "This Backup Can't be Unlocked With the Current " +
+          "Passphrase/Keyfile"
+        addToMenu("Backup Passphrase")
+      }
+    }
+  }
+}
+
+/* UNLOCK BACKUP (for backups made with another passphrase/keyfile) */
+func backupPassphraseSettings() {
+  ctrlC = true
+  passwordInput = true
+  locationTitle = "UNLOCK BACKUP " + backupList[backupNumber - 1].name
+  bottomCaption = "Input Backup Passphrase: "
+  options = "Enter:CONFIRM  Ctrl-T:"
+  if omit {
+    options += "INCLUDE"
+  } else {
+    options += "OMIT"
+  }
+  options += " KEYFILE"
+  termbox.SetCursor(len(bottomCaption) + edit_box.CursorX(), h - 1)
+}
+
+func backupPassphraseOptions(ev termbox.Event) {
+  var valueEntered bool
+  if ev.Key == termbox.KeyEnter {
+    value = string(edit_box.text)
+    valueEntered = true
+    edit_box.text = make([]byte, 0)
+    edit_box.MoveCursorTo(0)
+  } else if ev.Key == termbox.KeyCtrlT {
+    if omit {
+      omit = false
+    } else {
+      omit = true
+    }
+  } else {
+    textEdit(ev)
+  }
+  if valueEntered {
+    if !omit {
+      contentString = ""
+      tmpPassphrase = value
+      addToMenu("Keyfile")
+    } else {
+      unlockBackup(value)
+    }
+  }
+}
+
+/*
+ * Opens the chosen backup with pass and goes to the BACKUP menu if it was
+ * unlocked.
+ */
+func unlockBackup(pass string) {
+  entries, err := openBackup(backupList[backupNumber - 1].name, pass)
+  if err != nil {
+    contentString = err.Error()
+    return
+  }
+  contentString = ""
+  backupEntries = vault.Sort(entries)
+  backupCounts[backupNumber - 1] = len(entries)
+  for menu != "Backups" {
+    subtractFromMenu(1)
+  }
+  addToMenu("Backup Content")
+}
+
+/* BACKUPS (second menu) */
+func backupContentSettings() {
+  ctrlC = true
+  b := backupList[backupNumber - 1]
+  locationTitle = "BACKUP " + b.made.Format(timeLayout)
+  bottomCaption = ""
+  passwordInput = false
+  if entryData == "" {
+    termbox.HideCursor()
+    options = "r:RESTORE FILE  e:RESTORE ENTRY"
+    if readOnly {
+      options = "Ctrl-C:BACK"
+    }
+    contentString = ""
+    if contentExtra != "" {
+      contentString = contentExtra + "\n\n"
+    }
+    contentString += "Backup: " + backupDir + b.name + "\n" +
+      "Size: " + humanSize(b.size) + "\n" +
+      "Entries: " + strconv.Itoa(len(backupEntries)) + "\n"
+    for x, e := range backupEntries {
+      contentString += "\n[" + strconv.Itoa(x + 1) + "] " + e.NameGroup()
+    }
+  } else if entryData == "File" {
+    termbox.HideCursor()
+    options = "y:YES  n:NO"
+    contentString = "Are You Sure You Want to Replace All " +
+      strconv.Itoa(len(vlt.Entries)) + " Entries of " + fPath + " With the " +
+      strconv.Itoa(len(backupEntries)) + " Entries of This Backup?  A " +
+      "Backup of the Password File Will be Made First."
+  } else {
+    options = "Enter:CONFIRM"
+    bottomCaption = "Input Entry Number: "
+    contentString = ""
+    for x, e := range backupEntries {
+      contentString += "[" + strconv.Itoa(x + 1) + "] " + e.NameGroup() +
+        "\n"
+    }
+    contentString += "\nEntries With the Same Name/Group Combination as " +
+      "the Restored Entry Will be Replaced.  A Backup of the Password " +
+      "File Will be Made First."
+    if contentExtra != "" {
+      contentString += "\n\n" + contentExtra
+    }
+    termbox.SetCursor(len(bottomCaption) + edit_box.CursorX(), h - 1)
+  }
+}
+
+func backupContentOptions(ev termbox.Event) {
+  if readOnly {
+    return
+  }
+  if entryData == "" {
+    if ev.Ch == 'r' {
+      contentExtra = ""
+      entryData = "File"
+    } else if ev.Ch == 'e' && len(backupEntries) > 0 {
+      contentExtra = ""
+      entryData = "Entry"
+    }
+  } else if entryData == "File" {
+    if ev.Ch == 'y' {
+      entryData = ""
+      if !takeRestoreBackup() {
+        return
+      }
+      var entries []*vault.Entry
+      for _, e := range backupEntries {
+        restored := *e
+        entries = append(entries, &restored)
+      }
+      recordUndo("Restore From Backup")
+      vlt.Entries = entries
+      err := writeData()
+      if err != nil {
+        contentString = "Unable to Modify Password File (Write Error)"
+      } else {
+        contentString = "Password File Restored from Backup " +
+          backupList[backupNumber - 1].name
+      }
+      subtractFromMenu(2)
+    } else if ev.Ch == 'n' {
+      entryData = ""
+      contentExtra = "Password File Was NOT Restored"
+    }
+  } else {
+    var valueEntered bool
+    if ev.Key == termbox.KeyEnter {
+      value = string(edit_box.text)
+      valueEntered = true
+      edit_box.text = make([]byte, 0)
+      edit_box.MoveCursorTo(0)
+    } else {
+      textEdit(ev)
+    }
+    if valueEntered {
+      intVal, err := strconv.Atoi(value)
+      if err != nil || intVal < 1 || intVal > len(backupEntries) {
+        contentExtra = "Entry Number Must be Between 1 and " +
+          strconv.Itoa(len(backupEntries))
+        return
+      }
+      contentExtra = ""
+      entryData = ""
+      if !takeRestoreBackup() {
+        return
+      }
+      e := backupEntries[intVal - 1]
+      replaced, err := restoreEntry(e)
+      if err != nil {
+        contentExtra = "Unable to Modify Password File (Write Error)"
+      } else if replaced {
+        contentExtra = e.NameGroup() + " Was Replaced With the Backup Entry"
+      } else {
+        contentExtra = e.NameGroup() + " Was Restored from the Backup"
+      }
+    }
+  }
+}
+
+/*
+ * Makes a backup of the password file before the first restore since the
+ * BACKUPS menu was opened.  Returns false and sets an error message if the
+ * backup couldn't be made.
+ */
+func takeRestoreBackup() bool {
+  if backupTaken {
+    return true
+  }
+  if err := backupNow(); err != nil {
+    contentExtra = "Unable to Back Up Password File Before Restoring " +
+      "(Nothing Was Restored)"
+    return false
+  }
+  backupTaken = true
+  return true
+}
+
 /* MORE OPTIONS */
 func optionsSettings() {
   ctrlC = true
@@ -1757,14 +3416,30 @@ func optionsSettings() {
   options = "Ctrl-C:BACK"
   bottomCaption = ""
   contentString = "c:COPY          Copy Value of Entry\n\n" +
-    "v:VIEW          View Values of Entry\n\n" +
-    "n:NEW           Create a New Entry\n\n" +
-    "d:DELETE        Delete Entry\n\n" +
-    "e:EDIT          Edit Value of Entry\n\n" +
-    "p:PASSPHRASE    Change Passphrase/Keyfile of Password File\n\n" +
-    "i:IMPORT        Import Entries from .CSV File\n\n" +
-    "x:EXPORT        Export Entries to a .CSV File\n\n" +
-    "l:LOCK          Lock Password File"
+    "v:VIEW          View Values of Entry\n\n"
+  if !readOnly {
+    contentString += "n:NEW           Create a New Entry\n\n" +
+      "d:DELETE        Delete Entry\n\n" +
+      "e:EDIT          Edit Value of Entry\n\n" +
+      "p:PASSPHRASE    Change Passphrase/Keyfile of Password File\n\n" +
+      "h:HISTORY       Purge Password History of Every Entry\n\n" +
+      "i:IMPORT        Import Entries from .CSV File\n\n" +
+      "u:UNDO          Undo the Last Change to the Password File\n\n" +
+      "Ctrl-R:REDO     Redo the Last Change That Was Undone\n\n"
+  }
+  contentString += "x:EXPORT        Export Entries to a .CSV File\n\n" +
+    "b:BACKUPS       View and Restore Backups of Password File\n\n" +
+    "/:SEARCH        Search Entries (and the Trash)\n\n" +
+    "f:FILTER        List Only Entries With a Tag or Favorites\n\n" +
+    "a:AUDIT         Find Reused, Weak and Old Passwords and Other " +
+    "Problems\n\n" +
+    "t:TRASH         View, Restore and Purge Deleted Entries\n\n" +
+    "o:OPEN          Open Another Password File in a New Tab\n\n" +
+    "r:READ-ONLY     Open Another Password File Read-Only in a New Tab\n\n" +
+    "m:TRANSFER      Copy or Move Entry to Another Open Password " +
+    "File\n\n" +
+    "Tab:NEXT        Switch to the Next Open Password File\n\n" +
+    "l:LOCK          Lock Password File (Closes Its Tab)"
 }
 
 func fill(x, y, w, h int, cell termbox.Cell) {
@@ -1964,6 +3639,14 @@ func cli() {
   }
   defer termbox.Close()
   termbox.SetInputMode(termbox.InputEsc & termbox.InputAlt)
+  /* Redraw every second while a TOTP code is shown so it stays current. */
+  go func() {
+    for range time.Tick(time.Second) {
+      if atomic.LoadInt32(&otpTicking) == 1 {
+        termbox.Interrupt()
+      }
+    }
+  }()
   event_queue := make(chan termbox.Event)
   go func() {
     for {
@@ -1973,6 +3656,7 @@ func cli() {
 loop:
   for {
     value = ""
+    atomic.StoreInt32(&otpTicking, 0)
     /* Used for drawing menus. */
     if menu == "Welcome" {
       welcomeSettings()
@@ -1992,10 +3676,36 @@ loop:
       copyESettings()
     } else if menu == "Copy Content" {
       copyContentSettings()
+    } else if menu == "Transfer" {
+      transferESettings()
+    } else if menu == "Transfer Content" {
+      transferContentSettings()
     } else if menu == "View" {
       viewESettings()
     } else if menu == "View Content" {
       viewContentSettings()
+    } else if menu == "Password History" {
+      historySettings()
+    } else if menu == "Rotate Password" {
+      rotateSettings()
+    } else if menu == "Generate Words" {
+      wordsSettings()
+    } else if menu == "Generator Rules" {
+      rulesSettings()
+    } else if menu == "Audit" {
+      auditSettings()
+    } else if menu == "Purge History" {
+      purgeHistorySettings()
+    } else if menu == "Trash" {
+      trashSettings()
+    } else if menu == "Trash Content" {
+      trashContentSettings()
+    } else if menu == "Search" {
+      searchSettings()
+    } else if menu == "Filter" {
+      filterSettings()
+    } else if menu == "New Type" {
+      newTypeSettings()
     } else if menu == "New" {
       newESettings()
     } else if menu == "Delete" {
@@ -2014,6 +3724,16 @@ loop:
       exportSettings()
     } else if menu == "Passphrase" {
       passphraseSettings()
+    } else if menu == "Backups" {
+      backupsSettings()
+    } else if menu == "Backup Passphrase" {
+      backupPassphraseSettings()
+    } else if menu == "Backup Content" {
+      backupContentSettings()
+    } else if menu == "Salvage" {
+      salvageSettings()
+    } else if menu == "Rotate Backups" {
+      rotateBackupsSettings()
     } else if menu == "Options" {
       optionsSettings()
     }
@@ -2030,20 +3750,35 @@ loop:
        * the main body of the termbox instance.
        */
       case termbox.KeyCtrlC:
-        if ctrlC {
+        if menu == "Generate Words" {
+          /* Back to the question the passphrase was generated for. */
+          endWords()
+        } else if menu == "Generator Rules" {
+          /* Back to the question the password was generated for. */
+          endRules()
+        } else if menu == "Salvage" {
+          leaveSalvage()
+        } else if ctrlC {
           tmpPassphrase = ""
+          breachWarned = ""
           omit = true
           passChars = make([]bool, 0)
+          newGenerator = nil
           newValue = make([]string, 0)
           passLen = 0
           contentString = ""
-          nameGroupsList := nameGroups()
-          if menu == "Copy Content" {
+          if menu == "Copy Content" ||
+              (menu == "Password History" && contentCopied) {
             contentCopied = false
             clipboard.WriteAll("")
+          } else if menu == "Rotate Password" {
+            if contentCopied {
+              contentCopied = false
+              clipboard.WriteAll("")
+            }
+            endRotation()
           } else if menu == "Delete Content" {
-            contentString = nameGroupsList[entryNumber - 1] +
-              " Was NOT Deleted"
+            contentString = selectedEntry().NameGroup() + " Was NOT Deleted"
           } else if menu == "Export" {
             csvFile = ""
             contentString = "Content was NOT Exported!"
@@ -2052,6 +3787,9 @@ loop:
               " Changed!"
           }
           subtractFromMenu(1)
+          if menu == "Main Menu" && openingTab {
+            cancelOpenTab()
+          }
           if menu == "Secure Password" || menu == "Passphrase" {
             if menu == "Passphrase" {
               contentString = "Your Passphrase/Keyfile Was" +
@@ -2096,10 +3834,36 @@ loop:
           copyEOptions(ev)
         } else if menu == "Copy Content" {
           copyContentOptions(ev)
+        } else if menu == "Transfer" {
+          transferEOptions(ev)
+        } else if menu == "Transfer Content" {
+          transferContentOptions(ev)
         } else if menu == "View" {
           viewEOptions(ev)
         } else if menu == "View Content" {
           viewContentOptions(ev)
+        } else if menu == "Password History" {
+          historyOptions(ev)
+        } else if menu == "Rotate Password" {
+          rotateOptions(ev)
+        } else if menu == "Generate Words" {
+          wordsOptions(ev)
+        } else if menu == "Generator Rules" {
+          rulesOptions(ev)
+        } else if menu == "Audit" {
+          auditOptions(ev)
+        } else if menu == "Purge History" {
+          purgeHistoryOptions(ev)
+        } else if menu == "Trash" {
+          trashOptions(ev)
+        } else if menu == "Trash Content" {
+          trashContentOptions(ev)
+        } else if menu == "Search" {
+          searchOptions(ev)
+        } else if menu == "Filter" {
+          filterOptions(ev)
+        } else if menu == "New Type" {
+          newTypeOptions(ev)
         } else if menu == "New" {
           newEOptions(ev)
         } else if menu == "Delete" {
@@ -2118,6 +3882,16 @@ loop:
           cPassphraseOptions(ev)
         } else if menu == "Passphrase" {
           passphraseOptions(ev)
+        } else if menu == "Salvage" {
+          salvageOptions(ev)
+        } else if menu == "Rotate Backups" {
+          rotateBackupsOptions(ev)
+        } else if menu == "Backups" {
+          backupsOptions(ev)
+        } else if menu == "Backup Passphrase" {
+          backupPassphraseOptions(ev)
+        } else if menu == "Backup Content" {
+          backupContentOptions(ev)
         }
       }
     }
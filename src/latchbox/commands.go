@@ -0,0 +1,224 @@
+/*-
+ * Copyright (C) 2014-2017, Vi Grey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY AUTHOR AND CONTRIBUTORS "AS IS" AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL AUTHOR OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+/*
+ * Handles commands that are run straight from the command line without
+ * drawing the termbox instance, along with prompting for the passphrase and
+ * keyfile of the password files those commands work on.
+ */
+
+package main
+
+import (
+  "bufio"
+  "errors"
+  "fmt"
+  "latchbox/vault"
+  "os"
+  "os/exec"
+  "strings"
+)
+
+/* Every command has a run function that returns the exit status. */
+var commands = map[string]func([]string) int{
+  "attach": attachCommand,
+  "audit": auditCommand,
+  "attachments": attachmentsCommand,
+  "detach": detachCommand,
+  "diff": diffCommand,
+  "expiring": expiringCommand,
+  "extract": extractCommand,
+  "fsck": fsckCommand,
+  "gen": genCommand,
+  "ls": lsCommand,
+  "otp": otpCommand,
+  "verify-backups": verifyBackupsCommand,
+}
+
+var stdinReader = bufio.NewReader(os.Stdin)
+
+/* Returns true if name is the name of a command. */
+func isCommand(name string) bool {
+  _, ok := commands[name]
+  return ok
+}
+
+/* Runs command name with args and exits with the status of the command. */
+func runCommand(name string, args []string) {
+  os.Exit(commands[name](args))
+}
+
+/*
+ * Splits the command line arguments args of command into operands and
+ * options.  Options in boolOpts take no value and options in valueOpts take
+ * the next argument as their value.  Prints an error and exits if an option
+ * is unrecognized or is missing its value.
+ */
+func commandArgs(command string, args, boolOpts, valueOpts []string) (
+    map[string]string, []string) {
+  opts := make(map[string]string)
+  var operands []string
+  for x := 0; x < len(args); x++ {
+    arg := args[x]
+    if arg == "--" {
+      operands = append(operands, args[x + 1:]...)
+      break
+    }
+    if len(arg) < 3 || strings.Index(arg, "--") != 0 {
+      operands = append(operands, arg)
+      continue
+    }
+    optName := arg[2:]
+    optValue := ""
+    hasValue := false
+    if eq := strings.Index(optName, "="); eq > -1 {
+      optName, optValue, hasValue = optName[:eq], optName[eq + 1:], true
+    }
+    if inList(boolOpts, optName) && !hasValue {
+      opts[optName] = "true"
+    } else if inList(valueOpts, optName) {
+      if !hasValue {
+        if x + 1 >= len(args) {
+          fmt.Printf("latchbox %s: option '--%s' requires an argument\n" +
+                     "Try 'latchbox --help' for more information.\n",
+                     command, optName)
+          os.Exit(2)
+        }
+        x++
+        optValue = args[x]
+      }
+      opts[optName] = optValue
+    } else {
+      fmt.Printf("latchbox %s: unrecognized option '%s'\nTry 'latchbox " +
+                 "--help' for more information.\n", command, arg)
+      os.Exit(2)
+    }
+  }
+  return opts, operands
+}
+
+/* Checks if the string s is one of the values in list. */
+func inList(list []string, s string) bool {
+  for x := range list {
+    if list[x] == s {
+      return true
+    }
+  }
+  return false
+}
+
+/*
+ * Returns the password file path for command, which is file if it was given
+ * or defaultPasswordFile in the config file if it wasn't.  Prints an error
+ * and returns false if neither is set.  The config file has to be parsed
+ * first.
+ */
+func commandFile(command, file string) (string, bool) {
+  if file == "" {
+    file = defaultFile
+  }
+  if file == "" {
+    fmt.Fprintf(os.Stderr, "latchbox %s: No FILE Given and No " +
+                "defaultPasswordFile in the Config File\n", command)
+    return "", false
+  }
+  tildeHome(&file)
+  return file, true
+}
+
+/*
+ * Prints prompt to stderr and reads a line from stdin.  If hide is true and
+ * stdin is a terminal, the line is not echoed while it is typed.
+ */
+func promptLine(prompt string, hide bool) (string, error) {
+  fmt.Fprint(os.Stderr, prompt)
+  var hidden bool
+  if hide {
+    stty := exec.Command("stty", "-echo")
+    stty.Stdin = os.Stdin
+    hidden = stty.Run() == nil
+  }
+  line, err := stdinReader.ReadString('\n')
+  if hidden {
+    stty := exec.Command("stty", "echo")
+    stty.Stdin = os.Stdin
+    stty.Run()
+    fmt.Fprintln(os.Stderr)
+  }
+  if err != nil && line == "" {
+    return "", err
+  }
+  return strings.TrimRight(line, "\r\n"), nil
+}
+
+/*
+ * Prompts for the passphrase and optional keyfile of the password file in
+ * path and returns the resulting passphrase, which is the HMAC-SHA512 of the
+ * passphrase if a keyfile was given.
+ */
+func promptPassphrase(path string) (string, error) {
+  pass, err := promptLine("Input Passphrase for " + path + ": ", true)
+  if err != nil {
+    return "", err
+  }
+  keyfilePath, err := promptLine("Path to Keyfile (Empty for None): ", false)
+  if err != nil {
+    return "", err
+  }
+  if keyfilePath != "" {
+    keyfileContent, err := addKeyFile(keyfilePath)
+    if err != nil {
+      return "", errors.New("Cannot Open Keyfile " + keyfilePath)
+    }
+    pass = vault.KeyfilePassphrase(pass, keyfileContent)
+  }
+  return pass, nil
+}
+
+/*
+ * Opens the password file in path and prompts for its passphrase and keyfile
+ * to unlock it.  If the password file decrypted but couldn't be parsed, it
+ * is returned along with vault.ErrCorrupted so it can still be checked or
+ * salvaged.
+ */
+func unlockPrompted(path string) (*vault.Vault, error) {
+  v, err := vault.Open(path)
+  if err != nil {
+    return nil, err
+  }
+  pass, err := promptPassphrase(path)
+  if err != nil {
+    return nil, err
+  }
+  err = v.Unlock(pass)
+  if err == vault.ErrCorrupted {
+    return v, err
+  } else if err != nil {
+    return nil, err
+  }
+  return v, nil
+}
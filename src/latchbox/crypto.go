@@ -28,179 +28,206 @@
 package main
 
 import (
-  "golang.org/x/crypto/pbkdf2"
-  "golang.org/x/crypto/chacha20poly1305"
-  "crypto/aes"
-  "crypto/cipher"
-  "crypto/hmac"
   "crypto/rand"
-  "crypto/sha256"
-  "crypto/sha512"
+  "errors"
+  "latchbox/vault"
+  "math"
   "math/big"
   "strconv"
+  "strings"
 )
 
-/*
- * Generates a key from auth using HMAC-SHA256 based PBKDF2 with iter number of
- * iterations
- */
-func generatePBKDF2Key(auth, salt []byte, iter uint32) []byte {
-  return pbkdf2.Key(auth, salt, int(iter), 32, sha256.New)
+// Get a random int between 0 and number
+func getRandNumber(number int64) int {
+  randNumber, _ := rand.Int(rand.Reader, big.NewInt(number))
+  randString := randNumber.String()
+  randInt, _ := strconv.Atoi(randString)
+  return randInt
 }
 
-// Encrypts plaintext using key and ciph and returns the ciphertext
-func encrypt(message, key []byte, ciph int) []byte {
-  /*
-   * Add one to nonce, roll value over to 0 if the uint64 value hits
-   * 2 ** 64 - 1
-   */
-  nonce = nonce + 1 % 18446744073709551615
-  // Pad nonce value with 4 random bytes to create the iv value
-  iv := append(numToBytes(nonce, 8), randByteArray(4)...)
-  var mode cipher.AEAD
-  if ciph == AES256GCM {
-    block, err := aes.NewCipher(key)
-    if err != nil {
-      panic(err)
-    }
-    mode, err = cipher.NewGCM(block)
-    if err != nil {
-      panic(err)
+/* Times a password is generated before its rules are given up on. */
+const maxGenerateTries = 1000
+
+/* Characters that are easy to mistake for each other. */
+const lookAlikes = "0O1lI"
+
+/*
+ * Generate a random password with the settings and rules of g.  The
+ * password has at least as many characters of each allowed kind as the
+ * minimums of g.  If g allows no kind of character, a password will be
+ * generated with only lowercase characters.  Returns an error if the rules
+ * of g can't all be followed.
+ */
+func genPass(g *vault.Generator) (string, error) {
+  chars := generatorChars(g)
+  pool := strings.Join(chars, "")
+  minimums := 0
+  for x := range chars {
+    if chars[x] != "" {
+      minimums += int(g.Minimums[x])
     }
-  } else if ciph == CHACHA20POLY1305 {
-    var err error
-    mode, err = chacha20poly1305.New(key)
-    if err != nil {
-      panic(err)
+  }
+  if minimums > int(g.Length) {
+    return "", errors.New("Minimums Add Up to More Than the Password Length")
+  }
+  first := firstChars(g, chars)
+  if g.First != vault.FirstAny && first == "" {
+    return "", errors.New("The First Character Must be of an Allowed Kind")
+  }
+  for x := 0; x < maxGenerateTries; x++ {
+    if password := genTry(g, chars, pool, first); password != nil {
+      return string(password), nil
     }
-  } else {
-    panic("Invalid Cipher")
   }
-  additionalData := []byte("LatchBox")
-  ciphertext := mode.Seal(nil, iv, message, additionalData)
-  return append(iv, ciphertext...)
+  return "", errors.New("Unable to Generate a Password Following the Rules")
 }
 
 /*
- * Decrypts ciphertext using key and ciph and returns the plaintext and
- * whether or not the content was decrypted
+ * Makes one attempt at generating a password with g, picking the kind of
+ * character of every position before the characters themselves.  chars
+ * are the characters of each kind g allows, pool is all of them and first
+ * is the characters the password can start with ("" for any).  Returns nil
+ * if the picked kinds leave no character that follows the rules of g.
  */
-func decrypt(ciphertext, key []byte, ciph int, old bool) ([]byte, bool) {
-  if len(ciphertext) < 12 {
-    return nil, false
+func genTry(g *vault.Generator, chars []string, pool,
+            first string) []byte {
+  var kinds []int
+  for x := range chars {
+    for y := 0; chars[x] != "" && y < int(g.Minimums[x]); y++ {
+      kinds = append(kinds, x)
+    }
   }
-  iv := ciphertext[:12]
-  nonce = bytesToNum(iv[:8])
-  ct := ciphertext[12:]
-  var mode cipher.AEAD
-  if ciph == AES256GCM {
-    block, err := aes.NewCipher(key)
-    if err != nil {
-      panic(err)
+  for len(kinds) < int(g.Length) {
+    /* Kinds with more characters are picked more often. */
+    position := getRandNumber(int64(len(pool)))
+    kind := 0
+    for position >= len(chars[kind]) {
+      position -= len(chars[kind])
+      kind++
     }
-    mode, err = cipher.NewGCM(block)
-    if err != nil {
-      panic(err)
+    kinds = append(kinds, kind)
+  }
+  for x := len(kinds) - 1; x > 0; x-- {
+    y := getRandNumber(int64(x + 1))
+    kinds[x], kinds[y] = kinds[y], kinds[x]
+  }
+  if first != "" {
+    var starts []int
+    for x := range kinds {
+      if onlyChars(chars[kinds[x]], first) != "" {
+        starts = append(starts, x)
+      }
     }
-  } else if ciph == CHACHA20POLY1305 {
-    var err error
-    mode, err = chacha20poly1305.New(key)
-    if err != nil {
-      panic(err)
+    if len(starts) == 0 {
+      return nil
     }
-  } else {
-    panic("Invalid Cipher")
+    x := starts[getRandNumber(int64(len(starts)))]
+    kinds[0], kinds[x] = kinds[x], kinds[0]
   }
-  var additionalData []byte = nil
-  if !old {
-    additionalData = []byte("LatchBox")
-  }
-  plaintext, err := mode.Open(nil, iv, ct, additionalData)
-  mode = nil
-  if err != nil {
-    return nil, false
+  password := make([]byte, len(kinds))
+  for x := range kinds {
+    options := chars[kinds[x]]
+    if x == 0 && first != "" {
+      options = onlyChars(options, first)
+    }
+    var allowed []byte
+    for y := 0; y < len(options); y++ {
+      if followsRules(g, password[:x], options[y]) {
+        allowed = append(allowed, options[y])
+      }
+    }
+    if len(allowed) == 0 {
+      return nil
+    }
+    password[x] = allowed[getRandNumber(int64(len(allowed)))]
   }
-  return plaintext, true
+  return password
 }
 
-// Generates a random byte array of size length
-func randByteArray(size int) []byte {
-  randValue := make([]byte, size)
-  if _, err := rand.Read(randValue); err != nil {
-    panic(err)
+/*
+ * Returns whether character c can come after the characters before
+ * without breaking the no repeats and no sequences rules of g.
+ */
+func followsRules(g *vault.Generator, before []byte, c byte) bool {
+  n := len(before)
+  if g.NoRepeats && n > 0 && before[n - 1] == c {
+    return false
   }
-  return randValue
-}
-
-// Get a random int between 0 and number
-func getRandNumber(number int64) int {
-  randNumber, _ := rand.Int(rand.Reader, big.NewInt(number))
-  randString := randNumber.String()
-  randInt, _ := strconv.Atoi(randString)
-  return randInt
+  if g.NoSequences && n > 1 {
+    step := int(c) - int(before[n - 1])
+    if (step == 1 || step == -1) &&
+        int(before[n - 1]) - int(before[n - 2]) == step {
+      return false
+    }
+  }
+  return true
 }
 
 /*
- * Generate a random password with criteria ([uppercase, lowercase, digits
- * punctuation] (ulds), and lenth (pLen)).  Needs at least 4 for the
- * generated password length.  If NO was used for all criteria in ulds, a
- * password will be generated with only lowercase characters.  The password
- * is guaranteed to have at least one of every type of character allowed
- * under ulds.
+ * Returns the characters of each kind g allows as [uppercase, lowercase,
+ * digits, punctuation], without the ones its rules leave out.  Kinds that
+ * aren't allowed are "".
  */
-func genPass (pLen uint16, ulds []bool) string {
-  var password []byte
-  var passwordString string
-  if ulds[0] {
-    passwordString += uppercase
-  }
-  if ulds[1] {
-    passwordString += lowercase
-  }
-  if ulds[2] {
-    passwordString += digits
+func generatorChars(g *vault.Generator) []string {
+  chars := []string{uppercase, lowercase, digits, punctuation}
+  classes := g.Classes()
+  if !classes[0] && !classes[1] && !classes[2] && !classes[3] {
+    classes[1] = true
   }
-  if ulds[3] {
-    passwordString += punctuation
-  }
-  if passwordString == "" {
-    passwordString += lowercase
-  }
-  for x := 0; uint16(x) < pLen; x++ {
-    letter := getRandNumber(int64(len(passwordString)))
-    password = append(password, passwordString[letter])
-  }
-  var randomValues []uint16
-  for x := 0; x < 4; x++ {
-    position := getRandNumber(int64(pLen))
-    var bad bool
-    for y := range randomValues {
-      if randomValues[y] == uint16(position) {
-        bad = true
-      }
+  for x := range chars {
+    if !classes[x] {
+      chars[x] = ""
+      continue
     }
-    if bad {
-      x--
-    } else {
-      randomValues = append(randomValues, uint16(position))
+    if x == 3 && g.Symbols != "" {
+      chars[x] = onlyChars(chars[x], g.Symbols)
     }
-  }
-  characters := []string{uppercase, lowercase, digits, punctuation}
-  for x := 0; x < 4; x++ {
-    if ulds[x] {
-      z := getRandNumber(int64(len(characters[x])))
-      password[randomValues[x]] = characters[x][z]
+    if g.NoLookAlikes {
+      chars[x] = strings.Map(func(r rune) rune {
+        if strings.ContainsRune(lookAlikes, r) {
+          return -1
+        }
+        return r
+      }, chars[x])
     }
   }
-  return string(password)
+  return chars
+}
+
+/*
+ * Returns the characters a password generated with g can start with, out
+ * of the characters of each kind chars, or "" if it can start with any.
+ */
+func firstChars(g *vault.Generator, chars []string) string {
+  if g.First == vault.FirstUppercase {
+    return chars[0]
+  } else if g.First == vault.FirstLowercase {
+    return chars[1]
+  } else if g.First == vault.FirstDigit {
+    return chars[2]
+  } else if g.First == vault.FirstPunctuation {
+    return chars[3]
+  } else if g.First == vault.FirstLetter {
+    return chars[0] + chars[1]
+  }
+  return ""
+}
+
+/* Returns the characters of s that are also in keep. */
+func onlyChars(s, keep string) string {
+  return strings.Map(func(r rune) rune {
+    if !strings.ContainsRune(keep, r) {
+      return -1
+    }
+    return r
+  }, s)
 }
 
 /*
- * Creates a new HMAC of message using key as the secret key with the hashing
- * algorithm SHA512
+ * Returns the entropy in bits of a password generated with g, which is at
+ * most the password length times the bits of one of its characters.
  */
-func newHMAC(message string, key []byte) string {
-  sig := hmac.New(sha512.New, key)
-  sig.Write([]byte(message))
-  return string(sig.Sum(nil))
+func genEntropy(g *vault.Generator) float64 {
+  return float64(g.Length) *
+    math.Log2(float64(len(strings.Join(generatorChars(g), ""))))
 }
@@ -0,0 +1,122 @@
+/*-
+ * Copyright (C) 2014-2017, Vi Grey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY AUTHOR AND CONTRIBUTORS "AS IS" AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL AUTHOR OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+/*
+ * Tests of generating passwords with generator settings and rules.
+ */
+
+package main
+
+import (
+  "latchbox/vault"
+  "strings"
+  "testing"
+)
+
+func TestDefaultGenerator(t *testing.T) {
+  if defaultGenerator.Minimums != [4]uint8{1, 1, 1, 1} {
+    t.Fatalf("default generator minimums = %v, want one of every kind",
+             defaultGenerator.Minimums)
+  }
+  kinds := []string{uppercase, lowercase, digits, punctuation}
+  for x := 0; x < 200; x++ {
+    password, err := genPass(&defaultGenerator)
+    if err != nil {
+      t.Fatal(err)
+    }
+    if len(password) != int(defaultGenerator.Length) {
+      t.Fatalf("%q is %d characters, want %d", password, len(password),
+               defaultGenerator.Length)
+    }
+    for _, kind := range kinds {
+      if !strings.ContainsAny(password, kind) {
+        t.Fatalf("%q has no character of %q", password, kind)
+      }
+    }
+  }
+}
+
+func TestGenPassRules(t *testing.T) {
+  g, err := parseRules("length=12 upper=2 lower digits=3 symbolSet=!#$ " +
+                       "noLookAlikes noRepeats noSequences first=letter")
+  if err != nil {
+    t.Fatal(err)
+  }
+  minimums := []struct {
+    chars string
+    count int
+  }{{uppercase, 2}, {lowercase, 1}, {digits, 3}, {"!#$", 1}}
+  for x := 0; x < 200; x++ {
+    password, err := genPass(&g)
+    if err != nil {
+      t.Fatal(err)
+    }
+    if len(password) != 12 {
+      t.Fatalf("%q is not 12 characters", password)
+    }
+    for _, m := range minimums {
+      count := 0
+      for _, c := range password {
+        if strings.ContainsRune(m.chars, c) {
+          count++
+        }
+      }
+      if count < m.count {
+        t.Fatalf("%q has %d of %q, want at least %d", password, count,
+                 m.chars, m.count)
+      }
+    }
+    for _, c := range password {
+      if strings.ContainsRune(lookAlikes, c) ||
+          (strings.ContainsRune(punctuation, c) &&
+           !strings.ContainsRune("!#$", c)) {
+        t.Fatalf("%q has a character the rules leave out", password)
+      }
+    }
+    if !strings.ContainsAny(password[:1], uppercase + lowercase) {
+      t.Fatalf("%q doesn't start with a letter", password)
+    }
+    for y := 1; y < len(password); y++ {
+      if password[y] == password[y - 1] {
+        t.Fatalf("%q repeats a character", password)
+      }
+      step := int(password[y]) - int(password[y - 1])
+      if y > 1 && (step == 1 || step == -1) &&
+          int(password[y - 1]) - int(password[y - 2]) == step {
+        t.Fatalf("%q has a sequence", password)
+      }
+    }
+  }
+}
+
+func TestGenPassImpossibleRules(t *testing.T) {
+  g := vault.Generator{Length: 6, Punctuation: true, Symbols: "!",
+                       Minimums: [4]uint8{0, 0, 0, 3}, NoRepeats: true}
+  if password, err := genPass(&g); err == nil {
+    t.Errorf("genPass = %q, want an error", password)
+  }
+}
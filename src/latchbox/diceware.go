@@ -0,0 +1,380 @@
+/*-
+ * Copyright (C) 2014-2017, Vi Grey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY AUTHOR AND CONTRIBUTORS "AS IS" AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL AUTHOR OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+/*
+ * Generates passphrases of random words, like "stark-gumball-deity-unmade",
+ * from the EFF wordlists or wordlists set in the config file.
+ */
+
+package main
+
+import (
+  "errors"
+  "fmt"
+  "github.com/nsf/termbox-go"
+  "io/ioutil"
+  "latchbox/strength"
+  "math"
+  "os"
+  "path/filepath"
+  "strconv"
+  "strings"
+  "time"
+  "unicode"
+  "unicode/utf8"
+)
+
+/* Most words a generated passphrase can have. */
+const maxWords = 20
+
+/* Separators that can be put between words, in the order they are cycled. */
+var wordSeparators = []string{"-", " ", ".", "_", ""}
+
+/* How passphrases of words are generated. */
+type wordsGenerator struct {
+  /* Number of words. */
+  count int
+  /* "long" or "short" for the EFF wordlists, or the path of a wordlist. */
+  list string
+  separator string
+  /* Whether the first letter of every word is uppercase. */
+  capitalize bool
+  /* Whether a random digit or symbol is added to the end of a random word. */
+  insert bool
+}
+
+var (
+  /* Paths of the wordlists set with wordlist in the config file. */
+  wordlistPaths []string
+  /* Settings of the GENERATE PASSPHRASE menu, kept until LatchBox quits. */
+  wordsGen = wordsGenerator{count: 6, list: "long", separator: "-"}
+  /*
+   * Passphrase shown in the GENERATE PASSPHRASE menu, its entropy and the
+   * number of words in the wordlist it was picked from.
+   */
+  wordsPassphrase string
+  wordsBits float64
+  wordsSize int
+)
+
+/*
+ * Returns the words of wordlist list, which is "long" or "short" for the
+ * EFF wordlists or the path of a wordlist file.
+ */
+func loadWordlist(list string) ([]string, error) {
+  if list == "long" {
+    return strings.Fields(effLongWordlist), nil
+  } else if list == "short" {
+    return strings.Fields(effShortWordlist), nil
+  }
+  return readWordlist(list)
+}
+
+/*
+ * Returns the different words of the wordlist file in path, which has one
+ * word on each line.  Anything before the word on a line, like the dice
+ * rolls of diceware wordlists, is left out.
+ */
+func readWordlist(path string) ([]string, error) {
+  tildeHome(&path)
+  content, err := ioutil.ReadFile(path)
+  if err != nil {
+    return nil, errors.New("Unable to Read Wordlist")
+  }
+  var words []string
+  found := make(map[string]bool)
+  for _, line := range strings.Split(string(content), "\n") {
+    fields := strings.Fields(line)
+    if len(fields) == 0 {
+      continue
+    }
+    word := fields[len(fields) - 1]
+    if !found[word] {
+      found[word] = true
+      words = append(words, word)
+    }
+  }
+  if len(words) < 2 {
+    return nil, errors.New("Wordlist Needs at Least 2 Different Words")
+  }
+  return words, nil
+}
+
+/* Returns the name of wordlist list shown in menus. */
+func wordlistName(list string) string {
+  if list == "long" {
+    return "EFF Long"
+  } else if list == "short" {
+    return "EFF Short"
+  }
+  return filepath.Base(list)
+}
+
+/* Returns a passphrase of g.count random words from words. */
+func (g wordsGenerator) generate(words []string) string {
+  chosen := make([]string, g.count)
+  for x := range chosen {
+    chosen[x] = words[getRandNumber(int64(len(words)))]
+    if g.capitalize {
+      r, size := utf8.DecodeRuneInString(chosen[x])
+      chosen[x] = string(unicode.ToUpper(r)) + chosen[x][size:]
+    }
+  }
+  if g.insert {
+    chars := digits + punctuation
+    x := getRandNumber(int64(len(chosen)))
+    chosen[x] += string(chars[getRandNumber(int64(len(chars)))])
+  }
+  return strings.Join(chosen, g.separator)
+}
+
+/*
+ * Returns the bits of entropy of passphrases g generates from a wordlist of
+ * size words.
+ */
+func (g wordsGenerator) entropy(size int) float64 {
+  bits := float64(g.count) * math.Log2(float64(size))
+  if g.insert {
+    bits += math.Log2(float64(len(digits + punctuation) * g.count))
+  }
+  return bits
+}
+
+/* Returns bits of entropy as text, like "About 77.5 Bits of Entropy". */
+func entropyString(bits float64) string {
+  return "About " + strconv.FormatFloat(bits, 'f', 1, 64) +
+    " Bits of Entropy"
+}
+
+/*
+ * Goes to the GENERATE PASSPHRASE menu to pick a passphrase of words for
+ * the menu it was opened from.
+ */
+func startWords() {
+  contentExtra = ""
+  newWords()
+  addToMenu("Generate Words")
+}
+
+/* Generates a new passphrase with the settings in wordsGen. */
+func newWords() {
+  words, err := loadWordlist(wordsGen.list)
+  if err != nil {
+    wordsPassphrase = ""
+    contentExtra = err.Error()
+    return
+  }
+  contentExtra = ""
+  wordsPassphrase = wordsGen.generate(words)
+  wordsBits = wordsGen.entropy(len(words))
+  wordsSize = len(words)
+}
+
+/* Leaves the GENERATE PASSPHRASE menu without using the passphrase. */
+func endWords() {
+  wordsPassphrase = ""
+  contentExtra = ""
+  subtractFromMenu(1)
+}
+
+/* GENERATE PASSPHRASE */
+func wordsSettings() {
+  ctrlC = true
+  passwordInput = false
+  termbox.HideCursor()
+  bottomCaption = ""
+  locationTitle = "GENERATE PASSPHRASE"
+  options = "Enter:USE  g:GENERATE  +:MORE WORDS  -:FEWER WORDS  " +
+    "l:WORDLIST  s:SEPARATOR  c:CAPITALIZE  d:DIGIT/SYMBOL"
+  capitalized, inserted := "No", "No"
+  if wordsGen.capitalize {
+    capitalized = "Yes"
+  }
+  if wordsGen.insert {
+    inserted = "Yes"
+  }
+  contentString = ""
+  if wordsPassphrase != "" {
+    contentString = "Passphrase: " + wordsPassphrase + "\n" +
+      entropyString(wordsBits) + "\n\n"
+  }
+  contentString += "Words: " + strconv.Itoa(wordsGen.count) + "\n" +
+    "Wordlist: " + wordlistName(wordsGen.list)
+  if wordsPassphrase != "" {
+    contentString += " (" + strconv.Itoa(wordsSize) + " Words)"
+  }
+  contentString += "\nSeparator: \"" + wordsGen.separator + "\"\n" +
+    "Capitalized: " + capitalized + "\n" +
+    "Digit or Symbol: " + inserted
+  from := menuList[len(menuList) - 2]
+  if from == "Secure Password" || from == "Passphrase" {
+    contentString += "\n\nWrite the Passphrase Down Before Using It, It " +
+      "Has to be Repeated Next"
+  }
+  if contentExtra != "" {
+    contentString += "\n\n" + contentExtra
+  }
+}
+
+func wordsOptions(ev termbox.Event) {
+  if ev.Key == termbox.KeyEnter {
+    if wordsPassphrase != "" {
+      useWords()
+    }
+    return
+  }
+  if ev.Ch == '+' {
+    if wordsGen.count < maxWords {
+      wordsGen.count++
+    }
+  } else if ev.Ch == '-' {
+    if wordsGen.count > 1 {
+      wordsGen.count--
+    }
+  } else if ev.Ch == 'l' {
+    lists := append([]string{"long", "short"}, wordlistPaths...)
+    for x := range lists {
+      if lists[x] == wordsGen.list {
+        wordsGen.list = lists[(x + 1) % len(lists)]
+        break
+      }
+    }
+  } else if ev.Ch == 's' {
+    for x := range wordSeparators {
+      if wordSeparators[x] == wordsGen.separator {
+        wordsGen.separator = wordSeparators[(x + 1) % len(wordSeparators)]
+        break
+      }
+    }
+  } else if ev.Ch == 'c' {
+    wordsGen.capitalize = !wordsGen.capitalize
+  } else if ev.Ch == 'd' {
+    wordsGen.insert = !wordsGen.insert
+  } else if ev.Ch != 'g' {
+    return
+  }
+  newWords()
+}
+
+/*
+ * Uses the generated passphrase for the new or edited entry or password
+ * file passphrase it was generated for.
+ */
+func useWords() {
+  phrase := wordsPassphrase
+  from := menuList[len(menuList) - 2]
+  if from == "Secure Password" || from == "Passphrase" {
+    /* Words missing from the dictionaries can make it look stronger. */
+    score := strength.Estimate(phrase, passphraseInputs(fPath)).Score
+    if s := strength.Score(math.Pow(2, wordsBits)); s < score {
+      score = s
+    }
+    if msg := weakScore(score); msg != "" {
+      contentExtra = msg
+      return
+    }
+  }
+  endWords()
+  if menu == "New" {
+    newValue = append(newValue, phrase)
+    step[2], step[10] = false, true
+  } else if menu == "Edit Content" {
+    e := selectedEntry()
+    e.ChangePassword(phrase, time.Now(), passwordHistoryDepth)
+    e.Generator = nil
+    contentString = "Password Changed"
+    step[0] = false
+    subtractFromMenu(1)
+    saveEdit(e)
+  } else if menu == "Secure Password" {
+    key1 = phrase
+    step[0] = false
+  } else if menu == "Passphrase" {
+    key1 = phrase
+    step[1] = false
+  }
+}
+
+/*
+ * Prints a generated password, or a passphrase of words with --words, and
+ * its entropy.  Passwords are generated with the rules of --profile or
+ * --rules if one is set.
+ */
+func genCommand(args []string) int {
+  opts, operands := commandArgs("gen", args, []string{"capitalize", "digit"},
+                                []string{"words", "wordlist", "separator",
+                                         "length", "profile", "rules"})
+  if len(operands) > 0 {
+    fmt.Printf("Usage: latchbox gen [ --length LENGTH ] [ --profile NAME ] " +
+               "[ --rules RULES ]\n" +
+               "                    [ --words COUNT ] [ --wordlist LIST ] " +
+               "[ --separator SEPARATOR ]\n" +
+               "                    [ --capitalize ] [ --digit ]\n")
+    return 2
+  }
+  makeConfig()
+  configParse()
+  if count, ok := opts["words"]; ok {
+    g := wordsGenerator{list: "long", separator: "-",
+                        capitalize: opts["capitalize"] != "",
+                        insert: opts["digit"] != ""}
+    var err error
+    if g.count, err = strconv.Atoi(count); err != nil || g.count < 1 ||
+        g.count > maxWords {
+      fmt.Fprintf(os.Stderr, "latchbox gen: %s: Word Count Must be " +
+                  "Between 1 and %d\n", count, maxWords)
+      return 2
+    }
+    if list, ok := opts["wordlist"]; ok {
+      g.list = list
+    }
+    if separator, ok := opts["separator"]; ok {
+      g.separator = separator
+    }
+    words, err := loadWordlist(g.list)
+    if err != nil {
+      fmt.Fprintf(os.Stderr, "latchbox gen: %s: %s\n", g.list, err)
+      return 2
+    }
+    fmt.Println(g.generate(words))
+    fmt.Fprintln(os.Stderr, entropyString(g.entropy(len(words))))
+    return 0
+  }
+  g, err := genOptions(opts)
+  if err != nil {
+    fmt.Fprintf(os.Stderr, "latchbox gen: %s\n", err)
+    return 2
+  }
+  password, err := genPass(&g)
+  if err != nil {
+    fmt.Fprintf(os.Stderr, "latchbox gen: %s\n", err)
+    return 1
+  }
+  fmt.Println(password)
+  fmt.Fprintln(os.Stderr, entropyString(genEntropy(&g)))
+  return 0
+}
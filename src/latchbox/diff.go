@@ -0,0 +1,305 @@
+/*-
+ * Copyright (C) 2014-2017, Vi Grey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY AUTHOR AND CONTRIBUTORS "AS IS" AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL AUTHOR OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+/*
+ * Compares the entries of two password files and lists the entries that
+ * were added, removed or changed between them.
+ */
+
+package main
+
+import (
+  "bytes"
+  "crypto/sha256"
+  "fmt"
+  "github.com/patrickmn/sortutil"
+  "latchbox/vault"
+  "os"
+  "strconv"
+  "strings"
+)
+
+/* Label of every entry value that is compared, in the order shown. */
+var diffLabels = []string{"Username", "Password", "Email", "URL", "Comment",
+                          "Tags", "Favorite", "Expires", "Created",
+                          "Modified"}
+
+/* A value that is different between two versions of an entry. */
+type diffChange struct {
+  label, oldValue, newValue string
+  secret bool
+}
+
+/* Returns the values of e in the same order as diffLabels. */
+func diffValues(e *vault.Entry) []string {
+  favorite := "No"
+  if e.Favorite {
+    favorite = "Yes"
+  }
+  var expires string
+  if !e.Expires.IsZero() {
+    expires = e.Expires.Format(dateLayout)
+  }
+  return []string{e.Username, e.Password, e.Email, e.URL, e.Comment,
+                  strings.Join(e.Tags, ", "), favorite, expires,
+                  e.Created.Format(timeLayout), e.Modified.Format(timeLayout)}
+}
+
+/*
+ * Returns the values that are different between oldE and newE, followed by
+ * the custom fields and attachments that were removed, added or changed
+ * and the OTP, recovery codes and password history if they changed.
+ * Passwords, OTPs and concealed custom fields are marked as secret.
+ */
+func diffChanges(oldE, newE *vault.Entry) []diffChange {
+  var changes []diffChange
+  oldValues, newValues := diffValues(oldE), diffValues(newE)
+  for x := range diffLabels {
+    if oldValues[x] != newValues[x] {
+      changes = append(changes, diffChange{diffLabels[x], oldValues[x],
+                                           newValues[x],
+                                           diffLabels[x] == "Password"})
+    }
+  }
+  for _, f := range oldE.Fields {
+    newF := newE.Field(f.Name)
+    if newF == nil {
+      changes = append(changes, diffChange{"Field " + f.Name, f.Value, "",
+                                           f.Concealed})
+    } else if *newF != f {
+      changes = append(changes, diffChange{"Field " + f.Name, f.Value,
+                                           newF.Value,
+                                           f.Concealed || newF.Concealed})
+    }
+  }
+  for _, f := range newE.Fields {
+    if oldE.Field(f.Name) == nil {
+      changes = append(changes, diffChange{"Field " + f.Name, "", f.Value,
+                                           f.Concealed})
+    }
+  }
+  for _, a := range oldE.Attachments {
+    newA := newE.Attachment(a.Name)
+    if newA == nil {
+      changes = append(changes, diffChange{"Attachment " + a.Name,
+                                           attachmentSummary(a), "", false})
+    } else if !bytes.Equal(newA.Data, a.Data) {
+      changes = append(changes, diffChange{"Attachment " + a.Name,
+                                           attachmentSummary(a),
+                                           attachmentSummary(*newA), false})
+    }
+  }
+  for _, a := range newE.Attachments {
+    if oldE.Attachment(a.Name) == nil {
+      changes = append(changes, diffChange{"Attachment " + a.Name, "",
+                                           attachmentSummary(a), false})
+    }
+  }
+  if oldURI, newURI := otpURI(oldE), otpURI(newE); oldURI != newURI {
+    changes = append(changes, diffChange{"OTP", oldURI, newURI, true})
+  }
+  if !sameRecoveryCodes(oldE.RecoveryCodes, newE.RecoveryCodes) {
+    changes = append(changes, diffChange{"Recovery Codes",
+                                         recoverySummary(oldE),
+                                         recoverySummary(newE), false})
+  }
+  if !sameHistory(oldE.History, newE.History) {
+    changes = append(changes, diffChange{"Password History",
+                                         historySummary(oldE.History),
+                                         historySummary(newE.History),
+                                         false})
+  }
+  return changes
+}
+
+/* Returns the otpauth:// URI of the OTP of e or "" if e has no OTP. */
+func otpURI(e *vault.Entry) string {
+  if e.OTP == nil {
+    return ""
+  }
+  return e.OTP.URI()
+}
+
+/* Checks if a and b have the same recovery codes used at the same times. */
+func sameRecoveryCodes(a, b []vault.RecoveryCode) bool {
+  if len(a) != len(b) {
+    return false
+  }
+  for x := range a {
+    if a[x].Code != b[x].Code || !a[x].Used.Equal(b[x].Used) {
+      return false
+    }
+  }
+  return true
+}
+
+/*
+ * Returns how many of the recovery codes of e are left, or "" if e has no
+ * recovery codes, so the codes themselves aren't printed.
+ */
+func recoverySummary(e *vault.Entry) string {
+  if len(e.RecoveryCodes) == 0 {
+    return ""
+  }
+  return fmt.Sprintf("%d of %d Left", e.RecoveryCodesLeft(),
+                     len(e.RecoveryCodes))
+}
+
+/* Checks if a and b have the same previous passwords in the same order. */
+func sameHistory(a, b []vault.OldPassword) bool {
+  if len(a) != len(b) {
+    return false
+  }
+  for x := range a {
+    if a[x].Password != b[x].Password || !a[x].Replaced.Equal(b[x].Replaced) {
+      return false
+    }
+  }
+  return true
+}
+
+/* Returns how many previous passwords are in history. */
+func historySummary(history []vault.OldPassword) string {
+  return strconv.Itoa(len(history)) + " Old Passwords"
+}
+
+/*
+ * Returns the size and the start of the SHA-256 checksum of attachment a so
+ * attachments can be compared without printing their content.
+ */
+func attachmentSummary(a vault.Attachment) string {
+  sum := sha256.Sum256(a.Data)
+  return fmt.Sprintf("%s, SHA-256 %x...", humanSize(int64(len(a.Data))),
+                     sum[:8])
+}
+
+/*
+ * latchbox diff [--show-secrets] FILE1 FILE2
+ *
+ * Unlocks both password files, which can each use their own passphrase and
+ * keyfile, and prints the entries that were removed from FILE1, added to
+ * FILE2 or changed between the two.  Passwords and concealed custom fields
+ * are masked unless --show-secrets is used.  Exits with 0 if the files have
+ * the same entries, 1 if they differ and 2 if either file couldn't be
+ * unlocked.
+ */
+func diffCommand(args []string) int {
+  opts, files := commandArgs("diff", args, []string{"show-secrets"}, nil)
+  if len(files) != 2 {
+    fmt.Printf("Usage: latchbox diff [ --show-secrets ] FILE1 FILE2\n")
+    return 2
+  }
+  var entryDicts []map[string]*vault.Entry
+  for _, file := range files {
+    path := file
+    tildeHome(&path)
+    v, err := unlockPrompted(path)
+    if err != nil {
+      fmt.Fprintf(os.Stderr, "latchbox diff: %s: %s\n", file, err)
+      return 2
+    }
+    entryDict := make(map[string]*vault.Entry)
+    for _, e := range v.Entries {
+      entryDict[e.NameGroup()] = e
+    }
+    entryDicts = append(entryDicts, entryDict)
+  }
+  removed, added, changed := diffEntries(entryDicts[0], entryDicts[1])
+  if len(removed) + len(added) + len(changed) == 0 {
+    fmt.Printf("No Differences Between %s and %s\n", files[0], files[1])
+    return 0
+  }
+  if len(removed) > 0 {
+    fmt.Printf("Removed (%d):\n", len(removed))
+    for _, nameGroup := range removed {
+      fmt.Printf("  - %s\n", nameGroup)
+    }
+  }
+  if len(added) > 0 {
+    fmt.Printf("Added (%d):\n", len(added))
+    for _, nameGroup := range added {
+      fmt.Printf("  + %s\n", nameGroup)
+    }
+  }
+  if len(changed) > 0 {
+    fmt.Printf("Changed (%d):\n", len(changed))
+    for _, nameGroup := range changed {
+      var lines string
+      var changedLabels string
+      for _, c := range diffChanges(entryDicts[0][nameGroup],
+                                    entryDicts[1][nameGroup]) {
+        if changedLabels != "" {
+          changedLabels += ", "
+        }
+        changedLabels += c.label
+        oldValue, newValue := c.oldValue, c.newValue
+        if c.secret && opts["show-secrets"] == "" {
+          oldValue, newValue = maskSecret(oldValue), maskSecret(newValue)
+        }
+        lines += fmt.Sprintf("      %s: %q -> %q\n", c.label, oldValue,
+                             newValue)
+      }
+      fmt.Printf("  ~ %s (%s)\n%s", nameGroup, changedLabels, lines)
+    }
+  }
+  return 1
+}
+
+/*
+ * Returns the sorted name/group combinations of the entries only in
+ * oldDict, only in newDict and in both but with different values.
+ */
+func diffEntries(oldDict, newDict map[string]*vault.Entry) (removed, added,
+    changed []string) {
+  for nameGroup, e := range oldDict {
+    if newE, ok := newDict[nameGroup]; !ok {
+      removed = append(removed, nameGroup)
+    } else if len(diffChanges(e, newE)) > 0 {
+      changed = append(changed, nameGroup)
+    }
+  }
+  for nameGroup := range newDict {
+    if _, ok := oldDict[nameGroup]; !ok {
+      added = append(added, nameGroup)
+    }
+  }
+  sortutil.CiAsc(removed)
+  sortutil.CiAsc(added)
+  sortutil.CiAsc(changed)
+  return removed, added, changed
+}
+
+/*
+ * Returns a fixed length mask for secret so the length of secret isn't
+ * given away, or an empty string if secret is empty.
+ */
+func maskSecret(secret string) string {
+  if secret == "" {
+    return ""
+  }
+  return "********"
+}
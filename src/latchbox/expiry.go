@@ -0,0 +1,261 @@
+/*-
+ * Copyright (C) 2014-2017, Vi Grey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY AUTHOR AND CONTRIBUTORS "AS IS" AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL AUTHOR OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+/*
+ * Expiry dates of entry passwords, either set on an entry or worked out
+ * from the rotation interval of its group in the config file, along with
+ * the expiring command that lists the passwords that need to be rotated.
+ */
+
+package main
+
+import (
+  "errors"
+  "fmt"
+  "latchbox/vault"
+  "os"
+  "sort"
+  "strconv"
+  "strings"
+  "time"
+)
+
+/* YYYY-MM-DD (computer's localtime) */
+const dateLayout = "2006-01-02"
+
+/* How often the passwords of the entries in a group have to be changed. */
+type rotation struct {
+  group string
+  days int
+}
+
+/*
+ * Parses the rotation definition, which is a group name followed by a colon
+ * and a number of days like "bank/cards: 90d".
+ */
+func parseRotation(definition string) (rotation, error) {
+  colon := strings.LastIndex(definition, ":")
+  if colon < 1 {
+    return rotation{}, errors.New("Group Name Required")
+  }
+  r := rotation{group: strings.TrimSpace(definition[:colon])}
+  if !vault.ValidGroup(r.group) {
+    return rotation{}, errors.New("Invalid Group Name " + r.group)
+  }
+  days, err := parseDays(definition[colon + 1:])
+  if err != nil || days == 0 {
+    return rotation{}, errors.New("Invalid Number of Days")
+  }
+  r.days = days
+  return r, nil
+}
+
+/*
+ * Parses a number of days, which can be followed by "d" for days or "w" for
+ * weeks, like "14", "14d" or "2w".
+ */
+func parseDays(s string) (int, error) {
+  s = strings.ToLower(strings.TrimSpace(s))
+  multiplier := 1
+  if strings.HasSuffix(s, "w") {
+    multiplier = 7
+    s = s[:len(s) - 1]
+  } else if strings.HasSuffix(s, "d") {
+    s = s[:len(s) - 1]
+  }
+  days, err := strconv.Atoi(s)
+  if err != nil || days < 0 || days > 36500 {
+    return 0, errors.New("Invalid Number of Days")
+  }
+  return days * multiplier, nil
+}
+
+/*
+ * Returns the rotation interval in days of group, which is the interval of
+ * the closest group it is in that has one, or 0 if none of them do.
+ */
+func rotationDays(group string) int {
+  days, longest := 0, -1
+  for _, r := range rotations {
+    if (group == r.group || strings.HasPrefix(group, r.group + "/")) &&
+        len(r.group) > longest {
+      days, longest = r.days, len(r.group)
+    }
+  }
+  return days
+}
+
+/*
+ * Returns when the password of e expires, which is its expiry date if it
+ * has one or the rotation interval of its group after its password was set
+ * if it doesn't.  Returns the zero time if the password doesn't expire.
+ */
+func expiresAt(e *vault.Entry) time.Time {
+  if !e.Expires.IsZero() {
+    return e.Expires
+  }
+  if days := rotationDays(e.Group); days > 0 {
+    return e.PasswordSet().AddDate(0, 0, days)
+  }
+  return time.Time{}
+}
+
+/* Returns how many calendar days are left from now until t. */
+func daysUntil(t, now time.Time) int {
+  /* Compare the dates in UTC so every day is 24 hours long. */
+  y, m, d := t.Date()
+  day := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+  y, m, d = now.Date()
+  today := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+  return int(day.Sub(today).Hours() / 24)
+}
+
+/*
+ * Returns the entries in entries whose passwords expired or expire within
+ * days days of now, sorted by when they expire.
+ */
+func expiringEntries(entries []*vault.Entry, days int,
+    now time.Time) []*vault.Entry {
+  var expiring []*vault.Entry
+  for _, e := range vault.Sort(entries) {
+    if expires := expiresAt(e); !expires.IsZero() &&
+        daysUntil(expires, now) <= days {
+      expiring = append(expiring, e)
+    }
+  }
+  sort.SliceStable(expiring, func(x, y int) bool {
+    return expiresAt(expiring[x]).Before(expiresAt(expiring[y]))
+  })
+  return expiring
+}
+
+/* Describes when the password of e expires compared to now. */
+func expiryStatus(e *vault.Entry, now time.Time) string {
+  days := daysUntil(expiresAt(e), now)
+  if days < -1 {
+    return "Expired " + strconv.Itoa(-days) + " Days Ago"
+  } else if days == -1 {
+    return "Expired Yesterday"
+  } else if days == 0 {
+    return "Expires Today"
+  } else if days == 1 {
+    return "Expires Tomorrow"
+  }
+  return "Expires in " + strconv.Itoa(days) + " Days"
+}
+
+/*
+ * Returns the banner shown in the main menu after unlocking a password
+ * file, which lists the entries whose passwords expired or expire within
+ * expiryWarningDays days.  Returns "" if there are none.
+ */
+func expiryBanner() string {
+  now := time.Now()
+  expiring := expiringEntries(vlt.Entries, expiryWarningDays, now)
+  if len(expiring) == 0 {
+    return ""
+  }
+  banner := strconv.Itoa(len(expiring)) + " Passwords Need to Be Rotated:"
+  for _, e := range expiring {
+    banner += "\n  " + e.NameGroup() + " (" + expiryStatus(e, now) + ")"
+  }
+  return banner
+}
+
+/*
+ * Parses the expiry date value entered for an entry, which is either a
+ * date in the form YYYY-MM-DD or a number of days or weeks from now (see
+ * parseDays).  An empty value returns the zero time.
+ */
+func parseExpiry(value string, now time.Time) (time.Time, error) {
+  value = strings.TrimSpace(value)
+  if value == "" {
+    return time.Time{}, nil
+  }
+  if expires, err := time.ParseInLocation(dateLayout, value,
+                                          time.Local); err == nil {
+    return expires, nil
+  }
+  days, err := parseDays(value)
+  if err != nil {
+    return time.Time{}, errors.New("Expiry Date Must be YYYY-MM-DD or a " +
+                                   "Number of Days or Weeks")
+  }
+  y, m, d := now.AddDate(0, 0, days).Date()
+  return time.Date(y, m, d, 0, 0, 0, 0, time.Local), nil
+}
+
+/*
+ * latchbox expiring [--within DAYS] [ FILE ]
+ *
+ * Prints the entries of the password file FILE, which is the
+ * defaultPasswordFile in the config file if FILE isn't given, whose
+ * passwords expired or expire within DAYS days (expiryWarningDays in the
+ * config file if --within isn't used), soonest first.  DAYS can end with
+ * "d" for days or "w" for weeks.  Exits with 0 if no passwords need to be
+ * rotated, 1 if some do and 2 if the password file couldn't be unlocked.
+ */
+func expiringCommand(args []string) int {
+  opts, operands := commandArgs("expiring", args, nil, []string{"within"})
+  if len(operands) > 1 {
+    fmt.Printf("Usage: latchbox expiring [ --within DAYS ] [ FILE ]\n")
+    return 2
+  }
+  makeConfig()
+  configParse()
+  days := expiryWarningDays
+  if within, ok := opts["within"]; ok {
+    var err error
+    if days, err = parseDays(within); err != nil {
+      fmt.Fprintf(os.Stderr, "latchbox expiring: %s: %s\n", within, err)
+      return 2
+    }
+  }
+  var path string
+  if len(operands) == 1 {
+    path = operands[0]
+  }
+  path, ok := commandFile("expiring", path)
+  if !ok {
+    return 2
+  }
+  v, err := unlockPrompted(path)
+  if err != nil {
+    fmt.Fprintf(os.Stderr, "latchbox expiring: %s: %s\n", path, err)
+    return 2
+  }
+  now := time.Now()
+  expiring := expiringEntries(v.Entries, days, now)
+  for _, e := range expiring {
+    fmt.Printf("%s  %s (%s)\n", expiresAt(e).Format(dateLayout),
+               e.NameGroup(), expiryStatus(e, now))
+  }
+  if len(expiring) > 0 {
+    return 1
+  }
+  return 0
+}
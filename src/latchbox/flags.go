@@ -34,9 +34,69 @@ import (
 )
 
 func helpPrint() {
-  fmt.Printf("Usage: latchbox [ OPTIONS ]...\n\nOptions:\n" +
+  fmt.Printf("Usage: latchbox [ OPTIONS ]...\n" +
+             "       latchbox COMMAND [ COMMAND OPTIONS ]...\n\nOptions:\n" +
              "  -h, --help       Print Help (this message) and exit\n" +
-             "      --version    Print version information and exit\n")
+             "      --read-only  Open password files for viewing and copying " +
+             "only\n" +
+             "      --version    Print version information and exit\n" +
+             "\nCommands:\n" +
+             "  attach [ --name NAME ] [ --replace ] FILE ENTRY PATH\n" +
+             "                   Attach the file in PATH to an entry of a " +
+             "password file\n" +
+             "  attachments FILE [ ENTRY ]\n" +
+             "                   List the attachments of a password file " +
+             "and their\n" +
+             "                   sizes\n" +
+             "  audit [ --json ] [ --days DAYS ] [ --breached HASHFILE ] " +
+             "[ FILE ]\n" +
+             "                   Report reused, weak and old passwords and " +
+             "other\n" +
+             "                   problems by severity (exits with 1 if " +
+             "there are any)\n" +
+             "  detach FILE ENTRY NAME\n" +
+             "                   Remove an attachment from an entry\n" +
+             "  diff [ --show-secrets ] FILE1 FILE2\n" +
+             "                   List entries added, removed or changed " +
+             "between two\n" +
+             "                   password files (passwords are masked " +
+             "unless\n" +
+             "                   --show-secrets is used)\n" +
+             "  expiring [ --within DAYS ] [ FILE ]\n" +
+             "                   List the entries whose passwords expired " +
+             "or expire\n" +
+             "                   within DAYS days (exits with 1 if there " +
+             "are any)\n" +
+             "  extract [ --force ] FILE ENTRY NAME DEST\n" +
+             "                   Write an attachment to DEST (or stdout if " +
+             "DEST is -)\n" +
+             "                   with 0600 permissions\n" +
+             "  fsck [ --repair ] FILE\n" +
+             "                   Check a password file against the LatchBox " +
+             "file\n" +
+             "                   protocol (and rewrite a normalized password " +
+             "file\n" +
+             "                   with --repair)\n" +
+             "  gen [ --length LENGTH ] [ --profile NAME ] " +
+             "[ --rules RULES ]\n" +
+             "      [ --words COUNT ] [ --wordlist LIST ] " +
+             "[ --separator SEPARATOR ]\n" +
+             "      [ --capitalize ] [ --digit ]\n" +
+             "                   Print a random password, or a passphrase " +
+             "of random\n" +
+             "                   words with --words, and its entropy\n" +
+             "  ls [ --tag TAG ] [ --favorites ] [ FILE ]\n" +
+             "                   List the entries of a password file, " +
+             "favorites first\n" +
+             "  otp [ FILE ] ENTRY\n" +
+             "                   Print the current one-time password code of " +
+             "an entry\n" +
+             "                   (FILE defaults to the defaultPasswordFile " +
+             "config\n" +
+             "                   setting)\n" +
+             "  verify-backups   Check every backup against the checksum " +
+             "manifest of\n" +
+             "                   the backup directory\n")
 }
 
 func versionPrint() {
@@ -0,0 +1,118 @@
+/*-
+ * Copyright (C) 2014-2017, Vi Grey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY AUTHOR AND CONTRIBUTORS "AS IS" AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL AUTHOR OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+/*
+ * Checks that the decrypted content of a password file follows the LatchBox
+ * file protocol in docs/latchbox-spec.txt and can rewrite a normalized
+ * password file if it doesn't.
+ */
+
+package main
+
+import (
+  "fmt"
+  "latchbox/vault"
+  "os"
+  "time"
+)
+
+/*
+ * latchbox fsck [--repair] FILE
+ *
+ * Decrypts FILE and checks its content against the LatchBox file protocol.
+ * If --repair is used and problems were found, a backup of FILE is made and
+ * FILE is rewritten with every entry that could be salvaged.  Exits with 0
+ * if no problems were found, 1 if problems were found (and repaired if
+ * --repair was used) and 2 if FILE couldn't be unlocked or repaired.
+ */
+func fsckCommand(args []string) int {
+  opts, files := commandArgs("fsck", args, []string{"repair"}, nil)
+  if len(files) != 1 {
+    fmt.Printf("Usage: latchbox fsck [ --repair ] FILE\n")
+    return 2
+  }
+  path := files[0]
+  tildeHome(&path)
+  v, err := unlockPrompted(path)
+  if v == nil {
+    fmt.Fprintf(os.Stderr, "latchbox fsck: %s: %s\n", path, err)
+    return 2
+  }
+  problems := v.Check(time.Now())
+  for _, problem := range problems {
+    fmt.Printf("%s: %s\n", path, problem)
+  }
+  if len(problems) == 0 {
+    fmt.Printf("%s: No Problems Found\n", path)
+    return 0
+  }
+  fmt.Printf("%s: %d Problems Found\n", path, len(problems))
+  if opts["repair"] == "" {
+    return 1
+  }
+  if err := repairFile(v); err != nil {
+    fmt.Fprintf(os.Stderr, "latchbox fsck: %s: Unable to Repair (%s)\n",
+                path, err)
+    return 2
+  }
+  fmt.Printf("%s: Repaired (%d Entries Kept, Backup Made in %s)\n", path,
+             len(v.Entries), backupDir)
+  return 1
+}
+
+/*
+ * Makes a backup of the password file v, then rewrites it with every entry
+ * that can be salvaged, keeping its cipher and PBKDF2 iterations.
+ * Timestamps in the future are set to now and modified timestamps before
+ * created timestamps are set to the created timestamp.
+ */
+func repairFile(v *vault.Vault) error {
+  makeConfig()
+  configParse()
+  lock()
+  vlt = v
+  fPath = v.Path
+  cipherType = v.Cipher
+  iterations = v.Iterations
+  if err := backupNow(); err != nil {
+    return err
+  }
+  v.Salvage()
+  now := time.Unix(time.Now().Unix(), 0)
+  for _, e := range v.Entries {
+    if e.Created.After(now) {
+      e.Created = now
+    }
+    if e.Modified.After(now) {
+      e.Modified = now
+    }
+    if e.Modified.Before(e.Created) {
+      e.Modified = e.Created
+    }
+  }
+  return writeData()
+}
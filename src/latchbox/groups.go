@@ -32,101 +32,71 @@
 package main
 
 import (
-  "github.com/patrickmn/sortutil"
+  "latchbox/vault"
   "strconv"
 )
 
 /*
  * Creates a string with the group name combinations along with a number
  * in square brackets ahead of it to indicate that group name combination
- * option for selecting the group name entry to use.
+ * option for selecting the group name entry to use.  Entries that aren't
+ * logins have their type after them.  Only the entries that match the
+ * filter are listed, favorites first.
  */
 func displayNameGroups() string {
   content := ""
-  nameGroupsList := nameGroups()
-  for x := range nameGroupsList {
-    content += "[" + strconv.Itoa(x + 1) + "] " + nameGroupsList[x] + "\n"
+  for x, e := range listedEntries() {
+    content += "[" + strconv.Itoa(x + 1) + "] " + entryLabel(e) + "\n"
+  }
+  if content == "" {
+    return "No " + filterLabel()
   }
   return content[:len(content) - 1]
 }
 
 /*
- * Returns a case insensitive sorted string slice of the groups
- * concatenated with the names.
+ * Returns the entries listed by displayNameGroups, which are the entries
+ * with the tag tagFilter (and that are favorites if favoritesOnly is true)
+ * with favorites first.
  */
-func nameGroups() []string {
-  var nameGroupsList []string
-  for x := range names {
-    if groups[x] == "" {
-      nameGroupsList = append(nameGroupsList, names[x])
-    } else {
-      nameGroupsList = append(nameGroupsList, groups[x] + "/" + names[x])
-    }
-  }
-  nameGroupsList = ciSort(nameGroupsList)
-  return nameGroupsList
+func listedEntries() []*vault.Entry {
+  return vlt.Filter(tagFilter, favoritesOnly)
 }
 
-/* Case insentitive sorts the string slice list. */
-func ciSort(list []string) []string {
-  var nameGroupsList []string
-  nameGroupsList = append(nameGroupsList, list...)
-  for x := range list {
-    list[x] += "/:"
+/* Describes which entries are listed, like "Favorites Tagged prod". */
+func filterLabel() string {
+  label := "Entries"
+  if favoritesOnly {
+    label = "Favorites"
   }
-  sortutil.CiAsc(list)
-  var order []int
-  for x := range list {
-    list[x] = list[x][:len(list[x]) - 2]
-    for y := range nameGroupsList {
-      if list[x] == nameGroupsList[y] {
-        order = append(order, y)
-      }
-    }
+  if tagFilter != "" {
+    label += " Tagged " + tagFilter
   }
-  orderList = order
-  return list
+  return label
 }
 
-/* Make map of [groupName]groupPointer (group pointer can be up to 65535). */
-func groupMap() {
-  i := 1
-  groupDict = make(map[string]string)
-  for x := range groups {
-    var inGroupDict bool
-    group := groups[x]
-    for y := range groupDict {
-      if y == group {
-        inGroupDict = true
-      }
-    }
-    if !inGroupDict {
-      groupDict[group] = string(numToBytes(int(i), 2))
-      i++
-    }
+/*
+ * Returns the name/group combination of e followed by its type if it isn't
+ * a login and its tags.  Favorites start with an asterisk.
+ */
+func entryLabel(e *vault.Entry) string {
+  label := e.NameGroup()
+  if e.Favorite {
+    label = "* " + label
   }
-}
-
-/* Returns byte slice of protocol for groups and group pointers. */
-func groupHeader() []byte {
-  var groupData []byte
-  for x := range groupDict {
-    groupData = append(groupData, strLenAppend([]byte(x), 2)...)
-    groupData = append(groupData, []byte(groupDict[x])...)
+  if e.Type != "" {
+    label += " (" + e.Type + ")"
+  }
+  for _, tag := range e.Tags {
+    label += " #" + tag
   }
-  groupData = strLenAppend(groupData, 4)
-  return groupData
+  return label
 }
 
-/* Return true if there are duplicate values in nameGroupList. */
-func duplicateNameGroups(nameGroupsList []string) bool {
-  dup := make(map[string]bool)
-  for _, x := range nameGroupsList {
-    if !dup[x] {
-      dup[x] = true
-    } else {
-      return true
-    }
-  }
-  return false
+/*
+ * Returns the entry with number entryNumber in the list shown by
+ * displayNameGroups.
+ */
+func selectedEntry() *vault.Entry {
+  return listedEntries()[entryNumber - 1]
 }
@@ -25,15 +25,11 @@
  * SUCH DAMAGE.
  */
 
-/*
- * Handles functions that involve translating bytes to other formats or
- * translating other formats to bytes.  Also handles inString function.
- */
+/* Handles inString function. */
 
 package main
 
 import (
-  "math"
   "strings"
 )
 
@@ -44,54 +40,3 @@ func inString(input, char string) bool {
   }
   return false
 }
-
-/*
- * Returns byteNum bytes that signify the length of s and appends s
- * to the bytes.
- */
-func strLenAppend(s []byte, byteNum int) []byte {
-  return append(numToBytes(uint64(len(s)), byteNum), s...)
-}
-
-// Converts bytes b to an integer
-func bytesToNum(b []byte) uint64 {
-  var length uint64
-  y := len(b) - 1
-  for x := 0; x < len(b); x++ {
-    length += uint64(b[x]) * uint64(math.Pow(256, float64(y)))
-    y--
-  }
-  return length
-}
-
-// Converts num to a byte array of size int
-func numToBytes(num interface{}, size int) []byte {
-  var numBytes []byte
-  y := size - 1
-  switch numVal := num.(type) {
-  case uint64:
-    for x := 0; x < size; x++ {
-      byteVal := byte(numVal / uint64(math.Pow(256, float64(y))))
-      numVal -= uint64(byteVal) * uint64(math.Pow(256, float64(y)))
-      numBytes = append(numBytes, byteVal)
-      y--
-    }
-  case int64:
-    for x := 0; x < size; x++ {
-      byteVal := byte(numVal / int64(math.Pow(256, float64(y))))
-      numVal -= int64(byteVal) * int64(math.Pow(256, float64(y)))
-      numBytes = append(numBytes, byteVal)
-      y--
-    }
-  case int:
-    for x := 0; x < size; x++ {
-      byteVal := byte(numVal / int(math.Pow(256, float64(y))))
-      numVal -= int(byteVal) * int(math.Pow(256, float64(y)))
-      numBytes = append(numBytes, byteVal)
-      y--
-    }
-  default:
-    panic("Type of num must be int, int64, or uint64")
-  }
-  return numBytes
-}
@@ -26,10 +26,10 @@
  */
 
 /*
- * Organize data into password file protocol, then encrypt the
- * password file and write to file.  If first time writing and backups
- * allowed, make a backup.  Also does anything that involves reading and
- * writing to a file.
+ * Save the password file with the cipher and iterations from the config
+ * file.  If first time writing and backups allowed, make a backup.  A failed
+ * save marks the current tab as having unsaved changes.  Also
+ * does anything that involves reading and writing to a file.
  */
 
 package main
@@ -38,6 +38,7 @@ import (
   "encoding/csv"
   "errors"
   "io/ioutil"
+  "latchbox/vault"
   "os"
   "os/user"
   "runtime"
@@ -45,78 +46,29 @@ import (
   "time"
 )
 
+/* Returned by writeData when the current tab was opened read-only. */
+var errReadOnly = errors.New("Password File Is Read-Only")
+
 func writeData() error {
-  tildeHome(&fPath)
-  groupMap()
-  var dataList [][]byte
-  for x := range names {
-    var data []byte
-    data = append(data, strLenAppend([]byte(names[x]), 1)...)
-    data = append(data, strLenAppend([]byte(usernames[x]), 1)...)
-    data = append(data, strLenAppend([]byte(passwords[x]), 2)...)
-    data = append(data, strLenAppend([]byte(emails[x]), 1)...)
-    data = append(data, strLenAppend([]byte(urls[x]), 1)...)
-    if groups[x] != "" {
-      data = append(data, []byte(groupDict[groups[x]])...)
-    } else {
-      data = append(data, []byte{0, 0}...)
-    }
-    made := timeToUnix(created[x])
-    data = append(data, numToBytes(made, 8)...)
-    edited := timeToUnix(modified[x])
-    data = append(data, numToBytes(edited, 8)...)
-    data = append(data, []byte(comments[x])...)
-    dataList = append(dataList, strLenAppend(data, 3))
+  if readOnly {
+    return errReadOnly
   }
-  var data []byte
-  for x := range dataList {
-    data = append(data, dataList[x]...)
-  }
-  data = append(groupHeader(), data...)
-  data = append(numToBytes(uint64(protocolVersion), 2), data...)
-  salt := randByteArray(32)
-  key := generatePBKDF2Key([]byte(passphrase), salt, iterations)
-  dataEncrypt := encrypt(data, key, cipherType)
-  dataEncrypt = append(append(numToBytes(uint64(iterations), 4),
-                              salt...), dataEncrypt...)
-  dataEncrypt = append(numToBytes(cipherType, 2), dataEncrypt...)
-  err := ioutil.WriteFile(fPath, dataEncrypt, 0644)
+  tildeHome(&fPath)
+  vlt.Path = fPath
+  vlt.Cipher = cipherType
+  vlt.Iterations = iterations
+  err := vlt.Save()
   if err != nil {
+    dirty = true
     return err
   }
-  if len(names) > 0 {
+  dirty = false
+  if len(vlt.Entries) > 0 {
     doBackup()
   }
   return nil
 }
 
-/*
- * Makes backup files (and a backup directory inside of the latchbox
- * directory if it doesn't exist) and makes a copy of the password file
- * as it was when it was opened on the first time it is saved after
- * opening it.
- */
-func doBackup() {
-  if !backupSaved {
-    if backup && len(backupContents) > 0 {
-      backSlashSplit := strings.Split(fPath, "\\")
-      slashSplit := strings.Split(fPath, "/")
-      fileName := slashSplit[len(slashSplit) - 1]
-      if len(backSlashSplit) > 1 {
-        fileName = backSlashSplit[len(backSlashSplit) - 1]
-      }
-      fileName = strings.Split(fileName, ".")[0]
-      fileName += "-"
-      backupDir := configDir + "backup/"
-      backupFile := fileName + time.Now().Local().Format(backupLayout) +
-        ".lbp"
-      os.MkdirAll(backupDir, 0755)
-      ioutil.WriteFile(backupDir + backupFile, backupContents, 0644)
-      backupSaved = true
-    }
-  }
-}
-
 /*
  * Makes latchbox directory if one doesn't exist and creates config
  * if it doesn't exist.  If config.txt exists, but not config, config.txt
@@ -125,7 +77,10 @@ func doBackup() {
 func makeConfig() {
   usr, _ := user.Current()
   configDir = usr.HomeDir + "/.latchbox/"
-  configContent := "makeBackups = \"true\"\n\ndefaultPasswordFile = \"" +
+  configContent := "makeBackups = \"true\"\n\nbackupDirectory = \"" +
+    configDir + "backup/\"\n\nbackupKeepLast = \"0\"\n\n" +
+    "backupKeepDaily = \"0\"\n\nbackupKeepWeekly = \"0\"\n\n" +
+    "backupKeepMonthly = \"0\"\n\ndefaultPasswordFile = \"" +
     configDir + "passwords.lbp\"\n\ncipher = \"Chacha20Poly1305\""
   if _, err := os.Stat(configDir); err != nil {
     os.MkdirAll(configDir, 0755)
@@ -144,6 +99,15 @@ func makeConfig() {
   }
 }
 
+/* Plural of every label of an imported .csv file, used in messages. */
+var csvPlurals = map[string]string{"name": "Names", "username": "Usernames",
+                                   "password": "Passwords", "url": "URLs",
+                                   "group": "Group Names",
+                                   "comment": "Comments", "type": "Types",
+                                   "otp": "OTPs", "tags": "Tags",
+                                   "favorite": "Favorites",
+                                   "expires": "Expiry Dates"}
+
 /*
  * Reads the contents of a LastPass .csv file and adds the contents and saves
  * the password file with the added content.
@@ -156,6 +120,7 @@ func importCSV(location string) error {
     return err
   }
   csvLabels := make(map[int]string)
+  csvFields := make(map[int]string)
   r := csv.NewReader(csvLocation)
   csvContent, err := r.ReadAll()
   csvLocation.Close()
@@ -176,61 +141,77 @@ func importCSV(location string) error {
       csvLabels[x] = "group"
     } else if csvLower == "extra" || csvLower == "comments" {
       csvLabels[x] = "comment"
+    } else if csvLower == "type" {
+      csvLabels[x] = "type"
+    } else if csvLower == "otp" || csvLower == "otpauth" {
+      csvLabels[x] = "otp"
+    } else if csvLower == "tags" {
+      csvLabels[x] = "tags"
+    } else if csvLower == "expires" {
+      csvLabels[x] = "expires"
+    } else if csvLower == "fav" || csvLower == "favorite" {
+      csvLabels[x] = "favorite"
+    } else if strings.HasPrefix(csvLower, "field:") {
+      csvLabels[x] = "field"
+      csvFields[x] = csvContent[0][x][len("field:"):]
+    } else if strings.HasPrefix(csvLower, "concealed:") {
+      csvLabels[x] = "concealed"
+      csvFields[x] = csvContent[0][x][len("concealed:"):]
     }
   }
   if len(csvLabels) == 0 {
     contentString = "No Suitable Labels for Import Data"
     return errors.New("No Suitable Labels for Import Data")
   }
-  namesLen := len(names[:])
-  usernamesLen := len(usernames[:])
-  passwordsLen := len(passwords[:])
-  urlsLen := len(urls[:])
-  groupsLen := len(groups[:])
-  commentsLen := len(comments[:])
   if len(csvContent) > 1 {
     for x := range csvContent[1:] {
       x += 1
+      e := &vault.Entry{}
+      seen := make(map[string]bool)
       for y := range csvContent[x] {
         content := csvContent[x][y]
+        if csvLabels[y] == "field" || csvLabels[y] == "concealed" {
+          if content == "" {
+            continue
+          }
+          f := vault.Field{Name: csvFields[y], Value: content,
+                           Concealed: csvLabels[y] == "concealed"}
+          if err := f.Validate(); err != nil {
+            contentString = err.Error()
+            return err
+          } else if e.Field(f.Name) != nil {
+            contentString = "Duplicate Field Name " + f.Name
+            return errors.New(contentString)
+          }
+          e.Fields = append(e.Fields, f)
+          continue
+        }
+        if csvLabels[y] != "" && seen[csvLabels[y]] {
+          contentString = "Too Many " + csvPlurals[csvLabels[y]] +
+            " in One Entry"
+          return errors.New(contentString)
+        }
+        seen[csvLabels[y]] = true
         if csvLabels[y] == "name" {
-          if len(names) == namesLen + x - 1 {
-            if len(content) > 0 && len(content) < 256 {
-              name := strings.Replace(content, "/", "\\", -1)
-              names = append(names, name)
-            } else {
-              contentString = "Name is Not an Expected Length"
-              return errors.New("Name is Not an Expected Length")
-            }
+          if len(content) > 0 && len(content) < 256 {
+            e.Name = strings.Replace(content, "/", "\\", -1)
           } else {
-            contentString = "Too Many Names in One Entry"
-            return errors.New("Too Many Names in One Entry")
+            contentString = "Name is Not an Expected Length"
+            return errors.New("Name is Not an Expected Length")
           }
         } else if csvLabels[y] == "username" {
-          if len(usernames) == usernamesLen + x - 1 {
-            if len(content) >= 0 && len(content) < 256 {
-              usernames = append(usernames, content)
-            } else {
-              contentString = "Username is Not an Expected Length"
-              return errors.New("Username is Not an Expected " +
-                        "Length")
-            }
+          if len(content) >= 0 && len(content) < 256 {
+            e.Username = content
           } else {
-            contentString = "Too Many Usernames in One Entry"
-            return errors.New("Too Many Usernames in One Entry")
+            contentString = "Username is Not an Expected Length"
+            return errors.New("Username is Not an Expected Length")
           }
         } else if csvLabels[y] == "password" {
-          if len(passwords) == passwordsLen + x - 1 {
-            if len(content) >= 0 && len(content) < 65536 {
-              passwords = append(passwords, content)
-            } else {
-              contentString = "Password is Not an Expected Length"
-              return errors.New("Password is Not an Expected " +
-                        "Length")
-            }
+          if len(content) >= 0 && len(content) < 65536 {
+            e.Password = content
           } else {
-            contentString = "Too Many Passwords in One Entry"
-            return errors.New("Too Many Passwords in One Entry")
+            contentString = "Password is Not an Expected Length"
+            return errors.New("Password is Not an Expected Length")
           }
         } else if csvLabels[y] == "url" {
           // Get stripped URL
@@ -239,97 +220,89 @@ func importCSV(location string) error {
           if contentStrippedIndex >= 0 {
             contentStripped = content[:contentStrippedIndex]
           }
-          if len(urls) == urlsLen + x - 1 {
-            if len(contentStripped) >= 0 && len(contentStripped) < 256 {
-              if strings.ToLower(contentStripped) == "http://" ||
-                  strings.ToLower(contentStripped) == "https://" {
-                urls = append(urls, "")
-              } else {
-                urls = append(urls, contentStripped)
-              }
-            } else {
-              contentString = "URL is Not an Expected Length"
-              return errors.New("URL is Not an Expected Length")
+          if len(contentStripped) >= 0 && len(contentStripped) < 256 {
+            if strings.ToLower(contentStripped) != "http://" &&
+                strings.ToLower(contentStripped) != "https://" {
+              e.URL = contentStripped
             }
           } else {
-            contentString = "Too Many URLs in One Entry"
-            return errors.New("Too Many URLs in One Entry")
+            contentString = "URL is Not an Expected Length"
+            return errors.New("URL is Not an Expected Length")
           }
         } else if csvLabels[y] == "group" {
-          if len(groups) == groupsLen + x - 1 {
-            var group string
-            if len(content) >= 0 && len(content) < 256 {
-              for z := range content {
-                if content[z] == '/' {
-                  group += "\\"
-                } else if content[z] == '\\' {
-                  group += "/"
-                } else {
-                  group += string(content[z])
-                }
-              }
-              if len(group) > 0 {
-                if group[0] == ' ' || group[0] =='/' ||
-                   group[len(group) - 1] == '/' ||
-                   inString(group, "//") ||
-                   inString(group, "/ ") {
-                  contentString = "Invalid Group Name " + group
-                  return errors.New("Invalid Group Name " + group)
-                }
+          var group string
+          if len(content) >= 0 && len(content) < 256 {
+            for z := range content {
+              if content[z] == '/' {
+                group += "\\"
+              } else if content[z] == '\\' {
+                group += "/"
+              } else {
+                group += string(content[z])
               }
-              groups = append(groups, group)
-            } else {
-              contentString = "Group Name is Not an Expected " +
-                "Length"
-              return errors.New("Group Name is Not an Expected " +
-                        "Length")
             }
+            if !vault.ValidGroup(group) {
+              contentString = "Invalid Group Name " + group
+              return errors.New("Invalid Group Name " + group)
+            }
+            e.Group = group
           } else {
-            contentString = "Too Many Group Names In One Entry"
-            return errors.New("Too Many Group Names in One Entry")
+            contentString = "Group Name is Not an Expected Length"
+            return errors.New("Group Name is Not an Expected Length")
           }
         } else if csvLabels[y] == "comment" {
-          if len(comments) == commentsLen + x - 1 {
-            if len(content) >= 0 && len(content) < 65536 {
-              comments = append(comments, content)
-            } else {
-              contentString = "Comment is Not an Expected Length"
-              return errors.New("Comment is Not an Expected " +
-                        "Length")
+          if len(content) >= 0 && len(content) < 65536 {
+            e.Comment = content
+          } else {
+            contentString = "Comment is Not an Expected Length"
+            return errors.New("Comment is Not an Expected Length")
+          }
+        } else if csvLabels[y] == "type" {
+          if len(content) < 256 {
+            e.Type = strings.ToLower(content)
+            if e.Type == "login" {
+              e.Type = ""
             }
           } else {
-            contentString = "Too Many URLs in One Entry"
-            return errors.New("Too Many URLs in One Entry")
+            contentString = "Type is Not an Expected Length"
+            return errors.New("Type is Not an Expected Length")
+          }
+        } else if csvLabels[y] == "otp" && content != "" {
+          o, err := vault.ParseOTP(content)
+          if err != nil {
+            contentString = err.Error() + " (" + e.Name + ")"
+            return err
           }
+          e.OTP = o
+        } else if csvLabels[y] == "tags" {
+          if err := e.SetTags(content); err != nil {
+            contentString = err.Error() + " (" + e.Name + ")"
+            return err
+          }
+        } else if csvLabels[y] == "expires" && content != "" {
+          expires, err := time.ParseInLocation(dateLayout, content,
+                                               time.Local)
+          if err != nil {
+            contentString = "Expiry Date Must be YYYY-MM-DD (" + e.Name + ")"
+            return errors.New(contentString)
+          }
+          e.Expires = expires
+        } else if csvLabels[y] == "favorite" {
+          e.Favorite = content == "1" || strings.ToLower(content) == "true"
         }
       }
-      if len(names) <= namesLen + x - 1 {
-        names = append(names, "")
-      }
-      if len(usernames) <= usernamesLen + x - 1 {
-        usernames = append(usernames, "")
-      }
-      if len(passwords) <= passwordsLen + x - 1 {
-        passwords = append(passwords, "")
-      }
-      if len(urls) <= urlsLen + x - 1 {
-        urls = append(urls, "")
-      }
-      if len(groups) <= groupsLen + x - 1 {
-        groups = append(groups, "")
+      for _, f := range e.Fields {
+        if err := checkTemplateField(e.Type, f.Name, f.Value); err != nil {
+          contentString = e.Name + ": " + err.Error()
+          return errors.New(contentString)
+        }
       }
-      if len(comments) <= commentsLen + x - 1 {
-        comments = append(comments, "")
+      e.Created = time.Now()
+      e.Modified = e.Created
+      if err := vlt.Add(e); err != nil {
+        contentString = err.Error()
+        return err
       }
-      emails = append(emails, "")
-      create := time.Now().Format(timeLayout)
-      created = append(created, create)
-      modified = append(modified, create)
-    }
-    nameGroupsList := nameGroups()
-    if duplicateNameGroups(nameGroupsList) {
-      contentString = "Duplicate Name/Group Combination"
-      return errors.New("Duplicate Name/Group Combination")
     }
   } else {
     contentString = "No Contents in CSV File"
@@ -355,7 +328,12 @@ func importCSV(location string) error {
 
 /*
  * Creates a LastPass .csv file that can be imported to LastPass and
- * KeePass.
+ * KeePass.  The entry types are added as a type column if there are
+ * entries that aren't logins, one-time passwords are added as an otp column
+ * of otpauth:// URIs, tags are added as a tags column, expiry dates are
+ * added as an expires column and custom fields are added as field:NAME and
+ * concealed:NAME columns after the LastPass columns.  Favorites have 1 in
+ * the fav column.
  */
 func exportCSV(location string) error {
   tildeHome(&location);
@@ -364,28 +342,91 @@ func exportCSV(location string) error {
     return err
   }
   w := csv.NewWriter(csvLocation)
-  w.Write([]string{"name", "username", "password", "url",
-      "grouping", "extra", "fav"})
+  labels := []string{"name", "username", "password", "url", "grouping",
+                     "extra", "fav"}
+  fieldColumns := make(map[string]int)
+  for _, e := range vlt.Entries {
+    if e.Type != "" && fieldColumns["type"] == 0 {
+      fieldColumns["type"] = len(labels)
+      labels = append(labels, "type")
+    }
+  }
+  for _, e := range vlt.Entries {
+    if e.OTP != nil && fieldColumns["otp"] == 0 {
+      fieldColumns["otp"] = len(labels)
+      labels = append(labels, "otp")
+    }
+  }
+  for _, e := range vlt.Entries {
+    if len(e.Tags) > 0 && fieldColumns["tags"] == 0 {
+      fieldColumns["tags"] = len(labels)
+      labels = append(labels, "tags")
+    }
+  }
+  for _, e := range vlt.Entries {
+    if !e.Expires.IsZero() && fieldColumns["expires"] == 0 {
+      fieldColumns["expires"] = len(labels)
+      labels = append(labels, "expires")
+    }
+  }
+  for _, e := range vlt.Entries {
+    for _, f := range e.Fields {
+      label := "field:" + f.Name
+      if f.Concealed {
+        label = "concealed:" + f.Name
+      }
+      if _, ok := fieldColumns[label]; !ok {
+        fieldColumns[label] = len(labels)
+        labels = append(labels, label)
+      }
+    }
+  }
+  w.Write(labels)
   var writeErr error
-  for x := range names {
+  for _, e := range vlt.Entries {
     var newGroup string
-    for y := range groups[x] {
-      if groups[x][y] == '/' {
+    for y := range e.Group {
+      if e.Group[y] == '/' {
         newGroup += "\\"
-      } else if groups[x][y] == '\\' {
+      } else if e.Group[y] == '\\' {
         newGroup += "/"
       } else {
-        newGroup += string(groups[x][y])
+        newGroup += string(e.Group[y])
       }
     }
     var url string
-    if len(urls[x]) == 0 {
+    if len(e.URL) == 0 {
       url = "http://"
     } else {
-      url = urls[x]
+      url = e.URL
+    }
+    row := make([]string, len(labels))
+    fav := "0"
+    if e.Favorite {
+      fav = "1"
+    }
+    copy(row, []string{e.Name, e.Username, e.Password, url, newGroup,
+                       e.Comment, fav})
+    if fieldColumns["type"] > 0 {
+      row[fieldColumns["type"]] = typeName(e)
+    }
+    if e.OTP != nil {
+      row[fieldColumns["otp"]] = e.OTP.URI()
+    }
+    if len(e.Tags) > 0 {
+      row[fieldColumns["tags"]] = strings.Join(e.Tags, ",")
+    }
+    if !e.Expires.IsZero() {
+      row[fieldColumns["expires"]] = e.Expires.Format(dateLayout)
+    }
+    for _, f := range e.Fields {
+      if f.Concealed {
+        row[fieldColumns["concealed:" + f.Name]] = f.Value
+      } else {
+        row[fieldColumns["field:" + f.Name]] = f.Value
+      }
     }
-    writeErr = w.Write([]string{names[x], usernames[x], passwords[x],
-             url, newGroup, comments[x], "0"})
+    writeErr = w.Write(row)
   }
   if writeErr != nil {
     os.Remove(location)
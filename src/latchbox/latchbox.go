@@ -31,14 +31,13 @@ package main
 
 import (
   "fmt"
+  "latchbox/vault"
   "os"
   "runtime"
   "strings"
 )
 
 const (
-  /* Protocol Version to save password file under.*/
-  protocolVersion = 2
   versionNum = "3.0.0"
   version = "v" + versionNum
   title = "LatchBox " + version + " (Esc:QUIT"
@@ -51,74 +50,125 @@ const (
   digits = "1234567890"
   punctuation = "!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~"
   /* YYYY-MM-DD hh:mm:ss 24-hour time (computer's localtime) */
-  timeLayout = "2006-01-02 15:04:05"
+  timeLayout = vault.TimeLayout
   /* YYYYMMDDhhmmss 24-hour time (computer's localtime) */
   backupLayout = "20060102150405"
-  AES256GCM = 0
-  CHACHA20POLY1305 = 1
 )
 
 var (
-  contentCopied, helpFlag, versionFlag bool
+  contentCopied, helpFlag, readOnlyFlag, versionFlag bool
   passChars []bool
-  backupContents, fileContents []byte
-  bottomCaption, configDir, contentExtra, contentString, csvFile string
+  backupContents []byte
+  backupDir, bottomCaption, configDir, contentExtra, contentString string
+  csvFile string
   ctrlCValue, defaultFile, entryData, errMsg, fPath, key, key1, location string
-  locationTitle, options, passphrase, tmpDefault, tmpPassphrase string
+  locationTitle, oldPassphrase, options, tmpDefault string
+  tmpPassphrase string
   topTitle, value string
   menu = "Welcome"
-  comments, created, emails, groups, modified, names, newValue []string
-  passwords, urls, usernames []string
+  newValue []string
   menuList = []string{menu}
-  entryNumber, h, passLen, top, w int
-  cipherType = CHACHA20POLY1305
+  currentTab, entryNumber, fieldNumber, h, newFieldIndex, passLen int
+  top, w int
+  backupKeepDaily, backupKeepLast, backupKeepMonthly, backupKeepWeekly int
+  /* Largest file in bytes that can be attached to an entry. */
+  attachmentSizeLimit = 1048576
+  /* Most previous passwords kept for each entry. */
+  passwordHistoryDepth = 10
+  /* Days deleted entries are kept in the trash (0 keeps them forever). */
+  trashPurgeDays = 30
+  /* Unused recovery codes left at or below which an entry is warned about. */
+  recoveryCodesWarning = 3
+  /* Days ahead that expiring passwords are warned about. */
+  expiryWarningDays = 14
+  /* Days after which the audit reports a password as old (0 never does). */
+  auditDays = 365
+  /*
+   * Score from 0 (Very Weak) to 4 (Very Strong) passphrases of password
+   * files must have at least (0 allows any passphrase).
+   */
+  minPassphraseStrength = 2
+  rotations []rotation
+  trashNumber int
+  searchQuery string
+  searchTrash bool
+  /* Only entries with tagFilter (and favorites if favoritesOnly) are listed. */
+  tagFilter string
+  favoritesOnly bool
+  undoSteps, redoSteps []undoStep
+  editSnapshot vault.Snapshot
+  /* 1 while a TOTP code is on screen, which is redrawn every second. */
+  otpTicking int32
+  cipherType = vault.CHACHA20POLY1305
   iterations uint32
-  nonce uint64
-  orderList []int
-  pFileVersion uint16
-  groupDict = make(map[string]string)
-  orderDict = make(map[string]string)
+  vlt *vault.Vault
+  tabs []*tab
+  recentFiles []string
+  backupList []backupFile
+  backupCounts []int
+  backupEntries []*vault.Entry
+  newField vault.Field
+  newFields []vault.Field
+  templates []*template
+  newTemplate *template
+  salvageProblems []string
+  backupNumber int
   step = make([]bool, 13)
-  backup, backupSaved, checksum, ctrlC, keyDownPressed, keyUpPressed, omit bool
-  passwordInput, show bool
+  backup, backupSaved, backupTaken, checksum, ctrlC, keyDownPressed bool
+  dirty, keyUpPressed, lockPressed, omit, openingTab bool
+  openReadOnly, passwordInput, readOnly, show bool
   edit_box EditBox
 )
 
 /*
- * Resets variables and brings the user back to the Welcome menu to either
- * make a NEW password file or OPEN an old one.
+ * Resets variables, closes every tab and brings the user back to the Welcome
+ * menu to either make a NEW password file or OPEN an old one.
  */
 func lock() {
   passChars = make([]bool, 0)
+  newGenerator = nil
   newValue = make([]string, 0)
   passLen = 0
   entryData = ""
+  fieldNumber = 0
+  trashNumber = 0
+  searchQuery = ""
+  searchTrash = false
+  tagFilter = ""
+  favoritesOnly = false
+  undoSteps = nil
+  redoSteps = nil
+  editSnapshot = vault.Snapshot{}
+  endRotation()
+  breachWarned = ""
+  wordsPassphrase = ""
   fPath = ""
   value = ""
-  passphrase = ""
+  vlt = nil
+  tabs = make([]*tab, 0)
+  currentTab = 0
+  dirty = false
+  lockPressed = false
+  openingTab = false
+  openReadOnly = false
+  readOnly = false
+  oldPassphrase = ""
   key = ""
   location = ""
   backupSaved = false
   backupContents = make([]byte, 0)
-  fileContents = make([]byte, 0)
-  names = make([]string, 0)
-  usernames = make([]string, 0)
-  passwords = make([]string, 0)
-  emails = make([]string, 0)
-  urls = make([]string, 0)
-  groups = make([]string, 0)
-  comments = make([]string, 0)
-  created = make([]string, 0)
-  modified = make([]string, 0)
-  groupDict = make(map[string]string)
-  orderDict = make(map[string]string)
   menu = "Welcome"
   menuList = []string{menu}
   step = make([]bool, 13)
   key1 = ""
   bottomCaption = ""
   contentString = ""
-  orderList = make([]int, 0)
+  backupList = make([]backupFile, 0)
+  backupCounts = make([]int, 0)
+  backupEntries = make([]*vault.Entry, 0)
+  salvageProblems = make([]string, 0)
+  backupNumber = 0
+  backupTaken = false
 }
 
 func main() {
@@ -126,12 +176,17 @@ func main() {
   if runtime.GOOS == "windows" || runtime.GOOS == "plan9" {
     panic("Unsupported Operating System")
   }
+  if len(os.Args) > 1 && isCommand(os.Args[1]) {
+    runCommand(os.Args[1], os.Args[2:])
+  }
   for i := 1; i < len(os.Args); i++ {
     if len(os.Args[i]) > 2 && strings.Index(os.Args[i], "--") == 0 {
       if os.Args[i][2:] == "help" {
         helpFlag = true;
       } else if os.Args[i][2:] == "version" {
         versionFlag = true;
+      } else if os.Args[i][2:] == "read-only" {
+        readOnlyFlag = true;
       } else if os.Args[i][2:] != "" {
         fmt.Printf("latchbox: unrecognized option '%s'\nTry 'latchbox " +
                    "--help' for more information.\n", string(os.Args[i]))
@@ -161,7 +216,7 @@ func main() {
       versionPrint()
     }
   } else {
-    getIterationsFromPBKDF2Test()
+    iterations = vault.CalibrateIterations()
     cli()
   }
 }
@@ -25,40 +25,52 @@
  * SUCH DAMAGE.
  */
 
-/* A Console Based Password Management Program */
+/*
+ * Lists the entries of password files from the command line.
+ */
 
 package main
 
 import (
-  "math"
-  "time"
+  "fmt"
+  "os"
 )
 
-// Get YYYY-MM-DD hh:mm:ss timestamp out of 8 bytes
-func getTime(packet []byte, entryList *[]string, pointer *int, err *bool) {
-  var timeLen int
-  if len(packet) - *pointer >= 8 && !*err {
-    timeLen = 8
+/*
+ * latchbox ls [--tag TAG] [--favorites] [ FILE ]
+ *
+ * Prints the name/group combination, type and tags of every entry of the
+ * password file FILE, which is the defaultPasswordFile in the config file
+ * if FILE isn't given, one entry per line in the order they are listed in
+ * with favorites first and marked with an asterisk.  Only entries with the
+ * tag TAG are printed if --tag is used and only favorites are printed if
+ * --favorites is used.  Exits with 0 if the password file was unlocked and
+ * 2 if it wasn't.
+ */
+func lsCommand(args []string) int {
+  opts, operands := commandArgs("ls", args, []string{"favorites"},
+                                []string{"tag"})
+  if len(operands) > 1 {
+    fmt.Printf("Usage: latchbox ls [ --tag TAG ] [ --favorites ] [ FILE ]\n")
+    return 2
   }
-  if timeLen > 0 {
-    timeByteString := string(packet[*pointer: *pointer + timeLen])
-    var cInt float64
-    for x := range timeByteString {
-      cInt += float64(timeByteString[x]) * math.Pow(256, float64(timeLen -
-         1 - x))
-    }
-    timestamp := time.Unix(int64(cInt), 0).Format(timeLayout)
-    *entryList = append(*entryList, timestamp)
-  } else {
-    *err = true
+  makeConfig()
+  configParse()
+  var path string
+  if len(operands) == 1 {
+    path = operands[0]
   }
-  *pointer += 8
-}
-
-/* Convert YYYY-MM-DD hh:mm:ss timestamp to local Unix time. */
-func timeToUnix(value string) int64 {
-  local, _ := time.LoadLocation("Local")
-  toTime, _ := time.ParseInLocation(timeLayout, value, local)
-  return toTime.Unix()
+  path, ok := commandFile("ls", path)
+  if !ok {
+    return 2
+  }
+  v, err := unlockPrompted(path)
+  if err != nil {
+    fmt.Fprintf(os.Stderr, "latchbox ls: %s: %s\n", path, err)
+    return 2
+  }
+  for _, e := range v.Filter(opts["tag"], opts["favorites"] == "true") {
+    fmt.Println(entryLabel(e))
+  }
+  return 0
 }
-
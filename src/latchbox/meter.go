@@ -0,0 +1,92 @@
+/*-
+ * Copyright (C) 2014-2017, Vi Grey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY AUTHOR AND CONTRIBUTORS "AS IS" AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL AUTHOR OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+/*
+ * Strength meter shown while passwords and passphrases are typed, and the
+ * minimum strength of the passphrases of password files.
+ */
+
+package main
+
+import (
+  "latchbox/strength"
+  "math"
+  "path/filepath"
+  "strconv"
+  "strings"
+)
+
+/*
+ * Guesses per second an attacker is assumed to make against a stolen
+ * password file, whose passphrase is slowly hashed.
+ */
+const crackGuessesPerSecond = 1e4
+
+/*
+ * Returns a line saying how hard password is to guess, or "" if password is
+ * empty.  inputs are words an attacker would try first, like the name of
+ * the entry.
+ */
+func strengthLine(password string, inputs ...string) string {
+  if password == "" {
+    return ""
+  }
+  r := strength.Estimate(password, inputs)
+  return "Strength: " + r.Rating() + " (About 10^" +
+    strconv.Itoa(int(math.Log10(r.Guesses))) + " Guesses, Cracked in " +
+    r.CrackTime(crackGuessesPerSecond) + ")"
+}
+
+/*
+ * Returns the words an attacker would try first for the passphrase of the
+ * password file in path.
+ */
+func passphraseInputs(path string) []string {
+  name := filepath.Base(path)
+  return []string{"latchbox", strings.TrimSuffix(name, filepath.Ext(name))}
+}
+
+/*
+ * Returns why passphrase is too easy to guess for the password file in
+ * path, or "" if it is at least as strong as minPassphraseStrength.
+ */
+func weakPassphrase(passphrase, path string) string {
+  return weakScore(strength.Estimate(passphrase,
+                                     passphraseInputs(path)).Score)
+}
+
+/*
+ * Returns why a passphrase with strength score is too easy to guess, or ""
+ * if score is at least minPassphraseStrength.
+ */
+func weakScore(score int) string {
+  if score >= minPassphraseStrength {
+    return ""
+  }
+  return "Passphrase Strength Is " + strength.ScoreName(score) +
+    ", It Must be at Least " + strength.ScoreName(minPassphraseStrength)
+}
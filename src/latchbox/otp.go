@@ -0,0 +1,89 @@
+/*-
+ * Copyright (C) 2014-2017, Vi Grey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY AUTHOR AND CONTRIBUTORS "AS IS" AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL AUTHOR OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+/*
+ * Prints the one-time password codes of entries from the command line.
+ */
+
+package main
+
+import (
+  "fmt"
+  "os"
+  "time"
+)
+
+/*
+ * latchbox otp [ FILE ] ENTRY
+ *
+ * Prints the current one-time password code of the entry ENTRY (its
+ * name/group combination) of the password file FILE, which is the
+ * defaultPasswordFile in the config file if FILE isn't given.  The seconds
+ * a TOTP code is good for are printed to stderr so only the code is on
+ * stdout.  The counter of an HOTP entry is advanced and saved before its
+ * code is printed.  Exits with 0 if a code was printed and 2 if one wasn't.
+ */
+func otpCommand(args []string) int {
+  _, operands := commandArgs("otp", args, nil, nil)
+  if len(operands) < 1 || len(operands) > 2 {
+    fmt.Printf("Usage: latchbox otp [ FILE ] ENTRY\n")
+    return 2
+  }
+  makeConfig()
+  configParse()
+  var path string
+  if len(operands) == 2 {
+    path = operands[0]
+  }
+  path, ok := commandFile("otp", path)
+  if !ok {
+    return 2
+  }
+  nameGroup := operands[len(operands) - 1]
+  v, e, ok := unlockEntry("otp", path, nameGroup)
+  if !ok {
+    return 2
+  }
+  if e.OTP == nil {
+    fmt.Fprintf(os.Stderr, "latchbox otp: %s: Entry Has No OTP\n",
+                nameGroup)
+    return 2
+  }
+  now := time.Now()
+  code := e.OTP.Code(now)
+  if e.OTP.Kind == "hotp" {
+    e.OTP.Advance()
+    if err := saveVault(v); err != nil {
+      fmt.Fprintf(os.Stderr, "latchbox otp: %s: %s\n", path, err)
+      return 2
+    }
+  } else {
+    fmt.Fprintf(os.Stderr, "%d Seconds Left\n", e.OTP.Remaining(now))
+  }
+  fmt.Println(code)
+  return 0
+}
@@ -26,180 +26,35 @@
  */
 
 /*
- * Parses information so the parsed information can be used and stored.
+ * Parses the config file so its settings can be used.
  */
 
 package main
 
 import (
-  "errors"
   "io/ioutil"
+  "latchbox/vault"
   "strconv"
   "strings"
 )
 
 /*
- * Parses the decrypted password file and sorts the information in
- * accordance to the protocol for use with the program.
- */
-func parseFile() error {
-  var err bool
-  var pointer int
-  var packetPointer int
-  var hGroupPointer string
-  if len(fileContents) >= 2 {
-    pFileVersion = uint16(bytesToNum(fileContents[pointer: pointer + 2]))
-  } else {
-    err = true
-  }
-  pointer += 2
-  groupPacketLen, groupPacket := parseInfo(fileContents, 4, &pointer, &err)
-  if len(fileContents) - pointer + groupPacketLen >= groupPacketLen &&
-       groupPacketLen > 0 && !err {
-    for packetPointer < groupPacketLen && !err {
-      _, hGroup := parseInfo(groupPacket, 2, &packetPointer, &err)
-      if len(groupPacket) >= 2 + packetPointer && !err {
-        hGroupPointer = string(groupPacket[
-          packetPointer: packetPointer + 2])
-      } else {
-        err = true
-      }
-      packetPointer += 2
-      if !err {
-        groupDict[string(hGroup)] = hGroupPointer
-      }
-    }
-  } else if len(groupPacket) != 0 {
-    err = true
-  }
-  for pointer < len(fileContents) && !err {
-    _, packet := parseInfo(fileContents, 3, &pointer, &err)
-    packetPointer = 0
-    nameLen, name := parseInfo(packet, 1, &packetPointer, &err)
-    if nameLen > 0 && !inString(string(name), "/") && !err {
-      names = append(names, string(name))
-    } else {
-      err = true
-    }
-    _, username := parseInfo(packet, 1, &packetPointer, &err)
-    if len(packet) > packetPointer && !err {
-      usernames = append(usernames, string(username))
-    } else {
-      err = true
-    }
-    _, password := parseInfo(packet, 2, &packetPointer, &err)
-    if len(packet) > packetPointer && !err {
-      passwords = append(passwords, string(password))
-    } else {
-      err = true
-    }
-    _, email := parseInfo(packet, 1, &packetPointer, &err)
-    if len(packet) > packetPointer && !err {
-      emails = append(emails, string(email))
-    } else {
-      err = true
-    }
-    _, url := parseInfo(packet, 1, &packetPointer, &err)
-    if len(packet) > packetPointer && !err {
-      urls = append(urls, string(url))
-    } else {
-      err = true
-    }
-    var group string
-    if len(packet) - packetPointer >= 2 && !err {
-      for path, point := range groupDict {
-        if point == string(packet[packetPointer: packetPointer + 2]) {
-          group = path
-          if path != "" && point != "" {
-            if group[0] == ' ' || group[0] == '/' ||
-                group[len(group) - 1] == '/' ||
-                inString(group, "//") ||
-                inString(group, "/ ") {
-              err = true
-            }
-          } else if path == "" && point != "" {
-            err = true
-          }
-        }
-      }
-      groups = append(groups, group)
-    } else {
-      err = true
-    }
-    packetPointer += 2
-    getTime(packet, &created, &packetPointer, &err)
-    getTime(packet, &modified, &packetPointer, &err)
-    var comment string
-    if len(packet) - packetPointer >= 0 &&
-        len(packet) - packetPointer < 65536 && !err {
-      comment = string(packet[packetPointer:])
-      comments = append(comments, comment)
-    } else {
-      err = true
-    }
-  }
-  nameGroupsList := nameGroups()
-  if duplicateNameGroups(nameGroupsList) {
-    err = true
-  }
-  if err {
-    lock()
-    contentString = "Corrupted Password File"
-    return errors.New("Corrupted Password File")
-  }
-  return nil
-}
-
-/*
- * Returns the length of the next packet section along with the content of
- * the packet section.
- */
-func parseInfo(packet []byte, byteLen int, pointer *int, err *bool) (
-    packetLen int, content []byte) {
-  var pLen int
-  var pContent []byte
-  if len(packet) - *pointer >= byteLen && !*err {
-    pLen = int(bytesToNum(packet[*pointer: *pointer + byteLen]))
-  } else {
-    *err = true
-  }
-  *pointer += byteLen
-  if len(packet) >= *pointer + pLen && !*err {
-    pContent = packet[*pointer: *pointer + pLen]
-  } else {
-    *err = true
-  }
-  *pointer += pLen
-  return pLen, pContent
-}
-
-/*
- * Checks to see if the encrypted password file (fc) looks legitimate for
- * length, then parses out the iterations and ciphertext (ct).  If the length
- * of fc isn't at least 36 (length of iteration bytes, salt and AES256-GCM IV),
- * an error is returned.
- */
-func parseCt(fc []byte) (ciph int, iter uint32, salt, ct []byte, old bool,
-                         err error) {
-  if len(fc) < 36 {
-    return 0, 0, nil, nil, false, errors.New("latchbox file content too short")
-  }
-  if bytesToNum(fc[:4]) == 100000 {
-    return 0, uint32(bytesToNum(fc[:4])), fc[4: 36], fc[36:], true, nil
-  }
-  if len(fc) < 38 {
-    return 0, 0, nil, nil, false, errors.New("latchbox file content too short")
-  }
-  return int(bytesToNum(fc[:2])), uint32(bytesToNum(fc[2:6])), fc[6: 38],
-         fc[38:], false, nil
-}
-
-/*
- * Parses the config file to figure out the default password file location
- * and if backups are allowed.
+ * Parses the config file to figure out the default password file location,
+ * if backups are allowed, where backups are kept and how many are kept,
+ * which entry templates there are, how big attachments can be, how many
+ * previous passwords are kept, how long deleted entries are kept, how
+ * often passwords have to be rotated, how strong passphrases must be,
+ * which wordlists passphrases can be generated from and which generator
+ * profiles there are.
  */
 func configParse() {
   configFile := configDir + "config"
+  backupDir = configDir + "backup/"
+  loadTemplates()
+  rotations = nil
+  wordlistPaths = nil
+  profiles = nil
+  groupProfiles = nil
   content, err := ioutil.ReadFile(configFile)
   if err == nil {
     configSplit := strings.Split(string(content), "\n")
@@ -225,14 +80,50 @@ func configParse() {
               configLineSplit[1][first: last]) == "true" {
             backup = true
           }
+        } else if configLineSplit[0] == "backupDirectory" {
+          backupDir = configLineSplit[1][first: last]
+          tildeHome(&backupDir)
+          if backupDir == "" {
+            panic("Backup Directory Cannot be Empty")
+          }
+          if backupDir[len(backupDir) - 1] != '/' {
+            backupDir += "/"
+          }
+        } else if configLineSplit[0] == "backupKeepLast" {
+          backupKeepLast = configCount(configLineSplit[0],
+                                       configLineSplit[1][first: last])
+        } else if configLineSplit[0] == "backupKeepDaily" {
+          backupKeepDaily = configCount(configLineSplit[0],
+                                        configLineSplit[1][first: last])
+        } else if configLineSplit[0] == "backupKeepWeekly" {
+          backupKeepWeekly = configCount(configLineSplit[0],
+                                         configLineSplit[1][first: last])
+        } else if configLineSplit[0] == "backupKeepMonthly" {
+          backupKeepMonthly = configCount(configLineSplit[0],
+                                          configLineSplit[1][first: last])
+        } else if configLineSplit[0] == "attachmentSizeLimit" {
+          attachmentSizeLimit = configCount(configLineSplit[0],
+                                            configLineSplit[1][first: last])
+        } else if configLineSplit[0] == "passwordHistoryDepth" {
+          passwordHistoryDepth = configCount(configLineSplit[0],
+                                             configLineSplit[1][first: last])
+          if passwordHistoryDepth > vault.MaxHistory {
+            panic("passwordHistoryDepth must be at most 100")
+          }
+        } else if configLineSplit[0] == "trashPurgeDays" {
+          trashPurgeDays = configCount(configLineSplit[0],
+                                       configLineSplit[1][first: last])
+        } else if configLineSplit[0] == "recoveryCodesWarning" {
+          recoveryCodesWarning = configCount(configLineSplit[0],
+                                             configLineSplit[1][first: last])
         } else if configLineSplit[0] == "defaultPasswordFile" {
           defaultFile = configLineSplit[1][first: last]
         } else if configLineSplit[0] == "cipher" {
           ciph := configLineSplit[1][first: last]
           if strings.ToLower(ciph) == "chacha20poly1305" {
-            cipherType = CHACHA20POLY1305
+            cipherType = vault.CHACHA20POLY1305
           } else if strings.ToLower(ciph) == "aes256-gcm" {
-            cipherType = AES256GCM
+            cipherType = vault.AES256GCM
           } else {
             panic("Invalid Cipher in Config File")
           }
@@ -241,14 +132,77 @@ func configParse() {
           if err != nil {
             panic("Iterations must be an integer")
           }
-          if iter < 100000 {
+          if iter < vault.MinIterations {
             panic("Iterations must be at least 100000")
           }
           iterations = uint32(iter)
+        } else if configLineSplit[0] == "template" {
+          t, err := parseTemplate(configLineSplit[1][first: last])
+          if err != nil {
+            panic("Invalid Template in Config File: " + err.Error())
+          }
+          addTemplate(t)
+        } else if configLineSplit[0] == "rotation" {
+          r, err := parseRotation(configLineSplit[1][first: last])
+          if err != nil {
+            panic("Invalid Rotation in Config File: " + err.Error())
+          }
+          rotations = append(rotations, r)
+        } else if configLineSplit[0] == "expiryWarningDays" {
+          expiryWarningDays = configCount(configLineSplit[0],
+                                          configLineSplit[1][first: last])
+        } else if configLineSplit[0] == "breachedPasswordsFile" {
+          breachFile = configLineSplit[1][first: last]
+        } else if configLineSplit[0] == "auditDays" {
+          auditDays = configCount(configLineSplit[0],
+                                  configLineSplit[1][first: last])
+        } else if configLineSplit[0] == "wordlist" {
+          wordlistPaths = append(wordlistPaths,
+                                 configLineSplit[1][first: last])
+        } else if configLineSplit[0] == "minPassphraseStrength" {
+          minPassphraseStrength = configCount(configLineSplit[0],
+                                              configLineSplit[1][first: last])
+          if minPassphraseStrength > 4 {
+            panic("minPassphraseStrength must be at most 4")
+          }
+        } else if configLineSplit[0] == "profile" {
+          /* Rules have "=" in them, so the value is everything after. */
+          definition := strings.Join(configLineSplit[1:], "=")
+          first = strings.Index(definition, "\"") + 1
+          last = strings.LastIndex(definition, "\"")
+          if first > last {
+            first -= 1
+          }
+          p, err := parseProfile(definition[first: last])
+          if err != nil {
+            panic("Invalid Profile in Config File: " + err.Error())
+          }
+          addProfile(p)
+        } else if configLineSplit[0] == "groupProfile" {
+          gp, err := parseGroupProfile(configLineSplit[1][first: last])
+          if err != nil {
+            panic("Invalid Group Profile in Config File: " + err.Error())
+          }
+          groupProfiles = append(groupProfiles, gp)
         }
       }
     }
+    if err := checkGroupProfiles(); err != nil {
+      panic("Invalid Group Profile in Config File: " + err.Error())
+    }
   } else {
     panic("Unable to Read Config File " + configFile)
   }
 }
+
+/*
+ * Returns the count value of config setting name, which must be an integer
+ * that is at least 0.
+ */
+func configCount(name, value string) int {
+  count, err := strconv.Atoi(value)
+  if err != nil || count < 0 {
+    panic(name + " must be an integer that is at least 0")
+  }
+  return count
+}
@@ -0,0 +1,466 @@
+/*-
+ * Copyright (C) 2014-2017, Vi Grey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY AUTHOR AND CONTRIBUTORS "AS IS" AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL AUTHOR OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+/*
+ * Rules for generating passwords, like a minimum number of digits or no
+ * look-alike characters, and the named generator profiles of rules set in
+ * the config file, which can be attached to entries or groups.
+ */
+
+package main
+
+import (
+  "errors"
+  "github.com/nsf/termbox-go"
+  "latchbox/vault"
+  "strconv"
+  "strings"
+  "time"
+)
+
+/* Names of the kinds of characters in rules, in generator order. */
+var ruleKinds = []string{"upper", "lower", "digits", "symbols"}
+
+/* Names of the kinds of characters passwords can start with in rules. */
+var ruleFirsts = []string{"any", "upper", "lower", "digit", "symbol",
+                          "letter"}
+
+/* Rules of generating passwords saved under a name in the config file. */
+type profile struct {
+  name string
+  generator vault.Generator
+}
+
+/* Generator profile that passwords of the entries in a group use. */
+type groupProfile struct {
+  group, name string
+}
+
+var (
+  /* Generator profiles set with profile in the config file. */
+  profiles []profile
+  /* Group profiles set with groupProfile in the config file. */
+  groupProfiles []groupProfile
+  /* Generator settings of the password of the entry being made. */
+  newGenerator *vault.Generator
+)
+
+/*
+ * Parses rules, which are separated by spaces, like "length=16 upper=2
+ * lower digits symbolSet=!#$% noLookAlikes first=letter".  A kind of
+ * character without a number has to be in the password at least once.  If
+ * no kind of character is named, all of them are allowed.
+ */
+func parseRules(rules string) (vault.Generator, error) {
+  g := vault.Generator{Length: defaultGenerator.Length}
+  var named bool
+  for _, rule := range strings.Fields(rules) {
+    key, value := rule, ""
+    hasValue := strings.Contains(rule, "=")
+    if hasValue {
+      equals := strings.Index(rule, "=")
+      key, value = rule[:equals], rule[equals + 1:]
+    }
+    key = strings.ToLower(key)
+    kind := -1
+    for x := range ruleKinds {
+      if key == ruleKinds[x] {
+        kind = x
+      }
+    }
+    if kind >= 0 {
+      minimum := 1
+      if hasValue {
+        var err error
+        minimum, err = strconv.Atoi(value)
+        if err != nil || minimum < 0 || minimum > 255 {
+          return g, errors.New("Minimum of " + key + " Must be Between 0 " +
+                               "and 255")
+        }
+      }
+      g.Minimums[kind] = uint8(minimum)
+      setKind(&g, kind)
+      named = true
+    } else if key == "length" {
+      length, err := strconv.Atoi(value)
+      if err != nil || length < 4 || length > 65535 {
+        return g, errors.New("Password Length Must be Between 4 and 65535")
+      }
+      g.Length = uint16(length)
+    } else if key == "symbolset" {
+      if value == "" || onlyChars(value, punctuation) != value {
+        return g, errors.New("Symbol Set Must Only Have Symbols")
+      }
+      g.Symbols = onlyChars(punctuation, value)
+      if !g.Punctuation {
+        g.Minimums[3] = 1
+      }
+      setKind(&g, 3)
+      named = true
+    } else if key == "nolookalikes" && !hasValue {
+      g.NoLookAlikes = true
+    } else if key == "norepeats" && !hasValue {
+      g.NoRepeats = true
+    } else if key == "nosequences" && !hasValue {
+      g.NoSequences = true
+    } else if key == "first" {
+      first := -1
+      for x := range ruleFirsts {
+        if strings.ToLower(value) == ruleFirsts[x] {
+          first = x
+        }
+      }
+      if first < 0 {
+        return g, errors.New("First Must be any, upper, lower, digit, " +
+                             "symbol or letter")
+      }
+      g.First = uint8(first)
+    } else {
+      return g, errors.New("Unknown Rule " + rule)
+    }
+  }
+  if !named {
+    for x := range ruleKinds {
+      g.Minimums[x] = 1
+      setKind(&g, x)
+    }
+  }
+  minimums := 0
+  for _, minimum := range g.Minimums {
+    minimums += int(minimum)
+  }
+  if minimums > int(g.Length) {
+    return g, errors.New("Minimums Add Up to More Than the Password Length")
+  }
+  if g.First != vault.FirstAny &&
+      firstChars(&g, generatorChars(&g)) == "" {
+    return g, errors.New("The First Character Must be of an Allowed Kind")
+  }
+  return g, nil
+}
+
+/* Allows kind of character kind (as in ruleKinds) in g. */
+func setKind(g *vault.Generator, kind int) {
+  if kind == 0 {
+    g.Uppercase = true
+  } else if kind == 1 {
+    g.Lowercase = true
+  } else if kind == 2 {
+    g.Digits = true
+  } else {
+    g.Punctuation = true
+  }
+}
+
+/* Returns the rules of g in the form parseRules parses. */
+func rulesString(g *vault.Generator) string {
+  rules := []string{"length=" + strconv.Itoa(int(g.Length))}
+  for x, allowed := range g.Classes() {
+    if allowed {
+      rules = append(rules, ruleKinds[x] + "=" +
+                     strconv.Itoa(int(g.Minimums[x])))
+    }
+  }
+  if g.Punctuation && g.Symbols != "" {
+    rules = append(rules, "symbolSet=" + g.Symbols)
+  }
+  if g.NoLookAlikes {
+    rules = append(rules, "noLookAlikes")
+  }
+  if g.NoRepeats {
+    rules = append(rules, "noRepeats")
+  }
+  if g.NoSequences {
+    rules = append(rules, "noSequences")
+  }
+  if g.First != vault.FirstAny && int(g.First) < len(ruleFirsts) {
+    rules = append(rules, "first=" + ruleFirsts[g.First])
+  }
+  return strings.Join(rules, " ")
+}
+
+/*
+ * Parses the profile definition, which is a profile name followed by a
+ * colon and rules like "bank: length=16 digits=2 noLookAlikes".
+ */
+func parseProfile(definition string) (profile, error) {
+  colon := strings.Index(definition, ":")
+  if colon < 1 {
+    return profile{}, errors.New("Profile Name Required")
+  }
+  p := profile{name: strings.TrimSpace(definition[:colon])}
+  if p.name == "" || len(p.name) > 255 {
+    return profile{}, errors.New("Invalid Profile Name " + p.name)
+  }
+  g, err := parseRules(definition[colon + 1:])
+  if err != nil {
+    return profile{}, err
+  }
+  g.Profile = p.name
+  p.generator = g
+  return p, nil
+}
+
+/* Adds profile p, replacing the profile with the same name if there is one. */
+func addProfile(p profile) {
+  for x := range profiles {
+    if profiles[x].name == p.name {
+      profiles[x] = p
+      return
+    }
+  }
+  profiles = append(profiles, p)
+}
+
+/* Returns the generator profile named name, or nil if there isn't one. */
+func profileNamed(name string) *profile {
+  for x := range profiles {
+    if profiles[x].name == name {
+      return &profiles[x]
+    }
+  }
+  return nil
+}
+
+/*
+ * Parses the group profile definition, which is a group name followed by a
+ * colon and a profile name like "bank/cards: bank".
+ */
+func parseGroupProfile(definition string) (groupProfile, error) {
+  colon := strings.LastIndex(definition, ":")
+  if colon < 1 {
+    return groupProfile{}, errors.New("Group Name Required")
+  }
+  gp := groupProfile{group: strings.TrimSpace(definition[:colon]),
+                     name: strings.TrimSpace(definition[colon + 1:])}
+  if !vault.ValidGroup(gp.group) {
+    return groupProfile{}, errors.New("Invalid Group Name " + gp.group)
+  }
+  if gp.name == "" {
+    return groupProfile{}, errors.New("Profile Name Required")
+  }
+  return gp, nil
+}
+
+/* Returns an error if a group profile names a profile that doesn't exist. */
+func checkGroupProfiles() error {
+  for _, gp := range groupProfiles {
+    if profileNamed(gp.name) == nil {
+      return errors.New("No Profile Named " + gp.name)
+    }
+  }
+  return nil
+}
+
+/*
+ * Returns the name of the generator profile of group, which is the profile
+ * of the closest group it is in that has one, or "" if none of them do.
+ */
+func groupProfileName(group string) string {
+  name, longest := "", -1
+  for _, gp := range groupProfiles {
+    if (group == gp.group || strings.HasPrefix(group, gp.group + "/")) &&
+        len(gp.group) > longest {
+      name, longest = gp.name, len(gp.group)
+    }
+  }
+  return name
+}
+
+/*
+ * Returns the name of the generator profile passwords of e are generated
+ * with, which is the profile attached to e or else the profile of its
+ * group, or "" if there is neither.
+ */
+func entryProfileName(e *vault.Entry) string {
+  if e.Generator != nil && profileNamed(e.Generator.Profile) != nil {
+    return e.Generator.Profile
+  }
+  return groupProfileName(e.Group)
+}
+
+/*
+ * Returns the generator settings new passwords of e are generated with,
+ * which are the rules of its generator profile if it has one, or else the
+ * settings its password was last generated with, or else the default ones.
+ */
+func entryGenerator(e *vault.Entry) vault.Generator {
+  if p := profileNamed(entryProfileName(e)); p != nil {
+    return p.generator
+  }
+  if e.Generator != nil {
+    return *e.Generator
+  }
+  return defaultGenerator
+}
+
+/*
+ * Goes to the GENERATE PASSWORD WITH RULES menu to pick a profile or rules
+ * for the password of the new or edited entry.
+ */
+func startRules() {
+  contentExtra = ""
+  edit_box.text = make([]byte, 0)
+  edit_box.MoveCursorTo(0)
+  addToMenu("Generator Rules")
+}
+
+/* Leaves the GENERATE PASSWORD WITH RULES menu. */
+func endRules() {
+  contentExtra = ""
+  subtractFromMenu(1)
+}
+
+/*
+ * Returns the name of the profile used when no profile or rules are input,
+ * which is the profile of the edited entry, or "" if there is none.
+ */
+func rulesDefault() string {
+  if menuList[len(menuList) - 2] == "Edit Content" {
+    return entryProfileName(selectedEntry())
+  }
+  return ""
+}
+
+/* GENERATE PASSWORD WITH RULES */
+func rulesSettings() {
+  ctrlC = true
+  passwordInput = false
+  locationTitle = "GENERATE PASSWORD WITH RULES"
+  options = "Enter:GENERATE"
+  bottomCaption = "Input Profile Number or Rules: "
+  contentString = "Choose a Generator Profile or Input Rules for the " +
+    "Password"
+  if len(profiles) > 0 {
+    contentString += "\n"
+  }
+  for x, p := range profiles {
+    contentString += "\n[" + strconv.Itoa(x + 1) + "] " + p.name + ": " +
+      rulesString(&p.generator)
+  }
+  if name := rulesDefault(); name != "" {
+    contentString += "\n\nPress Enter for the " + name + " Profile"
+  }
+  contentString += "\n\nRules: length=LENGTH upper[=MIN] lower[=MIN] " +
+    "digits[=MIN] symbols[=MIN] symbolSet=SYMBOLS noLookAlikes noRepeats " +
+    "noSequences first=upper|lower|digit|symbol|letter"
+  if len(contentExtra) > 0 {
+    contentString += "\n\n" + contentExtra
+  }
+  termbox.SetCursor(len(bottomCaption) + edit_box.CursorX(), h - 1)
+}
+
+func rulesOptions(ev termbox.Event) {
+  if ev.Key != termbox.KeyEnter {
+    textEdit(ev)
+    return
+  }
+  value = string(edit_box.text)
+  edit_box.text = make([]byte, 0)
+  edit_box.MoveCursorTo(0)
+  var g vault.Generator
+  number, err := strconv.Atoi(value)
+  if strings.TrimSpace(value) == "" {
+    p := profileNamed(rulesDefault())
+    if p == nil {
+      contentExtra = "Input a Profile Number or Rules"
+      return
+    }
+    g = p.generator
+  } else if err == nil {
+    if number < 1 || number > len(profiles) {
+      contentExtra = "Profile Number Must be Between 1 and " +
+        strconv.Itoa(len(profiles))
+      return
+    }
+    g = profiles[number - 1].generator
+  } else {
+    g, err = parseRules(value)
+    if err != nil {
+      contentExtra = err.Error()
+      return
+    }
+  }
+  password, err := genPass(&g)
+  if err != nil {
+    contentExtra = err.Error()
+    return
+  }
+  useRules(password, g)
+}
+
+/*
+ * Uses password, generated with g, for the new or edited entry the
+ * GENERATE PASSWORD WITH RULES menu was opened for.
+ */
+func useRules(password string, g vault.Generator) {
+  endRules()
+  if menu == "New" {
+    newValue = append(newValue, password)
+    newGenerator = &g
+    step[2], step[10] = false, true
+  } else if menu == "Edit Content" {
+    e := selectedEntry()
+    e.ChangePassword(password, time.Now(), passwordHistoryDepth)
+    e.Generator = &g
+    contentString = "Password Changed"
+    step[0] = false
+    subtractFromMenu(1)
+    saveEdit(e)
+  }
+}
+
+/*
+ * Returns the generator settings of the gen command options opts, which are
+ * the rules of --profile or --rules or else the default settings, with the
+ * password length of --length if it is set.
+ */
+func genOptions(opts map[string]string) (vault.Generator, error) {
+  g := defaultGenerator
+  if name, ok := opts["profile"]; ok {
+    p := profileNamed(name)
+    if p == nil {
+      return g, errors.New(name + ": No Such Profile")
+    }
+    g = p.generator
+  } else if rules, ok := opts["rules"]; ok {
+    var err error
+    if g, err = parseRules(rules); err != nil {
+      return g, errors.New(rules + ": " + err.Error())
+    }
+  }
+  if l, ok := opts["length"]; ok {
+    length, err := strconv.Atoi(l)
+    if err != nil || length < 4 || length > 65535 {
+      return g, errors.New(l + ": Password Length Must be Between 4 and " +
+                           "65535")
+    }
+    g.Length = uint16(length)
+  }
+  return g, nil
+}
+
@@ -0,0 +1,254 @@
+/*-
+ * Copyright (C) 2014-2017, Vi Grey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY AUTHOR AND CONTRIBUTORS "AS IS" AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL AUTHOR OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+/*
+ * Guided rotation of entry passwords.  A new password is generated with the
+ * remembered generator settings of the entry and the old and new passwords
+ * are copied in turn for the change password form of the site.  The new
+ * password is only saved once the change is confirmed, and the old one is
+ * kept in the password history so the rotation can be rolled back.
+ */
+
+package main
+
+import (
+  "github.com/atotto/clipboard"
+  "github.com/nsf/termbox-go"
+  "latchbox/vault"
+  "time"
+)
+
+const (
+  /* The new password is shown and the old one can be copied. */
+  rotateStart = iota
+  /* The old password is copied and the new one can be copied. */
+  rotateOldCopied
+  /* The new password is copied and the change can be confirmed. */
+  rotateNewCopied
+  /* The new password is saved and the rotation can be rolled back. */
+  rotateDone
+  /* The rotation is over and nothing more can be done. */
+  rotateEnded
+)
+
+var (
+  rotateStep int
+  rotatePassword, rotateMessage string
+  rotateGenerator *vault.Generator
+  rotateBefore vault.Snapshot
+)
+
+/*
+ * Generator settings used to rotate passwords of entries that don't have
+ * any of their own, with at least one character of every kind.
+ */
+var defaultGenerator = *vault.NewGenerator(20, []bool{true, true, true,
+                                                      true})
+
+/* Starts rotating the password of e with a newly generated password. */
+func startRotation(e *vault.Entry) {
+  rotateStep = rotateStart
+  rotateMessage = ""
+  g := entryGenerator(e)
+  rotateGenerator = &g
+  rotateGenerate(e)
+}
+
+/*
+ * Generates the new password of e with rotateGenerator, ending the rotation
+ * if its rules can't be followed.
+ */
+func rotateGenerate(e *vault.Entry) {
+  password, err := genPass(rotateGenerator)
+  if err != nil {
+    rotatePassword = ""
+    rotateMessage = "Unable to Rotate the Password of " + e.NameGroup() +
+      " (" + err.Error() + ")"
+    rotateStep = rotateEnded
+    return
+  }
+  rotatePassword = password
+}
+
+/* Forgets the password that was being rotated to. */
+func endRotation() {
+  rotateStep = rotateStart
+  rotatePassword = ""
+  rotateMessage = ""
+  rotateGenerator = nil
+  rotateBefore = vault.Snapshot{}
+}
+
+/* ROTATE PASSWORD (of the viewed entry) */
+func rotateSettings() {
+  ctrlC = true
+  termbox.HideCursor()
+  locationTitle = "ROTATE PASSWORD"
+  bottomCaption = ""
+  e := selectedEntry()
+  password := rotatePassword
+  if !show {
+    password = concealed(password)
+  }
+  if rotateStep == rotateStart {
+    contentString = "Rotate the Password of " + e.NameGroup() +
+      "\n\nNew Password: " + password + "\n\nCopy the Old Password First " +
+      "for the Change Password Form of the Site"
+    options = "Enter:COPY OLD PASSWORD  g:GENERATE AGAIN"
+    if e.Password == "" {
+      contentString = "Rotate the Password of " + e.NameGroup() +
+        "\n\nNew Password: " + password
+      options = "Enter:COPY NEW PASSWORD  g:GENERATE AGAIN"
+    }
+    if show {
+      options += "  s:HIDE PASSWORD"
+    } else {
+      options += "  s:SHOW PASSWORD"
+    }
+  } else if rotateStep == rotateOldCopied {
+    contentString = "Old Password Copied.  Paste It Into the Change " +
+      "Password Form of the Site, Then Copy the New Password"
+    options = "Enter:COPY NEW PASSWORD  Ctrl-C:CANCEL"
+  } else if rotateStep == rotateNewCopied {
+    contentString = "New Password Copied.  Paste It Into the Change " +
+      "Password Form of the Site and Submit It\n\nDid the Site Accept the " +
+      "New Password?"
+    options = "y:YES  n:NO  p:COPY NEW PASSWORD"
+    if e.Password != "" {
+      options += "  o:COPY OLD PASSWORD"
+    }
+  } else if rotateStep == rotateDone {
+    contentString = "Password of " + e.NameGroup() + " Rotated.  The Old " +
+      "Password Was Kept in the Password History\n\nRoll Back if the Site " +
+      "Still Uses the Old Password"
+    options = "b:ROLL BACK  Ctrl-C:CLEAR CLIPBOARD/BACK"
+  } else {
+    contentString = rotateMessage
+    options = "Ctrl-C:CLEAR CLIPBOARD/BACK"
+  }
+  if len(contentExtra) > 0 {
+    contentString += "\n\n" + contentExtra
+  }
+}
+
+func rotateOptions(ev termbox.Event) {
+  e := selectedEntry()
+  if rotateStep == rotateStart {
+    if ev.Ch == 'g' {
+      contentExtra = ""
+      rotateGenerate(e)
+    } else if ev.Ch == 's' {
+      show = !show
+    } else if ev.Key == termbox.KeyEnter {
+      if e.Password == "" {
+        rotateCopy(rotatePassword, rotateNewCopied)
+      } else {
+        rotateCopy(e.Password, rotateOldCopied)
+      }
+    }
+  } else if rotateStep == rotateOldCopied {
+    if ev.Key == termbox.KeyEnter {
+      rotateCopy(rotatePassword, rotateNewCopied)
+    }
+  } else if rotateStep == rotateNewCopied {
+    if ev.Ch == 'y' {
+      commitRotation(e)
+    } else if ev.Ch == 'n' {
+      contentExtra = ""
+      rotateMessage = "Password of " + e.NameGroup() + " Was NOT Changed"
+      rotateStep = rotateEnded
+    } else if ev.Ch == 'o' && e.Password != "" {
+      rotateCopy(e.Password, rotateNewCopied)
+    } else if ev.Ch == 'p' {
+      rotateCopy(rotatePassword, rotateNewCopied)
+    }
+  } else if rotateStep == rotateDone {
+    if ev.Ch == 'b' {
+      rollBackRotation(e)
+    }
+  }
+}
+
+/* Copies password and moves on to rotation step next if it was copied. */
+func rotateCopy(password string, next int) {
+  if err := clipboard.WriteAll(password); err != nil {
+    contentExtra = "Unable to Copy Content to Clipboard"
+    return
+  }
+  contentExtra = ""
+  contentCopied = true
+  rotateStep = next
+}
+
+/*
+ * Saves the new password of e, keeping the old one in its password history
+ * even if the password history depth is 0.  An expiry date set on e is
+ * moved on by as long as the old password was meant to last, or removed if
+ * that can't be worked out.
+ */
+func commitRotation(e *vault.Entry) {
+  depth := passwordHistoryDepth
+  if depth < 1 {
+    depth = 1
+  }
+  now := time.Now()
+  before := vlt.Snapshot()
+  lifetime := e.Expires.Sub(e.PasswordSet())
+  e.ChangePassword(rotatePassword, now, depth)
+  e.Generator = rotateGenerator
+  e.Modified = now
+  if lifetime > 0 {
+    e.Expires = time.Unix(now.Add(lifetime).Unix(), 0)
+  } else {
+    e.Expires = time.Time{}
+  }
+  if err := writeData(); err != nil {
+    vlt.Revert(before)
+    contentExtra = "Unable to Modify Password File (" + err.Error() + ")"
+    return
+  }
+  addUndo("Password Rotation of " + e.NameGroup(), before)
+  contentExtra = ""
+  rotateBefore = before
+  rotateStep = rotateDone
+}
+
+/* Puts the old password of e back after it was rotated. */
+func rollBackRotation(e *vault.Entry) {
+  nameGroup := e.NameGroup()
+  before := vlt.Snapshot()
+  vlt.Revert(rotateBefore)
+  if err := writeData(); err != nil {
+    vlt.Revert(before)
+    contentExtra = "Unable to Modify Password File (" + err.Error() + ")"
+    return
+  }
+  addUndo("Password Rotation Rollback of " + nameGroup, before)
+  contentExtra = ""
+  rotateMessage = "Password Rotation of " + nameGroup + " Rolled Back"
+  rotateStep = rotateEnded
+}
@@ -0,0 +1,137 @@
+/*-
+ * Copyright (C) 2014-2017, Vi Grey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY AUTHOR AND CONTRIBUTORS "AS IS" AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL AUTHOR OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+/*
+ * Keyboard layouts keyboard walks like "qwerty" or "7896" are found on.
+ */
+
+package strength
+
+import (
+  "strings"
+)
+
+const qwertyLayout = "" +
+  "`~ 1! 2@ 3# 4$ 5% 6^ 7& 8* 9( 0) -_ =+\n" +
+  "    qQ wW eE rR tT yY uU iI oO pP [{ ]} \\|\n" +
+  "     aA sS dD fF gG hH jJ kK lL ;: '\"\n" +
+  "      zZ xX cC vV bB nN mM ,< .> /?"
+
+const dvorakLayout = "" +
+  "`~ 1! 2@ 3# 4$ 5% 6^ 7& 8* 9( 0) [{ ]}\n" +
+  "    '\" ,< .> pP yY fF gG cC rR lL /? =+ \\|\n" +
+  "     aA oO eE uU iI dD hH tT nN sS -_\n" +
+  "      ;: qQ jJ kK xX bB mM wW vV zZ"
+
+const keypadLayout = "" +
+  "  / * -\n" +
+  "7 8 9 +\n" +
+  "4 5 6\n" +
+  "1 2 3\n" +
+  "  0 ."
+
+/*
+ * A keyboard layout.  graph has the keys next to each key in a fixed order
+ * of directions, with "" where there is no key, and each key is its
+ * unshifted character followed by its shifted character if it has one.
+ */
+type keyboard struct {
+  graph map[rune][]string
+  /* Characters typed with shift. */
+  shiftedChars string
+  startingPositions, averageDegree float64
+}
+
+var keyboards = []*keyboard{
+  newKeyboard(qwertyLayout, true),
+  newKeyboard(dvorakLayout, true),
+  newKeyboard(keypadLayout, false),
+}
+
+/*
+ * Returns the keyboard of layout, where the rows of slanted layouts are
+ * shifted half a key to the right from the row above like on a typewriter
+ * keyboard and the rows of other layouts are aligned like on a keypad.
+ */
+func newKeyboard(layout string, slanted bool) *keyboard {
+  type position struct {
+    x, y int
+  }
+  keys := make(map[position]string)
+  var order []position
+  for y, line := range strings.Split(layout, "\n") {
+    slant := 0
+    if slanted {
+      slant = y
+    }
+    for x := 0; x < len(line); x++ {
+      if line[x] == ' ' {
+        continue
+      }
+      token := strings.Fields(line[x:])[0]
+      p := position{(x - slant) / (len(token) + 1), y}
+      keys[p] = token
+      order = append(order, p)
+      x += len(token)
+    }
+  }
+  var directions []position
+  if slanted {
+    directions = []position{{-1, 0}, {0, -1}, {1, -1}, {1, 0}, {0, 1},
+                            {-1, 1}}
+  } else {
+    directions = []position{{-1, 0}, {-1, -1}, {0, -1}, {1, -1}, {1, 0},
+                            {1, 1}, {0, 1}, {-1, 1}}
+  }
+  k := &keyboard{graph: make(map[rune][]string)}
+  degrees := 0
+  for _, p := range order {
+    if len(keys[p]) > 1 {
+      k.shiftedChars += keys[p][1:]
+    }
+    adjacent := make([]string, len(directions))
+    for x, d := range directions {
+      adjacent[x] = keys[position{p.x + d.x, p.y + d.y}]
+    }
+    for _, r := range keys[p] {
+      k.graph[r] = adjacent
+      for _, a := range adjacent {
+        if a != "" {
+          degrees++
+        }
+      }
+    }
+  }
+  k.startingPositions = float64(len(k.graph))
+  k.averageDegree = float64(degrees) / k.startingPositions
+  return k
+}
+
+/* Returns whether r is typed with shift on k. */
+func (k *keyboard) shifted(r rune) bool {
+  return strings.ContainsRune(k.shiftedChars, r)
+}
@@ -0,0 +1,2979 @@
+/*-
+ * Copyright (C) 2014-2017, Vi Grey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY AUTHOR AND CONTRIBUTORS "AS IS" AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL AUTHOR OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+/*
+ * Dictionaries the strength of passwords is estimated with, most common
+ * word first.  The words are taken from the frequency lists of zxcvbn,
+ * which are under the following license.
+ *
+ * Copyright (c) 2012-2016 Dan Wheeler and Dropbox, Inc.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining
+ * a copy of this software and associated documentation files (the
+ * "Software"), to deal in the Software without restriction, including
+ * without limitation the rights to use, copy, modify, merge, publish,
+ * distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so, subject to
+ * the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be
+ * included in all copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND,
+ * EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF
+ * MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+ * NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE
+ * LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+ * OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION
+ * WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package strength
+
+/* Common passwords. */
+const passwordList = "password 123456 12345678 1234 qwerty 12345 dragon " +
+  "pussy baseball football letmein monkey 696969 abc123 mustang shadow " +
+  "master 111111 2000 jordan superman harley 1234567 fuckme hunter fuckyou " +
+  "trustno1 ranger buster tigger soccer fuck batman test pass killer hockey " +
+  "charlie love sunshine asshole 6969 pepper access 123456789 654321 maggie " +
+  "starwars silver dallas yankees 123123 666666 hello orange biteme freedom " +
+  "computer sexy thunder ginger hammer summer corvette fucker austin 1111 " +
+  "merlin 121212 golfer cheese princess chelsea diamond yellow bigdog " +
+  "secret asdfgh sparky cowboy camaro matrix falcon iloveyou guitar purple " +
+  "scooter phoenix aaaaaa tigers porsche mickey maverick cookie nascar " +
+  "peanut 131313 money horny samantha panties steelers snoopy boomer " +
+  "whatever iceman smokey gateway dakota cowboys eagles chicken dick black " +
+  "zxcvbn ferrari knight hardcore compaq coffee booboo bitch bulldog xxxxxx " +
+  "welcome player ncc1701 wizard scooby junior internet bigdick brandy " +
+  "tennis blowjob banana monster spider lakers rabbit enter mercedes fender " +
+  "yamaha diablo boston tiger marine chicago rangers gandalf winter bigtits " +
+  "barney raiders porn badboy blowme spanky bigdaddy chester london " +
+  "midnight blue fishing 000000 hannah slayer 11111111 sexsex redsox " +
+  "thx1138 asdf marlboro panther zxcvbnm arsenal qazwsx mother 7777777 " +
+  "jasper winner golden butthead viking iwantu angels prince cameron girls " +
+  "madison hooters startrek captain maddog jasmine butter booger golf " +
+  "rocket theman liverpoo flower forever muffin turtle sophie redskins " +
+  "toyota sierra winston giants packers newyork casper bubba 112233 lovers " +
+  "mountain united driver helpme fucking pookie lucky maxwell 8675309 bear " +
+  "suckit gators 5150 222222 shithead fuckoff jaguar hotdog tits gemini " +
+  "lover xxxxxxxx 777777 canada florida 88888888 rosebud metallic doctor " +
+  "trouble success stupid tomcat warrior peaches apples fish qwertyui magic " +
+  "buddy dolphins rainbow gunner 987654 freddy alexis braves cock 2112 1212 " +
+  "cocacola xavier dolphin testing bond007 member voodoo 7777 samson apollo " +
+  "fire tester beavis voyager porno rush2112 beer apple scorpio skippy " +
+  "sydney red123 power beaver star jackass flyers boobs 232323 zzzzzz " +
+  "scorpion doggie legend ou812 yankee blazer runner birdie bitches 555555 " +
+  "topgun asdfasdf heaven viper animal 2222 bigboy 4444 private godzilla " +
+  "lifehack phantom rock august sammy cool platinum jake bronco heka6w2 " +
+  "copper cumshot garfield willow cunt slut 69696969 kitten super jordan23 " +
+  "eagle1 shelby america 11111 free 123321 chevy bullshit broncos horney " +
+  "surfer nissan 999999 saturn airborne elephant shit action adidas qwert " +
+  "1313 explorer police christin december wolf sweet therock online " +
+  "dickhead brooklyn cricket racing penis 0000 teens redwings dreams " +
+  "michigan hentai magnum 87654321 donkey trinity digital 333333 cartman " +
+  "guinness 123abc speedy buffalo kitty pimpin eagle einstein nirvana " +
+  "vampire xxxx playboy pumpkin snowball test123 sucker mexico beatles " +
+  "fantasy celtic cherry cassie 888888 sniper genesis hotrod reddog " +
+  "alexande college jester passw0rd bigcock lasvegas slipknot 3333 death " +
+  "1q2w3e eclipse 1q2w3e4r drummer montana music aaaa carolina colorado " +
+  "creative hello1 goober friday bollocks scotty abcdef bubbles hawaii " +
+  "fluffy horses thumper 5555 pussies darkness asdfghjk boobies buddha " +
+  "sandman naughty honda azerty 6666 shorty money1 beach loveme 4321 simple " +
+  "poohbear 444444 badass destiny vikings lizard assman nintendo 123qwe " +
+  "november xxxxx october leather bastard 101010 extreme password1 pussy1 " +
+  "lacrosse hotmail spooky amateur alaska badger paradise maryjane poop " +
+  "mozart video vagina spitfire cherokee cougar 420420 horse enigma raider " +
+  "brazil blonde 55555 dude drowssap lovely 1qaz2wsx booty snickers nipples " +
+  "diesel rocks eminem westside suzuki passion hummer ladies alpha suckme " +
+  "147147 pirate semperfi jupiter redrum freeuser wanker stinky ducati " +
+  "paris babygirl windows spirit pantera monday patches brutus smooth " +
+  "penguin marley forest cream 212121 flash maximus nipple vision pokemon " +
+  "champion fireman indian softball picard system cobra enjoy lucky1 boogie " +
+  "marines security dirty admin wildcats pimp dancer hardon fucked abcd1234 " +
+  "abcdefg ironman wolverin freepass bigred squirt justice hobbes pearljam " +
+  "mercury domino 9999 rascal hitman mistress bbbbbb peekaboo naked " +
+  "budlight electric sluts stargate saints bondage bigman zombie swimming " +
+  "duke qwerty1 babes scotland disney rooster mookie swordfis hunting " +
+  "blink182 8888 samsung bubba1 whore general passport aaaaaaaa erotic " +
+  "liberty arizona abcd newport skipper rolltide balls happy1 galore christ " +
+  "weasel 242424 wombat digger classic bulldogs poopoo accord popcorn " +
+  "turkey bunny mouse 007007 titanic liverpool dreamer everton chevelle " +
+  "psycho nemesis pontiac connor eatme lickme cumming ireland spiderma " +
+  "patriots goblue devils empire asdfg cardinal shaggy froggy qwer kawasaki " +
+  "kodiak phpbb 54321 chopper hooker whynot lesbian snake teen ncc1701d " +
+  "qqqqqq airplane britney avalon sugar sublime wildcat raven scarface " +
+  "elizabet 123654 trucks wolfpack pervert redhead american bambam woody " +
+  "shaved snowman tiger1 chicks raptor 1969 stingray shooter france stars " +
+  "madmax sports 789456 simpsons lights chronic hahaha packard hendrix " +
+  "service spring srinivas spike 252525 bigmac suck single popeye tattoo " +
+  "texas bullet taurus sailor wolves panthers japan strike pussycat chris1 " +
+  "loverboy berlin sticky tarheels russia wolfgang testtest mature catch22 " +
+  "juice michael1 nigger 159753 alpha1 trooper hawkeye freaky dodgers " +
+  "pakistan machine pyramid vegeta katana moose tinker coyote infinity " +
+  "pepsi letmein1 bang hercules james1 tickle outlaw browns billybob pickle " +
+  "test1 sucks pavilion changeme caesar prelude darkside bowling wutang " +
+  "sunset alabama danger zeppelin pppppp 2001 ping darkstar madonna qwe123 " +
+  "bigone casino charlie1 mmmmmm integra wrangler apache tweety qwerty12 " +
+  "bobafett transam 2323 seattle ssssss openup pandora pussys trucker " +
+  "indigo storm malibu weed review babydoll doggy dilbert pegasus joker " +
+  "catfish flipper fuckit detroit cheyenne bruins smoke marino fetish " +
+  "xfiles stinger pizza babe stealth manutd gundam cessna longhorn presario " +
+  "mnbvcxz wicked mustang1 victory 21122112 awesome athena q1w2e3r4 holiday " +
+  "knicks redneck 12341234 gizmo scully dragon1 devildog triumph bluebird " +
+  "shotgun peewee angel1 metallica madman impala lennon omega access14 " +
+  "enterpri search smitty blizzard unicorn tight asdf1234 trigger truck " +
+  "beauty thailand 1234567890 cadillac castle bobcat buddy1 sunny stones " +
+  "asian butt loveyou hellfire hotsex indiana panzer lonewolf trumpet " +
+  "colors blaster 12121212 fireball precious jungle atlanta gold corona " +
+  "polaris timber theone baller chipper skyline dragons dogs licker " +
+  "engineer kong pencil basketba hornet barbie wetpussy indians redman " +
+  "foobar travel morpheus target 141414 hotstuff photos rocky1 fuck_inside " +
+  "dollar turbo design hottie 202020 blondes 4128 lestat avatar goforit " +
+  "random abgrtyu jjjjjj cancer q1w2e3 smiley express virgin zipper " +
+  "wrinkle1 babylon consumer monkey1 serenity samurai 99999999 bigboobs " +
+  "skeeter joejoe master1 aaaaa chocolat christia stephani tang 1234qwer " +
+  "98765432 sexual maxima 77777777 buckeye highland seminole reaper bassman " +
+  "nugget lucifer airforce nasty warlock 2121 dodge chrissy burger snatch " +
+  "pink gang maddie huskers piglet photo dodger paladin chubby buckeyes " +
+  "hamlet abcdefgh bigfoot sunday manson goldfish garden deftones icecream " +
+  "blondie spartan charger stormy juventus galaxy escort zxcvb planet blues " +
+  "david1 ncc1701e 1966 51505150 cavalier gambit ripper oicu812 nylons " +
+  "aardvark whiskey bing plastic anal babylon5 loser racecar insane " +
+  "yankees1 mememe hansolo chiefs fredfred freak frog salmon concrete zxcv " +
+  "shamrock atlantis wordpass rommel 1010 predator massive cats sammy1 " +
+  "mister stud marathon rubber ding trunks desire montreal justme faster " +
+  "irish 1999 jessica1 alpine diamonds 00000 swinger shan stallion pitbull " +
+  "letmein2 ming shadow1 clitoris fuckers jackoff bluesky sundance renegade " +
+  "hollywoo 151515 wolfman soldier ling goddess manager sweety titans fang " +
+  "ficken niners bubble hello123 ibanez sweetpea stocking 323232 tornado " +
+  "content aragorn trojan christop rockstar geronimo pascal crimson google " +
+  "fatcat lovelove cunts stimpy finger wheels viper1 latin greenday " +
+  "987654321 creampie hiphop snapper funtime duck trombone adult cookies " +
+  "mulder westham latino jeep ravens drizzt madness energy kinky 314159 " +
+  "slick rocker 55555555 mongoose speed dddddd catdog cheng ghost gogogo " +
+  "tottenha curious butterfl mission january shark techno lancer lalala " +
+  "chichi orion trixie delta bobbob bomber kang 1968 spunky liquid beagle " +
+  "granny network kkkkkk 1973 biggie beetle teacher toronto anakin genius " +
+  "cocks dang karate snakes bangkok fuckyou2 pacific daytona infantry " +
+  "skywalke sailing raistlin vanhalen huang blackie tarzan strider sherlock " +
+  "gong dietcoke ultimate shai sprite ting artist chai chao devil python " +
+  "ninja ytrewq superfly 456789 tian jing jesus1 freedom1 drpepper chou " +
+  "hobbit shen nolimit mylove biscuit yahoo shasta sex4me smoker pebbles " +
+  "pics philly tong tintin lesbians cactus frank1 tttttt chun danni emerald " +
+  "showme pirates lian dogg xiao xian tazman tanker toshiba gotcha rang " +
+  "keng jazz bigguy yuan tomtom chaos fossil racerx creamy bobo musicman " +
+  "warcraft blade shuang shun lick jian microsoft rong feng getsome quality " +
+  "1977 beng wwwwww yoyoyo zhang seng harder qazxsw qian cong chuan deng " +
+  "nang boeing keeper western 1963 subaru sheng thuglife teng jiong miao " +
+  "mang maniac pussie a1b2c3 zhou zhuang xing stonecol spyder liang jiang " +
+  "memphis ceng magic1 logitech chuang sesame shao poison titty kuan kuai " +
+  "mian guan hamster guai ferret geng duan pang maiden quan velvet nong " +
+  "neng nookie buttons bian bingo biao zhong zeng zhun ying zong xuan zang " +
+  "0.0.000 suan shei shui sharks shang shua peng pian piao liao meng miami " +
+  "reng guang cang ruan diao luan qing chui chuo cuan nuan ning heng huan " +
+  "kansas muscle weng 1passwor bluemoon zhui zhua xiang zheng zhen zhei " +
+  "zhao zhan yomama zhai zhuo zuan tarheel shou shuo tiao leng kuang jiao " +
+  "13579 basket qiao qiong qiang chuai nian niao niang huai 22222222 zhuan " +
+  "zhuai shuan shuai stardust jumper 66666666 charlott qwertz bones " +
+  "waterloo 2002 11223344 oldman trains vertigo 246810 black1 swallow " +
+  "smiles standard alexandr parrot user 1976 surfing pioneer apple1 asdasd " +
+  "auburn hannibal frontier panama welcome1 vette blue22 shemale 111222 " +
+  "baggins groovy global 181818 1979 blades spanking byteme lobster dawg " +
+  "japanese 1970 1964 2424 polo coco deedee mikey 1972 171717 1701 strip " +
+  "jersey green1 capital putter vader seven7 banshee grendel dicks hidden " +
+  "iloveu 1980 ledzep 147258 female bugger buffett molson 2020 wookie " +
+  "sprint jericho 102030 ranger1 trebor deepthroat bonehead molly1 mirage " +
+  "models 1984 2468 showtime squirrel pentium anime gator powder twister " +
+  "connect neptune engine eatshit mustangs woody1 shogun septembe pooh " +
+  "jimbo russian sabine voyeur 2525 363636 camel germany giant qqqq nudist " +
+  "bone sleepy tequila fighter obiwan makaveli vacation walnut 1974 ladybug " +
+  "cantona ccbill satan rusty1 passwor1 columbia kissme motorola william1 " +
+  "1967 zzzz skater smut matthew1 valley coolio dagger boner bull horndog " +
+  "jason1 penguins rescue griffey 8j4ye3uz californ champs qwertyuiop " +
+  "portland colt45 xxxxxxx xanadu tacoma carpet gggggg safety palace italia " +
+  "picturs picasso thongs tempest asd123 hairy foxtrot nimrod hotboy 343434 " +
+  "1111111 asdfghjkl goose overlord stranger 454545 shaolin sooners " +
+  "socrates spiderman peanuts 13131313 andrew1 filthy ohyeah africa " +
+  "intrepid pickles assass fright potato hhhhhh kingdom weezer 424242 " +
+  "pepsi1 throat looker puppy butch sweets megadeth analsex nymets ddddddd " +
+  "bigballs oakland oooooo qweasd chucky carrot chargers discover dookie " +
+  "condor horny1 sunrise sinner jojo megapass martini assfuck ffffff " +
+  "mushroom jamaica 7654321 77777 cccccc gizmodo tractor mypass hongkong " +
+  "1975 blue123 pissing thomas1 redred basketball satan666 dublin bollox " +
+  "kingkong 1971 22222 272727 sexx bbbb grizzly passat defiant bowler " +
+  "knickers monitor wisdom slappy thor letsgo robert1 brownie 098765 " +
+  "playtime lightnin atomic goku llllll qwaszx cosmos bosco knights beast " +
+  "slapshot assword frosty dumbass mallard dddd 159357 titleist aussie " +
+  "golfing doobie loveit werewolf vipers 1965 blabla surf sucking tardis " +
+  "thegame legion rebels sarah1 onelove loulou toto blackcat 0007 tacobell " +
+  "soccer1 jedi method poopie boob breast kittycat belly pikachu thunder1 " +
+  "thankyou celtics frogger scoobydo sabbath coltrane budman jackal zzzzz " +
+  "licking gopher geheim lonestar primus pooper newpass brasil heather1 " +
+  "husker element moomoo beefcake zzzzzzzz shitty smokin jjjj anthony1 " +
+  "anubis backup gorilla fuckface lowrider punkrock traffic delta1 amazon " +
+  "fatass dodgeram dingdong qqqqqqqq breasts boots honda1 spidey poker temp " +
+  "johnjohn 147852 asshole1 dogdog tricky crusader syracuse spankme speaker " +
+  "meridian amadeus harley1 falcons turkey50 kenwood keyboard ilovesex 1978 " +
+  "shazam shalom lickit jimbob roller fatman sandiego magnus cooldude " +
+  "clover mobile plumber texas1 tool topper mariners rebel caliente celica " +
+  "oxford osiris orgasm punkin porsche9 tuesday breeze bossman kangaroo " +
+  "latinas astros scruffy qwertyu hearts jammer java 1122 goodtime chelsea1 " +
+  "freckles flyboy doodle nebraska bootie kicker webmaster vulcan 191919 " +
+  "blueeyes 321321 farside rugby director pussy69 power1 hershey hermes " +
+  "monopoly birdman blessed blackjac southern peterpan thumbs fuckyou1 " +
+  "rrrrrr a1b2c3d4 coke bohica elvis1 blacky sentinel snake1 richard1 " +
+  "1234abcd guardian candyman fisting scarlet dildo pancho mandingo lucky7 " +
+  "condom munchkin billyboy summer1 sword skiing site sony thong rootbeer " +
+  "assassin fffff fitness durango postal achilles kisses warriors plymouth " +
+  "topdog asterix hallo cameltoe fuckfuck eeeeee sithlord theking avenger " +
+  "backdoor chevrole trance cosworth houses homers eternity kingpin " +
+  "verbatim incubus 1961 blond zaphod shiloh spurs mighty aliens charly " +
+  "dogman omega1 printer aggies deadhead bitch1 stone55 pineappl thekid " +
+  "rockets camels formula oracle pussey porkchop abcde clancy mystic " +
+  "inferno blackdog steve1 alfa grumpy flames puffy proxy valhalla unreal " +
+  "herbie engage yyyyyy 010101 pistol celeb gggg portugal a12345 newbie " +
+  "mmmm 1qazxsw2 zorro writer stripper sebastia spread links metal 1221 " +
+  "565656 funfun trojans cyber hurrican moneys 1x2zkg8w zeus tomato lion " +
+  "atlantic usa123 trans aaaaaaa homerun hyperion kevin1 blacks 44444444 " +
+  "skittles fart gangbang fubar sailboat oilers buster1 hithere immortal " +
+  "sticks pilot lexmark jerkoff maryland cheers possum cutter muppet " +
+  "swordfish sport sonic peter1 jethro rockon asdfghj pass123 pornos " +
+  "ncc1701a bootys buttman bonjour 1960 bears 362436 spartans tinman " +
+  "threesom maxmax 1414 bbbbb camelot chewie gogo fusion saint dilligaf " +
+  "nopass hustler hunter1 whitey beast1 yesyes spank smudge pinkfloy " +
+  "patriot lespaul hammers formula1 sausage scooter1 orioles oscar1 " +
+  "colombia cramps exotic iguana suckers slave topcat lancelot magelan " +
+  "racer crunch british steph 456123 skinny seeking rockhard filter freaks " +
+  "sakura pacman poontang newlife homer1 klingon watcher walleye tasty " +
+  "sinatra starship steel starbuck poncho amber1 gonzo catherin candle " +
+  "firefly goblin scotch diver usmc huskies kentucky kitkat beckham bicycle " +
+  "yourmom studio 33333333 splash jimmy1 12344321 sapphire mailman raiders1 " +
+  "ddddd excalibu illini imperial lansing maxx gothic golfball facial " +
+  "front242 macdaddy qwer1234 vectra cowboys1 crazy1 dannyboy aquarius " +
+  "franky ffff sassy pppp pppppppp prodigy noodle eatpussy vortex wanking " +
+  "billy1 siemens phillies groups chevy1 cccc gggggggg doughboy dracula " +
+  "nurses loco lollipop utopia chrono cooler nevada wibble summit 1225 " +
+  "capone fugazi panda qazwsxed puppies triton 9876 nnnnnn momoney iforgot " +
+  "wolfie studly hamburg 81fukkc 741852 catman china gagging scott1 oregon " +
+  "qweqwe crazybab daniel1 cutlass holes mothers music1 walrus 1957 bigtime " +
+  "xtreme simba ssss rookie bathing rotten maestro turbo1 99999 butthole " +
+  "hhhh yoda shania phish thecat rightnow baddog greatone gateway1 abstr " +
+  "napster brian1 bogart hitler wildfire jackson1 1981 beaner yoyo " +
+  "0.0.0.000 super1 select snuggles slutty phoenix1 technics toon raven1 " +
+  "rayray 123789 1066 albion greens gesperrt brucelee hehehe kelly1 mojo " +
+  "1998 bikini woofwoof yyyy strap sites central f**k nyjets punisher " +
+  "username vanilla twisted bunghole viagra veritas pony titts labtec " +
+  "jenny1 masterbate mayhem redbull govols gremlin 505050 gmoney rovers " +
+  "diamond1 trident abnormal deskjet cuddles bristol milano vh5150 jarhead " +
+  "1982 bigbird bizkit sixers slider star69 starfish penetration tommy1 " +
+  "john316 caligula flicks films railroad cosmo cthulhu br0d3r bearbear " +
+  "swedish spawn patrick1 reds anarchy groove fuckher oooo airbus cobra1 " +
+  "clips delete duster kitty1 mouse1 monkeys jazzman 1919 262626 swinging " +
+  "stroke stocks sting pippen labrador jordan1 justdoit meatball females " +
+  "vector cooter defender nike bubbas bonkers kahuna wildman 4121 sirius " +
+  "static piercing terror teenage leelee microsof mechanic robotech rated " +
+  "chaser salsero macross quantum tsunami daddy1 cruise newpass6 nudes " +
+  "hellyeah 1959 zaq12wsx striker spice spectrum smegma thumb jjjjjjjj " +
+  "mellow cancun cartoon sabres samiam oranges oklahoma lust denali nude " +
+  "noodles brest hooter mmmmmmmm warthog blueblue zappa wolverine sniffing " +
+  "jjjjj calico freee rover pooter closeup bonsai emily1 keystone iiii 1955 " +
+  "yzerman theboss tolkien megaman rasta bbbbbbbb hal9000 goofy gringo " +
+  "gofish gizmo1 samsam scuba onlyme tttttttt corrado clown clapton bulls " +
+  "jayhawk wwww sharky seeker ssssssss pillow thesims lighter lkjhgf " +
+  "melissa1 marcius2 guiness gymnast casey1 goalie godsmack lolo rangers1 " +
+  "poppy clemson clipper deeznuts holly1 eeee kingston yosemite sucked " +
+  "sex123 sexy69 pic's tommyboy masterbating gretzky happyday frisco orchid " +
+  "orange1 manchest aberdeen ne1469 boxing korn intercourse 161616 1985 " +
+  "ziggy supersta stoney amature babyboy bcfields goliath hack hardrock " +
+  "frodo scout scrappy qazqaz tracker active craving commando cohiba " +
+  "cyclone bubba69 katie1 mpegs vsegda irish1 sexy1 smelly squerting lions " +
+  "jokers jojojo meathead ashley1 groucho cheetah champ firefox gandalf1 " +
+  "packer love69 tyler1 typhoon tundra bobby1 kenworth village volley " +
+  "wolf359 0420 000007 swimmer skydive smokes peugeot pompey legolas redhot " +
+  "rodman redalert grapes 4runner carrera floppy ou8122 quattro cloud9 " +
+  "davids nofear busty homemade mmmmm whisper vermont webmaste wives " +
+  "insertion jayjay philips topher temptress midget ripken havefun canon " +
+  "celebrity ghetto ragnarok usnavy conover cruiser dalshe nicole1 buzzard " +
+  "hottest kingfish misfit milfnew warlord wassup bigsexy blackhaw zippy " +
+  "tights kungfu labia meatloaf area51 batman1 bananas 636363 ggggg paradox " +
+  "queens adults aikido cigars hoosier eeyore moose1 warez interacial " +
+  "streaming 313131 pertinant pool6123 mayday animated banker baddest " +
+  "gordon24 ccccc fantasies aisan deadman homepage ejaculation whocares " +
+  "iscool jamesbon 1956 1pussy womam sweden skidoo spock sssss pepper1 " +
+  "pinhead micron allsop amsterda gunnar 666999 february fletch george1 " +
+  "sapper sasha1 luckydog lover1 magick popopo ultima cypress businessbabe " +
+  "brandon1 vulva vvvv jabroni bigbear yummy 010203 searay secret1 sinbad " +
+  "sexxxx soleil software piccolo thirteen leopard legacy memorex redwing " +
+  "rasputin 134679 anfield greenbay catcat feather scanner pa55word " +
+  "contortionist danzig daisy1 hores exodus iiiiii 1001 subway snapple " +
+  "sneakers sonyfuck picks poodle test1234 llll junebug marker mellon " +
+  "ronaldo roadkill amanda1 asdfjkl beaches great1 cheerleaers doitnow ozzy " +
+  "boxster brighton housewifes kkkk mnbvcx moocow vides 1717 bigmoney " +
+  "blonds 1000 storys stereo 4545 420247 seductive sexygirl lesbean justin1 " +
+  "124578 cabbage canadian gangbanged dodge1 dimas malaka puss probes " +
+  "coolman nacked hotpussy erotica kool implants intruder bigass zenith " +
+  "woohoo womans tango pisces laguna maxell andyod22 barcelon chainsaw " +
+  "chickens flash1 orgasms magicman profit pusyy pothead coconut chuckie " +
+  "clevelan builder budweise hotshot horizon experienced mondeo wifes 1962 " +
+  "stumpy smiths slacker pitchers passwords laptop allmine alliance bbbbbbb " +
+  "asscock halflife 88888 chacha saratoga sandy1 doogie qwert40 transexual " +
+  "close-up ib6ub9 volvo jacob1 iiiii beastie sunnyday stoned sonics " +
+  "starfire snapon pictuers pepe testing1 tiberius lisalisa lesbain litle " +
+  "retard ripple austin1 badgirl golfgolf flounder royals dragoon dickie " +
+  "passwor majestic poppop trailers nokia bobobo br549 minime mikemike " +
+  "whitesox 1954 3232 353535 seamus solo sluttey pictere titten lback 1024 " +
+  "goodluck fingerig gallaries goat passme oasis lockerroom logan1 rainman " +
+  "treasure custom cyclops nipper bucket homepage- hhhhh momsuck indain " +
+  "2345 beerbeer bimmer stunner 456456 tootsie testerer reefer 1012 harcore " +
+  "gollum 545454 chico caveman fordf150 fishes gaymen saleen doodoo " +
+  "pa55w0rd presto qqqqq cigar bogey helloo dutch kamikaze wasser vietnam " +
+  "visa japanees 0123 swords slapper peach masterbaiting redwood 1005 " +
+  "ametuer chiks fucing sadie1 panasoni mamas rambo unknown absolut dallas1 " +
+  "housewife keywest kipper 18436572 1515 zxczxc 303030 shaman terrapin " +
+  "masturbation mick redfish 1492 angus goirish hardcock forfun galary " +
+  "freeporn duchess olivier lotus pornographic ramses purdue traveler crave " +
+  "brando enter1 killme moneyman welder windsor wifey indon yyyyy taylor1 " +
+  "4417 picher pickup thumbnils johnboy jets ameteur amateurs apollo13 " +
+  "hambone goldwing 5050 sally1 doghouse padres pounding quest truelove " +
+  "underdog trader climber bolitas hohoho beanie beretta wrestlin stroker " +
+  "sexyman jewels johannes mets rhino bdsm balloons grils happy123 flamingo " +
+  "route66 devo outkast paintbal magpie llllllll twilight critter cupcake " +
+  "nickel bullseye knickerless videoes binladen xerxes slim slinky pinky " +
+  "thanatos meister menace retired albatros balloon goten 5551212 getsdown " +
+  "donuts nwo4life tttt comet deer dddddddd deeznutz nasty1 nonono " +
+  "enterprise eeeee misfit99 milkman vvvvvv 1818 blueboy bigbutt tech " +
+  "toolman juggalo jetski barefoot 50spanks gobears scandinavian cubbies " +
+  "nitram kings bilbo yumyum zzzzzzz stylus 321654 shannon1 server squash " +
+  "starman steeler phrases techniques laser 135790 athens cbr600 chemical " +
+  "fester gangsta fucku2 droopy objects passwd lllll manchester vedder clit " +
+  "chunky darkman buckshot buddah boobed henti winter1 bigmike beta zidane " +
+  "talon slave1 pissoff thegreat lexus matador readers armani goldstar 5656 " +
+  "fmale fuking fucku ggggggg sauron diggler pacers looser pounded premier " +
+  "triangle cosmic depeche norway helmet mustard misty1 jagger 3x7pxr " +
+  "silver1 snowboar penetrating photoes lesbens lindros roadking rockford " +
+  "1357 143143 asasas goodboy 898989 chicago1 ferrari1 galeries godfathe " +
+  "gawker gargoyle gangster rubble rrrr onetime pussyman pooppoop trapper " +
+  "cinder newcastl boricua bunny1 boxer hotred hockey1 edward1 moscow " +
+  "mortgage bigtit snoopdog joshua1 july 1230 assholes frisky sanity divine " +
+  "dharma lucky13 akira butterfly hotbox hootie howdy earthlink kiteboy " +
+  "westwood 1988 blackbir biggles wrench wrestle slippery pheonix penny1 " +
+  "pianoman thedude jenn jonjon jones1 roadrunn arrow azzer seahawks " +
+  "diehard dotcom tunafish chivas cinnamon clouds deluxe northern boobie " +
+  "momomo modles volume 23232323 bluedog wwwwwww zerocool yousuck pluto " +
+  "limewire joung awnyce gonavy haha films+pic+galeries girsl fuckthis " +
+  "girfriend uncencored a123456 chrisbln combat cygnus cupoi netscape " +
+  "hhhhhhhh eagles1 elite knockers 1958 tazmania shonuf pharmacy thedog " +
+  "midway arsenal1 anaconda australi gromit gotohell 787878 66666 carmex2 " +
+  "camber gator1 ginger1 fuzzy seadoo lovesex rancid uuuuuu 911911 bulldog1 " +
+  "heater monalisa mmmmmmm whiteout virtual jamie1 japanes james007 2727 " +
+  "2469 blam bitchass zephyr stiffy sweet1 southpar spectre tigger1 tekken " +
+  "lakota lionking jjjjjjj megatron 1369 hawaiian gymnastic golfer1 gunners " +
+  "7779311 515151 sanfran optimus panther1 love1 maggie1 pudding aaron1 " +
+  "delphi niceass bounce house1 killer1 momo musashi jammin 2003 234567 " +
+  "wp2003wp submit sssssss spikes sleeper passwort kume meme medusa mantis " +
+  "reebok 1017 artemis harry1 cafc91 fettish oceans oooooooo mango ppppp " +
+  "trainer uuuu 909090 death1 bullfrog hokies holyshit eeeeeee jasmine1 " +
+  "&amp &amp; spinner jockey babyblue gooner 474747 cheeks pass1234 parola " +
+  "okokok poseidon 989898 crusher cubswin nnnn kotaku mittens whatsup vvvvv " +
+  "iomega insertions bengals biit yellow1 012345 spike1 sowhat pitures " +
+  "pecker theend hayabusa hawkeyes florian qaz123 usarmy twinkle chuckles " +
+  "hounddog hover hothot europa kenshin kojak mikey1 water1 196969 wraith " +
+  "zebra wwwww 33333 simon1 spider1 snuffy philippe thunderb teddy1 " +
+  "marino13 maria1 redline renault aloha handyman cerberus gamecock gobucks " +
+  "freesex duffman ooooo nuggets magician longbow preacher porno1 chrysler " +
+  "contains dalejr navy buffy1 hedgehog hoosiers honey1 hott heyhey " +
+  "dutchess everest wareagle ihateyou sunflowe 3434 senators shag spoon " +
+  "sonoma stalker poochie terminal terefon maradona 1007 142536 alibaba " +
+  "america1 bartman astro goth chicken1 cheater ghost1 passpass oral " +
+  "r2d2c3po civic cicero myxworld kkkkk missouri wishbone infiniti 1a2b3c " +
+  "1qwerty wonderboy shojou sparky1 smeghead poiuy titanium lantern jelly " +
+  "1213 bayern basset gsxr750 cattle fishing1 fullmoon gilles dima obelix " +
+  "popo prissy ramrod bummer hotone dynasty entry konyor missy1 282828 " +
+  "xyz123 426hemi 404040 seinfeld pingpong lazarus marine1 12345a beamer " +
+  "babyface greece gustav 7007 ccccccc faggot foxy gladiato duckie dogfood " +
+  "packers1 longjohn radical tuna clarinet danny1 novell bonbon kashmir " +
+  "kiki mortimer modelsne moondog vladimir insert 1953 zxc123 supreme 3131 " +
+  "sexxx softail poipoi pong mars martin1 rogue avalanch audia4 55bgates " +
+  "cccccccc came11 figaro dogboy dnsadm dipshit paradigm othello operator " +
+  "tripod chopin coucou cocksuck borussia heritage hiziad homerj mullet " +
+  "whisky 4242 speedo starcraf skylar spaceman piggy tiger2 legos jezebel " +
+  "joker1 mazda 727272 chester1 rrrrrrrr dundee lumber ppppppp tranny " +
+  "aaliyah admiral comics delight buttfuck homeboy eternal kilroy violin " +
+  "wingman walmart bigblue blaze beemer beowulf bigfish yyyyyyy woodie " +
+  "yeahbaby 0123456 tbone syzygy starter linda1 merlot mexican 11235813 " +
+  "banner bangbang badman barfly grease charles1 ffffffff doberman dogshit " +
+  "overkill coolguy claymore demo nomore hhhhhhh hondas iamgod enterme " +
+  "electron eastside minimoni mybaby wildbill wildcard ipswich 200000 " +
+  "bearcat zigzag yyyyyyyy sweetnes 369369 skyler skywalker pigeon tipper " +
+  "asdf123 alphabet asdzxc babybaby banane guyver graphics chinook florida1 " +
+  "flexible fuckinside ursitesux tototo adam12 christma chrome buddie " +
+  "bombers hippie misfits 292929 woofer wwwwwwww stubby sheep sparta stang " +
+  "spud sporty pinball just4fun maxxxx rebecca1 fffffff freeway garion " +
+  "rrrrr sancho outback maggot puddin 987456 hoops mydick 19691969 bigcat " +
+  "shiner silverad templar lamer juicy mike1 maximum 1223 10101010 arrows " +
+  "alucard haggis cheech safari dog123 orion1 paloma qwerasdf presiden " +
+  "vegitto 969696 adonis cookie1 newyork1 buddyboy hellos heineken eraser " +
+  "moritz millwall visual jaybird 1983 beautifu zodiac steven1 sinister " +
+  "slammer smashing slick1 sponge teddybea ticklish jonny 1211 aptiva " +
+  "applepie bailey1 guitar1 canyon gagged fuckme1 digital1 dinosaur 98765 " +
+  "90210 clowns cubs deejay nigga naruto boxcar icehouse hotties electra " +
+  "widget 1986 2004 bluefish bingo1 ***** stratus sultan storm1 44444 4200 " +
+  "sentnece sexyboy sigma smokie spam pippo temppass manman 1022 bacchus " +
+  "aztnm axio bamboo hakr gregor hahahaha 5678 camero1 dolphin1 paddle " +
+  "magnet qwert1 pyon porsche1 tripper noway burrito bozo highheel hookem " +
+  "eddie1 entropy kkkkkkkk kkkkkkk illinois 1945 1951 24680 21212121 100000 " +
+  "stonecold taco subzero sexxxy skolko skyhawk spurs1 sputnik testpass " +
+  "jiggaman 1224 hannah1 525252 4ever carbon scorpio1 rt6ytere madison1 " +
+  "loki coolness coldbeer citadel monarch morgan1 washingt 1997 bella1 yaya " +
+  "superb taxman studman 3636 pizzas tiffany1 lassie larry1 joseph1 " +
+  "mephisto reptile razor 1013 hammer1 gypsy grande camper chippy cat123 " +
+  "chimera fiesta glock domain dieter dragonba onetwo nygiants password2 " +
+  "quartz prowler prophet towers ultra cocker corleone dakota1 cumm nnnnnnn " +
+  "boxers heynow iceberg kittykat wasabi vikings1 beerman splinter snoopy1 " +
+  "pipeline mickey1 mermaid micro meowmeow redbird baura chevys caravan " +
+  "frogman diving dogger draven drifter oatmeal paris1 longdong quant4307s " +
+  "rachel1 vegitta cobras corsair dadada mylife bowwow hotrats eastwood " +
+  "moonligh modena illusion iiiiiii jayhawks swingers shocker shrimp sexgod " +
+  "squall poiu tigers1 toejam tickler julie1 jimbo1 jefferso michael2 rodeo " +
+  "robot 1023 annie1 bball happy2 charter flasher falcon1 fiction fastball " +
+  "gadget scrabble diaper dirtbike oliver1 paco macman poopy popper postman " +
+  "ttttttt acura cowboy1 conan daewoo nemrac58 nnnnn nextel bobdylan eureka " +
+  "kimmie kcj9wx5n killbill musica volkswag wage windmill wert vintage " +
+  "iloveyou1 itsme zippo 311311 starligh smokey1 snappy soulmate plasma " +
+  "krusty just4me marius rebel1 1123 audi fick goaway rusty2 dogbone doofus " +
+  "ooooooo oblivion mankind mahler lllllll pumper puck pulsar valkyrie " +
+  "tupac compass concorde cougars delaware niceguy nocturne bob123 boating " +
+  "bronze herewego hewlett houhou earnhard eeeeeeee mingus mobydick venture " +
+  "verizon imation 1950 1948 1949 223344 bigbig wowwow sissy spiker snooker " +
+  "sluggo player1 jsbach jumbo medic reddevil reckless 123456a 1125 1031 " +
+  "astra gumby 757575 585858 chillin fuck1 radiohea upyours trek coolcool " +
+  "classics choochoo nikki1 nitro boytoy excite kirsty wingnut wireless " +
+  "icu812 1master beatle bigblock wolfen summer99 sugar1 tartar sexysexy " +
+  "senna sexman soprano platypus pixies telephon laura1 laurent rimmer 1020 " +
+  "12qwaszx hamish halifax fishhead forum dododo doit paramedi lonesome " +
+  "mandy1 uuuuu uranus ttttt bruce1 helper hopeful eduard dusty1 kathy1 " +
+  "moonbeam muscles monster1 monkeybo windsurf vvvvvvv vivid install 1947 " +
+  "187187 1941 1952 susan1 31415926 sinned sexxy smoothie snowflak playstat " +
+  "playa playboy1 toaster jerry1 marie1 mason1 merlin1 roger1 roadster " +
+  "112358 1121 andrea1 bacardi hardware 789789 5555555 captain1 fergus " +
+  "sascha rrrrrrr dome onion lololo qqqqqqq undertak uuuuuuuu uuuuuuu " +
+  "cobain cindy1 coors descent nimbus nomad nanook norwich bombay broker " +
+  "hookup kiwi winners jackpot 1a2b3c4d 1776 beardog bighead bird33 0987 " +
+  "spooge pelican peepee titan thedoors jeremy1 altima baba hardone 5454 " +
+  "catwoman finance farmboy farscape genesis1 salomon loser1 r2d2 pumpkins " +
+  "chriss cumcum ninjas ninja1 killers miller1 islander jamesbond intel " +
+  "19841984 2626 bizzare blue12 biker yoyoma sushi shitface spanker steffi " +
+  "sphinx please1 paulie pistons tiburon maxwell1 mdogg rockies armstron " +
+  "alejandr arctic banger audio asimov 753951 4you chilly care1839 flyfish " +
+  "fantasia freefall sandrine oreo ohshit macbeth madcat loveya qwerqwer " +
+  "colnago chocha cobalt crystal1 dabears nevets nineinch broncos1 epsilon " +
+  "kestrel winston1 warrior1 iiiiiiii iloveyou2 1616 woowoo sloppy specialk " +
+  "tinkerbe jellybea reader redsox1 1215 1112 arcadia baggio 555666 cayman " +
+  "cbr900rr gabriell glennwei sausages disco pass1 lovebug macmac puffin " +
+  "vanguard trinitro airwolf aaa111 cocaine cisco datsun bricks bumper " +
+  "eldorado kidrock wizard1 whiskers wildwood istheman 25802580 bigones " +
+  "woodland wolfpac strawber 3030 sheba1 sixpack peace1 physics tigger2 " +
+  "toad megan1 meow ringo amsterdam 717171 686868 5424 canuck football1 " +
+  "footjob fulham seagull orgy lobo mancity vancouve vauxhall acidburn derf " +
+  "myspace1 boozer buttercu hola minemine munch 1dragon biology bestbuy " +
+  "bigpoppa blackout blowfish bmw325 bigbob stream talisman tazz sundevil " +
+  "3333333 skate shutup shanghai spencer1 slowhand pinky1 tootie thecrow " +
+  "jubilee jingle matrix1 manowar messiah resident redbaron romans andromed " +
+  "athlon beach1 badgers guitars harald harddick gotribe 6996 7grout " +
+  "5wr2i7h8 635241 chase1 fallout fiddle fenris francesc fortuna fairlane " +
+  "felix1 gasman fucks sahara sassy1 dogpound dogbert divx1 manila pornporn " +
+  "quasar venom 987987 access1 clippers daman crusty nathan1 nnnnnnnn " +
+  "bruno1 budapest kittens kerouac mother1 waldo1 whistler whatwhat " +
+  "wanderer idontkno 1942 1946 bigdawg bigpimp zaqwsx 414141 3000gt 434343 " +
+  "serpent smurf pasword thisisit john1 robotics redeye rebelz 1011 alatam " +
+  "asians bama banzai harvest 575757 5329 fatty fender1 flower2 funky sambo " +
+  "drummer1 dogcat oedipus osama prozac private1 rampage concord cinema " +
+  "cornwall cleaner ciccio clutch corvet07 daemon bruiser boiler hjkl " +
+  "egghead mordor jamess iverson3 bluesman zouzou 090909 1002 stone1 4040 " +
+  "sexo smith1 sperma sneaky polska thewho terminat krypton lekker johnson1 " +
+  "johann rockie aspire goodie cheese1 fenway fishon fishin fuckoff1 girls1 " +
+  "doomsday pornking ramones rabbits transit aaaaa1 boyz bookworm bongo " +
+  "bunnies buceta highbury henry1 eastern mischief mopar ministry vienna " +
+  "wildone bigbooty beavis1 xxxxxx1 yogibear 000001 0815 zulu 420000 sigmar " +
+  "sprout stalin lkjhgfds lagnaf rolex redfox referee 123123123 1231 angus1 " +
+  "ballin attila greedy grunt 747474 carpedie caramel foxylady gatorade " +
+  "futbol frosch saiyan drums donner doggy1 drum doudou nutmeg quebec " +
+  "valdepen tosser tuscl comein cola deadpool bremen hotass hotmail1 eskimo " +
+  "eggman koko kieran katrin kordell1 komodo mone munich vvvvvvvv jackson5 " +
+  "2222222 bergkamp bigben zanzibar xxx123 sunny1 373737 slayer1 snoop " +
+  "peachy thecure little1 jennaj rasta69 1114 aries havana gratis calgary " +
+  "checkers flanker salope dirty1 draco dogface luv2epus rainbow6 qwerty123 " +
+  "umpire turnip vbnm tucson troll codered commande neon nico nightwin " +
+  "boomer1 bushido hotmail0 enternow keepout karen1 mnbv viewsoni volcom " +
+  "wizards 1995 berkeley woodstoc tarpon shinobi starstar phat toolbox " +
+  "julien johnny1 joebob riders reflex 120676 1235 angelus anthrax atlas " +
+  "grandam harlem hawaii50 655321 cabron challeng callisto firewall " +
+  "firefire flyer flower1 gambler frodo1 sam123 scania dingo papito " +
+  "passmast ou8123 randy1 twiggy travis1 treetop addict admin1 963852 " +
+  "aceace cirrus bobdole bonjovi bootsy boater elway7 kenny1 moonshin " +
+  "montag wayne1 white1 jazzy jakejake 1994 1991 2828 bluejays belmont " +
+  "sensei southpark peeper pharao pigpen tomahawk teensex leedsutd jeepster " +
+  "jimjim josephin melons matthias robocop 1003 1027 antelope azsxdc gordo " +
+  "hazard granada 8989 7894 ceasar cabernet cheshire chelle candy1 fergie " +
+  "fidelio giorgio fuckhead dominion qawsed trucking chloe1 daddyo nostromo " +
+  "boyboy booster bucky honolulu esquire dynamite mollydog windows1 waffle " +
+  "wealth vincent1 jabber jaguars javelin irishman idefix bigdog1 blue42 " +
+  "blanked blue32 biteme1 bearcats yessir sylveste sunfire tbird stryker " +
+  "3ip76k2 sevens pilgrim tenchi titman leeds lithium linkin marijuan " +
+  "mariner markie midnite reddwarf 1129 123asd 12312312 allstar albany " +
+  "asdf12 aspen hardball goldfing 7734 49ers carnage callum carlos1 fitter " +
+  "fandango gofast gamma fucmy69 scrapper dogwood django magneto premium " +
+  "9999999 abc1234 newyear bookie bounty brown1 bologna elway killjoy " +
+  "klondike mouser wayer impreza insomnia 24682468 2580 24242424 billbill " +
+  "bellaco blues1 blunts teaser sf49ers shovel solitude spikey pimpdadd " +
+  "timeout toffee lefty johndoe johndeer mega manolo ratman robin1 1124 " +
+  "1210 1028 1226 babylove barbados gramma 646464 carpente chaos1 fishbone " +
+  "fireblad frogs screamer scuba1 ducks doggies dicky obsidian rams " +
+  "tottenham aikman comanche corolla cumslut cyborg boston1 houdini helmut " +
+  "elvisp keksa12 monty1 wetter watford wiseguy 1989 1987 20202020 biatch " +
+  "beezer bigguns blueball bitchy wyoming yankees2 wrestler stupid1 " +
+  "sealteam sidekick simple1 smackdow sporting spiral smeller plato tophat " +
+  "test2 toomuch jello junkie maxim maxime meadow remingto roofer 124038 " +
+  "1018 1269 1227 123457 arkansas aramis beaker barcelona baltimor googoo " +
+  "goochi 852456 4711 catcher champ1 fortress fishfish firefigh geezer " +
+  "rsalinas samuel1 saigon scooby1 dick1 doom dontknow magpies manfred " +
+  "vader1 universa tulips mygirl bowtie holycow honeys enforcer waterboy " +
+  "1992 23skidoo bimbo blue11 birddog zildjian 030303 stinker stoppedby " +
+  "sexybabe speakers slugger spotty smoke1 polopolo perfect1 torpedo " +
+  "lakeside jimmys junior1 masamune 1214 april1 grinch 767676 5252 cherries " +
+  "chipmunk cezer121 carnival capecod finder fearless goats funstuff gideon " +
+  "savior seabee sandro schalke salasana disney1 duckman pancake pantera1 " +
+  "malice love123 qwert123 tracer creation cwoui nascar24 hookers erection " +
+  "ericsson edthom kokoko kokomo mooses inter 1michael 1993 19781978 " +
+  "25252525 shibby shamus skibum sheepdog sex69 spliff slipper spoons " +
+  "spanner snowbird toriamos temp123 tennesse lakers1 jomama mazdarx7 recon " +
+  "revolver 1025 1101 barney1 babycake gotham gravity hallowee 616161 " +
+  "515000 caca cannabis chilli fdsa getout fuck69 gators1 sable rumble " +
+  "dolemite dork duffer dodgers1 onions logger lookout magic32 poon twat " +
+  "coventry citroen civicsi cocksucker coochie compaq1 nancy1 buzzer " +
+  "boulder butkus bungle hogtied hotgirls heidi1 eggplant mustang6 monkey12 " +
+  "wapapapa wendy1 volleyba vibrate blink birthday4 xxxxx1 stephen1 " +
+  "suburban sheeba start1 soccer10 starcraft soccer12 peanut1 plastics " +
+  "penthous peterbil tetsuo torino tennis1 termite lemmein lakewood jughead " +
+  "melrose megane redone angela1 goodgirl gonzo1 golden1 gotyoass 656565 " +
+  "626262 capricor chains calvin1 getmoney gabber runaway salami dungeon " +
+  "dudedude opus paragon panhead pasadena opendoor odyssey magellan " +
+  "printing prince1 trustme nono buffet hound kajak killkill moto winner1 " +
+  "vixen whiteboy versace voyager1 indy jackjack bigal beech biggun blake1 " +
+  "blue99 big1 synergy success1 336699 sixty9 shark1 simba1 sebring " +
+  "spongebo spunk springs sliver phialpha password9 pizza1 pookey tickling " +
+  "lexingky lawman joe123 mike123 romeo1 redheads apple123 backbone " +
+  "aviation green123 carlitos byebye cartman1 camden chewy camaross " +
+  "favorite6 forumwp ginscoot fruity sabrina1 devil666 doughnut pantie " +
+  "oldone paintball lumina rainbow1 prosper umbrella ajax 951753 achtung " +
+  "abc12345 compact corndog deerhunt darklord dank nimitz brandy1 hetfield " +
+  "holein1 hillbill hugetits evolutio kenobi whiplash wg8e3wjf istanbul " +
+  "invis 1996 bigjohn bluebell beater benji bluejay xyzzy suckdick taichi " +
+  "stellar shaker semper splurge squeak pearls playball pooky titfuck " +
+  "joemama johnny5 marcello maxi rhubarb ratboy reload 1029 1030 1220 " +
+  "bbking baritone gryphon 57chevy 494949 celeron fishy gladiator fucker1 " +
+  "roswell dougie dicker diva donjuan nympho racers truck1 trample acer " +
+  "cricket1 climax denmark cuervo notnow nittany neutron bosco1 buffa " +
+  "breaker hello2 hydro kisskiss kittys montecar modem mississi 20012001 " +
+  "bigdick1 benfica yahoo1 striper tabasco supra 383838 456654 seneca " +
+  "shuttle penguin1 pathfind testibil thethe jeter2 marma mark1 metoo " +
+  "republic rollin redleg redbone redskin 1245 anthony7 altoids barley " +
+  "asswipe bauhaus bbbbbb1 gohome harrier golfpro goldeney 818181 6666666 " +
+  "5000 5rxypn cameron1 checker calibra freefree faith1 fdm7ed giraffe " +
+  "giggles fringe scamper rrpass1 screwyou dimples pacino ontario passthie " +
+  "oberon quest1 postov1000 puppydog puffer qwerty7 tribal adam25 a1234567 " +
+  "collie cleopatr davide namaste buffalo1 bonovox bukkake burner bordeaux " +
+  "burly hun999 enters mohawk vgirl jayden 1812 1943 222333 bigjim bigd " +
+  "zoom wordup ziggy1 yahooo workout young1 xmas zzzzzz1 surfer1 strife " +
+  "sunlight tasha1 skunk sprinter peaches1 pinetree plum pimping theforce " +
+  "thedon toocool laddie lkjh jupiter1 matty redrose 1200 102938 antares " +
+  "austin31 goose1 737373 78945612 789987 6464 calimero caster casper1 " +
+  "cement chevrolet chessie caddy canucks fellatio f00tball gateway2 " +
+  "gamecube rugby1 scheisse dshade dixie1 offshore lucas1 macaroni manga " +
+  "pringles puff trouble1 ussy coolhand colonial colt darthvad cygnusx1 " +
+  "natalie1 newark hiking errors elcamino koolaid knight1 murphy1 volcano " +
+  "idunno 2005 2233 blueberr biguns yamahar1 zapper zorro1 0911 3006 sixsix " +
+  "shopper sextoy snowboard speedway pokey playboy2 titi toonarmy lambda " +
+  "joecool juniper max123 mariposa met2002 reggae ricky1 1236 1228 1016 " +
+  "all4one baberuth asgard 484848 5683 6669 catnip charisma capslock " +
+  "cashmone galant frenchy gizmodo1 girlies screwy doubled divers dte4uw " +
+  "dragonfl treble twinkie tropical crescent cococo dabomb daffy dandfa " +
+  "cyrano nathanie boners helium hellas espresso killa kikimora w4g8at " +
+  "ilikeit iforget 1944 20002000 birthday1 beatles1 blue1 bigdicks beethove " +
+  "blacklab blazers benny1 woodwork 0069 0101 taffy 4567 shodan pavlov " +
+  "pinnacle petunia tito teenie lemonade lalakers lebowski lalalala ladyboy " +
+  "jeeper joyjoy mercury1 mantle mannn rocknrol riversid 123aaa 11112222 " +
+  "121314 1021 1004 1120 allen1 ambers amstel alice1 alleycat allegro " +
+  "ambrosia gspot goodsex hattrick harpoon 878787 8inches 4wwvte cassandr " +
+  "charlie123 gatsby generic gareth fuckme2 samm seadog satchmo scxakv " +
+  "santafe dipper outoutout madmad london1 qbg26i pussy123 tzpvaw vamp comp " +
+  "cowgirl coldplay dawgs nt5d27 novifarm notredam newness mykids bryan1 " +
+  "bouncer hihihi honeybee iceman1 hotlips dynamo kappa kahlua muffy mizzou " +
+  "wannabe wednesda whatup waterfal willy1 bear1 billabon youknow yyyyyy1 " +
+  "zachary1 01234567 070462 zurich superstar stiletto strat 427900 sigmachi " +
+  "shells sexy123 smile1 sophie1 stayout somerset playmate pinkfloyd phish1 " +
+  "payday thebear telefon laetitia kswbdu jerky metro revoluti 1216 1201 " +
+  "1204 1222 1115 archange barry1 handball 676767 chewbacc furball gocubs " +
+  "fullback gman dewalt dominiqu diver1 dhip6a olemiss mandrake mangos " +
+  "pretzel pusssy tripleh vagabond clovis dandan csfbr5yy deadspin ninguna " +
+  "ncc74656 bootsie bp2002 bourbon bumble heyyou houston1 hemlock hippo " +
+  "hornets horseman excess extensa muffin1 virginie werdna idontknow jack1 " +
+  "1bitch 151nxjmt bendover bmwbmw zaq123 wxcvbn supernov tahoe shakur " +
+  "sexyone seviyi smart1 speed1 pepito phantom1 playoffs terry1 terrier " +
+  "laser1 lite lancia johngalt jenjen midori maserati matteo miami1 " +
+  "riffraff ronald1 1218 1026 123987 1015 1103 armada architec austria " +
+  "gotmilk cambridg camero flex foreplay getoff glacier glotest froggie " +
+  "gerbil rugger sanity72 donna1 orchard oyster palmtree pajero m5wkqf " +
+  "magenta luckyone treefrog vantage usmarine tyvugq uptown abacab aaaaaa1 " +
+  "chuck1 darkange cyclones navajo bubba123 iawgk2 hrfzlz dylan1 enrico " +
+  "encore eclipse1 mutant mizuno mustang2 video1 viewer weed420 whales " +
+  "jaguar1 1990 159159 1love bears1 bigtruck bigboss blitz xqgann yeahyeah " +
+  "zeke zardoz stickman 3825 sentra shiva skipper1 singapor southpaw sonora " +
+  "squid slamdunk slimjim placid photon placebo pearl1 test12 therock1 " +
+  "tiger123 leinad legman jeepers joeblow mike23 redcar rhinos rjw7x4 1102 " +
+  "13576479 112211 gwju3g greywolf 7bgiqk 7878 535353 4snz9g candyass " +
+  "cccccc1 catfight cali fister fosters finland frankie1 gizzmo royalty " +
+  "rugrat dodo oemdlg out3xf paddy opennow puppy1 qazwsxedc ramjet abraxas " +
+  "cn42qj dancer1 death666 nudity nimda2k buick bobb braves1 henrik " +
+  "hooligan everlast karachi mortis monies motocros wally1 willie1 inspiron " +
+  "1test 2929 bigblack xytfu7 yackwin zaq1xsw2 yy5rbfsc 100100 0660 tahiti " +
+  "takehana 332211 3535 sedona seawolf skydiver spleen slash spjfet " +
+  "special1 slimshad sopranos spock1 penis1 patches1 thierry thething " +
+  "toohot limpone mash4077 matchbox masterp maxdog ribbit rockin redhat " +
+  "1113 14789632 1331 allday aladin andrey amethyst baseball1 athome goofy1 " +
+  "greenman goofball ha8fyp goodday 778899 charon chappy caracas cardiff " +
+  "capitals canada1 cajun catter freddy1 favorite2 forme forsaken feelgood " +
+  "gfxqx686 saskia sanjose salsa dilbert1 dukeduke downhill longhair " +
+  "locutus lockdown malachi mamacita lolipop rainyday pumpkin1 punker " +
+  "prospect rambo1 rainbows quake trinity1 trooper1 citation coolcat " +
+  "default deniro d9ungl daddys nautica nermal bukowski bubbles1 bogota " +
+  "buds hulk hitachi ender export kikiki kcchiefs kram morticia montrose " +
+  "mongo waqw3p wizzard whdbtp whkzyc 154ugeiu 1fuck binky bigred1 blubber " +
+  "becky1 year2005 wonderfu xrated 0001 tampabay survey tammy1 stuffer " +
+  "3mpz4r 3000 3some sierra1 shampoo shyshy slapnuts standby spartan1 " +
+  "sprocket stanley1 poker1 theshit lavalamp light1 laserjet jediknig " +
+  "jjjjj1 mazda626 menthol margaux medic1 rhino1 1209 1234321 amigos " +
+  "apricot asdfgh1 hairball hatter grimace 7xm5rq 6789 cartoons capcom " +
+  "cashflow carrots fanatic format girlie safeway dogfart dondon outsider " +
+  "odin opiate lollol love12 mallrats prague primetime21 pugsley r29hqq " +
+  "valleywa airman abcdefg1 darkone cummer natedogg nineball ndeyl5 natchez " +
+  "newone normandy nicetits buddy123 buddys homely husky iceland hr3ytm " +
+  "highlife holla earthlin exeter eatmenow kimkim k2trix kernel money123 " +
+  "moonman miles1 mufasa mousey whites warhamme jackass1 2277 20spanks " +
+  "blobby blinky bikers blackjack becca blue23 xman wyvern 085tzzqi zxzxzx " +
+  "zsmj2v suede t26gn4 sugars tantra swoosh 4226 4271 321123 383pdjvl " +
+  "shane1 shelby1 spades smother sparhawk pisser photo1 pebble peavey " +
+  "pavement thistle kronos lilbit linux melanie1 marbles redlight 1208 1138 " +
+  "1008 alchemy aolsucks alexalex atticus auditt b929ezzh goodyear gubber " +
+  "863abgsg 7474 797979 464646 543210 4zqauf 4949 ch5nmk carlito chewey " +
+  "carebear checkmat cheddar chachi forgetit forlife giants1 getit gerhard " +
+  "galileo g3ujwg ganja rufus1 rushmore discus dudeman olympus oscars " +
+  "osprey madcow locust loyola mammoth proton rabbit1 ptfe3xxp pwxd5x " +
+  "purple1 punkass prophecy uyxnyd tyson1 aircraft access99 abcabc colts " +
+  "civilwar claudia1 contour dddddd1 cypher dapzu455 daisydog noles hoochie " +
+  "hoser eldiablo kingrich mudvayne motown mp8o6d vipergts italiano 2055 " +
+  "2211 bloke blade1 yamato zooropa yqlgr667 050505 zxcvbnm1 zw6syj " +
+  "suckcock tango1 swampy 445566 333666 380zliki sexpot sexylady sixtynin " +
+  "sickboy spiffy skylark sparkles pintail phreak teller timtim thighs " +
+  "latex letsdoit lkjhg landmark lizzard marlins marauder metal1 manu " +
+  "righton 1127 alain alcat amigo basebal1 azertyui azrael hamper gotenks " +
+  "golfgti hawkwind h2slca grace1 6chid8 789654 canine casio cazzo cbr900 " +
+  "cabrio calypso capetown feline flathead fisherma flipmode fungus g9zns4 " +
+  "giggle gabriel1 fuck123 saffron dogmeat dreamcas dirtydog douche dresden " +
+  "dickdick destiny1 pappy oaktree luft4 puta ramada trumpet1 vcradq tulip " +
+  "tracy71 tycoon aaaaaaa1 conquest chitown creepers cornhole danman dada " +
+  "density d9ebk7 darth nirvana1 nestle brenda1 bonanza hotspur hufmqw " +
+  "electro erasure elisabet etvww4 ewyuza eric1 kenken kismet klaatu " +
+  "milamber willi isacs155 igor 1million 1letmein x35v8l yogi ywvxpz xngwoj " +
+  "zippy1 020202 **** stonewal sentry sexsexsex sonysony smirnoff star12 " +
+  "solace star1 pkxe62 pilot1 pommes paulpaul tical tictac lighthou lemans " +
+  "kubrick letmein22 letmesee jys6wz jonesy jjjjjj1 jigga redstorm riley1 " +
+  "14141414 1126 allison1 badboy1 asthma auggie hardwood gumbo 616913 " +
+  "57np39 56qhxs 4mnveh fatluvr69 fqkw5m fidelity feathers fresno godiva " +
+  "gecko gibson1 gogators general1 saxman rowing sammys scotts scout1 " +
+  "sasasa samoht dragon69 ducky dragonball driller p3wqaw papillon oneone " +
+  "openit optimist longshot rapier pussy2 ralphie tuxedo undertow copenhag " +
+  "delldell culinary deltas mytime noname noles1 bucker bopper burnout " +
+  "ibilltes hihje863 hitter ekim espana eatme69 elpaso express1 eeeeee1 " +
+  "eatme1 karaoke mustang5 wellingt willem waterski webcam jasons infinite " +
+  "iloveyou! jakarta belair bigdad beerme yoshi yinyang x24ik3 063dyjuy " +
+  "0000007 ztmfcq stopit stooges symow8 strato 2hot4u skins shakes sex1 " +
+  "snacks softtail slimed123 pizzaman tigercat tonton lager lizzy juju " +
+  "john123 jesse1 jingles martian mario1 rootedit rochard redwine requiem " +
+  "riverrat 1117 1014 1205 amor amiga alpina atreides banana1 bahamut " +
+  "golfman happines 7uftyx 5432 5353 5151 4747 foxfire ffvdj474 foreskin " +
+  "gayboy gggggg1 gameover glitter funny1 scoobydoo saxophon dingbat " +
+  "digimon omicron panda1 loloxx macintos lululu lollypop racer1 queen1 " +
+  "qwertzui upnfmc tyrant trout1 9skw5g aceman acls2h aaabbb acapulco aggie " +
+  "comcast cloudy cq2kph d6o8pm cybersex davecole darian crumbs davedave " +
+  "dasani mzepab myporn narnia booger1 bravo1 budgie btnjey highlander " +
+  "hotel6 humbug ewtosi kristin1 kobe knuckles keith1 katarina muff muschi " +
+  "montana1 wingchun wiggle whatthe vette1 vols virago intj3a ishmael " +
+  "jachin illmatic 199999 2010 blender bigpenis bengal blue1234 zaqxsw xray " +
+  "xxxxxxx1 zebras yanks tadpole stripes 3737 4343 3728 4444444 368ejhih " +
+  "solar sonne sniffer sonata squirts playstation pktmxr pescator texaco " +
+  "lesbos l8v53x jo9k2jw2 jimbeam jimi jupiter2 jurassic marines1 rocket1 " +
+  "14725836 12345679 1219 123098 1233 alessand althor arch alpha123 basher " +
+  "barefeet balboa bbbbb1 badabing gopack golfnut gsxr1000 gregory1 " +
+  "766rglqy 8520 753159 8dihc6 69camaro 666777 cheeba chino cheeky camel1 " +
+  "fishcake flubber gianni gnasher23 frisbee fuzzy1 fuzzball save13tx " +
+  "russell1 sandra1 scrotum scumbag sabre samdog dripping dragon12 dragster " +
+  "orwell mainland maine qn632o poophead rapper porn4life rapunzel velocity " +
+  "vanessa1 trueblue vampire1 abacus 902100 crispy chooch d6wnro dabulls " +
+  "dehpye navyseal njqcw4 nownow nigger1 nightowl nonenone nightmar bustle " +
+  "buddy2 boingo bugman bosshog hybrid hillside hilltop hotlegs hzze929b " +
+  "hhhhh1 hellohel evilone edgewise e5pftu eded embalmer excalibur elefant " +
+  "kenzie killah kleenex mouses mounta1n motors mutley muffdive vivitron " +
+  "w00t88 iloveit jarjar incest indycar 17171717 1664 17011701 222777 2663 " +
+  "beelch benben yitbos yyyyy1 zzzzz1 stooge tangerin taztaz stewart1 " +
+  "summer69 system1 surveyor stirling 3qvqod 3way 456321 sizzle simhrq " +
+  "sparty ssptx452 sphere persian ploppy pn5jvw poobear pianos plaster " +
+  "testme tiff thriller master12 rockey 1229 1217 1478 1009 anastasi amonra " +
+  "argentin albino azazel grinder 6uldv8 83y6pv 8888888 4tlved 515051 " +
+  "carsten flyers88 ffffff1 firehawk firedog flashman ggggg1 godspeed " +
+  "galway giveitup funtimes gohan giveme geryfe frenchie sayang rudeboy " +
+  "sandals dougal drag0n dga9la desktop onlyone otter pandas mafia luckys " +
+  "lovelife manders qqh92r qcmfd454 radar1 punani ptbdhw turtles undertaker " +
+  "trs8f7 ugejvp abba 911turbo acdc abcd123 crash1 colony delboy davinci " +
+  "notebook nitrox borabora bonzai brisbane heeled hooyah hotgirl i62gbq " +
+  "horse1 hpk2qc epvjb6 mnbvc mommy1 munster wiccan 2369 bettyboo blondy " +
+  "bismark beanbag bjhgfi blackice yvtte545 ynot yess zlzfrh wolvie 007bond " +
+  "****** tailgate tanya1 sxhq65 stinky1 3234412 3ki42x seville shimmer " +
+  "sienna shitshit skillet sooners1 solaris smartass pedros pennywis pfloyd " +
+  "tobydog thetruth letme1n mario66 micky rocky2 rewq reindeer 1128 1207 " +
+  "1104 1432 aprilia allstate bagels baggies barrage guru 72d5tn 606060 " +
+  "4wcqjn chance1 flange fartman geil gbhcf2 fussball fuaqz4 gameboy " +
+  "geneviev rotary seahawk saab samadams devlt4 ditto drevil drinker deuce " +
+  "dipstick octopus ottawa losangel loverman porky q9umoz rapture pussy4me " +
+  "triplex ue8fpw turbos aaa340 churchil crazyman cutiepie ddddd1 dejavu " +
+  "cuxldv nbvibt nikon niko nascar1 bubba2 boobear boogers bullwink " +
+  "bulldawg horsemen escalade eagle2 dynamic efyreg minnesot mogwai msnxbi " +
+  "mwq6qlzo werder verygood voodoo1 iiiiii1 159951 1624 1911a1 2244 " +
+  "bellagio bedlam belkin bill1 xirt2k ?????? susieq sundown sukebe swifty " +
+  "2fast4u sexe shroom seaweed skeeter1 snicker spanky1 spook phaedrus " +
+  "pilots peddler thumper1 tiger7 tmjxn151 thematri l2g7k3 letmeinn " +
+  "jeffjeff johnmish mantra mike69 mazda6 riptide robots 1107 1130 142857 " +
+  "11001001 1134 armored allnight amatuers bartok astral baboon balls1 " +
+  "bassoon hcleeb happyman granite graywolf golf1 gomets 8vjzus 7890 789123 " +
+  "8uiazp 5757 474jdvff 551scasi 50cent camaro1 cherry1 chemist firenze " +
+  "fishtank freewill glendale frogfrog ganesh scirocco devilman doodles " +
+  "okinawa olympic orpheus ohmygod paisley pallmall lunchbox manhatta " +
+  "mahalo mandarin qwqwqw qguvyt pxx3eftp rambler poppy1 turk182 vdlxuc " +
+  "tugboat valiant uwrl7c chris123 cmfnpu decimal debbie1 dandy daedalus " +
+  "natasha1 nissan1 nancy123 nevermin napalm newcastle bonghit ibxnsm " +
+  "hhhhhh1 holger edmonton equinox dvader kimmy knulla mustafa monsoon " +
+  "mistral morgana monica1 mojave monterey mrbill vkaxcs victor1 violator " +
+  "vfdhif wilson1 wavpzt wildstar winter99 iqzzt580 imback 1914 19741974 " +
+  "1monkey 1q2w3e4r5t 2500 2255 bigshow bigbucks blackcoc zoomer wtcacq " +
+  "wobble xmen xjznq5 yesterda yhwnqc zzzxxx 393939 2fchbg skinhead skilled " +
+  "shadow12 seaside sinful silicon smk7366 snapshot sniper1 soccer11 smutty " +
+  "peepers plokij pdiddy pimpdaddy thrust terran topaz today1 lionhear " +
+  "littlema lauren1 lincoln1 lgnu9d juneau methos rogue1 romulus redshift " +
+  "1202 1469 12locked arizona1 alfarome al9agd aol123 altec apollo1 arse " +
+  "baker1 bbb747 axeman astro1 hawthorn goodfell hawks1 gstring hannes " +
+  "8543852 868686 4ng62t 554uzpad 5401 567890 5232 catfood fire1 flipflop " +
+  "fffff1 fozzie fluff fzappa rustydog scarab satin ruger samsung1 destin " +
+  "diablo2 dreamer1 detectiv doqvq3 drywall paladin1 papabear offroad " +
+  "panasonic nyyankee luetdi qcfmtz pyf8ah puddles pussyeat ralph1 princeto " +
+  "trivia trewq tri5a3 advent 9898 agyvorc clarkie coach1 courier christo " +
+  "chowder cyzkhw davidb dad2ownu daredevi de7mdf nazgul booboo1 bonzo " +
+  "butch1 huskers1 hgfdsa hornyman elektra england1 elodie kermit1 kaboom " +
+  "morten mocha monday1 morgoth weewee weenie vorlon wahoo ilovegod insider " +
+  "jayman 1911 1dallas 1900 1ranger 201jedlz 2501 1qaz bignuts bigbad " +
+  "beebee billows belize wvj5np wu4etd yamaha1 wrinkle5 zebra1 yankee1 " +
+  "zoomzoom 09876543 0311 ????? stjabn tainted 3tmnej skooter skelter " +
+  "starlite spice1 stacey1 smithy pollux peternorth pixie piston poets " +
+  "toons topspin kugm7b legends jeepjeep joystick junkmail jojojojo jonboy " +
+  "midland mayfair riches reznor rockrock reboot renee1 roadway rasta220 " +
+  "1411 1478963 1019 archery andyandy barks bagpuss auckland gooseman " +
+  "hazmat gucci grammy happydog 7kbe9d 7676 6bjvpe 5lyedn 5858 5291 " +
+  "charlie2 c7lrwu candys chateau ccccc1 cardinals fihdfv fortune12 gocats " +
+  "gaelic fwsadn godboy gldmeo fx3tuo fubar1 generals gforce rxmtkp rulz " +
+  "sairam dunhill dogggg ozlq6qwm ov3ajy lockout makayla macgyver mallorca " +
+  "prima pvjegu qhxbij prelude1 totoro tusymo trousers tulane turtle1 " +
+  "tracy1 aerosmit abbey1 clticic cooper1 comets delpiero cyprus dante1 " +
+  "dave1 nounours nexus6 nogard norfolk brent1 booyah bootleg bulls23 " +
+  "bulls1 booper heretic icecube hellno hounds honeydew hooters1 hoes " +
+  "hevnm4 hugohugo epson evangeli eeeee1 eyphed"
+
+/* Common English words. */
+const englishList = "you i to the a and that it of me what is in this know " +
+  "i'm for no have my don't just not do be on your was we it's with so but " +
+  "all well are he oh about right you're get here out going like yeah if " +
+  "her she can up want think that's now go him at how got there one did why " +
+  "see come good they really as would look when time will okay back can't " +
+  "mean tell i'll from hey were he's could didn't yes his been or something " +
+  "who because some had then say ok take an way us little make need gonna " +
+  "never we're too she's i've sure them more over our sorry where what's " +
+  "let thing am maybe down man has uh very by there's should anything said " +
+  "much any life even off doing thank give only thought help two talk " +
+  "people god still wait into find nothing again things let's doesn't call " +
+  "told great before better ever night than away first believe other feel " +
+  "everything work you've fine home after last these day keep does put " +
+  "around stop they're i'd guy isn't always listen wanted mr guys huh those " +
+  "big lot happened thanks won't trying kind wrong through talking made new " +
+  "being guess hi care bad mom remember getting we'll together dad leave " +
+  "place understand wouldn't actually hear baby nice father else stay done " +
+  "wasn't their course might mind every enough try hell came someone you'll " +
+  "own family whole another house yourself idea ask best must coming old " +
+  "looking woman which years room left knew tonight real son hope name same " +
+  "went um hmm happy pretty saw girl sir show friend already saying next " +
+  "three job problem minute found world thinking haven't heard honey matter " +
+  "myself couldn't exactly having ah probably happen we've hurt boy both " +
+  "while dead gotta alone since excuse start kill hard you'd today car " +
+  "ready until without wants hold wanna yet seen deal took once gone called " +
+  "morning supposed friends head stuff most used worry second part live " +
+  "truth school face forget true business each cause soon knows few telling " +
+  "wife who's use chance run move anyone person bye somebody dr heart such " +
+  "miss married point later making meet anyway many phone reason damn lost " +
+  "looks bring case turn wish tomorrow kids trust check change end late " +
+  "anymore five least town aren't ha working year makes taking means " +
+  "brother play hate ago says beautiful gave fact crazy party sit open " +
+  "afraid between important rest fun kid word watch glad everyone days " +
+  "sister minutes everybody bit couple whoa either mrs feeling daughter wow " +
+  "gets asked under break promise door set close hand easy question tried " +
+  "far walk needs mine though times different killed hospital anybody " +
+  "alright wedding shut able die perfect stand comes hit story ya mm " +
+  "waiting dinner against funny husband almost pay answer four office eyes " +
+  "news child shouldn't half side yours moment sleep read where's started " +
+  "men sounds sonny pick sometimes em bed also date line plan hours lose " +
+  "hands serious behind inside high ahead week wonderful fight past cut " +
+  "quite number he'll sick it'll game eat nobody goes along save seems " +
+  "finally lives worried upset carly met book brought seem sort safe living " +
+  "children weren't leaving front shot loved asking running clear figure " +
+  "hot felt six parents drink absolutely how's daddy alive sense meant " +
+  "happens special bet blood ain't kidding lie full meeting dear seeing " +
+  "sound fault water ten women buy months hour speak lady jen thinks " +
+  "christmas body order outside hang possible worse company mistake ooh " +
+  "handle spend totally giving control here's marriage realize president " +
+  "unless sex send needed taken died scared picture talked ass hundred " +
+  "changed completely explain playing certainly sign boys relationship " +
+  "loves hair lying choice anywhere future weird luck she'll turned known " +
+  "touch kiss crane questions obviously wonder pain calling somewhere throw " +
+  "straight cold fast words food none drive feelings they'll worked marry " +
+  "light drop cannot sent city dream protect twenty class surprise its " +
+  "sweetheart poor looked mad except gun y'know dance takes appreciate " +
+  "especially situation besides pull himself hasn't act worth sheridan " +
+  "amazing top given expect rather involved swear piece busy law decided " +
+  "happening movie we'd catch country less perhaps step fall watching kept " +
+  "darling dog win air honor personal moving till admit problems murder " +
+  "he'd evil definitely feels information honest eye broke missed longer " +
+  "dollars tired evening human starting red entire trip club niles suppose " +
+  "calm imagine fair caught blame street sitting favor apartment court " +
+  "terrible clean learn works frasier relax million accident wake prove " +
+  "smart message missing forgot interested table nbsp become mouth pregnant " +
+  "middle ring careful shall team ride figured wear shoot stick follow " +
+  "angry instead write stopped early ran war standing forgive jail wearing " +
+  "kinda lunch cristian eight greenlee gotten hoping phoebe thousand ridge " +
+  "paper tough tape state count boyfriend proud agree birthday seven " +
+  "they've history share offer hurry feet wondering decision building ones " +
+  "finish voice herself would've list mess deserve evidence cute dress " +
+  "interesting hotel quiet concerned road staying beat sweetie mention " +
+  "clothes finished fell neither mmm fix respect spent prison attention " +
+  "holding calls near surprised bar keeping gift hadn't putting dark self " +
+  "owe using ice helping normal aunt lawyer apart certain plans jax " +
+  "girlfriend floor whether everything's present earth box cover judge " +
+  "upstairs sake mommy possibly worst station acting accept blow strange " +
+  "saved conversation plane mama yesterday lied quick lately stuck report " +
+  "difference rid store she'd bag bought doubt listening walking cops deep " +
+  "dangerous buffy sleeping chloe rafe shh record lord moved join card " +
+  "crime gentlemen willing window return walked guilty likes fighting " +
+  "difficult soul joke favorite uncle promised public bother island " +
+  "seriously cell lead knowing broken advice somehow paid losing push " +
+  "helped killing usually earlier boss beginning liked innocent doc rules " +
+  "cop learned thirty risk letting speaking officer ridiculous support " +
+  "afternoon born apologize seat nervous across song charge patient boat " +
+  "how'd hide detective planning nine huge breakfast horrible age awful " +
+  "pleasure driving hanging picked sell quit apparently dying notice " +
+  "congratulations chief one's month visit could've c'mon letter decide " +
+  "double sad press forward fool showed smell seemed spell memory pictures " +
+  "slow seconds hungry board position hearing roz kitchen ma'am force fly " +
+  "during space should've realized experience kick others grab mother's " +
+  "discuss third cat fifty responsible fat reading idiot yep suddenly agent " +
+  "destroy bucks track shoes scene peace arms demon low livvie consider " +
+  "papers medical incredible witch drunk attorney tells knock ways gives " +
+  "department nose skye turns keeps jealous drug sooner cares plenty extra " +
+  "tea won attack ground whose outta weekend matters wrote type father's " +
+  "gosh opportunity impossible books waste pretend named jump eating proof " +
+  "complete slept career arrest breathe perfectly warm pulled twice easier " +
+  "goin dating suit romantic drugs comfortable finds checked fit divorce " +
+  "begin ourselves closer ruin although smile laugh treat god's fear what'd " +
+  "guy's otherwise excited mail hiding cost stole pacey noticed fired " +
+  "excellent lived bringing pop bottom note sudden bathroom flight honestly " +
+  "sing foot games remind bank charges witness finding places tree dare " +
+  "hardly that'll interest steal silly contact teach shop plus colonel " +
+  "fresh trial invited roll radio reach heh choose emergency dropped credit " +
+  "obvious cry locked loving positive nuts agreed prue goodbye condition " +
+  "guard fuckin grow cake mood dad's total crap crying belong lay partner " +
+  "trick pressure ohh arm dressed cup lies bus taste neck south something's " +
+  "nurse raise lots carry group whoever drinking they'd breaking file lock " +
+  "wine closed writing spot paying study assume asleep man's turning legal " +
+  "viki bedroom shower nikolas camera fill reasons forty bigger nope breath " +
+  "doctors pants level movies gee area folks ugh continue focus wild truly " +
+  "desk convince client threw band hurts spending allow grand answers shirt " +
+  "chair allowed rough doin sees government ought empty round hat wind " +
+  "shows aware dealing pack meaning hurting ship subject guest mom's pal " +
+  "match arrested salem confused surgery expecting deacon unfortunately " +
+  "goddamn lab passed bottle beyond whenever pool opinion held common " +
+  "starts jerk secrets falling played necessary barely dancing health tests " +
+  "copy cousin planned dry ahem twelve simply tess skin often fifteen " +
+  "speech names issue orders nah final results code believed complicated " +
+  "umm research nowhere escape biggest restaurant grateful usual burn " +
+  "address within someplace screw everywhere train film regret goodness " +
+  "mistakes details responsibility suspect corner hero dumb terrific " +
+  "further gas whoo hole memories o'clock following ended nobody's teeth " +
+  "ruined split airport bite stenbeck older liar showing project cards " +
+  "desperate themselves pathetic damage spoke quickly scare marah afford " +
+  "vote settle mentioned due stayed rule checking tie hired upon heads " +
+  "concern blew natural alcazar champagne connection tickets happiness form " +
+  "saving kissing hated personally suggest prepared build leg onto leaves " +
+  "downstairs ticket it'd taught loose holy staff sea duty convinced " +
+  "throwing defense kissed legs according loud practice saturday babies " +
+  "army where'd warning miracle carrying flying blind ugly shopping hates " +
+  "someone's sight bride coat account states clearly celebrate brilliant " +
+  "wanting add forrester lips custody center screwed buying size toast " +
+  "thoughts student stories however professional reality birth lexie " +
+  "attitude advantage grandfather sami sold opened grandma beg changes " +
+  "someday grade roof brothers signed ahh marrying powerful grown " +
+  "grandmother fake opening expected eventually must've ideas exciting " +
+  "covered familiar bomb bout television harmony color heavy schedule " +
+  "records capable practically including correct clue forgotten immediately " +
+  "appointment social nature deserves threat bloody lonely ordered shame " +
+  "local jacket hook destroyed scary investigation above invite shooting " +
+  "port lesson criminal growing caused victim professor followed funeral " +
+  "nothing's considering burning strength loss view gia sisters everybody's " +
+  "several pushed written somebody's shock pushing heat chocolate greatest " +
+  "miserable corinthos nightmare brings zander character became famous " +
+  "enemy crash chances sending recognize healthy boring feed engaged " +
+  "percent headed lines treated purpose knife rights drag san fan badly " +
+  "hire paint pardon built behavior closet warn gorgeous milk survive " +
+  "forced operation offered ends dump rent remembered lieutenant trade " +
+  "thanksgiving rain revenge physical available program prefer baby's spare " +
+  "pray disappeared aside statement sometime meat fantastic breathing " +
+  "laughing itself tip stood market affair ours depends main protecting " +
+  "jury national brave large jack's interview fingers murdered explanation " +
+  "process picking based style pieces blah assistant stronger aah pie " +
+  "handsome unbelievable anytime nearly shake everyone's oakdale cars " +
+  "wherever serve pulling points medicine facts waited lousy circumstances " +
+  "stage disappointed weak trusted license nothin community trash " +
+  "understanding slip cab sounded awake friendship stomach weapon " +
+  "threatened mystery official regular river vegas understood contract race " +
+  "basically switch frankly issues cheap lifetime deny painting ear clock " +
+  "weight garbage why'd tear ears dig selling setting indeed changing " +
+  "singing tiny particular draw decent avoid messed filled touched score " +
+  "people's disappear exact pills kicked harm recently fortune pretending " +
+  "raised insurance fancy drove cared belongs nights shape lorelai base " +
+  "lift stock sonny's fashion timing guarantee chest bridge woke source " +
+  "patients theory original burned watched heading selfish oil drinks " +
+  "failed period doll committed elevator freeze noise exist science pair " +
+  "edge wasting sat ceremony pig uncomfortable peg guns staring files bike " +
+  "weather name's mostly stress permission arrived thrown possibility " +
+  "example borrow release ate notes hoo library property negative fabulous " +
+  "event doors screaming xander term what're meal fellow apology anger " +
+  "honeymoon wet bail parking non protection fixed families chinese " +
+  "campaign map wash stolen sensitive stealing chose lets comfort worrying " +
+  "whom pocket mateo bleeding students shoulder ignore fourth neighborhood " +
+  "fbi talent tied garage dies demons dumped witches training rude crack " +
+  "model bothering radar grew remain soft meantime gimme connected kinds " +
+  "cast sky likely fate buried hug brother's concentrate prom messages east " +
+  "unit intend crew ashamed somethin manage guilt weapons terms interrupt " +
+  "guts tongue distance conference treatment shoe basement sentence purse " +
+  "glasses cabin universe towards repeat mirror wound travers tall reaction " +
+  "odd engagement therapy letters emotional runs magazine jeez decisions " +
+  "soup daughter's thrilled society managed stake chef moves extremely " +
+  "entirely moments expensive counting shots kidnapped square son's " +
+  "cleaning shift plate impressed smells trapped male tour aidan knocked " +
+  "charming attractive argue puts whip language embarrassed settled package " +
+  "laid animals hitting disease bust stairs alarm pure nail nerve " +
+  "incredibly walks dirt stamp sister's becoming terribly friendly easily " +
+  "damned jobs suffering disgusting stopping deliver riding helps federal " +
+  "disaster bars dna crossed rate create trap claim california talks eggs " +
+  "effect chick threatening spoken introduce confession embarrassing bags " +
+  "impression gate year's reputation attacked among knowledge presents inn " +
+  "europe chat suffer argument talkin crowd homework fought coincidence " +
+  "cancel accepted rip pride solve hopefully pounds pine mate illegal " +
+  "generous streets con separate outfit maid bath punch mayor freaked " +
+  "begging recall enjoying bug woman's prepare parts wheel signal direction " +
+  "defend signs painful yourselves rat maris amount that'd suspicious flat " +
+  "cooking button warned sixty pity parties crisis coach row yelling leads " +
+  "awhile pen confidence offering falls image farm pleased panic hers " +
+  "gettin role refuse determined hell's grandpa progress testify passing " +
+  "military choices uhh gym cruel wings bodies mental gentleman coma " +
+  "cutting proteus guests girl's expert benefit faces cases led jumped " +
+  "toilet secretary sneak mix firm halloween agreement privacy dates " +
+  "anniversary smoking reminds pot created twins swing successful season " +
+  "scream considered solid options commitment senior ill else's crush " +
+  "ambulance wallet discovered officially til rise reached eleven option " +
+  "laundry former assure stays skip fail accused wide challenge popular " +
+  "learning discussion clinic plant exchange betrayed bro sticking " +
+  "university members lower bored mansion soda sheriff suite handled busted " +
+  "senator load happier younger studying romance procedure ocean section " +
+  "sec commit assignment suicide minds swim ending bat yell llanview league " +
+  "chasing seats proper command believes humor hopes fifth winning solution " +
+  "leader theresa's sale lawyers nor material latest highly escaped " +
+  "audience parent tricks insist dropping cheer medication higher flesh " +
+  "district routine century shared sandwich handed false beating appear " +
+  "warrant family's awfully odds article treating thin suggesting fever " +
+  "sweat silent specific clever sweater request prize mall tries mile fully " +
+  "estate union sharing assuming judgment goodnight divorced despite surely " +
+  "steps jet confess math listened comin answered vulnerable bless dreaming " +
+  "rooms chip zero potential pissed nate kills tears knees chill carly's " +
+  "brains agency harvard degree unusual wife's joint packed dreamed cure " +
+  "covering newspaper lookin coast grave egg direct cheating breaks quarter " +
+  "mixed locker husband's gifts awkward toy thursday rare policy kid's " +
+  "joking competition classes assumed reasonable dozen curse quartermaine " +
+  "millions dessert rolling detail alien served delicious closing vampires " +
+  "released ancient wore value tail secure salad murderer hits toward spit " +
+  "screen offense dust conscience bread answering admitted lame invitation " +
+  "grief smiling path stands bowl pregnancy hollywood prisoner delivery " +
+  "guards virus shrink influence freezing concert wreck partners massimo " +
+  "chain birds life's wire technically presence blown anxious cave version " +
+  "holidays cleared wishes survived caring candles bound related charm yup " +
+  "pulse jumping jokes frame boom vice performance occasion silence opera " +
+  "nonsense frightened downtown americans slipped dimera blowing world's " +
+  "session relationships kidnapping actual spin civil roxy packing " +
+  "education blaming wrap obsessed fruit torture personality location " +
+  "effort daddy's commander trees there'll owner fairy per other's " +
+  "necessarily county contest seventy print motel fallen directly underwear " +
+  "grams exhausted believing particularly freaking carefully trace touching " +
+  "messing committee recovery intention consequences belt sacrifice courage " +
+  "officers enjoyed lack attracted appears bay yard returned remove nut " +
+  "carried today's testimony intense granted violence heal defending " +
+  "attempt unfair relieved political loyal approach slowly plays normally " +
+  "buzz alcohol actor surprises psychiatrist pre plain attic who'd uniform " +
+  "terrified sons pet cleaned zach threaten teaching mum motion fella " +
+  "enemies desert collection incident failure satisfied imagination hooked " +
+  "headache forgetting counselor andie acted opposite highest equipment " +
+  "badge italian visiting naturally frozen commissioner sakes labor " +
+  "appropriate trunk armed thousands received dunno costume temporary " +
+  "sixteen impressive zone kicking junk hon grabbed unlike understands " +
+  "describe clients owns affect witnesses starving instincts happily " +
+  "discussing deserved strangers leading intelligence host authority " +
+  "surveillance cow commercial admire questioning fund dragged barn object " +
+  "deeply amp wrapped wasted tense route reports hoped fellas election " +
+  "roommate mortal fascinating chosen stops shown arranged abandoned sides " +
+  "delivered becomes arrangements agenda began theater series literally " +
+  "propose honesty underneath forces services sauce promises lecture eighty " +
+  "torn shocked relief explained counter circle victims transfer response " +
+  "channel identity differently campus spy ninety interests guide deck " +
+  "biological pheebs ease creep will's waitress skills telephone ripped " +
+  "raising scratch rings prints wave thee arguing figures ephram asks " +
+  "reception pin oops diner annoying agents taggert goal mass ability " +
+  "sergeant julian's international gig blast basic tradition towel earned " +
+  "rub president's habit customers creature bermuda actions snap react " +
+  "prime paranoid wha handling eaten therapist comment charged tax sink " +
+  "reporter beats priority interrupting gain fed warehouse shy pattern " +
+  "loyalty inspector events pleasant media excuses threats permanent " +
+  "guessing financial demand assault tend praying motive los unconscious " +
+  "trained museum tracks range nap mysterious unhappy tone switched " +
+  "rappaport award sookie neighbor loaded gut childhood causing swore piss " +
+  "hundreds balance background toss mob misery valentine's thief squeeze " +
+  "lobby hah goa'uld geez exercise ego drama al's forth facing booked boo " +
+  "songs sandburg eighteen d'you bury perform everyday digging creepy " +
+  "compared wondered trail liver hmmm drawn device magical journey fits " +
+  "discussed supply moral helpful attached timmy's searching flew depressed " +
+  "aisle underground pro daughters cris amen vows proposal pit neighbors " +
+  "darn cents arrange annulment uses useless squad represent product joined " +
+  "afterwards adventure resist protected net fourteen celebrating piano " +
+  "inch flag debt violent tag sand gum dammit teal'c hip celebration below " +
+  "reminded claims tonight's replace phones paperwork emotions typical " +
+  "stubborn stable sheridan's pound papa lap designed current bum tension " +
+  "tank suffered steady provide overnight meanwhile chips beef wins suits " +
+  "boxes salt cassadine collect boy's tragedy therefore spoil realm profile " +
+  "degrees wipe surgeon stretch stepped nephew neat limo confident anti " +
+  "perspective designer climb title suggested punishment finest ethan's " +
+  "springfield occurred hint furniture blanket twist surrounded surface " +
+  "proceed lip fries worries refused niece gloves soap signature disappoint " +
+  "crawl convicted zoo result pages lit flip counsel doubts crimes accusing " +
+  "when's shaking remembering phase hallway halfway bothered useful makeup " +
+  "madam gather concerns cia cameras blackmail symptoms rope ordinary " +
+  "imagined concept cigarette supportive memorial explosion yay woo trauma " +
+  "ouch leo's furious cheat avoiding whew thick oooh boarding approve " +
+  "urgent shhh misunderstanding minister drawer sin phony joining jam " +
+  "interfere governor chapter catching bargain tragic schools respond " +
+  "punish penthouse hop thou remains rach ohhh insult doctor's bugs beside " +
+  "begged absolute strictly stefano socks senses ups sneaking yah serving " +
+  "reward polite checks tale physically instructions fooled blows tabby " +
+  "internal bitter adorable y'all tested suggestion string jewelry debate " +
+  "com alike pitch fax distracted shelter lessons foreign average twin " +
+  "friend's damnit constable circus audition tune shoulders mud mask " +
+  "helpless feeding explains dated robbery objection behave valuable " +
+  "shadows courtroom confusing tub talented struck smarter mistaken italy " +
+  "customer bizarre scaring punk motherfucker holds focused alert activity " +
+  "vecchio reverend highway foolish compliment bastards attend scheme aid " +
+  "worker wheelchair protective poetry gentle script reverse picnic knee " +
+  "intended construction cage wednesday voices toes stink scares pour " +
+  "effects cheated tower time's slide ruining recent jewish filling exit " +
+  "cottage corporate upside supplies proves parked instance grounds diary " +
+  "complaining basis wounded thing's politics confessed pipe merely massage " +
+  "data chop budget brief spill prayer costs betray begins arrangement " +
+  "waiter scam rats fraud flu brush anyone's adopted tables sympathy pill " +
+  "pee web seventeen landed expression entrance employee drawing cap " +
+  "bracelet principal pays jen's fairly facility dru deeper arrive unique " +
+  "tracking spite shed recommend oughta nanny naive menu grades diet corn " +
+  "authorities separated roses patch dime devastated description tap subtle " +
+  "include citizen bullets beans ric pile las executive confirm toe strings " +
+  "parade harbor charity's bow borrowed toys straighten steak status remote " +
+  "premonition poem planted honored youth specifically meetings exam " +
+  "convenient traveling matches laying insisted apply units technology dish " +
+  "aitoro sis kindly grandson donor temper teenager strategy richard's " +
+  "proven iron denial couples backwards tent swell noon happiest episode " +
+  "drives thinkin spirits potion fence affairs acts whatsoever rehearsal " +
+  "proved overheard nuclear lemme hostage faced constant bench tryin taxi " +
+  "shove sets moron limits impress entitled needle limit lad intelligent " +
+  "instant forms disagree stinks rianna recover paul's losers groom gesture " +
+  "developed constantly blocks bartender tunnel suspects sealed removed " +
+  "legally illness hears dresses aye vehicle thy teachers sheet receive " +
+  "psychic night's denied knocking judging bible behalf accidentally waking " +
+  "ton superior seek rumor natalie's manners homeless hollow desperately " +
+  "critical theme tapes referring personnel item genoa gear majesty fans " +
+  "exposed cried tons spells producer launch instinct belief quote " +
+  "motorcycle convincing appeal advance greater fashioned aids accomplished " +
+  "mommy's grip bump upsetting soldiers scheduled production needing " +
+  "invisible forgiveness feds complex compare bothers tooth territory " +
+  "sacred mon jessica's inviting inner earn compromise cocktail tramp " +
+  "temperature signing landing jabot intimate dignity dealt souls informed " +
+  "gods entertainment dressing cigarettes blessing billion alistair upper " +
+  "manner lightning leak heaven's fond corky alternative seduce players " +
+  "operate modern liquor fingerprints enchantment butters stuffed stavros " +
+  "rome filed emotionally division conditions uhm transplant tips passes " +
+  "oxygen nicely lunatic hid drill designs complain announcement visitors " +
+  "unfortunate slap prayers plug organization opens oath o'neill mutual " +
+  "graduate confirmed broad yacht spa remembers fried extraordinary bait " +
+  "appearance abuse warton sworn stare safely reunion plot burst aha " +
+  "might've experiment dive commission cells aboard returning independent " +
+  "expose environment buddies trusting smaller mountains booze sweep sore " +
+  "scudder properly parole manhattan effective ditch decides canceled bra " +
+  "antonio's speaks spanish reaching glow foundation women's wears thirsty " +
+  "skull ringing dorm dining bend unexpected systems sob pancakes michael's " +
+  "harsh flattered existence ahhh troubles proposed fights favourite eats " +
+  "driven computers rage luke's causes border undercover spoiled sloane " +
+  "shine rug identify destroying deputy deliberately conspiracy clothing " +
+  "thoughtful similar sandwiches plates nails miracles investment fridge " +
+  "drank contrary beloved allergic washed stalking solved sack misses " +
+  "hope's forgiven erica's cuz bent approval practical organized maciver " +
+  "involve industry fuel dragging cooked possession pointing foul editor " +
+  "dull beneath ages horror heels grass faking deaf stunt portrait painted " +
+  "jealousy hopeless fears cuts conclusion volunteer scenario satellite " +
+  "necklace men's crashed chapel accuse restraining jason's humans homicide " +
+  "helicopter formal firing shortly safer devoted auction videotape tore " +
+  "stores reservations pops appetite anybody's wounds vanquish symbol " +
+  "prevent patrol ironic flow fathers excitement anyhow tearing sends sam's " +
+  "rape laughed function core charmed whatever's sub lucy's dealer " +
+  "cooperate bachelor accomplish wakes struggle spotted sorts reservation " +
+  "ashes yards votes tastes supposedly loft intentions integrity wished " +
+  "towels suspected slightly qualified log investigating inappropriate " +
+  "immediate companies backed pan owned lipstick lawn compassion cafeteria " +
+  "belonged affected scarf precisely obsession management loses lighten " +
+  "jake's infection granddaughter explode chemistry balcony this'll storage " +
+  "spying publicity exists employees depend cue cracked conscious aww ally " +
+  "ace accounts absurd vicious tools strongly rap invented forbid " +
+  "directions defendant bare announce alcazar's screwing salesman robbed " +
+  "leap lakeview insanity injury genetic document why's reveal religious " +
+  "possibilities kidnap gown entering chairs wishing statue setup serial " +
+  "punished dramatic dismissed criminals seventh regrets raped quarters " +
+  "produce lamp dentist anyways anonymous added semester risks regarding " +
+  "owes magazines machines lungs explaining delicate child's tricked oldest " +
+  "liv eager doomed cafe bureau adoption traditional surrender stab " +
+  "sickness scum loop independence generation floating envelope entered " +
+  "combination chamber worn vault sorel pretended potatoes plea photograph " +
+  "payback misunderstood kiddo healing cascade capeside application stabbed " +
+  "remarkable cabinet brat wrestling sixth scale privilege passionate " +
+  "nerves lawsuit kidney disturbed crossing cozy associate tire shirts " +
+  "required posted oven ordering mill journal gallery delay clubs risky " +
+  "nest monsters honorable grounded favour culture closest brenda's " +
+  "breakdown attempted tony's placed conflict bald actress abandon steam " +
+  "scar pole duh collar worthless standards resources photographs " +
+  "introduced injured graduation enormous disturbing disturb distract deals " +
+  "conclusions vodka situations require mid measure dishes crawling " +
+  "congress children's briefcase wiped whistle sits roast rented pigs greek " +
+  "flirting existed deposit damaged bottles vanessa's types topic riot " +
+  "overreacting minimum logical impact hostile embarrass casual beacon " +
+  "amusing altar values recognized maintain goods covers claus battery " +
+  "survival skirt shave prisoners porch med ghosts favors drops dizzy chili " +
+  "begun beaten advise transferred strikes rehab raw photographer peaceful " +
+  "leery heavens fortunately fooling expectations draft citizens weakness " +
+  "ski ships ranch practicing musical movement individual homes executed " +
+  "examine documents cranes column bribe task species sail rum resort " +
+  "prescription operating hush fragile forensics expense drugged " +
+  "differences cows conduct comic bells avenue attacking assigned visitor " +
+  "suitcase sources sorta scan payment motor mini manticore inspired " +
+  "insecure imagining hardest clerk yea wrist what'll tube starters silk " +
+  "pump pale nicer haul flies demands boot arts african there'd limited " +
+  "how're elders connections quietly pulls idiots factor erase denying " +
+  "attacks ankle amnesia accepting ooo heartbeat gal devane confront " +
+  "backing phrase operations minus meets legitimate hurricane fixing " +
+  "communication boats auto arrogant supper studies slightest sins sayin " +
+  "recipe pier paternity humiliating genuine catholic snack rational " +
+  "pointed minded guessed grace's display dip brooke's advanced weddings " +
+  "unh tumor teams reported humiliated destruction copies closely bid " +
+  "aspirin academy wig throughout spray occur logic eyed equal drowning " +
+  "contacts shakespeare ritual perfume kelly's hiring hating generally " +
+  "error elected docks creatures visions thanking thankful sock replaced " +
+  "nineteen nick's fork comedy analysis yale throws teenagers studied " +
+  "stressed slice rolls requires plead ladder kicks detectives assured " +
+  "alison's widow tomorrow's tissue tellin shallow responsibilities repay " +
+  "rejected permanently girlfriends deadly comforting ceiling bonus verdict " +
+  "maintenance jar insensitive factory aim triple spilled respected " +
+  "recovered messy interrupted halliwell car's bleed benefits wardrobe " +
+  "takin significant objective murders doo chart backs workers waves " +
+  "underestimate ties registered multiple justify harmless frustrated fold " +
+  "enzo convention communicate bugging attraction arson whack salary rumors " +
+  "residence party's obligation medium liking laura's development develop " +
+  "dearest david's danny's congratulate vengeance switzerland severe rack " +
+  "puzzle puerto guidance fires courtesy caller blamed tops repair quiz " +
+  "prep now's involves headquarters curiosity codes circles barbecue troops " +
+  "sunnydale spinning scores pursue psychotic cough claimed accusations " +
+  "shares resent money's laughs gathered freshman envy drown cristian's " +
+  "bartlet asses sofa scientist poster islands highness dock apologies " +
+  "welfare victor's theirs stat stall spots somewhat ryan's realizes psych " +
+  "fools finishing album wee understandable unable treats theatre succeed " +
+  "stir relaxed makin inches gratitude faithful bin accent zip witter " +
+  "wandering regardless que locate inevitable gretel deed crushed " +
+  "controlling taxes smelled settlement robe poet opposed marked greenlee's " +
+  "gossip gambling determine cuba cosmetics cent accidents surprising stiff " +
+  "sincere shield rushed resume reporting refrigerator reference preparing " +
+  "nightmares mijo ignoring hunch fog fireworks drowned crown cooperation " +
+  "brass accurate whispering sophisticated religion luggage investigate " +
+  "hike explore emotion creek crashing contacted complications ceo acid " +
+  "shining rolled righteous reconsider inspiration goody geek frightening " +
+  "festival ethics creeps courthouse camping assistance affection vow " +
+  "smythe protest lodge haircut forcing essay chairman baked apologized " +
+  "vibe respects receipt mami includes hats exclusive destructive define " +
+  "defeat adore adopt voted tracked signals shorts rory's reminding " +
+  "relative ninth floors dough creations continues cancelled cabot barrel " +
+  "adam's snuck slight reporters rear pressing novel newspapers magnificent " +
+  "madame lazy glorious fiancee candidate brick bits australia activities " +
+  "visitation scholarship sane previous kindness ivy's shoulda rescued " +
+  "mattress maria's lounge lifted label importantly glove enterprises " +
+  "driver's disappointment condo cemetery beings admitting yelled waving " +
+  "screech satisfaction requested reads plants nun nailed described " +
+  "dedicated certificate centuries annual worm tick resting primary polish " +
+  "marvelous fuss funds defensive cortlandt compete chased provided pockets " +
+  "luckily lilith filing depression conversations consideration " +
+  "consciousness worlds innocence indicate grandmother's forehead bam " +
+  "appeared aggressive trailer slam retirement quitting pry person's narrow " +
+  "levels kay's inform encourage dug delighted daylight danced currently " +
+  "confidential billy's ben's aunts washing vic tossed spectra rick's " +
+  "permit marrow lined implying hatred grill efforts corpse clues sober " +
+  "relatives promotion offended morgue larger infected humanity eww emily's " +
+  "electricity electrical distraction cart broadcast wired violation " +
+  "suspended promising harassment glue gathering d'angelo cursed controlled " +
+  "calendar brutal assets warlocks wagon unpleasant proving priorities " +
+  "observation mustn't lease grows flame domestic disappearance depressing " +
+  "thrill sitter ribs offers naw flush exception earrings deadline corporal " +
+  "collapsed update snapped smack orleans offices melt figuring delusional " +
+  "coulda burnt actors trips tender sperm specialist scientific realise " +
+  "pork popped planes kev interrogation institution included esteem " +
+  "communications choosing choir undo pres prayed plague manipulate " +
+  "lifestyle insulting honour detention delightful coffeehouse chess " +
+  "betrayal apologizing adjust wrecked wont whipped rides reminder " +
+  "psychological principle monsieur injuries fame faint confusion christ's " +
+  "bon bake nearest korea industries execution distress definition creating " +
+  "correctly complaint blocked trophy tortured structure rot risking " +
+  "pointless household heir handing eighth dumping cups chloe's alibi " +
+  "absence vital tokyo thus struggling shiny risked refer mummy mint joey's " +
+  "involvement hose hobby fortunate fleischman fitting curtain counseling " +
+  "addition wit transport technical rode puppet opportunities modeling memo " +
+  "irresponsible humiliation hiya freakin fez felony choke blackmailing " +
+  "appreciated tabloid suspicion recovering rally psychology pledge " +
+  "panicked nursery louder jeans investigator identified homecoming " +
+  "helena's height graduated frustrating fabric distant buys busting buff " +
+  "wax sleeve products philosophy irony hospitals dope declare autopsy " +
+  "workin torch substitute scandal prick limb leaf lady's hysterical growth " +
+  "goddamnit fetch dimension day's crowded clip climbing bonding approved " +
+  "yeh woah ultimately trusts returns negotiate millennium majority lethal " +
+  "length iced deeds bore babysitter questioned outrageous medal kiriakis " +
+  "insulted grudge established driveway deserted definite capture beep " +
+  "wires suggestions searched owed originally nickname lighting lend " +
+  "drunken demanding costanza conviction characters bumped weigh touches " +
+  "tempted shout resolve relate poisoned pip phoebe's pete's occasionally " +
+  "molly's meals maker invitations haunted fur footage depending bogus " +
+  "autograph affects tolerate stepping spontaneous sleeps probation " +
+  "presentation performed manny identical fist cycle associates aaron's " +
+  "streak spectacular sector lasted isaac's increase hostages heroin havin " +
+  "habits encouraging cult consult burgers boyfriends bailed baggage " +
+  "association wealthy watches versus troubled torturing teasing sweetest " +
+  "stations sip shawn's rag qualities postpone pad overwhelmed malkovich " +
+  "impulse hut follows classy charging barbara's angel's amazed scenes " +
+  "rising revealed representing policeman offensive mug hypocrite humiliate " +
+  "hideous finals experiences d'ya courts costumes captured bluffing " +
+  "betting bein bedtime alcoholic vegetable tray suspicions spreading " +
+  "splendid shouting roots pressed nooo liza's jew intent grieving gladly " +
+  "fling eliminate disorder courtney's cereal arrives aaah yum technique " +
+  "statements sonofabitch servant roads republican paralyzed orb lotta " +
+  "locks guaranteed european dummy discipline despise dental corporation " +
+  "carries briefing bluff batteries atmosphere whatta tux sounding servants " +
+  "rifle presume kevin's handwriting goals gin fainted elements dried cape " +
+  "allright allowing acknowledge whacked toxic skating reliable quicker " +
+  "penalty panel overwhelming nearby lining importance harassing fatal " +
+  "endless elsewhere dolls convict bold ballet whatcha unlikely spiritual " +
+  "shutting separation recording positively overcome goddam failing essence " +
+  "dose diagnosis cured claiming bully airline ahold yearbook various " +
+  "tempting shelf rig pursuit prosecution pouring possessed partnership " +
+  "miguel's lindsay's countries wonders tsk thorough spine rath psychiatric " +
+  "meaningless latte jammed ignored fiance exposure exhibit evidently " +
+  "duties contempt compromised capacity cans weekends urge theft suing " +
+  "shipment scissors responding refuses proposition noises matching located " +
+  "ink hormones hiv hail grandchildren godfather gently establish crane's " +
+  "contracts compound buffy's worldwide smashed sexually sentimental senor " +
+  "scored patient's nicest marketing manipulated jaw intern handcuffs " +
+  "framed errands entertaining discovery crib carriage barge awards " +
+  "attending ambassador videos tab spends slipping seated rubbing rely " +
+  "reject recommendation reckon ratings headaches float embrace corners " +
+  "whining sweating sole skipped restore receiving population pep mountie " +
+  "motives mama's listens korean heroes heart's cristobel controls " +
+  "cheerleader balsom unnecessary stunning shipping scent santa's " +
+  "quartermaines praise pose montega luxury loosen kyle's keri's info hum " +
+  "haunt gracious git forgiving fleet errand emperor cakes blames abortion " +
+  "worship theories strict sketch shifts plotting physician perimeter " +
+  "passage pals mere mattered lonigan longest jews interference eyewitness " +
+  "enthusiasm encounter diapers craig's artists strongest shaken serves " +
+  "punched projects portal outer nazi hal's colleagues catches bearing " +
+  "backyard academic winds terrorists sabotage pea organs needy mentor " +
+  "measures listed lex cuff civilization caribbean articles writes woof " +
+  "who'll viki's valid rarely rabbi prank performing obnoxious mates " +
+  "improve hereby gabby faked cellar whitelighter void substance strangle " +
+  "sour skill senate purchase native muffins interfering hoh gina's demonic " +
+  "colored clearing civilian buildings boutique barrington trading terrace " +
+  "smoked seed righty relations quack published preliminary petey pact " +
+  "outstanding opinions knot ketchup items examined disappearing cordy coin " +
+  "circuit assist administration walt uptight ticking terrifying tease " +
+  "tabitha's syd swamp secretly rejection reflection realizing rays " +
+  "pennsylvania partly mentally marone jurisdiction frasier's doubted " +
+  "deception crucial congressman cheesy arrival visited supporting stalling " +
+  "scouts scoop ribbon reserve raid notion income immune grandma's expects " +
+  "edition destined constitution classroom bets appreciation appointed " +
+  "accomplice whitney's wander shoved sewer scroll retire paintings lasts " +
+  "fugitive freezer discount cranky crank clearance bodyguard anxiety " +
+  "accountant abby's whoops volunteered terrorist tales talents stinking " +
+  "resolved remotely protocol livvie's garlic decency cord beds asa's areas " +
+  "altogether uniforms tremendous restaurants rank profession popping " +
+  "philadelphia outa observe lung largest hangs feelin experts enforcement " +
+  "encouraged economy dudes donation disguise diane's curb continued " +
+  "competitive businessman bites antique advertising ads toothbrush retreat " +
+  "represents realistic profits predict nora's lid landlord hourglass " +
+  "hesitate frank's focusing equally consolation boyfriend's babbling aged " +
+  "troy's tipped stranded smartest sabrina's rhythm replacement repeating " +
+  "puke psst paycheck overreacted macho leadership kendall's juvenile " +
+  "john's images grocery freshen disposal cuffs consent caffeine arguments " +
+  "agrees abigail's vanished unfinished tobacco tin syndrome ripping pinch " +
+  "missiles isolated flattering expenses dinners cos colleague ciao buh " +
+  "belthazor belle's attorneys amber's woulda whereabouts wars waitin " +
+  "visits truce tripped tee tasted stu steer ruling poisoning nursing " +
+  "manipulative immature husbands heel granddad delivering deaths condoms " +
+  "automatically anchor trashed tournament throne raining prices pasta " +
+  "needles leaning leaders judges ideal detector coolest casting batch " +
+  "approximately appointments almighty achieve vegetables sum spark ruled " +
+  "revolution principles perfection pains momma mole interviews initiative " +
+  "hairs getaway employment den cracking counted compliments behold verge " +
+  "tougher timer tapped taped stakes specialty snooping shoots semi " +
+  "rendezvous pentagon passenger leverage jeopardize janitor grandparents " +
+  "forbidden examination communist clueless cities bidding arriving adding " +
+  "ungrateful unacceptable tutor soviet shaped serum scuse savings pub " +
+  "pajamas mouths modest methods lure irrational depth cries classified " +
+  "bombs beautifully arresting approaching vessel variety traitor " +
+  "sympathetic smug smash rental prostitute premonitions mild jumps " +
+  "inventory ing improved grandfather's developing darlin committing " +
+  "caleb's banging asap amendment worms violated vent traumatic traced tow " +
+  "swiss sweaty shaft recommended overboard literature insight healed grasp " +
+  "fluid experiencing crappy crab connecticut chunk chandler's awww applied " +
+  "witnessed traveled stain shack reacted pronounce presented poured " +
+  "occupied moms marriages jabez invested handful gob gag flipped fireplace " +
+  "expertise embarrassment disappears concussion bruises brakes anything's " +
+  "week's twisting tide swept summon splitting settling scientists " +
+  "reschedule regard purposes ohio notch mike's improvement hooray grabbing " +
+  "extend exquisite disrespect complaints colin's armor voting thornhart " +
+  "sustained straw slapped simon's shipped shattered ruthless reva's refill " +
+  "recorded payroll numb mourning marijuana manly jerry's involving hunk " +
+  "entertain earthquake drift dreadful doorstep confirmation chops " +
+  "bridget's appreciates announced vague tires stressful stem stashed stash " +
+  "sensed preoccupied predictable noticing madly halls gunshot embassy " +
+  "dozens dinner's confuse cleaners charade chalk cappuccino breed bouquet " +
+  "amulet addiction who've warming unlock transition satisfy sacrificed " +
+  "relaxing lone input hampshire girlfriend's elaborate concerning " +
+  "completed channels category cal blocking blend blankets america's " +
+  "addicted yuck voters professionals positions monica's mode initial " +
+  "hunger hamburger greeting greet gravy gram dreamt dice declared " +
+  "collecting caution brady's backpack agreeing writers whale tribe taller " +
+  "supervisor sacrifices radiation poo phew outcome ounce missile meter " +
+  "likewise irrelevant gran felon feature favorites farther fade " +
+  "experiments erased easiest disk convenience conceived compassionate " +
+  "challenged cane blair's backstage agony adores veins tweek thieves " +
+  "surgical strangely stetson recital proposing productive meaningful " +
+  "marching immunity hassle goddamned frighten directors dearly comments " +
+  "closure cease ambition wisconsin unstable sweetness salvage richer " +
+  "refusing raging pumping pressuring petition mortals lowlife jus " +
+  "intimidated intentionally inspire forgave eric's devotion despicable " +
+  "deciding dash comfy breach bo's bark alternate aaaah switching swallowed " +
+  "stove slot screamed scars russians relevant poof pipes persons pawn " +
+  "losses legit invest generations farewell experimental difficulty " +
+  "curtains civilized championship caviar boost token tends temporarily " +
+  "superstition supernatural sunk sadness reduced recorder psyched " +
+  "presidential owners motivated microwave lands karen's hallelujah gap " +
+  "fraternity engines dryer cocoa chewing additional acceptable " +
+  "unbelievably survivor smiled smelling sized simpler sentenced " +
+  "respectable remarks registration premises passengers organ occasional " +
+  "khasinau indication gutter grabs goo fulfill flashlight ellenor courses " +
+  "blooded blessings beware beth's bands advised water's uhhh turf swings " +
+  "slips shocking resistance privately olivia's mirrors lyrics locking " +
+  "instrument historical heartless fras decades comparison childish " +
+  "cassie's cardiac admission utterly tuscany ticked suspension stunned " +
+  "statesville sadly resolution reserved purely opponent noted lowest " +
+  "kiddin jerks hitch flirt fare extension establishment equals dismiss " +
+  "delayed decade christening casket c'mere breakup brad's biting " +
+  "antibiotics accusation abducted witchcraft whoever's traded thread " +
+  "spelling so's school's runnin remaining punching protein printed " +
+  "paramedics newest murdering mine's masks lawndale intact ins initials " +
+  "heights grampa democracy deceased colleen's choking charms careless " +
+  "bushes buns bummed accounting travels taylor's shred saves saddle " +
+  "rethink regards references precinct persuade patterns meds manipulating " +
+  "llanfair leash kenny's housing hearted guarantees flown feast extent " +
+  "educated disgrace determination deposition coverage corridor burial " +
+  "bookstore boil abilities vitals veil trespassing teaches sidewalk " +
+  "sensible punishing overtime optimistic occasions obsessing oak notify " +
+  "mornin jeopardy jaffa injection hilarious distinct directed desires " +
+  "curve confide challenging cautious alter yada wilderness where're " +
+  "vindictive vial tomb teeny subjects stroll sittin scrub rebuild rachel's " +
+  "posters parallel ordeal orbit o'brien nuns max's jennifer's intimacy " +
+  "inheritance fails exploded donate distracting despair democratic " +
+  "defended crackers commercials bryant's ammunition wildwind virtue " +
+  "thoroughly tails spicy sketches sights sheer shaving seize scarecrow " +
+  "refreshing prosecute possess platter phillip's napkin misplaced " +
+  "merchandise membership loony jinx heroic frankenstein fag efficient " +
+  "devil's corps clan boundaries attract ambitious virtually syrup solitary " +
+  "resignation resemblance reacting pursuing premature pod liz's lavery " +
+  "journalist honors harvey's genes flashes erm contribution company's " +
+  "client's cheque charts cargo awright acquainted wrapping untie salute " +
+  "ruins resign realised priceless partying myth moonlight lightly lifting " +
+  "kasnoff insisting glowing generator flowing explosives employer cutie " +
+  "confronted clause buts breakthrough blouse ballistic antidote analyze " +
+  "allowance adjourned vet unto understatement tucked touchy toll " +
+  "subconscious sequence screws sarge roommates reaches rambaldi programs " +
+  "offend nerd knives kin irresistible inherited incapable hostility " +
+  "goddammit fuse frat equation curfew centered blackmailed allows alleged " +
+  "walkin transmission text starve sleigh sarcastic recess rebound " +
+  "procedures pinned parlor outfits livin issued institute industrial " +
+  "heartache head's haired fundraiser doorman documentary discreet dilucca " +
+  "detect cracks cracker considerate climbed catering author apophis zoey " +
+  "vacuum urine tunnels todd's tanks strung stitches sordid sark referred " +
+  "protector portion phoned pets paths mat lengths kindergarten hostess " +
+  "flaw flavor discharge deveraux consumed confidentiality automatic " +
+  "amongst viktor victim's tactics straightened specials spaghetti soil " +
+  "prettier powerless por poems playin playground parker's paranoia nsa " +
+  "mainly mac's joe's instantly havoc exaggerating evaluation eavesdropping " +
+  "doughnuts diversion deepest cutest companion comb bela behaving avoided " +
+  "anyplace agh accessory zap whereas translate stuffing speeding slime " +
+  "polls personalities payments musician marital lurking lottery journalism " +
+  "interior imaginary hog guinea greetings game's fairwinds ethical " +
+  "equipped environmental elegant elbow customs cuban credibility " +
+  "credentials consistent collapse cloth claws chopped challenges bridal " +
+  "boards bedside babysitting authorized assumption ant youngest witty vast " +
+  "unforgivable underworld tempt tabs succeeded sophomore selfless secrecy " +
+  "runway restless programming professionally okey movin metaphor messes " +
+  "meltdown lecter incoming hence gasoline gained funding episodes " +
+  "diefenbaker contain comedian collected cam buckle assembly ancestors " +
+  "admired adjustment acceptance weekly warmth throats seduced ridge's " +
+  "reform rebecca's queer poll parenting noses luckiest graveyard gifted " +
+  "footsteps dimeras cynical assassination wedded voyage volunteers verbal " +
+  "unpredictable tuned stoop slides sinking show's rio rigged regulations " +
+  "region promoted plumbing lingerie layer katie's hankey greed everwood " +
+  "essential elope dresser departure dat dances coup chauffeur bulletin " +
+  "bugged bouncing website tubes temptation supported strangest sorel's " +
+  "slammed selection sarcasm rib primitive platform pending partial " +
+  "packages orderly obsessive nevertheless nbc murderers motto meteor " +
+  "inconvenience glimpse froze fiber execute etc ensure drivers dispute " +
+  "damages crop courageous consulate closes bosses bees amends wuss wolfram " +
+  "wacky unemployed traces town's testifying tendency syringe symphony stew " +
+  "startled sorrow sleazy shaky screams rsquo remark poke phone's philip's " +
+  "nutty nobel mentioning mend mayor's iowa inspiring impulsive housekeeper " +
+  "germans formed foam fingernails economic divide conditioning baking " +
+  "whine thug starved sedative rose's reversed publishing programmed picket " +
+  "paged nowadays newman's mines margo's invasion homosexual homo hips " +
+  "forgets flipping flea flatter dwell dumpster consultant choo banking " +
+  "assignments apartments ants affecting advisor vile unreasonable tossing " +
+  "thanked steals souvenir screening scratched rep psychopath proportion " +
+  "outs operative obstruction obey neutral lump lily's insists ian's harass " +
+  "gloat flights filth extended electronic edgy diseases didn coroner " +
+  "confessing cologne cedar bruise betraying bailing attempting appealing " +
+  "adebisi wrath wandered waist vain traps transportation stepfather " +
+  "publicly presidents poking obligated marshal lexie's instructed heavenly " +
+  "halt employed diplomatic dilemma crazed contagious coaster cheering " +
+  "carved bundle approached appearances vomit thingy stadium speeches " +
+  "robbing reflect raft qualify pumped pillows peep pageant packs neo " +
+  "neglected m'kay loneliness liberal intrude indicates helluva gardener " +
+  "freely forresters err drooling continuing betcha alan's addressed " +
+  "acquired vase supermarket squat spitting spaces slaves rhyme relieve " +
+  "receipts racket purchased preserve pictured pause overdue officials nod " +
+  "motivation morgendorffer lucky's lacking kidnapper introduction insect " +
+  "hunters horns feminine eyeballs dumps disc disappointing difficulties " +
+  "crock convertible context claw clamp canned cambias bathtub avanya " +
+  "artery weep warmer vendetta tenth suspense summoned stuff's spiders " +
+  "sings reiber raving pushy produced poverty postponed ohhhh noooo mold " +
+  "mice laughter incompetent hugging groceries frequency fastest drip " +
+  "differ daphne's communicating body's beliefs bats bases auntie adios " +
+  "wraps willingly weirdest voila timmih thinner swelling swat steroids " +
+  "sensitivity scrape rehearse quarterback organic matched ledge justified " +
+  "insults increased heavily hateful handles feared doorway decorations " +
+  "colour chatting buyer buckaroo bedrooms batting askin ammo tutoring " +
+  "subpoena span scratching requests privileges pager mart kel intriguing " +
+  "idiotic hotels grape enlighten dum door's dixie's demonstrate dairy " +
+  "corrupt combined brunch bridesmaid barking architect applause alongside " +
+  "ale acquaintance yuh wretched superficial sufficient sued soak smoothly " +
+  "sensing restraint quo pow posing pleading pittsburgh peru payoff " +
+  "participate organize oprah nemo morals loans loaf lists laboratory jumpy " +
+  "intervention ignorant herbal hangin germs generosity flashing country's " +
+  "convent clumsy chocolates captive bianca's behaved apologise vanity " +
+  "trials stumbled republicans represented recognition preview poisonous " +
+  "perjury parental onboard mugged minding linen learns knots interviewing " +
+  "inmates ingredients humour grind greasy goons estimate elementary " +
+  "edmund's drastic database coop comparing cocky clearer bruised brag bind " +
+  "axe asset apparent ann's worthwhile whoop wedding's vanquishing tabloids " +
+  "survivors stenbeck's sprung spotlight shops sentencing sentences " +
+  "revealing reduce ram racist provoke piper's pining overly oui ops mop " +
+  "louisiana locket king's jab imply impatient hovering hotter fest endure " +
+  "dots doren dim diagnosed debts cultures crawled contained condemned " +
+  "chained brit breaths adds weirdo warmed wand utah troubling tok'ra " +
+  "stripped strapped soaked skipping sharon's scrambled rattle profound " +
+  "musta mocking mnh misunderstand merit loading linked limousine kacl " +
+  "investors interviewed hustle forensic foods enthusiastic duct drawers " +
+  "devastating democrats conquer concentration comeback clarify chores " +
+  "cheerleaders cheaper charlie's callin blushing barging abused yoga " +
+  "wrecking wits waffles virginity vibes uninvited unfaithful underwater " +
+  "tribute strangled state's scheming ropes responded residents rescuing " +
+  "rave priests postcard overseas orientation ongoing o'reily newly neil's " +
+  "morphine lotion limitations lesser lectures lads kidneys judgement jog " +
+  "itch intellectual installed infant indefinitely grenade glamorous " +
+  "genetically freud faculty engineering doh discretion delusions " +
+  "declaration crate competent commonwealth catalog bakery attempts asylum " +
+  "argh applying ahhhh yesterday's wedge wager unfit tripping treatments " +
+  "torment superhero stirring spinal sorority seminar scenery repairs " +
+  "rabble pneumonia perks owl override ooooh moo mija manslaughter mailed " +
+  "love's lime lettuce intimidate instructor guarded grieve grad globe " +
+  "frustration extensive exploring exercises eve's doorbell devices deal's " +
+  "dam cultural ctu credits commerce chinatown chemicals baltimore " +
+  "authentic arraignment annulled altered allergies wanta verify vegetarian " +
+  "tunes tourist tighter telegram suitable stalk specimen spared solving " +
+  "shoo satisfying saddam requesting publisher pens overprotective " +
+  "obstacles notified negro nasedo judged jill's identification grandchild " +
+  "genuinely founded flushed fluids floss escaping ditched demon's " +
+  "decorated criticism cramp corny contribute connecting bunk bombing " +
+  "bitten billions bankrupt yikes wrists ultrasound ultimatum thirst " +
+  "spelled sniff scope ross's room's retrieve releasing reassuring pumps " +
+  "properties predicted neurotic negotiating needn't multi monitors " +
+  "millionaire microphone mechanical lydecker limp incriminating hatchet " +
+  "gracias gordie fills feeds egypt doubting dedication decaf dawson's " +
+  "competing cellular biopsy whiz voluntarily visible ventilator unpack " +
+  "unload universal tomatoes targets suggests strawberry spooked snitch " +
+  "schillinger sap reassure providing prey pressure's persuasive mystical " +
+  "mysteries mri moment's mixing matrimony mary's mails lighthouse " +
+  "liability kgb jock headline frankie's factors explosive explanations " +
+  "dispatch detailed curly cupid condolences comrade cassadines bulb " +
+  "brittany's bragging awaits assaulted ambush adolescent adjusted abort " +
+  "yank whit verse vaguely undermine tying trim swamped stitch stan's " +
+  "stabbing slippers skye's sincerely sigh setback secondly rotting rev " +
+  "retail proceedings preparation precaution pox pcpd nonetheless melting " +
+  "materials mar liaison hots hooking headlines hag ganz fury felicity " +
+  "fangs expelled encouragement earring dreidel draws dory donut dog's dis " +
+  "dictate dependent decorating coordinates cocktails bumps blueberry " +
+  "believable backfired backfire apron anticipated adjusting activated vous " +
+  "vouch vitamins vista urn uncertain ummm tourists tattoos surrounding " +
+  "sponsor slimy singles sibling shhhh restored representative renting " +
+  "reign publish planets peculiar parasite paddington noo marries mailbox " +
+  "magically lovebirds listeners knocks kane's informant grain exits elf " +
+  "drazen distractions disconnected dinosaurs designing dashwood crooked " +
+  "conveniently contents argued wink warped underestimated testified tacky " +
+  "substantial steve's steering staged stability shoving seizure reset " +
+  "repeatedly radius pushes pitching pairs opener mornings mississippi " +
+  "matthew's mash investigations invent indulge horribly hallucinating " +
+  "festive eyebrows expand enjoys dictionary dialogue desperation dealers " +
+  "darkest daph critic consulting cartman's canal boragora belts bagel " +
+  "authorization auditions associated ape amy's agitated adventures " +
+  "withdraw wishful wimp vehicles vanish unbearable tonic tom's tackle " +
+  "suffice suction slaying singapore safest rosanna's rocking relive rates " +
+  "puttin prettiest oval noisy newlyweds nauseous moi misguided mildly " +
+  "midst maps liable kristina's judgmental introducing individuals hunted " +
+  "hen givin frequent fisherman fascinated elephants dislike diploma " +
+  "deluded decorate crummy contractions carve careers bottled bonded " +
+  "bahamas unavailable twenties trustworthy translation traditions " +
+  "surviving surgeons stupidity skies secured salvation remorse rafe's " +
+  "princeton preferably pies photography operational nuh northwest nausea " +
+  "napkins mule mourn melted mechanism mashed julia's inherit holdings hel " +
+  "greatness golly excused edges dumbo drifting delirious damaging cubicle " +
+  "compelled comm colleges cole's chooses checkup chad's certified " +
+  "candidates boredom bob's bandages baldwin's bah automobile athletic " +
+  "alarms absorbed absent windshield who're whaddya vitamin transparent " +
+  "surprisingly sunglasses starring slit sided schemes roar relatively " +
+  "reade quarry prosecutor prognosis probe potentially pitiful persistent " +
+  "perception percentage peas oww nosy neighbourhood nagging morons " +
+  "molecular meters masterpiece martinis limbo liars jax's irritating " +
+  "inclined hump hoynes haw gauge functions fiasco educational eatin " +
+  "donated destination dense cubans continent concentrating commanding " +
+  "colorful clam cider brochure behaviour barto bargaining awe artistic " +
+  "welcoming weighing villain vein vanquished striking stains sooo smear " +
+  "sire simone's secondary roughly rituals resentment psychologist " +
+  "preferred pint pension passive overhear origin orchestra negotiations " +
+  "mounted morality landingham labs kisser jackson's icy hoot holling " +
+  "handshake grilled functioning formality elevators edward's depths " +
+  "confirms civilians bypass briefly boathouse binding acres accidental " +
+  "westbridge wacko ulterior transferring tis thugs tangled stirred " +
+  "stefano's sought snag smallest sling sleaze seeds rumour ripe remarried " +
+  "reluctant regularly puddle promote precise popularity pins perceptive " +
+  "miraculous memorable maternal lucinda's longing lockup locals librarian " +
+  "job's inspection impressions immoral hypothetically guarding gourmet " +
+  "gabe fighters fees features faxed extortion expressed essentially " +
+  "downright digest der crosses cranberry city's chorus casualties bygones " +
+  "buzzing burying bikes attended allah all's weary viewing viewers " +
+  "transmitter taping takeout sweeping stepmother stating stale seating " +
+  "seaborn resigned rating prue's pros pepperoni ownership occurs nicole's " +
+  "newborn merger mandatory malcolm's ludicrous jan's injected holden's " +
+  "henry's heating geeks forged faults expressing eddie's drue dire dief " +
+  "desi deceiving centre celebrities caterer calmed businesses budge " +
+  "ashley's applications ankles vending typing tribbiani there're squared " +
+  "speculation snowing shades sexist scudder's scattered sanctuary rewrite " +
+  "regretted regain raises processing picky orphan mural misjudged " +
+  "miscarriage memorize marshall's mark's licensed lens leaking launched " +
+  "larry's languages judge's jitters invade interruption implied illegally " +
+  "handicapped glitch gittes finer fewer engineered distraught dispose " +
+  "dishonest digs dahlia's dads cruelty conducting clinical circling " +
+  "champions canceling butterflies belongings barbrady amusement " +
+  "allegations alias aging zombies where've unborn tri swearing stables " +
+  "squeezed spaulding's slavery sew sensational revolutionary resisting " +
+  "removing radioactive races questionable privileged portofino par owning " +
+  "overlook overhead orson oddly nazis musicians interrogate instruments " +
+  "imperative impeccable icu hurtful hors heap harley's graduating graders " +
+  "glance endangered disgust devious destruct demonstration creates crazier " +
+  "countdown coffee's chump cheeseburger cat's burglar brotherhood berries " +
+  "ballroom assumptions ark annoyed allies allergy advantages admirer " +
+  "admirable addresses activate accompany wed victoria's valve underpants " +
+  "twit triggered teacher's tack strokes stool starr's sham seasons " +
+  "sculpture scrap sailed retarded resourceful remarkably refresh ranks " +
+  "pressured precautions pointy obligations nightclub mustache month's " +
+  "minority mind's maui lace isabella's improving iii hunh hubby flare " +
+  "fierce farmers dont dokey divided demise demanded dangerously crushing " +
+  "considerable complained clinging choked chem cheerleading checkbook " +
+  "cashmere calmly blush believer aspect amazingly alas acute a's yak " +
+  "whores what've tuition trey's tolerance toilets tactical tacos stairwell " +
+  "spur spirited slower sewing separately rubbed restricted punches " +
+  "protects partially ole nuisance niagara motherfuckers mingle mia's " +
+  "kynaston knack kinkle impose hosting harry's gullible grid godmother " +
+  "funniest friggin folding financially filming fashions eater " +
+  "dysfunctional drool distinguished defence defeated cruising crude " +
+  "criticize corruption contractor conceive clone circulation cedars " +
+  "caliber brighter blinded birthdays bio bill's banquet artificial " +
+  "anticipate annoy achievement whim whichever volatile veto vested uncle's " +
+  "supports successfully shroud severely rests representation quarantine " +
+  "premiere pleases parent's painless pads orphans orphanage offence " +
+  "obliged nip niggers negotiation narcotics nag mistletoe meddling " +
+  "manifest lookit loo lilah investigated intrigued injustice homicidal " +
+  "hayward's gigantic exposing elves disturbance disastrous depended " +
+  "demented correction cooped colby's cheerful buyers brownies beverage " +
+  "basics attorney's atm arvin arcade weighs upsets unethical tidy swollen " +
+  "sweaters swap stupidest sensation scalpel rail prototype props " +
+  "prescribed pompous poetic ploy paws operates objections mushrooms " +
+  "mulwray monitoring manipulation lured lays lasting kung keg jell " +
+  "internship insignificant inmate incentive gandhi fulfilled flooded " +
+  "expedition evolution discharged disagreement dine dean's crypt coroner's " +
+  "cornered copied confrontation cds catalogue brightest beethoven banned " +
+  "attendant athlete amaze airlines yogurt wyndemere wool vocabulary vcr " +
+  "tulsa tags tactic stuffy slug sexuality seniors segment revelation " +
+  "respirator pulp prop producing processed pretends polygraph perp pennies " +
+  "ordinarily opposition olives necks morally martyr martial lisa's " +
+  "leftovers joints jimmy's irs invaded imported hopping homey hints " +
+  "helicopters heed heated heartbroken gulf greatly forge florist firsthand " +
+  "fiend expanding emma's defenses crippled cousin's corrected conniving " +
+  "conditioner clears chemo bubbly bladder beeper baptism apb answer's " +
+  "anna's angles ache womb wiring wench weaknesses volunteering violating " +
+  "unlocked unemployment tummy tibet threshold surrogate submarine subid " +
+  "stray stated startle specifics snob slowing sled scoot robbers rightful " +
+  "richest quid qfxmjrie puffs probable pitched pierced pencils paralysis " +
+  "nuke managing makeover luncheon lords linksynergy jury's jacuzzi ish " +
+  "interstate hitched historic hangover gasp fracture flock firemen " +
+  "drawings disgusted darned coal clams chez cables broadcasting brew " +
+  "borrowing banged achieved wildest weirder unauthorized stunts sleeves " +
+  "sixties shush shalt senora rises retro quits pupils politicians pegged " +
+  "painfully paging outlet omelet observed ned's memorized lawfully jackets " +
+  "interpretation intercept ingredient grownup glued gaining fulfilling " +
+  "flee enchanted dvd delusion daring conservative conducted compelling " +
+  "charitable carton bronx bridesmaids bribed boiling bathrooms bandage " +
+  "awareness awaiting assign arrogance antiques ainsley turkeys travelling " +
+  "trashing tic takeover sync supervision stockings stalked stabilized " +
+  "spacecraft slob skates sirs sedated robes reviews respecting rat's " +
+  "psyche prominent prizes presumptuous prejudice platoon permitted " +
+  "paragraph mush mum's movements mist missions mints mating mantan lorne " +
+  "lord's loads listener legendary itinerary hugs hepatitis heave guesses " +
+  "gender flags fading exams examining elizabeth's egyptian dumbest " +
+  "dishwasher dimera's describing deceive cunning cripple cove convictions " +
+  "congressional confided compulsive compromising burglary bun bumpy " +
+  "brainwashed benes arnie alvy affirmative adrenaline adamant watchin " +
+  "waitresses uncommon treaty transgenic toughest toby's surround stormed " +
+  "spree spilling spectacle soaking significance shreds sewers severed " +
+  "scarce scamming scalp sami's salem's rewind rehearsing pretentious " +
+  "potions possessions planner placing periods overrated obstacle notices " +
+  "nerds meems medieval mcmurphy maturity maternity masses maneuver lyin " +
+  "loathe lawyer's irv investigators hep grin gospel gals formation " +
+  "fertility facilities exterior epidemic eloping ecstatic ecstasy duly " +
+  "divorcing distribution dignan debut costing coaching clubhouse clot " +
+  "clocks classical candid bursting breather braces bennett's bending " +
+  "australian attendance arsonist applies adored accepts absorb vacant uuh " +
+  "uphold unarmed turd topolsky thrilling thigh terminate tempo sustain " +
+  "spaceship snore sneeze smuggling shrine sera scott's salty salon ramp " +
+  "quaint prostitution prof policies patronize patio nasa morbid marlo's " +
+  "mamma locations licence kettle joyous invincible interpret insecurities " +
+  "insects inquiry infamous impulses illusions holed glen's fragments " +
+  "forrester's exploit economics drivin des defy defenseless dedicate " +
+  "cradle cpr coupon countless conjure confined celebrated cardboard " +
+  "booking blur bleach ban backseat austin's alternatives afterward " +
+  "accomplishment wordsworth wisely wildlife valet vaccine urges unnatural " +
+  "unlucky truths traumatized tit tennessee tasting swears strawberries " +
+  "steaks stats skank seducing secretive screwdriver schedules rooting " +
+  "rightfully rattled qualifies puppets provides prospects pronto prevented " +
+  "powered posse poorly polling pedestal palms muddy morty miniature " +
+  "microscope merci margin lecturing inject incriminate hygiene hospital's " +
+  "grapefruit gazebo funnier freight flooding equivalent eliminated " +
+  "elaine's dios deacon's cuter continental container cons compensation " +
+  "clap cbs cavity caves capricorn canvas calculations bossy booby bacteria " +
+  "aides zende winthrop wider warrants valentines undressed underage " +
+  "truthfully tampered suffers stored statute speechless sparkling sod " +
+  "socially sidelines shrek sank roy's raul's railing puberty practices " +
+  "pesky parachute outrage outdoors operated openly nominated motions moods " +
+  "lunches litter kidnappers itching intuition index imitation icky " +
+  "humility hassling gallons firmly excessive evolved employ eligible " +
+  "elections elderly drugstore dosage disrupt directing dipping deranged " +
+  "debating cuckoo cremated craziness cooperating compatible circumstantial " +
+  "chimney bonnie's blinking biscuits belgium arise analyzed admiring " +
+  "acquire accounted willow's weeping volumes views triad trashy " +
+  "transaction tilt soothing slumber slayers skirts siren ship's shindig " +
+  "sentiment sally's rosco riddance rewarded quaid purity proceeding " +
+  "pretzels practiced politician polar panicking overall occupation naming " +
+  "minimal mckechnie massacre marah's lovin leaked layers isolation " +
+  "intruding impersonating ignorance hoop hamburgers gwen's fruits " +
+  "footprints fluke fleas festivities fences feisty evacuate emergencies " +
+  "diabetes detained democrat deceived creeping craziest corpses conned " +
+  "coincidences charleston bums brussels bounced bodyguards blasted " +
+  "bitterness baloney ashtray apocalypse advances zillion watergate " +
+  "wallpaper viable tory's tenants telesave sympathize sweeter swam sup " +
+  "startin stages spencer's sodas snowed sleepover signor seein reviewing " +
+  "reunited retainer restroom rested replacing repercussions reliving reef " +
+  "reconciliation reconcile recognise prevail preaching planting overreact " +
+  "oof omen o'neil numerous noose moustache morning's manicure maids mah " +
+  "lorelei's landlady hypothetical hopped homesick hives hesitation herbs " +
+  "hectic heartbreak haunting gangs frown fingerprint extract expired " +
+  "exhausting exchanged exceptional everytime encountered disregard daytime " +
+  "cooperative constitutional cling chevron chaperone buenos blinding bitty " +
+  "beads battling badgering anticipation advocate zander's waterfront " +
+  "upstanding unprofessional unity unhealthy undead turmoil truthful " +
+  "toothpaste tippin thoughtless tagataya stretching strategic spun " +
+  "shortage shooters sheriff's shady senseless sailors rewarding refuge " +
+  "rapid rah pun propane pronounced preposterous pottery portable pigeons " +
+  "pastry overhearing ogre obscene novels negotiable mtv morgan's monthly " +
+  "loner leisure leagues jogging jaws itchy insinuating insides induced " +
+  "immigration hospitality hormone hilda's hearst grandpa's frequently " +
+  "forthcoming fists fifties etiquette endings elevated editing dunk " +
+  "distinction disabled dibs destroys despises desired designers deprived " +
+  "dancers dah cuddy crust conductor communists cloak circumstance chewed " +
+  "casserole bora bidder bearer assessment artoo applaud appalling amounts " +
+  "admissions withdrawal weights vowed virgins vigilante vatican undone " +
+  "trench touchdown throttle thaw tha testosterone tailor symptom swoop " +
+  "suited suitcases stomp sticker stakeout spoiling snatched smoochy " +
+  "smitten shameless restraints researching renew relay regional refund " +
+  "reclaim rapids raoul rags puzzles purposely punks prosecuted plaid " +
+  "pineapple picturing pickin pbs parasites offspring nyah mysteriously " +
+  "multiply mineral masculine mascara laps kramer's jukebox interruptions " +
+  "hoax gunfire gays furnace exceptions engraved elbows duplicate drapes " +
+  "designated deliberate deli decoy cub cryptic crowds critics coupla " +
+  "convert conventional condemn complicate combine colossal clerks clarity " +
+  "cassadine's byes brushed bride's banished arrests argon andy's alarmed " +
+  "worships versa uncanny troop treasury transformation terminated " +
+  "telescope technicality sydney's sundae stumble stripping shuts " +
+  "separating schmuck saliva robber retain remained relentless reconnect " +
+  "recipes rearrange ray's rainy psychiatrists producers policemen plunge " +
+  "plugged patched overload ofc obtained obsolete o'malley numbered " +
+  "number's nay moth module mkay mindless menus lullaby lotte leavin layout " +
+  "knob killin karinsky irregular invalid hides grownups griff flaws flashy " +
+  "flaming fettes evicted epic encoded dread dil degrassi dealings dangers " +
+  "cushion console concluded casey's bowel beginnings barged apes " +
+  "announcing amanda's admits abroad abide abandoning workshop wonderfully " +
+  "woak warfare wait'll wad violate turkish tim's ter targeted susan's " +
+  "suicidal stayin sorted slamming sketchy shoplifting shapes selected " +
+  "sarah's retiring raiser quizmaster pursued pupkin profitable prefers " +
+  "politically phenomenon palmer's olympics needless nature's mutt " +
+  "motherhood momentarily migraine lizzie's lilo lifts leukemia leftover " +
+  "law's keepin idol hinks hellhole h'mm gowns goodies gallon futures " +
+  "friction finale farms extraction entertained electronics eighties " +
+  "earth's dmv darker daniel's cum conspiring consequence cheery caps calf " +
+  "cadet builds benign barney's aspects artillery apiece allison's " +
+  "aggression adjustments abusive abduction wiping whipping welles " +
+  "unspeakable unlimited unidentified trivial transcripts threatens " +
+  "textbook tenant supervise superstitious stricken stretched story's " +
+  "stimulating steep statistics spielberg sodium slices shelves scratches " +
+  "saudi sabotaged roxy's retrieval repressed relation rejecting quickie " +
+  "promoting ponies peeking paw paolo outraged observer o'connell moping " +
+  "moaning mausoleum males licked kovich klutz iraq interrogating " +
+  "interfered intensive insulin infested incompetence hyper horrified " +
+  "handedly hacked guiding glamour geoff gekko fraid fractured formerly " +
+  "flour firearms fend executives examiner evaluate eloped duke's " +
+  "disoriented delivers dashing crystals crossroads crashdown court's " +
+  "conclude coffees cockroach climate chipped camps brushing boulevard " +
+  "bombed bolts begs baths baptized astronaut assurance anemia allegiance " +
+  "aiming abuela abiding workplace withholding weave wearin weaker warnings " +
+  "usa tours thesis terrorism suffocating straws straightforward stench " +
+  "steamed starboard sideways shrinks shortcut sean's scram roasted roaming " +
+  "riviera respectfully repulsive recognizes receiver psychiatry provoked " +
+  "penitentiary peed pas painkillers oink norm ninotchka muslim " +
+  "montgomery's mitzvah milligrams mil midge marshmallows markets macy's " +
+  "looky lapse kubelik knit jeb investments intellect improvise implant " +
+  "hometown hanged handicap halo governor's goa'ulds giddy gia's geniuses " +
+  "fruitcake footing flop findings fightin fib editorial drinkin doork " +
+  "discovering detour danish cuddle crashes coordinate combo colonnade " +
+  "collector cheats cetera canadians bip bailiff auditioning assed amused " +
+  "alienate algebra alexi aiding aching woe wah unwanted typically tug " +
+  "topless tongues tiniest them's symbols superiors soy soften sheldrake " +
+  "sensors seller seas ruler rival rips renowned recruiting reasoning " +
+  "rawley raisins racial presses preservation portfolio oversight " +
+  "organizing obtain observing nessa narrowed minions midwest meth merciful " +
+  "manages magistrate lawsuits labour invention intimidating infirmary " +
+  "indicated inconvenient imposter hugged honoring holdin hades godforsaken " +
+  "fumes forgery foremost foolproof folder folded flattery fingertips " +
+  "financing fifteenth exterminator explodes eccentric drained dodging " +
+  "documented disguised developments currency crafts constructive concealed " +
+  "compartment chute chinpokomon captains capitol calculated buses bodily " +
+  "astronauts alimony accustomed accessories abdominal zen zach's wrinkle " +
+  "wallow viv vicinity venue valued valium valerie's upgrade upcoming " +
+  "untrue uncover twig twelfth trembling treasures torched toenails timed " +
+  "termites telly taunting taransky tar talker succubus statues smarts " +
+  "sliding sizes sighting semen seizures scarred savvy sauna saddest " +
+  "sacrificing rubbish riled ricky's rican revive recruit ratted rationally " +
+  "provenance professors prestigious pms phonse perky pedal overdose " +
+  "organism nasal nanites mushy movers moot missus midterm merits " +
+  "melodramatic manure magnetic knockout knitting jig invading interpol " +
+  "incapacitated idle hotline horse's highlight hauling hair's gunpoint " +
+  "greenwich grail ganza framing formally fleeing flap flannel fin fibers " +
+  "faded existing email eavesdrop dwelling dwarf donations detected " +
+  "desserts dar corporations constellation collision chic calories " +
+  "businessmen buchanan's breathtaking bleak blacked batter balanced ante " +
+  "aggravated agencies abu yanked wuh withdrawn wigand whoah wham vocal " +
+  "unwind undoubtedly unattractive twitch trimester torrance timetable " +
+  "taxpayers strained stationed stared slapping sincerity signatures siding " +
+  "siblings shit's shenanigans shacking seer satellites sappy samaritan " +
+  "rune regained rebellion proceeds privy power's poorer politely paste " +
+  "oysters overruled olaf nightcap networks necessity mosquito millimeter " +
+  "michelle's merrier massachusetts manuscript manufacture manhood lunar " +
+  "lug lucked loaned kilos ignition hurl hauled harmed goodwill freshmen " +
+  "forming fenmore fasten farce failures exploding erratic elm drunks " +
+  "ditching d'artagnan crops cramped contacting coalition closets clientele " +
+  "chimp cavalry casa cabs bled bargained arranging archives anesthesia " +
+  "amuse altering afternoons accountable abetting wrinkles wolek waved " +
+  "unite uneasy unaware ufo toot toddy tens tattooed tad's sway stained " +
+  "spauldings solely sliced sirens schibetta scatter rumours roger's " +
+  "robbie's rinse remo remedy redemption queen's progressive pleasures " +
+  "picture's philosopher pacey's optimism oblige natives muy measuring " +
+  "measured masked mascot malicious mailing luca lifelong kosher koji " +
+  "kiddies judas isolate intercepted insecurity initially inferior " +
+  "incidentally ifs hun heals headlights guided growl grilling glazed gem " +
+  "gel gaps fundamental flunk floats fiery fairness exercising excellency " +
+  "evenings ere enrolled disclosure det department's damp curling cupboard " +
+  "counterfeit cooling condescending conclusive clicked cleans cholesterol " +
+  "chap cashed brow broccoli brats blueprints blindfold biz billing " +
+  "barracks attach aquarium appalled altitude alrighty aimed yawn xander's " +
+  "wynant winslow's welcomed violations upright unsolved unreliable toots " +
+  "tighten symbolic sweatshirt steinbrenner steamy spouse sox sonogram " +
+  "slowed slots sleepless skeleton shines roles retaliate representatives " +
+  "rephrase repeated renaissance redeem rapidly rambling quilt quarrel " +
+  "prying proverbial priced presiding presidency prescribe prepped pranks " +
+  "possessive plaintiff philosophical pest persuaded perk pediatrics " +
+  "paige's overlooked outcast oop odor notorious nightgown mythology mumbo " +
+  "monitored mediocre master's mademoiselle lunchtime lifesaver legislation " +
+  "leaned lambs lag killings interns intensity increasing identities " +
+  "hounding hem hellmouth goon goner ghoul germ gardening frenzy foyer " +
+  "food's extras extinct exhibition exaggerate everlasting enlightened " +
+  "drilling doubles digits dialed devote defined deceitful d'oeuvres csi " +
+  "cosmetic contaminated conspired conning colonies cerebral cavern " +
+  "cathedral carving butting boiled blurry beams barf babysit assistants " +
+  "ascension architecture approaches albums albanian aaaaah wildly whoopee " +
+  "whiny weiskopf walkie vultures veteran vacations upfront unresolved tile " +
+  "tampering struggled stockholders specially snaps sleepwalking shrunk " +
+  "sermon seeks seduction scenarios scams ridden revolve repaired " +
+  "regulation reasonably reactor quotes preserved phenomenal patrolling " +
+  "paranormal ounces omigod offs nonstop nightfall nat militia meeting's " +
+  "logs lineup libby's lava lashing labels kilometers kate's invites " +
+  "investigative innocents infierno incision import implications humming " +
+  "highlights haunts greeks gloss gloating general's frannie flute fled " +
+  "fitted finishes fiji fetal feeny entrapment edit dyin download " +
+  "discomfort dimensions detonator dependable deke decree dax cot " +
+  "confiscated concludes concede complication commotion commence chulak " +
+  "caucasian casually canary brainer bolie ballpark arm's anwar anatomy " +
+  "analyzing accommodations yukon youse wring wharf wallowing uranium " +
+  "unclear treason transgenics thrive think's thermal territories tedious " +
+  "survives stylish strippers sterile squeezing squeaky sprained solemn " +
+  "snoring sic shifting shattering shabby seams scrawny rotation risen " +
+  "revoked residue reeks recite reap ranting quoting primal pressures " +
+  "predicament precision plugs pits pinpoint petrified petite persona " +
+  "pathological passports oughtta nods nighter navigate nashville namely " +
+  "museums morale milwaukee meditation mathematics martin's malta logan's " +
+  "latter kippie jackie's intrigue intentional insufferable incomplete " +
+  "inability imprisoned hup hunky how've horrifying hearty headmaster hath " +
+  "har hank's handbook hamptons grazie goof george's funerals fuck's " +
+  "fraction forks finances fetched excruciating enjoyable enhanced enhance " +
+  "endanger efficiency dumber drying diabolical destroyer desirable " +
+  "defendants debris darts cuisine cucumber cube crossword contestant " +
+  "considers comprehend club's clipped classmates choppers certificates " +
+  "carmen's canoe candlelight building's brutally brutality boarded " +
+  "bathrobe backward authorize audrey's atom assemble appeals airports " +
+  "aerobics ado abbott's wholesome whiff vessels vermin varsity trophies " +
+  "trait tragically toying titles tissues testy team's tasteful surge sun's " +
+  "studios strips stocked stephen's staircase squares spinach sow southwest " +
+  "southeast sookie's slayer's sipping singers sidetracked seldom scrubbing " +
+  "scraping sanctity russell's ruse robberies rink ridin retribution " +
+  "reinstated refrain rec realities readings radiant protesting projector " +
+  "posed plutonium plaque"
+
+/* Common female first names. */
+const femaleNameList = "mary patricia linda barbara elizabeth jennifer maria " +
+  "susan margaret dorothy lisa nancy karen betty helen sandra donna carol " +
+  "ruth sharon michelle laura sarah kimberly deborah jessica shirley " +
+  "cynthia angela melissa brenda amy anna rebecca virginia kathleen pamela " +
+  "martha debra amanda stephanie carolyn christine marie janet catherine " +
+  "frances ann joyce diane alice julie heather teresa doris gloria evelyn " +
+  "jean cheryl mildred katherine joan ashley judith rose janice kelly " +
+  "nicole judy christina kathy theresa beverly denise tammy irene jane lori " +
+  "rachel marilyn andrea kathryn louise sara anne jacqueline wanda bonnie " +
+  "julia ruby lois tina phyllis norma paula diana annie lillian emily robin " +
+  "peggy crystal gladys rita dawn connie florence tracy edna tiffany carmen " +
+  "rosa cindy grace wendy victoria edith kim sherry sylvia josephine thelma " +
+  "shannon sheila ethel ellen elaine marjorie carrie charlotte monica " +
+  "esther pauline emma juanita anita rhonda hazel amber eva debbie april " +
+  "leslie clara lucille jamie joanne eleanor valerie danielle megan alicia " +
+  "suzanne michele gail bertha darlene veronica jill erin geraldine lauren " +
+  "cathy joann lorraine lynn sally regina erica beatrice dolores bernice " +
+  "audrey yvonne annette june marion dana stacy ana renee ida vivian " +
+  "roberta holly brittany melanie loretta yolanda jeanette laurie katie " +
+  "kristen vanessa alma sue elsie beth jeanne vicki carla tara rosemary " +
+  "eileen terri gertrude lucy tonya ella stacey wilma gina kristin jessie " +
+  "natalie agnes vera charlene bessie delores melinda pearl arlene maureen " +
+  "colleen allison tamara joy georgia constance lillie claudia jackie " +
+  "marcia tanya nellie minnie marlene heidi glenda lydia viola courtney " +
+  "marian stella caroline dora jo vickie mattie maxine irma mabel marsha " +
+  "myrtle lena christy deanna patsy hilda gwendolyn jennie nora margie nina " +
+  "cassandra leah penny kay priscilla naomi carole olga billie dianne " +
+  "tracey leona jenny felicia sonia miriam velma becky bobbie violet " +
+  "kristina toni misty mae shelly daisy ramona sherri erika katrina claire " +
+  "lindsey lindsay geneva guadalupe belinda margarita sheryl cora faye ada " +
+  "natasha sabrina isabel marguerite hattie harriet molly cecilia kristi " +
+  "brandi blanche sandy rosie joanna iris eunice angie inez lynda madeline " +
+  "amelia alberta genevieve monique jodi janie kayla sonya jan kristine " +
+  "candace fannie maryann opal alison yvette melody luz susie olivia flora " +
+  "shelley kristy mamie lula lola verna beulah antoinette candice juana " +
+  "jeannette pam kelli whitney bridget karla celia latoya patty shelia " +
+  "gayle della vicky lynne sheri marianne kara jacquelyn erma blanca myra " +
+  "leticia pat krista roxanne angelica robyn adrienne rosalie alexandra " +
+  "brooke bethany sadie bernadette traci jody kendra nichole rachael mable " +
+  "ernestine muriel marcella elena krystal angelina nadine kari estelle " +
+  "dianna paulette lora mona doreen rosemarie desiree antonia janis betsy " +
+  "christie freda meredith lynette teri cristina eula leigh meghan sophia " +
+  "eloise rochelle gretchen cecelia raquel henrietta alyssa jana gwen jenna " +
+  "tricia laverne olive tasha silvia elvira delia kate patti lorena kellie " +
+  "sonja lila lana darla mindy essie mandy lorene elsa josefina jeannie " +
+  "miranda dixie lucia marta faith lela johanna shari camille tami shawna " +
+  "elisa ebony melba ora nettie tabitha ollie winifred kristie marina " +
+  "alisha aimee rena myrna marla tammie latasha bonita patrice ronda " +
+  "sherrie addie francine deloris stacie adriana cheri abigail celeste " +
+  "jewel cara adele rebekah lucinda dorthy effie trina reba sallie aurora " +
+  "lenora etta lottie kerri trisha nikki estella francisca josie tracie " +
+  "marissa karin brittney janelle lourdes laurel helene fern elva corinne " +
+  "kelsey ina bettie elisabeth aida caitlin ingrid iva eugenia christa " +
+  "goldie maude jenifer therese dena lorna janette latonya candy consuelo " +
+  "tamika rosetta debora cherie polly dina jewell fay jillian dorothea nell " +
+  "trudy esperanza patrica kimberley shanna helena cleo stefanie rosario " +
+  "ola janine mollie lupe alisa lou maribel susanne bette susana elise " +
+  "cecile isabelle lesley jocelyn paige joni rachelle leola daphne alta " +
+  "ester petra graciela imogene jolene keisha lacey glenna gabriela keri " +
+  "ursula lizzie kirsten shana adeline mayra jayne jaclyn gracie sondra " +
+  "carmela marisa rosalind charity tonia beatriz marisol clarice jeanine " +
+  "sheena angeline frieda lily shauna millie claudette cathleen angelia " +
+  "gabrielle autumn katharine jodie staci lea christi justine elma luella " +
+  "margret dominique socorro martina margo mavis callie bobbi maritza " +
+  "lucile leanne jeannine deana aileen lorie ladonna willa manuela gale " +
+  "selma dolly sybil abby ivy dee winnie marcy luisa jeri magdalena ofelia " +
+  "meagan audra matilda leila cornelia bianca simone bettye randi virgie " +
+  "latisha barbra georgina eliza leann bridgette rhoda haley adela nola " +
+  "bernadine flossie ila greta ruthie nelda minerva lilly terrie letha " +
+  "hilary estela valarie brianna rosalyn earline catalina ava mia clarissa " +
+  "lidia corrine alexandria concepcion tia sharron rae dona ericka jami " +
+  "elnora chandra lenore neva marylou melisa tabatha serena avis allie " +
+  "sofia jeanie odessa nannie harriett loraine penelope milagros emilia " +
+  "benita allyson ashlee tania esmeralda karina eve pearlie zelma malinda " +
+  "noreen tameka saundra hillary amie althea rosalinda lilia alana clare " +
+  "alejandra elinor lorrie jerri darcy earnestine carmella noemi marcie " +
+  "liza annabelle louisa earlene mallory carlene nita selena tanisha katy " +
+  "julianne lakisha edwina maricela margery kenya dollie roxie roslyn " +
+  "kathrine nanette charmaine lavonne ilene tammi suzette corine kaye " +
+  "chrystal lina deanne lilian juliana aline luann kasey maryanne " +
+  "evangeline colette melva lawanda yesenia nadia madge kathie ophelia " +
+  "valeria nona mitzi mari georgette claudine fran alissa roseann lakeisha " +
+  "susanna reva deidre chasity sheree elvia alyce deirdre gena briana " +
+  "araceli katelyn rosanne wendi tessa berta marva imelda marietta marci " +
+  "leonor arline sasha madelyn janna juliette deena aurelia josefa augusta " +
+  "liliana lessie amalia savannah anastasia vilma natalia rosella lynnette " +
+  "corina alfreda leanna amparo coleen tamra aisha wilda karyn queen maura " +
+  "mai evangelina rosanna hallie erna enid mariana lacy juliet jacklyn " +
+  "freida madeleine mara cathryn lelia casandra bridgett angelita jannie " +
+  "dionne annmarie katina beryl millicent katheryn diann carissa maryellen " +
+  "liz lauri helga gilda rhea marquita hollie tisha tamera angelique " +
+  "francesca kaitlin lolita florine rowena reyna twila fanny janell ines " +
+  "concetta bertie alba brigitte alyson vonda pansy elba noelle letitia " +
+  "deann brandie louella leta felecia sharlene lesa beverley isabella " +
+  "herminia terra celina tori octavia jade denice germaine michell cortney " +
+  "nelly doretha deidra monika lashonda judi chelsey antionette margot " +
+  "adelaide nan leeann elisha dessie libby kathi gayla latanya mina mellisa " +
+  "kimberlee jasmin renae zelda elda justina gussie emilie camilla abbie " +
+  "rocio kaitlyn edythe ashleigh selina lakesha geri allene pamala michaela " +
+  "dayna caryn rosalia sun jacquline rebeca marybeth krystle iola dottie " +
+  "belle griselda ernestina elida adrianne demetria delma jaqueline arleen " +
+  "virgina retha fatima tillie eleanore cari treva wilhelmina rosalee " +
+  "maurine latrice jena taryn elia debby maudie jeanna delilah catrina " +
+  "shonda hortencia theodora teresita robbin danette delphine brianne nilda " +
+  "danna cindi bess iona winona vida rosita marianna racheal guillermina " +
+  "eloisa celestine caren malissa lona chantel shellie marisela leora " +
+  "agatha soledad migdalia ivette christen janel veda pattie tessie tera " +
+  "marilynn lucretia karrie dinah daniela alecia adelina vernice shiela " +
+  "portia merry lashawn dara tawana oma verda alene zella sandi rafaela " +
+  "maya kira candida alvina suzan shayla lyn lettie samatha oralia matilde " +
+  "larissa vesta renita india delois shanda phillis lorri erlinda cathrine " +
+  "barb zoe isabell ione gisela roxanna mayme kisha ellie mellissa dorris " +
+  "dalia bella annetta zoila reta reina lauretta kylie christal pilar " +
+  "charla elissa tiffani tana paulina leota breanna jayme carmel vernell " +
+  "tomasa mandi dominga santa melodie lura alexa tamela mirna kerrie venus " +
+  "felicita cristy carmelita berniece annemarie tiara roseanne missy cori " +
+  "roxana pricilla kristal jung elyse haydee aletha bettina marge gillian " +
+  "filomena zenaida harriette caridad vada una aretha pearline marjory " +
+  "marcela flor evette elouise alina damaris catharine belva nakia marlena " +
+  "luanne lorine karon dorene danita brenna tatiana louann julianna andria " +
+  "philomena lucila leonora dovie romona mimi jacquelin gaye tonja misti " +
+  "chastity stacia roxann micaela nikita mei velda marlys johnna aura " +
+  "ivonne hayley nicki majorie herlinda yadira perla gregoria antonette " +
+  "shelli mozelle mariah joelle cordelia josette chiquita trista laquita " +
+  "georgiana candi shanon hildegard valentina stephany magda karol " +
+  "gabriella tiana roma richelle oleta jacque idella alaina suzanna jovita " +
+  "tosha nereida marlyn kyla delfina tena stephenie sabina nathalie " +
+  "marcelle gertie darleen thea sharonda shantel belen venessa rosalina ona " +
+  "genoveva clementine rosalba renate renata georgianna floy dorcas ariana " +
+  "tyra theda mariam juli jesica vikki verla roselyn melvina jannette ginny " +
+  "debrah corrie asia violeta myrtis latricia collette charleen anissa " +
+  "viviana twyla nedra latonia lan hellen fabiola annamarie adell sharyn " +
+  "chantal niki maud lizette lindy kia kesha jeana danelle charline chanel " +
+  "valorie lia dortha cristal leone leilani gerri debi andra keshia ima " +
+  "eulalia easter dulce natividad linnie kami georgie catina brook alda " +
+  "winnifred sharla ruthann meaghan magdalene lissette adelaida venita " +
+  "trena shirlene shameka elizebeth dian shanta latosha carlotta windy " +
+  "rosina mariann leisa jonnie dawna cathie astrid laureen janeen holli " +
+  "fawn vickey teressa shante rubye marcelina chanda terese scarlett marnie " +
+  "lulu lisette jeniffer elenor dorinda donita carman bernita altagracia " +
+  "aleta adrianna zoraida nicola lyndsey janina ami starla phylis phuong " +
+  "kyra charisse blanch sanjuanita rona nanci marilee maranda brigette " +
+  "sanjuana marita kassandra joycelyn felipa chelsie bonny mireya lorenza " +
+  "kyong ileana candelaria sherie lucie leatrice lakeshia gerda edie bambi " +
+  "marylin lavon hortense garnet evie tressa shayna lavina kyung jeanetta " +
+  "sherrill shara phyliss mittie anabel alesia thuy tawanda joanie tiffanie " +
+  "lashanda karissa enriqueta daria daniella corinna alanna abbey roxane " +
+  "roseanna magnolia lida joellen era coral carleen tresa peggie novella " +
+  "nila maybelle jenelle carina nova melina marquerite margarette josephina " +
+  "evonne cinthia albina toya tawnya sherita myriam lizabeth lise keely " +
+  "jenni giselle cheryle ardith ardis alesha adriane shaina linnea karolyn " +
+  "felisha dori darci artie armida zola xiomara vergie shamika nena " +
+  "nannette maxie lovie jeane jaimie inge farrah elaina caitlyn felicitas " +
+  "cherly caryl yolonda yasmin teena prudence pennie nydia mackenzie orpha " +
+  "marvel lizbeth laurette jerrie hermelinda carolee tierra mirian meta " +
+  "melony kori jennette jamila ena anh yoshiko susannah salina rhiannon " +
+  "joleen cristine ashton aracely tomeka shalonda marti lacie kala jada " +
+  "ilse hailey brittani zona syble sherryl nidia marlo kandice kandi deb " +
+  "alycia ronna norene mercy ingeborg giovanna gemma christel audry zora " +
+  "vita trish stephaine shirlee shanika melonie mazie jazmin inga hoa " +
+  "hettie geralyn fonda estrella adella sarita rina milissa maribeth golda " +
+  "evon ethelyn enedina cherise chana velva tawanna sade mirta karie " +
+  "jacinta elna davina cierra ashlie albertha tanesha nelle mindi lorinda " +
+  "larue florene demetra dedra ciara chantelle ashly suzy rosalva noelia " +
+  "lyda leatha krystyna kristan karri darline darcie cinda cherrie awilda " +
+  "almeda rolanda lanette jerilyn gisele evalyn cyndi cleta carin zina zena " +
+  "velia tanika charissa talia margarete lavonda kaylee kathlene jonna " +
+  "irena ilona idalia candis candance brandee anitra alida sigrid nicolette " +
+  "maryjo linette hedwig christiana alexia tressie modesta lupita lita " +
+  "gladis evelia davida cherri cecily ashely annabel agustina wanita shirly " +
+  "rosaura hulda eun yetta verona thomasina sibyl shannan mechelle lue " +
+  "leandra lani kylee kandy jolynn ferne eboni corene alysia zula nada " +
+  "moira lyndsay lorretta jammie hortensia gaynell adria vina vicenta " +
+  "tangela stephine norine nella liana leslee kimberely iliana glory felica " +
+  "emogene elfriede eden eartha carma bea ocie lennie kiara jacalyn carlota " +
+  "arielle otilia kirstin kacey johnetta joetta jeraldine jaunita elana " +
+  "dorthea cami amada adelia vernita tamar siobhan renea rashida ouida " +
+  "nilsa meryl kristyn julieta danica breanne aurea anglea sherron odette " +
+  "malia lorelei leesa kenna kathlyn fiona charlette suzie shantell sabra " +
+  "racquel myong mira martine lucienne lavada juliann elvera delphia " +
+  "christiane charolette carri asha angella paola ninfa leda lai eda " +
+  "stefani shanell palma machelle lissa kecia kathryne karlene julissa " +
+  "jettie jenniffer hui corrina carolann alena rosaria myrtice marylee " +
+  "liane kenyatta judie janey elmira eldora denna cristi cathi zaida vonnie " +
+  "viva vernie rosaline mariela luciana lesli karan felice deneen adina " +
+  "wynona tarsha sheron shanita shani shandra randa pinkie nelida marilou " +
+  "lyla laurene laci joi janene dorotha daniele dani carolynn carlyn " +
+  "berenice ayesha anneliese alethea thersa tamiko rufina oliva mozell " +
+  "marylyn kristian kathyrn kasandra kandace janae domenica debbra " +
+  "dannielle arcelia aja zenobia sharen sharee lavinia kum kacie jackeline " +
+  "huong felisa emelia eleanora cythia cristin claribel anastacia zulma " +
+  "zandra yoko tenisha susann sherilyn shay shawanda romana mathilda linsey " +
+  "keiko joana isela gretta georgetta eugenie desirae delora corazon " +
+  "antonina anika willene tracee tamatha nichelle mickie maegan luana " +
+  "lanita kelsie edelmira bree afton teodora tamie shena meg linh keli kaci " +
+  "danyelle arlette albertine adelle tiffiny simona nicolasa nichol nia " +
+  "nakisha mee maira loreen kizzy fallon christene bobbye vincenza tanja " +
+  "rubie roni queenie margarett kimberli irmgard idell hilma evelina esta " +
+  "emilee dennise dania carie wai risa rikki particia mui masako luvenia " +
+  "loree loni lien gigi florencia denita billye tomika sharita rana nikole " +
+  "neoma margarite madalyn lucina laila kali jenette gabriele evelyne " +
+  "elenora clementina alejandrina zulema violette vannessa thresa retta pia " +
+  "patience noella nickie jonell chaya camelia bethel anya suzann shu mila " +
+  "lilla laverna keesha kattie georgene eveline estell elizbeth vivienne " +
+  "vallie trudie stephane magaly madie kenyetta karren janetta hermine " +
+  "drucilla debbi celestina candie britni beckie amina zita yun yolande " +
+  "vivien vernetta trudi sommer pearle patrina ossie nicolle loyce letty " +
+  "larisa katharina joselyn jonelle jenell iesha heide florinda florentina " +
+  "flo elodia dorine brunilda brigid ashli ardella twana thu tarah shavon " +
+  "serina rayna ramonita nga margurite lucrecia kourtney kati jesenia " +
+  "crista ayana alica alia vinnie suellen romelia rachell olympia michiko " +
+  "kathaleen jolie jessi janessa hana elease carletta britany shona salome " +
+  "rosamond regena raina ngoc nelia louvenia lesia latrina laticia larhonda " +
+  "jina jacki emmy deeann coretta arnetta thalia shanice neta mikki micki " +
+  "lonna leana lashunda kiley joye jacqulyn ignacia hyun hiroko henriette " +
+  "elayne delinda dahlia coreen consuela conchita celine babette ayanna " +
+  "anette albertina shawnee shaneka quiana pamelia min merri merlene margit " +
+  "kiesha kiera kaylene jodee jenise erlene emmie dalila daisey casie belia " +
+  "babara versie vanesa shelba shawnda nikia naoma marna margeret madaline " +
+  "lawana kindra jutta jazmine janett hannelore glendora gertrud garnett " +
+  "freeda frederica florance flavia carline beverlee anjanette valda tamala " +
+  "shonna sha sarina oneida merilyn marleen lurline lenna katherin jin jeni " +
+  "hae gracia glady farah enola ema dominque devona delana cecila caprice " +
+  "alysha alethia vena theresia tawny shakira samara sachiko rachele " +
+  "pamella marni mariel maren malisa ligia lera latoria larae kimber " +
+  "kathern karey jennefer janeth halina fredia delisa debroah ciera " +
+  "angelika andree altha yen vivan terresa tanna suk sudie soo signe salena " +
+  "ronni rebbecca myrtie malika maida loan leonarda kayleigh ethyl ellyn " +
+  "dayle cammie brittni birgit avelina asuncion arianna akiko venice tyesha " +
+  "tonie tiesha takisha steffanie sindy meghann manda macie kellye kellee " +
+  "joslyn inger indira glinda glennis fernanda faustina eneida elicia dot " +
+  "digna dell arletta willia tammara tabetha sherrell sari rebbeca pauletta " +
+  "natosha nakita mammie kenisha kazuko kassie earlean daphine corliss " +
+  "clotilde carolyne bernetta augustina audrea annis annabell yan tennille " +
+  "tamica selene rosana regenia qiana markita macy leeanne laurine kym " +
+  "jessenia janita georgine genie emiko elvie deandra dagmar corie collen " +
+  "cherish romaine porsha pearlene micheline merna margorie margaretta lore " +
+  "jenine hermina fredericka elke drusilla dorathy dione celena brigida " +
+  "angeles allegra tamekia synthia sook slyvia rosann reatha raye marquetta " +
+  "margart layla kymberly kiana kayleen katlyn karmen joella irina emelda " +
+  "eleni detra clemmie cheryll chantell cathey arnita arla angle angelic " +
+  "alyse zofia thomasine tennie sherly sherley sharyl remedios petrina " +
+  "nickole myung myrle mozella louanne lisha latia krysta julienne jeanene " +
+  "jacqualine isaura gwenda earleen cleopatra carlie audie antonietta alise " +
+  "verdell tomoko thao talisha shemika savanna santina rosia raeann odilia " +
+  "nana minna magan lynelle karma joeann ivana inell ilana hye hee gudrun " +
+  "dreama crissy chante carmelina arvilla annamae alvera aleida yanira " +
+  "vanda tianna tam stefania shira nicol nancie monserrate melynda melany " +
+  "lovella laure kacy jacquelynn hyon gertha eliana christena christeen " +
+  "charise caterina carley candyce arlena ammie willette vanita tuyet " +
+  "syreeta penney nyla maryam marya magen ludie loma livia lanell kimberlie " +
+  "julee donetta diedra denisha deane dawne clarine cherryl bronwyn alla " +
+  "valery tonda sueann soraya shoshana shela sharleen shanelle nerissa " +
+  "meridith mellie maye maple magaret lili leonila leonie leeanna lavonia " +
+  "lavera kristel kathey kathe jann ilda hildred hildegarde genia fumiko " +
+  "evelin ermelinda elly dung doloris dionna danae berneice annice alix " +
+  "verena verdie shawnna shawana shaunna rozella randee ranae milagro " +
+  "lynell luise loida lisbeth karleen junita jona isis hyacinth hedy gwenn " +
+  "ethelene erline donya domonique delicia dannette cicely branda blythe " +
+  "bethann ashlyn annalee alline yuko vella trang towanda tesha sherlyn " +
+  "narcisa miguelina meri maybell marlana marguerita madlyn lory loriann " +
+  "leonore leighann laurice latesha laronda katrice kasie kaley jadwiga " +
+  "glennie gearldine francina epifania dyan dorie diedre denese demetrice " +
+  "delena cristie cleora catarina carisa barbera almeta trula tereasa " +
+  "solange sheilah shavonne sanora rochell mathilde margareta maia lynsey " +
+  "lawanna launa kena keena katia glynda gaylene elvina elanor danuta " +
+  "danika cristen cordie coletta clarita carmon brynn azucena aundrea " +
+  "angele verlie verlene tamesha silvana sebrina samira reda raylene penni " +
+  "norah noma mireille melissia maryalice laraine kimbery karyl karine kam " +
+  "jolanda johana jesusa jaleesa jacquelyne iluminada hilaria hanh gennie " +
+  "francie floretta exie edda drema delpha bev barbar assunta ardell " +
+  "annalisa alisia yukiko yolando wonda wei waltraud veta temeka tameika " +
+  "shirleen shenita piedad ozella mirtha marilu kimiko juliane jenice janay " +
+  "jacquiline hilde fae elois echo devorah chau brinda betsey arminda " +
+  "aracelis apryl annett alishia veola usha toshiko theola tashia talitha " +
+  "shery renetta reiko rasheeda obdulia mika melaine meggan marlen marget " +
+  "marceline mana magdalen librada lezlie latashia lasandra kelle isidra " +
+  "isa inocencia gwyn francoise erminia erinn dimple devora criselda " +
+  "armanda arie ariane angelena aliza adriene adaline xochitl twanna tomiko " +
+  "tamisha taisha susy siu rutha rhona noriko natashia merrie marinda " +
+  "mariko margert loris lizzette leisha kaila joannie jerrica jene jannet " +
+  "janee jacinda herta elenore doretta delaine daniell claudie britta " +
+  "apolonia amberly alease yuri yuk wen waneta ute tomi sharri sandie " +
+  "roselle reynalda raguel phylicia patria olimpia odelia mitzie minda " +
+  "mignon mica mendy marivel maile lynetta lavette lauryn latrisha lakiesha " +
+  "kiersten kary josphine jolyn jetta janise jacquie ivelisse glynis gianna " +
+  "gaynelle danyell danille dacia coralee cher ceola arianne aleshia yung " +
+  "williemae trinh thora tai svetlana sherika shemeka shaunda roseline " +
+  "ricki melda mallie lavonna latina laquanda lala lachelle klara kandis " +
+  "johna jeanmarie jaye grayce gertude emerita ebonie clorinda ching chery " +
+  "carola breann blossom bernardine becki arletha argelia ara alita yulanda " +
+  "yon yessenia tobi tasia sylvie shirl shirely shella shantelle sacha " +
+  "rebecka providencia paulene misha miki marline marica lorita latoyia " +
+  "lasonya kerstin kenda keitha kathrin jaymie gricelda ginette eryn elina " +
+  "elfrieda danyel cheree chanelle barrie aurore annamaria alleen ailene " +
+  "aide yasmine vashti treasa tiffaney sheryll sharie shanae sau raisa neda " +
+  "mitsuko mirella milda maryanna maragret mabelle luetta lorina letisha " +
+  "latarsha lanelle lajuana krissy karly karena jessika jerica jeanelle " +
+  "jalisa jacelyn izola euna etha domitila dominica daina creola carli " +
+  "camie brittny ashanti anisha aleen adah yasuko valrie tona tinisha thi " +
+  "terisa taneka simonne shalanda serita ressie refugia olene margherita " +
+  "mandie maire lyndia luci lorriane loreta leonia lavona lashawnda lakia " +
+  "kyoko krystina krysten kenia kelsi jeanice isobel georgiann genny " +
+  "felicidad eilene deloise conception clora cherilyn calandra armandina " +
+  "anisa ula tiera theressa stephania sima shyla shonta shera shaquita " +
+  "shala rossana nohemi nery moriah melita melida melani marylynn marisha " +
+  "mariette malorie madelene ludivina loria lorette loralee lianne lavenia " +
+  "laurinda lashon kit kimi keila katelynn kai jone joane jayna janella hue " +
+  "hertha francene elinore despina delsie deedra clemencia carolin bulah " +
+  "brittanie bok blondell bibi beaulah beata annita agripina virgen valene " +
+  "twanda tommye toi tarra tari tammera shakia sadye ruthanne rochel rivka " +
+  "pura nenita natisha merrilee melodee marvis lucilla leena laveta larita " +
+  "lanie keren ileen georgeann genna frida ewa eufemia emely ela edyth " +
+  "deonna deadra darlena chanell cathern cassondra cassaundra bernarda " +
+  "berna arlinda anamaria vertie valeri torri tatyana stasia sherise " +
+  "sherill sanda ruthe rosy robbi ranee quyen pearly palmira onita nisha " +
+  "niesha nida nam merlyn mayola marylouise marth margene madelaine londa " +
+  "leontine leoma leia lauralee lanora lakita kiyoko keturah katelin kareen " +
+  "jonie johnette jenee jeanett izetta hiedi heike hassie giuseppina " +
+  "georgann fidela fernande elwanda ellamae eliz dusti dotty cyndy coralie " +
+  "celesta argentina alverta xenia wava vanetta torrie tashina tandy tambra " +
+  "tama stepanie shila shaunta sharan shaniqua shae setsuko serafina sandee " +
+  "rosamaria priscila olinda nadene muoi michelina mercedez maryrose " +
+  "marcene mao magali mafalda lannie kayce karoline kamilah kamala justa " +
+  "joline jennine jacquetta iraida georgeanna franchesca emeline elane " +
+  "ehtel earlie dulcie dalene classie chere charis caroyln carmina carita " +
+  "bethanie ayako arica alysa alessandra akilah adrien zetta youlanda " +
+  "yelena yahaira wendolyn tijuana terina teresia suzi sherell shavonda " +
+  "shaunte sharda shakita sena ryann rubi riva reginia rachal parthenia " +
+  "pamula monnie monet michaele melia malka maisha lisandra lekisha lean " +
+  "lakendra krystin kortney kizzie kittie kera kendal kemberly kanisha " +
+  "julene jule johanne jamee halley gidget galina fredricka fleta fatimah " +
+  "eusebia elza eleonore dorthey doria donella dinorah delorse claretha " +
+  "christinia charlyn bong belkis azzie andera aiko adena yer yajaira wan " +
+  "vania ulrike toshia tifany stefany shizue shenika shawanna sharolyn " +
+  "sharilyn shaquana shantay rozanne roselee remona reanna raelene phung " +
+  "petronila natacha nancey myrl miyoko miesha merideth marvella marquitta " +
+  "marhta marchelle lizeth libbie lahoma ladawn kina katheleen katharyn " +
+  "karisa kaleigh junie julieann johnsie janean jaimee jackqueline hisako " +
+  "herma helaine gwyneth gita eustolia emelina elin edris donnette donnetta " +
+  "dierdre denae darcel clarisa cinderella chia charlesetta charita celsa " +
+  "cassy cassi carlee bruna brittaney brande billi bao antonetta angla " +
+  "angelyn analisa alane wenona wendie veronique vannesa tobie tempie " +
+  "sumiko sulema sparkle somer sheba sharice shanel shalon rosio roselia " +
+  "renay rema reena ozie oretha oralee oda ngan nakesha milly marybelle " +
+  "margrett maragaret manie lurlene lillia lieselotte lavelle lashaunda " +
+  "lakeesha kaycee kalyn joya joette jenae janiece illa grisel glayds " +
+  "genevie gala fredda eleonor debera deandrea corrinne cordia contessa " +
+  "colene cleotilde chantay cecille beatris azalee arlean ardath anjelica " +
+  "anja alfredia aleisha zada yuonne willodean vennie vanna tyisha tova " +
+  "torie tonisha tilda tien sirena sherril shanti senaida samella robbyn " +
+  "renda reita phebe paulita nobuko nguyet neomi mikaela melania maximina " +
+  "marg maisie lynna lilli lashaun lakenya lael kirstie kathline kasha " +
+  "karlyn karima jovan josefine jennell jacqui jackelyn hyo hien grazyna " +
+  "florrie floria eleonora dwana dorla delmy deja dede dann crysta clelia " +
+  "claris chieko cherlyn cherelle charmain chara cammy bee arnette ardelle " +
+  "annika amiee amee allena yvone yuki yoshie yevette yael willetta voncile " +
+  "venetta tula tonette timika temika telma teisha taren stacee shawnta " +
+  "saturnina ricarda pok pasty onie nubia marielle mariella marianela " +
+  "mardell luanna loise lisabeth lindsy lilliana lilliam lelah leigha " +
+  "leanora kristeen khalilah keeley kandra junko joaquina jerlene jani " +
+  "jamika hsiu hermila genevive evia eugena emmaline elfreda elene donette " +
+  "delcie deeanna darcey cuc clarinda cira chae celinda catheryn casimira " +
+  "carmelia camellia breana bobette bernardina bebe basilia arlyne amal " +
+  "alayna zonia zenia yuriko yaeko wynell willena vernia tora terrilyn " +
+  "terica tenesha tawna tajuana taina stephnie sona sina shondra shizuko " +
+  "sherlene sherice sharika rossie rosena rima ria rheba renna natalya " +
+  "nancee melodi meda matha marketta maricruz marcelene malvina luba " +
+  "louetta leida lecia lauran lashawna laine khadijah katerine kasi kallie " +
+  "julietta jesusita jestine jessia jeffie janyce isadora georgianne " +
+  "fidelia evita eura eulah estefana elsy eladia dodie dia denisse deloras " +
+  "delila daysi crystle concha claretta charlsie charlena carylon bettyann " +
+  "asley ashlea amira agueda agnus yuette vinita victorina tynisha treena " +
+  "toccara tish thomasena tegan soila shenna sharmaine shantae shandi " +
+  "september saran sarai sana rosette rolande regine otelia olevia nicholle " +
+  "necole naida myrta myesha mitsue minta mertie margy mahalia madalene " +
+  "loura lorean lesha leonida lenita lavone lashell lashandra lamonica " +
+  "kimbra katherina karry kanesha jong jeneva jaquelyn hwa gilma ghislaine " +
+  "gertrudis fransisca fermina ettie etsuko ellan elidia edra dorethea " +
+  "doreatha denyse deetta daine cyrstal corrin cayla carlita camila burma " +
+  "bula buena barabara avril alaine zana wilhemina wanetta veronika verline " +
+  "vasiliki tonita tisa teofila tayna taunya tandra takako sunni suanne " +
+  "sixta sharell seema rosenda robena raymonde pei pamila ozell neida " +
+  "mistie micha merissa maurita maryln maryetta marcell malena makeda " +
+  "lovetta lourie lorrine lorilee laurena lashay larraine laree lacresha " +
+  "kristle krishna keva keira karole joie jinny jeannetta jama heidy " +
+  "gilberte gema faviola evelynn enda elli ellena divina dagny collene codi " +
+  "cindie chassidy chasidy catrice catherina cassey caroll carlena candra " +
+  "calista bryanna britteny beula bari audrie audria ardelia annelle angila " +
+  "alona allyn"
+
+/* Common male first names. */
+const maleNameList = "james john robert michael william david richard " +
+  "charles joseph thomas christopher daniel paul mark donald george kenneth " +
+  "steven edward brian ronald anthony kevin jason matthew gary timothy jose " +
+  "larry jeffrey frank scott eric stephen andrew raymond gregory joshua " +
+  "jerry dennis walter patrick peter harold douglas henry carl arthur ryan " +
+  "roger joe juan jack albert jonathan justin terry gerald keith samuel " +
+  "willie ralph lawrence nicholas roy benjamin bruce brandon adam harry " +
+  "fred wayne billy steve louis jeremy aaron randy eugene carlos russell " +
+  "bobby victor ernest phillip todd jesse craig alan shawn clarence sean " +
+  "philip chris johnny earl jimmy antonio danny bryan tony luis mike " +
+  "stanley leonard nathan dale manuel rodney curtis norman marvin vincent " +
+  "glenn jeffery travis jeff chad jacob melvin alfred kyle francis bradley " +
+  "jesus herbert frederick ray joel edwin don eddie ricky troy randall " +
+  "barry bernard mario leroy francisco marcus micheal theodore clifford " +
+  "miguel oscar jay jim tom calvin alex jon ronnie bill lloyd tommy leon " +
+  "derek darrell jerome floyd leo alvin tim wesley dean greg jorge dustin " +
+  "pedro derrick dan zachary corey herman maurice vernon roberto clyde glen " +
+  "hector shane ricardo sam rick lester brent ramon tyler gilbert gene marc " +
+  "reginald ruben brett angel nathaniel rafael edgar milton raul ben cecil " +
+  "duane andre elmer brad gabriel ron roland jared adrian karl cory claude " +
+  "erik darryl neil christian javier fernando clinton ted mathew tyrone " +
+  "darren lonnie lance cody julio kurt allan clayton hugh max dwayne dwight " +
+  "armando felix jimmie everett ian ken bob jaime casey alfredo alberto " +
+  "dave ivan johnnie sidney byron julian isaac clifton willard daryl virgil " +
+  "andy salvador kirk sergio seth kent terrance rene eduardo terrence " +
+  "enrique freddie stuart fredrick arturo alejandro joey nick luther " +
+  "wendell jeremiah evan julius donnie otis trevor luke homer gerard doug " +
+  "kenny hubert angelo shaun lyle matt alfonso orlando rex carlton ernesto " +
+  "pablo lorenzo omar wilbur blake horace roderick kerry abraham rickey ira " +
+  "andres cesar johnathan malcolm rudolph damon kelvin rudy preston alton " +
+  "archie marco wm pete randolph garry geoffrey jonathon felipe bennie " +
+  "gerardo ed dominic loren delbert colin guillermo earnest benny noel " +
+  "rodolfo myron edmund salvatore cedric lowell gregg sherman devin " +
+  "sylvester roosevelt israel jermaine forrest wilbert leland simon irving " +
+  "owen rufus woodrow kristopher levi marcos gustavo lionel marty gilberto " +
+  "clint nicolas laurence ismael orville drew ervin dewey al wilfred josh " +
+  "hugo ignacio caleb tomas sheldon erick frankie darrel rogelio terence " +
+  "alonzo elias bert elbert ramiro conrad noah grady phil cornelius lamar " +
+  "rolando clay percy dexter bradford merle darin amos terrell moses irvin " +
+  "saul roman darnell randal tommie timmy darrin brendan toby van abel " +
+  "dominick emilio elijah cary domingo aubrey emmett marlon emanuel jerald " +
+  "edmond emil dewayne otto teddy reynaldo bret jess trent humberto " +
+  "emmanuel stephan louie vicente lamont garland micah efrain heath rodger " +
+  "demetrius ethan eldon rocky pierre eli bryce antoine robbie kendall " +
+  "royce sterling grover elton cleveland dylan chuck damian reuben stan " +
+  "leonardo russel erwin benito hans monte blaine ernie curt quentin " +
+  "agustin jamal devon adolfo tyson wilfredo bart jarrod vance denis damien " +
+  "joaquin harlan desmond elliot darwin gregorio kermit roscoe esteban " +
+  "anton solomon norbert elvin nolan carey rod quinton hal brain rob elwood " +
+  "kendrick darius moises marlin fidel thaddeus cliff marcel ali raphael " +
+  "bryon armand alvaro jeffry dane joesph thurman ned sammie rusty michel " +
+  "monty rory fabian reggie kris isaiah gus avery loyd diego adolph millard " +
+  "rocco gonzalo derick rodrigo gerry rigoberto alphonso ty rickie noe vern " +
+  "elvis bernardo mauricio hiram donovan basil nickolas scot vince quincy " +
+  "eddy sebastian federico ulysses heriberto donnell denny gavin emery " +
+  "romeo jayson dion dante clement coy odell jarvis bruno issac dudley " +
+  "sanford colby carmelo nestor hollis stefan donny art linwood beau weldon " +
+  "galen isidro truman delmar johnathon silas frederic irwin merrill " +
+  "charley marcelino carlo trenton kurtis aurelio winfred vito collin " +
+  "denver leonel emory pasquale mohammad mariano danial landon dirk branden " +
+  "adan numbers clair buford german bernie wilmer emerson zachery jacques " +
+  "errol josue edwardo wilford theron raymundo daren tristan robby lincoln " +
+  "jame genaro octavio cornell hung arron antony herschel alva giovanni " +
+  "garth cyrus cyril ronny stevie lon kennith carmine augustine erich " +
+  "chadwick wilburn russ myles jonas mitchel mervin zane jamel lazaro " +
+  "alphonse randell major johnie jarrett ariel abdul dusty luciano seymour " +
+  "scottie eugenio mohammed valentin arnulfo lucien ferdinand thad ezra " +
+  "aldo rubin royal mitch earle abe marquis lanny kareem jamar boris isiah " +
+  "emile elmo aron leopoldo everette josef eloy dorian rodrick reinaldo " +
+  "lucio jerrod weston hershel lemuel lavern burt jules gil eliseo ahmad " +
+  "nigel efren antwan alden margarito refugio dino osvaldo les deandre " +
+  "normand kieth ivory trey norberto napoleon jerold fritz rosendo milford " +
+  "sang deon christoper alfonzo lyman josiah brant wilton rico jamaal " +
+  "dewitt brenton yong olin faustino claudio judson gino edgardo alec " +
+  "jarred donn trinidad tad porfirio odis lenard chauncey tod mel marcelo " +
+  "kory augustus keven hilario bud sal orval mauro dannie zachariah olen " +
+  "anibal milo jed thanh amado lenny tory richie horacio brice mohamed " +
+  "delmer dario mac jonah jerrold robt hank sung rupert rolland kenton " +
+  "damion chi antone waldo fredric bradly kip burl tyree jefferey ahmed " +
+  "willy stanford oren moshe mikel enoch brendon quintin jamison florencio " +
+  "darrick tobias minh hassan giuseppe demarcus cletus tyrell lyndon keenan " +
+  "werner theo geraldo columbus chet bertram markus huey hilton dwain donte " +
+  "tyron omer isaias hipolito fermin chung adalberto jamey teodoro mckinley " +
+  "maximo sol raleigh lawerence abram rashad emmitt daron chong samual otha " +
+  "miquel eusebio dong domenic darron wilber renato hoyt haywood ezekiel " +
+  "chas florentino elroy clemente arden neville edison deshawn carrol " +
+  "shayne nathanial jordon danilo claud val sherwood raymon rayford " +
+  "cristobal ambrose titus hyman felton ezequiel erasmo lonny len ike milan " +
+  "lino jarod herb andreas rhett jude douglass cordell oswaldo ellsworth " +
+  "virgilio toney nathanael del benedict mose hong isreal garret fausto asa " +
+  "arlen zack modesto francesco manual jae gaylord gaston filiberto " +
+  "deangelo michale granville wes malik zackary tuan nicky cristopher " +
+  "antione malcom korey jospeh colton waylon von hosea shad santo rudolf " +
+  "rolf rey renaldo marcellus lucius kristofer harland arnoldo rueben " +
+  "leandro kraig jerrell jeromy hobert cedrick arlie winford wally luigi " +
+  "keneth jacinto graig franklyn edmundo sid leif jeramy willian vincenzo " +
+  "shon michal lynwood jere hai elden darell broderick alonso"
+
+/* Common surnames. */
+const surnameList = "smith johnson williams jones brown davis miller wilson " +
+  "moore taylor anderson jackson white harris martin thompson garcia " +
+  "martinez robinson clark rodriguez lewis lee walker hall allen young " +
+  "hernandez king wright lopez hill green adams baker gonzalez nelson " +
+  "carter mitchell perez roberts turner phillips campbell parker evans " +
+  "edwards collins stewart sanchez morris rogers reed cook morgan bell " +
+  "murphy bailey rivera cooper richardson cox howard ward torres peterson " +
+  "gray ramirez watson brooks sanders price bennett wood barnes ross " +
+  "henderson coleman jenkins perry powell long patterson hughes flores " +
+  "washington butler simmons foster gonzales bryant alexander griffin diaz " +
+  "hayes myers ford hamilton graham sullivan wallace woods cole west owens " +
+  "reynolds fisher ellis harrison gibson mcdonald cruz marshall ortiz gomez " +
+  "murray freeman wells webb simpson stevens tucker porter hicks crawford " +
+  "boyd mason morales kennedy warren dixon ramos reyes burns gordon shaw " +
+  "holmes rice robertson hunt daniels palmer mills nichols grant ferguson " +
+  "stone hawkins dunn perkins hudson spencer gardner stephens payne pierce " +
+  "berry matthews arnold wagner willis watkins olson carroll duncan snyder " +
+  "hart cunningham lane andrews ruiz harper fox riley armstrong carpenter " +
+  "weaver greene elliott chavez sims peters kelley franklin lawson fields " +
+  "gutierrez schmidt carr vasquez castillo wheeler chapman oliver " +
+  "montgomery richards williamson johnston banks meyer bishop mccoy howell " +
+  "alvarez morrison hansen fernandez garza burton nguyen jacobs reid fuller " +
+  "lynch garrett romero welch larson frazier burke hanson mendoza moreno " +
+  "bowman medina fowler brewer hoffman carlson silva pearson holland " +
+  "fleming jensen vargas byrd davidson hopkins may herrera wade soto " +
+  "walters neal caldwell lowe jennings barnett graves jimenez horton " +
+  "shelton barrett obrien castro sutton mckinney lucas miles rodriquez " +
+  "chambers holt lambert fletcher watts bates hale rhodes pena beck newman " +
+  "haynes mcdaniel mendez bush vaughn parks dawson santiago norris hardy " +
+  "steele curry powers schultz barker guzman page munoz ball keller " +
+  "chandler weber walsh lyons ramsey wolfe schneider mullins benson sharp " +
+  "bowen barber cummings hines baldwin griffith valdez hubbard salazar " +
+  "reeves warner stevenson burgess santos tate cross garner mann mack moss " +
+  "thornton mcgee farmer delgado aguilar vega glover manning cohen harmon " +
+  "rodgers robbins newton blair higgins ingram reese cannon strickland " +
+  "townsend potter goodwin walton rowe hampton ortega patton swanson " +
+  "goodman maldonado yates becker erickson hodges rios conner adkins " +
+  "webster malone hammond flowers cobb moody quinn pope osborne mccarthy " +
+  "guerrero estrada sandoval gibbs gross fitzgerald stokes doyle saunders " +
+  "wise colon gill alvarado greer padilla waters nunez ballard schwartz " +
+  "mcbride houston christensen klein pratt briggs parsons mclaughlin " +
+  "zimmerman french buchanan moran copeland pittman brady mccormick " +
+  "holloway brock poole logan bass marsh drake wong jefferson park morton " +
+  "abbott sparks norton huff massey figueroa carson bowers roberson barton " +
+  "tran lamb harrington boone cortez clarke mathis singleton wilkins cain " +
+  "underwood hogan mckenzie collier luna phelps mcguire bridges wilkerson " +
+  "nash summers atkins wilcox pitts conley marquez burnett cochran chase " +
+  "davenport hood gates ayala sawyer vazquez dickerson hodge acosta flynn " +
+  "espinoza nicholson monroe morrow whitaker oconnor skinner ware molina " +
+  "kirby huffman gilmore dominguez oneal lang combs kramer hancock " +
+  "gallagher gaines shaffer short wiggins mathews mcclain fischer wall " +
+  "small melton hensley bond dyer grimes contreras wyatt baxter snow mosley " +
+  "shepherd larsen hoover beasley petersen whitehead meyers garrison " +
+  "shields horn savage olsen schroeder hartman woodard mueller kemp deleon " +
+  "booth patel calhoun wiley eaton cline navarro harrell humphrey parrish " +
+  "duran hutchinson hess dorsey bullock robles beard dalton avila rich " +
+  "blackwell york johns blankenship trevino salinas campos pruitt callahan " +
+  "montoya hardin guerra mcdowell stafford gallegos henson wilkinson booker " +
+  "merritt atkinson orr decker hobbs tanner knox pacheco stephenson glass " +
+  "rojas serrano marks hickman english sweeney strong mcclure conway roth " +
+  "maynard farrell lowery hurst nixon weiss trujillo ellison sloan juarez " +
+  "winters mclean boyer villarreal mccall gentry carrillo ayers lara sexton " +
+  "pace hull leblanc browning velasquez leach chang sellers herring noble " +
+  "foley bartlett mercado landry durham walls barr mckee bauer rivers " +
+  "bradshaw pugh velez rush estes dodson morse sheppard weeks camacho bean " +
+  "barron livingston middleton spears branch blevins chen kerr mcconnell " +
+  "hatfield harding solis frost giles blackburn pennington woodward finley " +
+  "mcintosh koch mccullough blanchard rivas brennan mejia kane benton " +
+  "buckley valentine maddox russo mcknight buck moon mcmillan crosby berg " +
+  "dotson mays roach church chan richmond meadows faulkner oneill knapp " +
+  "kline ochoa jacobson gay hendricks horne shepard hebert cardenas " +
+  "mcintyre waller holman donaldson cantu morin gillespie fuentes tillman " +
+  "bentley peck key salas rollins gamble dickson battle santana cabrera " +
+  "cervantes howe hinton hurley spence zamora yang mcneil suarez petty " +
+  "gould mcfarland sampson carver bray macdonald stout hester melendez " +
+  "dillon farley hopper galloway potts joyner stein aguirre osborn mercer " +
+  "bender franco rowland sykes pickett sears mayo dunlap hayden wilder " +
+  "mckay coffey mccarty ewing cooley vaughan bonner cotton holder stark " +
+  "ferrell cantrell fulton lott calderon pollard hooper burch mullen fry " +
+  "riddle levy odonnell britt daugherty berger dillard alston frye riggs " +
+  "chaney odom duffy fitzpatrick valenzuela mayer alford mcpherson acevedo " +
+  "barrera cote reilly compton mooney mcgowan craft clemons wynn nielsen " +
+  "baird stanton snider rosales bright witt hays holden rutledge kinney " +
+  "clements castaneda slater hahn burks delaney pate lancaster sharpe " +
+  "whitfield talley macias burris ratliff mccray madden kaufman goff cash " +
+  "bolton mcfadden levine byers kirkland kidd workman carney mcleod holcomb " +
+  "england finch sosa haney franks sargent nieves downs rasmussen bird " +
+  "hewitt foreman valencia oneil delacruz vinson dejesus hyde forbes " +
+  "gilliam guthrie wooten huber barlow boyle mcmahon buckner rocha puckett " +
+  "langley knowles cooke velazquez whitley vang shea rouse hartley mayfield " +
+  "elder rankin hanna cowan lucero arroyo slaughter haas oconnell minor " +
+  "boucher archer boggs dougherty andersen newell crowe wang friedman bland " +
+  "swain holley pearce childs yarbrough galvan proctor meeks lozano mora " +
+  "rangel bacon villanueva schaefer rosado helms boyce goss stinson lake " +
+  "ibarra hutchins covington crowley hatcher mackey bunch womack polk dodd " +
+  "childress childers camp villa dye springer mahoney dailey belcher " +
+  "lockhart griggs costa brandt walden moser tatum mccann akers lutz pryor " +
+  "orozco mcallister lugo davies shoemaker rutherford newsome magee " +
+  "chamberlain blanton simms godfrey flanagan crum cordova escobar downing " +
+  "sinclair donahue krueger mcginnis gore farris webber corbett andrade " +
+  "starr lyon yoder hastings mcgrath spivey krause harden crabtree " +
+  "kirkpatrick arrington ritter mcghee bolden maloney gagnon dunbar ponce " +
+  "pike mayes beatty mobley kimball butts montes eldridge braun hamm " +
+  "gibbons moyer manley herron plummer elmore cramer rucker pierson " +
+  "fontenot field rubio goldstein elkins wills novak hickey worley gorman " +
+  "katz dickinson broussard woodruff crow britton nance lehman bingham " +
+  "zuniga whaley shafer coffman steward delarosa nix neely mata davila " +
+  "mccabe kessler hinkle welsh pagan goldberg goins crouch cuevas quinones " +
+  "mcdermott hendrickson samuels denton bergeron lam ivey locke haines " +
+  "snell hoskins byrne arias roe corbin beltran chappell downey dooley " +
+  "tuttle couch payton mcelroy crockett groves cartwright dickey mcgill " +
+  "dubois muniz tolbert dempsey cisneros sewell latham vigil tapia rainey " +
+  "norwood stroud meade tipton kuhn hilliard bonilla teague gunn greenwood " +
+  "correa reece poe pineda phipps frey kaiser ames gunter schmitt milligan " +
+  "espinosa bowden vickers lowry pritchard costello piper mcclellan lovell " +
+  "sheehan hatch dobson singh jeffries hollingsworth sorensen meza fink " +
+  "donnelly burrell tomlinson colbert billings ritchie helton sutherland " +
+  "peoples mcqueen thomason givens crocker vogel robison dunham coker " +
+  "swartz keys ladner richter hargrove edmonds brantley albright murdock " +
+  "boswell muller quintero padgett kenney daly connolly inman quintana lund " +
+  "barnard villegas simons land huggins tidwell sanderson bullard mcclendon " +
+  "duarte draper marrero dwyer abrams stover goode fraser crews bernal " +
+  "godwin conklin mcneal baca esparza crowder bower brewster mcneill " +
+  "rodrigues leal coates raines mccain mccord miner holbrook swift dukes " +
+  "carlisle aldridge ackerman starks ricks holliday ferris hairston " +
+  "sheffield lange fountain doss betts kaplan carmichael bloom ruffin penn " +
+  "kern bowles sizemore larkin dupree seals metcalf hutchison henley farr " +
+  "mccauley hankins gustafson curran ash waddell ramey cates pollock " +
+  "cummins messer heller lin funk cornett palacios galindo cano hathaway " +
+  "singer pham enriquez salgado pelletier painter wiseman blount feliciano " +
+  "temple houser doherty mead mcgraw swan capps blanco blackmon thomson " +
+  "mcmanus burkett post gleason ott dickens cormier voss rushing rosenberg " +
+  "hurd dumas benitez arellano marin caudill bragg jaramillo huerta gipson " +
+  "colvin biggs vela platt cassidy tompkins mccollum dolan daley crump " +
+  "sneed kilgore grove grimm davison brunson prater marcum devine stratton " +
+  "rosas choi tripp ledbetter hightower feldman epps yeager posey scruggs " +
+  "cope stubbs richey overton trotter sprague cordero butcher stiles burgos " +
+  "woodson horner bassett purcell haskins akins ziegler spaulding hadley " +
+  "grubbs sumner murillo zavala shook lockwood driscoll dahl thorpe redmond " +
+  "putnam mcwilliams mcrae romano joiner sadler hedrick hager hagen fitch " +
+  "coulter thacker mansfield langston guidry ferreira corley conn rossi " +
+  "lackey baez saenz mcnamara mcmullen mckenna mcdonough link engel browne " +
+  "roper peacock eubanks drummond stringer pritchett parham mims landers " +
+  "ham grayson schafer egan timmons ohara keen hamlin finn cortes mcnair " +
+  "nadeau moseley michaud rosen oakes kurtz jeffers calloway beal bautista " +
+  "winn suggs stern stapleton lyles laird montano dawkins hagan goldman " +
+  "bryson barajas lovett segura metz lockett langford hinson eastman hooks " +
+  "smallwood shapiro crowell whalen triplett chatman aldrich cahill " +
+  "youngblood ybarra stallings sheets reeder connelly bateman abernathy " +
+  "winkler wilkes masters hackett granger gillis schmitz sapp napier souza " +
+  "lanier gomes weir otero ledford burroughs babcock ventura siegel dugan " +
+  "bledsoe atwood wray varner spangler anaya staley kraft fournier belanger " +
+  "wolff thorne bynum burnette boykin swenson purvis pina khan duvall darby " +
+  "xiong kauffman healy engle benoit valle steiner spicer shaver randle " +
+  "lundy dow chin calvert staton neff kearney darden oakley medeiros " +
+  "mccracken crenshaw block perdue dill whittaker tobin washburn hogue " +
+  "goodrich easley bravo dennison shipley kerns jorgensen crain villalobos " +
+  "maurer longoria keene coon witherspoon staples pettit kincaid eason " +
+  "madrid echols lusk stahl currie thayer shultz mcnally seay north maher " +
+  "gagne barrow nava moreland honeycutt hearn diggs caron whitten westbrook " +
+  "stovall ragland munson meier looney kimble jolly hobson goddard culver " +
+  "burr presley negron connell tovar huddleston ashby salter root pendleton " +
+  "oleary nickerson myrick judd jacobsen bain adair starnes matos busby " +
+  "herndon hanley bellamy doty bartley yazzie rowell parson gifford cullen " +
+  "christiansen benavides barnhart talbot mock crandall connors bonds whitt " +
+  "gage bergman arredondo addison lujan dowdy jernigan huynh bouchard " +
+  "dutton rhoades ouellette kiser herrington hare blackman babb allred rudd " +
+  "paulson ogden koenig geiger begay parra lassiter hawk esposito cho " +
+  "waldron ransom prather chacon vick sands roark parr mayberry greenberg " +
+  "coley bruner whitman skaggs shipman leary hutton romo medrano ladd kruse " +
+  "askew schulz alfaro tabor mohr gallo bermudez pereira bliss reaves flint " +
+  "comer woodall naquin guevara delong carrier pickens brand tilley " +
+  "schaffer lim knutson fenton doran chu vogt vann prescott mclain landis " +
+  "corcoran zapata hyatt hemphill faulk dove boudreaux aragon whitlock " +
+  "trejo tackett shearer saldana hanks mckinnon koehler bourgeois keyes " +
+  "goodson foote lunsford goldsmith flood winslow sams reagan mccloud hough " +
+  "esquivel naylor loomis coronado ludwig braswell bearden fagan ezell " +
+  "edmondson cyr cronin nunn lemon guillory grier dubose traylor ryder " +
+  "dobbins coyle aponte whitmore smalls rowan malloy cardona braxton borden " +
+  "humphries carrasco ruff metzger huntley hinojosa finney madsen hills " +
+  "ernst dozier burkhart bowser peralta daigle whittington sorenson saucedo " +
+  "roche redding fugate avalos waite lind huston hay hawthorne hamby boyles " +
+  "boles regan faust crook beam barger hinds gallardo willoughby willingham " +
+  "eckert busch zepeda worthington tinsley hoff hawley carmona varela " +
+  "rector newcomb kinsey dube whatley ragsdale bernstein becerra yost " +
+  "mattson felder cheek handy grossman gauthier escobedo braden beckman " +
+  "mott hillman flaherty dykes doe stockton stearns lofton coats cavazos " +
+  "beavers barrios parish mosher cardwell coles burnham weller lemons beebe " +
+  "aguilera parnell harman couture alley schumacher redd dobbs blum blalock " +
+  "merchant ennis denson cottrell brannon bagley aviles watt sousa " +
+  "rosenthal rooney dietz blank paquette mcclelland duff velasco lentz " +
+  "grubb burrows barbour ulrich shockley rader beyer mixon layton altman " +
+  "weathers stoner squires shipp priest lipscomb cutler caballero zimmer " +
+  "willett thurston storey medley epperson shah mcmillian baggett torrez " +
+  "laws hirsch dent poirier peachey farrar creech barth trimble dupre " +
+  "albrecht sample lawler crisp conroy wetzel nesbitt murry jameson wilhelm " +
+  "patten minton matson kimbrough iverson guinn croft toth pulliam nugent " +
+  "newby littlejohn dias canales bernier baron singletary renteria pruett " +
+  "mchugh mabry landrum brower stoddard cagle stjohn scales kohler kellogg " +
+  "hopson gant tharp gann zeigler pringle hammons fairchild deaton chavis " +
+  "carnes rowley matlock kearns irizarry carrington starkey lopes jarrell " +
+  "craven baum spain littlefield linn humphreys etheridge cuellar chastain " +
+  "bundy speer skelton quiroz pyle portillo ponder moulton machado liu " +
+  "killian hutson hitchcock dowling cloud burdick spann pedersen levin " +
+  "leggett hayward hacker dietrich beaulieu barksdale wakefield snowden " +
+  "briscoe bowie berman ogle mcgregor laughlin helm burden wheatley " +
+  "schreiber pressley parris alaniz agee urban swann snodgrass schuster " +
+  "radford monk mattingly harp girard cheney yancey wagoner ridley lombardo " +
+  "lau hudgins gaskins duckworth coe coburn willey prado newberry magana " +
+  "hammonds elam whipple slade serna ojeda liles dorman diehl upton reardon " +
+  "michaels goetz eller bauman baer layne hummel brenner amaya adamson " +
+  "ornelas dowell cloutier castellanos wing wellman saylor orourke moya " +
+  "montalvo kilpatrick durbin shell oldham garvin foss branham bartholomew " +
+  "templeton maguire holton rider monahan mccormack beaty anders streeter " +
+  "nieto nielson moffett lankford keating heck gatlin delatorre callaway " +
+  "adcock worrell unger robinette nowak jeter brunner steen parrott " +
+  "overstreet nobles montanez clevenger brinkley trahan quarles pickering " +
+  "pederson jansen grantham gilchrist crespo aiken schell schaeffer lorenz " +
+  "leyva harms dyson wallis pease leavitt cavanaugh batts warden seaman " +
+  "rockwell quezada paxton linder houck fontaine durant caruso adler " +
+  "pimentel mize lytle cleary cason acker switzer isaacs higginbotham han " +
+  "waterman vandyke stamper sisk shuler riddick mcmahan levesque hatton " +
+  "bronson bollinger arnett okeefe gerber gannon farnsworth baughman " +
+  "silverman satterfield mccrary kowalski grigsby greco cabral trout " +
+  "rinehart mahon linton gooden curley baugh wyman weiner schwab schuler " +
+  "morrissey mahan bunn thrasher spear waggoner qualls purdy mcwhorter " +
+  "mauldin gilman perryman newsom menard martino graf billingsley artis " +
+  "simpkins salisbury quintanilla gilliland fraley foust crouse scarborough " +
+  "ngo grissom fultz marlow markham madrigal lawton barfield whiting varney " +
+  "schwarz gooch arce wheat truong poulin hurtado selby gaither fortner " +
+  "culpepper coughlin brinson boudreau barkley bales stepp holm tan " +
+  "schilling morrell kahn heaton gamez causey turpin shanks schrader meek " +
+  "isom hardison carranza yanez scroggins schofield runyon ratcliff murrell " +
+  "moeller irby currier butterfield yee ralston pullen pinson estep carbone " +
+  "hawks ellington casillas spurlock sikes motley mccartney kruger isbell " +
+  "houle burk tomlin quigley neumann lovelace fennell cheatham bustamante " +
+  "skidmore hidalgo forman culp bowens betancourt aquino robb rea milner " +
+  "martel gresham wiles ricketts dowd collazo bostic blakely sherrod kenyon " +
+  "gandy ebert deloach allard sauer robins olivares gillette chestnut " +
+  "bourque paine hite hauser devore crawley chapa talbert poindexter meador " +
+  "mcduffie mattox kraus harkins choate wren sledge sanborn kinder geary " +
+  "cornwell barclay abney seward rhoads howland fortier benner vines tubbs " +
+  "troutman rapp mccurdy deluca westmoreland havens guajardo ely clary seal " +
+  "meehan herzog guillen ashcraft waugh renner milam elrod churchill breaux " +
+  "bolin asher windham tirado pemberton nolen noland knott emmons cornish " +
+  "christenson brownlee barbee waldrop pitt olvera lombardi gruber gaffney " +
+  "eggleston banda archuleta slone prewitt pfeiffer nettles mena mcadams " +
+  "henning gardiner cromwell chisholm burleson vest oglesby mccarter " +
+  "lumpkin grey wofford vanhorn thorn teel swafford stclair stanfield " +
+  "ocampo herrmann hannon arsenault roush mcalister hiatt gunderson " +
+  "forsythe duggan delvalle cintron wilks weinstein uribe rizzo noyes " +
+  "mclendon gurley bethea winstead maples guyton giordano alderman valdes " +
+  "polanco pappas lively grogan griffiths arevalo whitson sowell rendon " +
+  "fernandes farrow benavidez ayres alicea stump smalley seitz schulte " +
+  "gilley gallant canfield wolford omalley mcnutt mcnulty mcgovern hardman " +
+  "harbin cowart chavarria brink beckett bagwell armstead anglin abreu " +
+  "reynoso krebs jett hoffmann greenfield forte burney broome sisson " +
+  "trammell partridge mace lomax lemieux gossett frantz fogle cooney " +
+  "broughton pence paulsen muncy mcarthur hollins beauchamp withers osorio " +
+  "mulligan hoyle foy dockery cockrell begley amador roby rains lindquist " +
+  "gentile everhart bohannon wylie sommers purnell fortin dunning breeden " +
+  "vail phelan phan marx cosby colburn boling biddle ledesma gaddis denney " +
+  "chow bueno berrios wicker tolliver thibodeaux nagle lavoie fisk crist " +
+  "barbosa reedy march locklear kolb himes behrens beckwith weems wahl " +
+  "shorter shackelford rees muse cerda valadez thibodeau saavedra ridgeway " +
+  "reiter mchenry majors lachance keaton ferrara clemens blocker applegate " +
+  "paz needham mojica kuykendall hamel escamilla doughty burchett ainsworth " +
+  "vidal upchurch thigpen strauss spruill sowers riggins ricker mccombs " +
+  "harlow buffington sotelo olivas negrete morey macon logsdon lapointe " +
+  "bigelow bello westfall stubblefield peak lindley hein hawes farrington " +
+  "breen birch wilde steed sepulveda reinhardt proffitt minter messina " +
+  "mcnabb maier keeler gamboa donohue basham shinn crooks cota borders " +
+  "bills bachman tisdale tavares schmid pickard gulley fonseca delossantos " +
+  "condon batista wicks wadsworth martell littleton ison haag folsom " +
+  "brumfield broyles brito mireles mcdonnell leclair hamblin gough fanning " +
+  "binder winfield whitworth soriano palumbo newkirk mangum hutcherson " +
+  "comstock carlin beall bair wendt watters walling putman otoole morley " +
+  "mares lemus keener hundley dial damico billups strother mcfarlane lamm " +
+  "eaves crutcher caraballo canty atwell taft siler rust rawls rawlings " +
+  "prieto mcneely mcafee hulsey hackney galvez escalante delagarza crider " +
+  "charlton bandy wilbanks stowe steinberg renfro masterson massie lanham " +
+  "haskell hamrick fort dehart burdette branson bourne babin aleman worthy " +
+  "tibbs smoot slack paradis mull luce houghton gantt furman danner " +
+  "christianson burge ashford arndt almeida stallworth shade searcy sager " +
+  "noonan mclemore mcintire maxey lavigne jobe ferrer falk coffin byrnes " +
+  "aranda apodaca stamps rounds peek olmstead lewandowski kaminski dunaway " +
+  "bruns brackett amato reich mcclung lacroix koontz herrick hardesty " +
+  "flanders cousins cato cade vickery shank nagel dupuis croteau cotter " +
+  "cable stuckey stine porterfield pauley nye moffitt knudsen hardwick " +
+  "goforth dupont blunt barrows barnhill shull rash loftis lemay kitchens " +
+  "horvath grenier fuchs fairbanks culbertson calkins burnside beattie " +
+  "ashworth albertson wertz vaught vallejo turk tuck tijerina sage peterman " +
+  "marroquin marr lantz hoang demarco daily cone berube barnette wharton " +
+  "stinnett slocum scanlon sander pinto mancuso lima headley epstein counts " +
+  "clarkson carnahan boren arteaga adame zook whittle whitehurst wenzel " +
+  "saxton reddick puente handley haggerty earley devlin chaffin cady acuna " +
+  "solano sigler pollack pendergrass ostrander janes francois crutchfield " +
+  "chamberlin brubaker baptiste willson reis neeley mullin mercier lira " +
+  "layman keeling higdon espinal chapin warfield toledo pulido peebles nagy " +
+  "montague mello lear jaeger hogg graff furr soliz poore mendenhall " +
+  "mclaurin maestas gable barraza tillery snead pond neill mcculloch " +
+  "mccorkle lightfoot hutchings holloman harness dorn council bock " +
+  "zielinski turley treadwell stpierre starling somers oswald merrick " +
+  "easterling bivens truitt poston parry ontiveros olivarez moreau medlin " +
+  "lenz knowlton fairley cobbs chisolm bannister woodworth toler ocasio " +
+  "noriega neuman moye milburn mcclanahan lilley hanes flannery dellinger " +
+  "danielson conti blodgett beers weatherford strain karr hitt denham " +
+  "custer coble clough casteel bolduc batchelor ammons whitlow tierney " +
+  "staten sibley seifert schubert salcedo mattison laney haggard grooms dix " +
+  "dees cromer cooks colson caswell zarate swisher shin ragan pridgen mcvey " +
+  "matheny lafleur franz ferraro dugger whiteside rigsby mcmurray lehmann " +
+  "jacoby hildebrand hendrick headrick goad fincher drury borges archibald " +
+  "albers woodcock trapp soares seaton monson luckett lindberg kopp keeton " +
+  "hsu healey garvey gaddy fain burchfield wentworth strand stack spooner " +
+  "saucier sales ricci plunkett pannell ness leger hoy freitas fong " +
+  "elizondo duval beaudoin urbina rickard partin moe mcgrew mcclintock " +
+  "ledoux forsyth faison devries bertrand wasson tilton scarbrough leung " +
+  "irvine garber denning corral colley castleberry bowlin bogan beale " +
+  "baines trice rayburn parkinson pak nunes mcmillen leahy kimmel higgs " +
+  "fulmer carden bedford taggart spearman register prichard morrill koonce " +
+  "heinz hedges guenther grice findley dover creighton boothe bayer arreola " +
+  "vitale valles raney osgood hanlon burley bounds worden weatherly vetter " +
+  "tanaka stiltner nevarez mosby montero melancon harter hamer goble " +
+  "gladden gist ginn akin zaragoza towns tarver sammons royster oreilly " +
+  "muir morehead luster kingsley kelso grisham glynn baumann alves yount " +
+  "tamayo paterson oates menendez longo hargis gillen desantis breedlove " +
+  "sumpter scherer rupp reichert heredia creel cohn clemmons casas bickford " +
+  "belton bach williford whitcomb tennant sutter stull sessions mccallum " +
+  "langlois keel keegan dangelo dancy damron clapp clanton bankston " +
+  "oliveira mintz mcinnis martens mabe laster jolley hildreth hefner glaser " +
+  "duckett demers brockman blais alcorn agnew toliver tice seeley najera " +
+  "musser mcfall laplante galvin fajardo doan coyne copley clawson cheung " +
+  "barone wynne woodley tremblay stoll sparrow sparkman schweitzer sasser " +
+  "samples roney legg heim farias colwell christman bratcher winchester " +
+  "upshaw southerland sorrell sells mount mccloskey martindale luttrell " +
+  "loveless lovejoy linares latimer embry coombs bratton bostick venable " +
+  "tuggle toro staggs sandlin jefferies heckman griffis crayton clem " +
+  "browder thorton sturgill sprouse royer rousseau ridenour pogue perales " +
+  "peeples metzler mesa mccutcheon mcbee hornsby heffner corrigan armijo " +
+  "vue plante peyton paredes macklin hussey hodgson granados frias becnel " +
+  "batten almanza turney teal sturgeon meeker mcdaniels limon keeney kee " +
+  "hutto holguin gorham fishman fierro blanchette rodrigue reddy osburn " +
+  "oden lerma kirkwood keefer haugen hammett chalmers brinkman baumgartner " +
+  "valerio tellez steffen shumate sauls ripley kemper jacks guffey evers " +
+  "craddock carvalho blaylock banuelos balderas wooden wheaton turnbull " +
+  "shuman pointer mosier mccue ligon kozlowski johansen ingle herr briones " +
+  "snipes rickman pipkin pantoja orosco moniz lawless kunkel hibbard " +
+  "galarza enos bussey schott salcido perreault mcdougal mccool haight " +
+  "garris ferry easton conyers atherton wimberly utley spellman smithson " +
+  "slagle ritchey rand petit osullivan oaks nutt mcvay mccreary mayhew " +
+  "knoll jewett harwood cardoza ashe arriaga zeller wirth whitmire stauffer " +
+  "rountree redden mccaffrey martz larose langdon humes gaskin faber devito " +
+  "cass almond wingfield wingate villareal tyner smothers severson reno " +
+  "pennell maupin leighton janssen hassell hallman halcomb folse " +
+  "fitzsimmons fahey cranford bolen battles battaglia wooldridge trask " +
+  "rosser regalado mcewen keefe fuqua echevarria caro boynton andrus viera " +
+  "vanmeter taber spradlin seibert provost prentice oliphant laporte hwang " +
+  "hatchett hass greiner freedman covert chilton byars wiese venegas swank " +
+  "shrader roberge mullis mortensen mccune marlowe kirchner keck isaacson " +
+  "hostetler halverson gunther griswold fenner durden blackwood ahrens " +
+  "sawyers savoy nabors mcswain mackay loy lavender lash labbe jessup " +
+  "fullerton cruse crittenden correia centeno caudle canady callender " +
+  "alarcon ahern winfrey tribble styles salley roden musgrove minnick " +
+  "fortenberry carrion bunting batiste whited underhill stillwell rauch " +
+  "pippin perrin messenger mancini lister kinard hartmann fleck broadway " +
+  "wilt treadway thornhill spalding rafferty pitre patino ordonez linkous " +
+  "kelleher homan galbraith feeney curtin coward camarillo buss bunnell " +
+  "bolt beeler autry alcala witte wentz stidham shively nunley meacham " +
+  "martins lemke lefebvre hynes horowitz hoppe holcombe dunne derr cochrane " +
+  "brittain bedard beauregard torrence strunk soria simonson shumaker " +
+  "scoggins oconner moriarty kuntz ives hutcheson horan hales garmon fitts " +
+  "bohn atchison wisniewski vanwinkle sturm sallee prosser moen lundberg " +
+  "kunz kohl keane jorgenson jaynes funderburk freed durr creamer cosgrove " +
+  "batson vanhoose thomsen teeter smyth redmon orellana maness heflin " +
+  "goulet frick forney bunker asbury aguiar talbott southard mowery mears " +
+  "lemmon krieger hickson elston duong delgadillo dayton dasilva conaway " +
+  "catron bruton bradbury bordelon bivins bittner bergstrom beals abell " +
+  "whelan tejada pulley pino norfleet nealy maes loper gatewood frierson " +
+  "freund finnegan cupp covey catalano boehm bader yoon walston tenney " +
+  "sipes rawlins medlock mccaskill mccallister marcotte maclean hughey " +
+  "henke harwell gladney gilson dew chism caskey brandenburg baylor " +
+  "villasenor veal thatcher stegall shore petrie nowlin navarrete muhammad " +
+  "lombard loftin lemaster kroll kovach kimbrell kidwell hershberger " +
+  "fulcher eng cantwell bustos boland bobbitt binkley wester weis verdin " +
+  "tiller sisco sharkey seymore rosenbaum rohr quinonez pinkston nation " +
+  "malley logue lessard lerner lebron krauss klinger halstead haller getz " +
+  "burrow alger shores pfeifer perron nelms munn mcmaster mckenney manns " +
+  "knudson hutchens huskey goebel flagg cushman click castellano carder " +
+  "bumgarner wampler spinks robson neel mcreynolds mathias maas loera " +
+  "kasper jenson florez coons buckingham brogan berryman wilmoth wilhite " +
+  "thrash shephard seidel schulze roldan pettis obryan maki mackie hatley " +
+  "frazer fiore chesser bui bottoms bisson benefield allman wilke trudeau " +
+  "timm shifflett rau mundy milliken mayers leake kohn huntington horsley " +
+  "hermann guerin fryer frizzell foret flemming fife criswell carbajal " +
+  "bozeman boisvert angulo wallen tapp silvers ramsay oshea orta moll " +
+  "mckeever mcgehee linville kiefer ketchum howerton groce gass fusco " +
+  "corbitt betz bartels amaral aiello yoo weddle sperry seiler runyan raley " +
+  "overby osteen olds mckeown matney lauer lattimore hindman hartwell " +
+  "fredrickson fredericks espino clegg carswell cambell burkholder woodbury " +
+  "welker totten thornburg theriault stitt stamm stackhouse scholl saxon " +
+  "rife razo quinlan pinkerton olivo nesmith nall mattos lafferty justus " +
+  "giron geer fielder drayton dortch conners conger boatwright billiot " +
+  "barden armenta tibbetts steadman slattery rinaldi raynor pinckney " +
+  "pettigrew milne matteson halsey gonsalves fellows durand desimone cowley " +
+  "cowles brill barham barela barba ashmore withrow valenti tejeda spriggs " +
+  "sayre salerno peltier peel merriman matheson lowman lindstrom hyland " +
+  "giroux earls dugas dabney collado briseno baxley whyte wenger vanover " +
+  "vanburen thiel schindler schiller rigby pomeroy passmore marble manzo " +
+  "mahaffey lindgren laflamme greathouse fite calabrese bayne yamamoto wick " +
+  "townes thames reinhart peeler naranjo montez mcdade mast markley " +
+  "marchand leeper kellum hudgens hennessey hadden gainey coppola borrego " +
+  "bolling beane ault slaton poland pape null mulkey lightner langer " +
+  "hillard glasgow ethridge enright derosa baskin weinberg turman " +
+  "somerville pardo noll lashley ingraham hiller hendon glaze cothran " +
+  "cooksey conte carrico abner wooley swope summerlin sturgis sturdivant " +
+  "stott spurgeon spillman speight roussel popp nutter mckeon mazza " +
+  "magnuson lanning kozak jankowski heyward forster corwin callaghan bays " +
+  "wortham usher theriot sayers sabo poling loya lieberman laroche labelle " +
+  "howes harr garay fogarty everson durkin dominquez chaves chambliss " +
+  "witcher vieira vandiver terrill stoker schreiner moorman liddell lew " +
+  "lawhorn krug irons hylton hollenbeck herrin hembree goolsby goodin " +
+  "gilmer foltz dinkins daughtry caban brim briley bilodeau wyant vergara " +
+  "tallent swearingen stroup scribner quillen pitman monaco mccants " +
+  "maxfield martinson holtz flournoy brookins brody baumgardner straub " +
+  "sills roybal roundtree oswalt mcgriff mcdougall mccleary maggard gragg " +
+  "gooding godinez doolittle donato cowell cassell bracken appel zambrano " +
+  "reuter perea nakamura monaghan mickens mcclinton mcclary marler kish " +
+  "judkins gilbreath freese flanigan felts erdmann dodds chew brownell " +
+  "boatright barreto slayton sandberg saldivar pettway odum narvaez " +
+  "moultrie montemayor merrell lees keyser hoke hardaway hannan gilbertson " +
+  "fogg dumont deberry coggins buxton bucher broadnax beeson araujo " +
+  "appleton amundson aguayo ackley yocum worsham shivers sanches sacco " +
+  "robey rhoden pender ochs mccurry madera luong knotts jackman heinrich " +
+  "hargrave gault comeaux chitwood caraway boettcher bernhardt barrientos " +
+  "zink wickham whiteman thorp stillman settles schoonover roque riddell " +
+  "pilcher phifer novotny macleod hardee haase grider doucette clausen " +
+  "bevins beamon badillo tolley tindall soule snook seale pitcher pinkney " +
+  "pellegrino nowell nemeth mondragon mclane lundgren ingalls hudspeth " +
+  "hixson gearhart furlong downes dibble deyoung cornejo camara brookshire " +
+  "boyette wolcott surratt sellars segal salyer reeve rausch labonte haro " +
+  "gower freeland fawcett eads driggers donley collett bromley boatman " +
+  "ballinger baldridge volz trombley stonge shanahan rivard rhyne pedroza " +
+  "matias jamieson hedgepeth hartnett estevez eskridge denman chiu chinn " +
+  "catlett carmack buie bechtel beardsley bard ballou ulmer skeen robledo " +
+  "rincon reitz piazza munger moten mcmichael loftus ledet kersey groff " +
+  "fowlkes folk crumpton clouse bettis villagomez timmerman strom santoro " +
+  "roddy penrod musselman macpherson leboeuf harless haddad guido golding " +
+  "fulkerson fannin dulaney dowdell cottle ceja cate bosley benge albritton " +
+  "voigt trowbridge soileau seely rohde pearsall paulk orth nason mota " +
+  "mcmullin marquardt madigan hoag gillum gabbard fenwick eck danforth " +
+  "cushing cress creed cazares casanova bey bettencourt barringer baber " +
+  "stansberry schramm rutter rivero oquendo necaise mouton montenegro miley " +
+  "mcgough marra macmillan lamontagne jasso horst hetrick heilman gaytan " +
+  "gall fortney dingle desjardins dabbs burbank brigham breland beaman " +
+  "arriola yarborough wallin toscano stowers reiss pichardo orton michels " +
+  "mcnamee mccrory leatherman kell keister horning hargett guay ferro " +
+  "deboer dagostino carper blanks beaudry towle tafoya stricklin strader " +
+  "soper sonnier sigmon schenk saddler pedigo mendes lunn lohr lahr " +
+  "kingsbury jarman hume holliman hofmann haworth harrelson hambrick flick " +
+  "edmunds dacosta crossman colston chaplin carrell budd weiler waits " +
+  "valentino trantham tarr solorio roebuck powe plank pettus palm pagano " +
+  "mink luker leathers joslin hartzell gambrell deutsch cepeda carty caputo " +
+  "brewington bedell ballew applewhite warnock walz urena tudor reel pigg " +
+  "parton mickelson meagher mclellan mcculley mandel leech lavallee kraemer " +
+  "kling kipp kehoe hochstetler harriman gregoire grabowski gosselin gammon " +
+  "fancher edens desai brannan armendariz woolsey whitehouse whetstone " +
+  "ussery towne testa tallman studer strait steinmetz sorrells sauceda " +
+  "rolfe paddock mitchem mcginn mccrea lovato hazen gilpin gaynor fike " +
+  "devoe delrio curiel burkhardt bode backus zinn watanabe wachter vanpelt " +
+  "turnage shaner schroder sato riordan quimby portis natale mckoy mccown " +
+  "kilmer hotchkiss hesse halbert gwinn godsey delisle chrisman canter " +
+  "arbogast angell acree yancy woolley wesson weatherspoon trainor stockman " +
+  "spiller sipe rooks reavis propst porras neilson mullens loucks llewellyn " +
+  "kumar koester klingensmith kirsch kester honaker hodson hennessy helmick " +
+  "garrity garibay fee drain casarez callis botello aycock avant wingard " +
+  "wayman tully theisen szymanski stansbury segovia rainwater preece pirtle " +
+  "padron mincey mckelvey mathes larrabee kornegay klug ingersoll hecht " +
+  "germain eggers dykstra deering decoteau deason dearing cofield carrigan " +
+  "bonham bahr aucoin appleby almonte yager womble wimmer weimer vanderpool " +
+  "stancil sprinkle romine remington pfaff peckham olivera meraz maze " +
+  "lathrop koehn hazelton halvorson hallock haddock ducharme dehaven " +
+  "caruthers brehm bosworth bost bias beeman basile bane aikens wold " +
+  "walther tabb suber strawn stocker shirey schlosser riedel rembert reimer " +
+  "pyles peele merriweather letourneau latta kidder hixon hillis hight " +
+  "herbst henriquez haygood hamill gabel fritts eubank dawes correll cha " +
+  "bushey buchholz brotherton botts barnwell auger atchley westphal " +
+  "veilleux ulloa stutzman shriver ryals prior pilkington moyers marrs " +
+  "mangrum maddux lockard laing kuhl harney hammock hamlett felker doerr " +
+  "depriest carrasquillo carothers bogle bischoff bergen albanese wyckoff " +
+  "vermillion vansickle thibault tetreault stickney shoemake ruggiero " +
+  "rawson racine philpot paschal mcelhaney mathison legrand lapierre kwan " +
+  "kremer jiles hilbert geyer faircloth ehlers egbert desrosiers dalrymple " +
+  "cotten cashman cadena breeding boardman alcaraz ahn wyrick therrien " +
+  "tankersley strickler puryear plourde pattison pardue mcginty mcevoy " +
+  "landreth kuhns koon hewett giddens emerick eades deangelis cosme " +
+  "ceballos birdsong benham bemis armour anguiano welborn tsosie storms " +
+  "shoup sessoms samaniego rood rojo rhinehart raby northcutt myer munguia " +
+  "morehouse mcdevitt mallett lozada lemoine kuehn hallett grim gillard " +
+  "gaylor garman gallaher feaster faris darrow dardar coney carreon " +
+  "braithwaite boylan boyett bixler bigham benford barragan barnum zuber " +
+  "wyche westcott vining stoltzfus simonds shupe sabin ruble rittenhouse " +
+  "richman perrone mulholland millan lomeli kite jemison hulett holler " +
+  "hickerson herold hazelwood griffen gause forde eisenberg dilworth " +
+  "charron chaisson brodie bristow breunig brace boutwell bentz belk " +
+  "bayless batchelder baran baeza zimmermann weathersby volk toole theis " +
+  "tedesco searle schenck satterwhite ruelas rankins partida nesbit morel " +
+  "menchaca levasseur kaylor johnstone hulse hollar hersey harrigan " +
+  "harbison guyer gish giese gerlach geller geisler falcone elwell doucet " +
+  "deese darr corder chafin byler bussell burdett brasher bowe bellinger " +
+  "bastian barner alleyne wilborn weil wegner wales tatro spitzer smithers " +
+  "schoen resendez parisi overman obrian mudd moy mclaren maggio lindner " +
+  "lalonde lacasse laboy killion kahl jessen jamerson houk henshaw gustin " +
+  "graber durst duenas davey cundiff conlon colunga coakley chiles capers " +
+  "buell bricker bissonnette birmingham bartz bagby zayas volpe treece " +
+  "toombs thom terrazas swinney skiles silveira shouse senn ramage nez moua " +
+  "langham kyles holston hoagland herd feller denison carraway burford " +
+  "bickel ambriz abercrombie yamada weidner waddle verduzco thurmond " +
+  "swindle schrock sanabria rosenberger probst peabody olinger nazario " +
+  "mccafferty mcbroom mcabee mazur matherne mapes leverett killingsworth " +
+  "heisler griego gosnell frankel franke ferrante fenn ehrlich " +
+  "christopherso chasse chancellor caton brunelle bly bloomfield babbitt " +
+  "azevedo abramson ables abeyta youmans wozniak wainwright stowell " +
+  "smitherman samuelson runge rothman rosenfeld peake owings olmos munro " +
+  "moreira leatherwood larkins krantz kovacs kizer kindred karnes jaffe " +
+  "hubbell hosey hauck goodell erdman dvorak doane cureton cofer buehler " +
+  "bierman berndt banta abdullah warwick waltz turcotte torrey stith seger " +
+  "sachs quesada pinder peppers pascual paschall parkhurst ozuna oster " +
+  "nicholls lheureux lavalley kimura jablonski haun gourley gilligan derby " +
+  "croy cotto cargill burwell burgett buckman booher adorno wrenn " +
+  "whittemore urias szabo sayles saiz rutland rael pharr pelkey ogrady " +
+  "nickell musick moats mather massa kirschner kieffer kellar hendershot " +
+  "gott godoy gadson furtado fiedler erskine dutcher dever daggett " +
+  "chevalier brake ballesteros amerson wingo waldon trott"
@@ -0,0 +1,611 @@
+/*-
+ * Copyright (C) 2014-2017, Vi Grey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY AUTHOR AND CONTRIBUTORS "AS IS" AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL AUTHOR OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+/*
+ * Finds the patterns in a password that make parts of it easy to guess.
+ */
+
+package strength
+
+import (
+  "regexp"
+  "sort"
+  "strconv"
+  "strings"
+  "sync"
+  "unicode"
+)
+
+const (
+  bruteforcePattern = iota
+  dictionaryPattern
+  spatialPattern
+  repeatPattern
+  sequencePattern
+  yearPattern
+  datePattern
+)
+
+const (
+  /* Largest difference between characters of a sequence like "aceg". */
+  maxSequenceDelta = 5
+  minDateYear = 1000
+  maxDateYear = 2050
+  /* Most combinations of l33t substitutions that are tried. */
+  maxL33tSubs = 64
+)
+
+/*
+ * A part of a password from character i to character j (inclusive) that
+ * matches a pattern.  Which of the other fields are set depends on the
+ * pattern.
+ */
+type match struct {
+  pattern int
+  i, j int
+  token string
+  /* Estimated guesses of the match, or 0 if not estimated yet. */
+  guesses float64
+
+  /* Dictionary words. */
+  word string
+  rank int
+  reversed, l33t bool
+  /* Substituted l33t characters of token and the letters they stand for. */
+  sub map[rune]rune
+
+  /* Keyboard walks. */
+  keyboard *keyboard
+  turns, shifted int
+
+  /* Repeats. */
+  baseGuesses float64
+  repeatCount int
+
+  /* Sequences. */
+  ascending bool
+
+  /* Years and dates. */
+  year int
+  separator string
+}
+
+/* Words an attacker tries, ranked from most to least common. */
+type dictionary struct {
+  ranks map[string]int
+  /* Length in characters of the longest word. */
+  maxLength int
+}
+
+var (
+  dictionaries []*dictionary
+  dictionariesOnce sync.Once
+)
+
+/* Letters and the l33t characters that are substituted for them. */
+var l33tTable = []struct {
+  letter rune
+  subs string
+}{
+  {'a', "4@"},
+  {'b', "8"},
+  {'c', "({[<"},
+  {'e', "3"},
+  {'g', "69"},
+  {'i', "1!|"},
+  {'l', "1|7"},
+  {'o', "0"},
+  {'s', "$5"},
+  {'t', "+7"},
+  {'x', "%"},
+  {'z', "2"},
+}
+
+var (
+  dateNoSeparator = regexp.MustCompile(`^\d{4,8}$`)
+  dateSeparator = regexp.MustCompile(
+    `^(\d{1,4})([\s/\\_.-])(\d{1,2})([\s/\\_.-])(\d{1,4})$`)
+)
+
+/*
+ * Where to split dates without separators of each length into day, month
+ * and year.
+ */
+var dateSplits = map[int][][2]int{
+  4: {{1, 2}, {2, 3}},
+  5: {{1, 3}, {2, 3}},
+  6: {{1, 2}, {2, 4}, {4, 5}},
+  7: {{1, 3}, {2, 3}, {4, 5}, {4, 6}},
+  8: {{2, 4}, {4, 6}},
+}
+
+/* Builds the embedded dictionaries the first time they are needed. */
+func loadDictionaries() {
+  dictionariesOnce.Do(func() {
+    for _, list := range []string{passwordList, englishList, femaleNameList,
+                                  maleNameList, surnameList} {
+      dictionaries = append(dictionaries,
+                            newDictionary(strings.Fields(list)))
+    }
+  })
+}
+
+/* Returns a dictionary of words ranked in the order they are in. */
+func newDictionary(words []string) *dictionary {
+  d := &dictionary{ranks: make(map[string]int, len(words))}
+  for _, w := range words {
+    w = strings.ToLower(w)
+    if _, ok := d.ranks[w]; ok {
+      continue
+    }
+    d.ranks[w] = len(d.ranks) + 1
+    if length := len([]rune(w)); length > d.maxLength {
+      d.maxLength = length
+    }
+  }
+  return d
+}
+
+/*
+ * Returns a dictionary of userInputs and the words in them, like the parts
+ * of an email address.
+ */
+func userDictionary(userInputs []string) *dictionary {
+  var words []string
+  for _, input := range userInputs {
+    if input == "" {
+      continue
+    }
+    words = append(words, input)
+    for _, w := range strings.FieldsFunc(input, func(r rune) bool {
+      return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+    }) {
+      if len([]rune(w)) > 2 {
+        words = append(words, w)
+      }
+    }
+  }
+  return newDictionary(words)
+}
+
+/* Returns every match of every pattern in pw, ordered by position. */
+func omnimatch(pw []rune, dicts []*dictionary) []*match {
+  var matches []*match
+  matches = append(matches, dictionaryMatch(pw, dicts)...)
+  matches = append(matches, reverseDictionaryMatch(pw, dicts)...)
+  matches = append(matches, l33tMatch(pw, dicts)...)
+  for _, k := range keyboards {
+    matches = append(matches, spatialMatch(pw, k)...)
+  }
+  matches = append(matches, repeatMatch(pw, dicts)...)
+  matches = append(matches, sequenceMatch(pw)...)
+  matches = append(matches, yearMatch(pw)...)
+  matches = append(matches, dateMatch(pw)...)
+  sort.SliceStable(matches, func(a, b int) bool {
+    if matches[a].i != matches[b].i {
+      return matches[a].i < matches[b].i
+    }
+    return matches[a].j < matches[b].j
+  })
+  return matches
+}
+
+/* Returns the match of bruteforcing characters i to j of pw. */
+func bruteforceMatch(pw []rune, i, j int) *match {
+  return &match{pattern: bruteforcePattern, i: i, j: j,
+                token: string(pw[i:j + 1])}
+}
+
+/* Returns the dictionary words of dicts in pw, ignoring case. */
+func dictionaryMatch(pw []rune, dicts []*dictionary) []*match {
+  lower := make([]rune, len(pw))
+  for x, r := range pw {
+    lower[x] = unicode.ToLower(r)
+  }
+  var matches []*match
+  for _, d := range dicts {
+    for i := range lower {
+      for j := i; j < len(lower) && j - i < d.maxLength; j++ {
+        word := string(lower[i:j + 1])
+        if rank, ok := d.ranks[word]; ok {
+          matches = append(matches, &match{pattern: dictionaryPattern,
+                                           i: i, j: j,
+                                           token: string(pw[i:j + 1]),
+                                           word: word, rank: rank})
+        }
+      }
+    }
+  }
+  return matches
+}
+
+/* Returns the dictionary words of dicts that are backwards in pw. */
+func reverseDictionaryMatch(pw []rune, dicts []*dictionary) []*match {
+  n := len(pw)
+  reversed := make([]rune, n)
+  for x, r := range pw {
+    reversed[n - 1 - x] = r
+  }
+  matches := dictionaryMatch(reversed, dicts)
+  for _, m := range matches {
+    m.i, m.j = n - 1 - m.j, n - 1 - m.i
+    m.token = string(pw[m.i:m.j + 1])
+    m.reversed = true
+  }
+  return matches
+}
+
+/*
+ * Returns the dictionary words of dicts in pw that have some of their
+ * letters substituted with l33t characters, like "p4ssw0rd".
+ */
+func l33tMatch(pw []rune, dicts []*dictionary) []*match {
+  var matches []*match
+  found := make(map[string]bool)
+  for _, sub := range l33tSubs(pw) {
+    translated := make([]rune, len(pw))
+    for x, r := range pw {
+      if letter, ok := sub[r]; ok {
+        translated[x] = letter
+      } else {
+        translated[x] = r
+      }
+    }
+    for _, m := range dictionaryMatch(translated, dicts) {
+      token := pw[m.i:m.j + 1]
+      if len(token) < 2 || strings.ToLower(string(token)) == m.word {
+        continue
+      }
+      key := strconv.Itoa(m.i) + " " + strconv.Itoa(m.j) + " " + m.word
+      if found[key] {
+        continue
+      }
+      found[key] = true
+      m.token = string(token)
+      m.l33t = true
+      m.sub = make(map[rune]rune)
+      for _, r := range token {
+        if letter, ok := sub[r]; ok {
+          m.sub[r] = letter
+        }
+      }
+      matches = append(matches, m)
+    }
+  }
+  return matches
+}
+
+/*
+ * Returns the combinations of letters the l33t characters in pw could stand
+ * for, as maps of l33t characters to letters.
+ */
+func l33tSubs(pw []rune) []map[rune]rune {
+  var chars []rune
+  letters := make(map[rune][]rune)
+  for _, entry := range l33tTable {
+    for _, c := range entry.subs {
+      if !strings.ContainsRune(string(pw), c) {
+        continue
+      }
+      if letters[c] == nil {
+        chars = append(chars, c)
+      }
+      letters[c] = append(letters[c], entry.letter)
+    }
+  }
+  if len(chars) == 0 {
+    return nil
+  }
+  subs := []map[rune]rune{{}}
+  for _, c := range chars {
+    var next []map[rune]rune
+    for _, sub := range subs {
+      for _, letter := range letters[c] {
+        if len(next) == maxL33tSubs {
+          break
+        }
+        s := make(map[rune]rune, len(sub) + 1)
+        for k, v := range sub {
+          s[k] = v
+        }
+        s[c] = letter
+        next = append(next, s)
+      }
+    }
+    subs = next
+  }
+  return subs
+}
+
+/*
+ * Returns the walks of at least 3 neighboring keys on keyboard k in pw,
+ * like "qwerty" or "zxcvfr".
+ */
+func spatialMatch(pw []rune, k *keyboard) []*match {
+  var matches []*match
+  for i := 0; i < len(pw) - 1; {
+    j := i + 1
+    lastDirection := -1
+    turns := 0
+    shifted := 0
+    if k.shifted(pw[i]) {
+      shifted = 1
+    }
+    for {
+      found := false
+      if j < len(pw) {
+        for direction, adjacent := range k.graph[pw[j - 1]] {
+          if index := strings.IndexRune(adjacent, pw[j]); index != -1 {
+            found = true
+            if index == 1 {
+              shifted++
+            }
+            if lastDirection != direction {
+              turns++
+              lastDirection = direction
+            }
+            break
+          }
+        }
+      }
+      if found {
+        j++
+        continue
+      }
+      if j - i > 2 {
+        matches = append(matches, &match{pattern: spatialPattern, i: i,
+                                         j: j - 1,
+                                         token: string(pw[i:j]),
+                                         keyboard: k, turns: turns,
+                                         shifted: shifted})
+      }
+      i = j
+      break
+    }
+  }
+  return matches
+}
+
+/*
+ * Returns the parts of pw that repeat the same characters at least twice,
+ * like "abcabc".  The guesses of the repeated characters are estimated
+ * with dicts.
+ */
+func repeatMatch(pw []rune, dicts []*dictionary) []*match {
+  var matches []*match
+  for i := 0; i < len(pw) - 1; {
+    bestLength, bestCount := 0, 0
+    for length := 1; i + length * 2 <= len(pw); length++ {
+      count := 1
+      for i + (count + 1) * length <= len(pw) && equalRunes(
+          pw[i:i + length], pw[i + count * length:i + (count + 1) * length]) {
+        count++
+      }
+      if count > 1 && length * count > bestLength * bestCount {
+        bestLength, bestCount = length, count
+      }
+    }
+    if bestCount == 0 {
+      i++
+      continue
+    }
+    base := pw[i:i + bestLength]
+    j := i + bestLength * bestCount - 1
+    matches = append(matches, &match{pattern: repeatPattern, i: i, j: j,
+      token: string(pw[i:j + 1]), repeatCount: bestCount,
+      baseGuesses: mostGuessable(base, omnimatch(base, dicts))})
+    i = j + 1
+  }
+  return matches
+}
+
+/* Returns whether a and b are the same characters. */
+func equalRunes(a, b []rune) bool {
+  if len(a) != len(b) {
+    return false
+  }
+  for x := range a {
+    if a[x] != b[x] {
+      return false
+    }
+  }
+  return true
+}
+
+/*
+ * Returns the parts of pw where each character is the same small step
+ * from the one before it, like "abcd", "9753" or "aceg".
+ */
+func sequenceMatch(pw []rune) []*match {
+  var matches []*match
+  if len(pw) < 2 {
+    return matches
+  }
+  add := func(i, j, delta int) {
+    if delta < 0 {
+      delta = -delta
+    }
+    if (j - i > 1 || delta == 1) && delta > 0 &&
+        delta <= maxSequenceDelta {
+      matches = append(matches, &match{pattern: sequencePattern, i: i,
+                                       j: j, token: string(pw[i:j + 1]),
+                                       ascending: pw[j] > pw[i]})
+    }
+  }
+  i := 0
+  lastDelta := int(pw[1] - pw[0])
+  for k := 1; k < len(pw); k++ {
+    delta := int(pw[k] - pw[k - 1])
+    if delta == lastDelta {
+      continue
+    }
+    add(i, k - 1, lastDelta)
+    i = k - 1
+    lastDelta = delta
+  }
+  add(i, len(pw) - 1, lastDelta)
+  return matches
+}
+
+/* Returns the years from 1900 to 2049 in pw. */
+func yearMatch(pw []rune) []*match {
+  var matches []*match
+  for i := 0; i + 4 <= len(pw); i++ {
+    token := string(pw[i:i + 4])
+    year, err := strconv.Atoi(token)
+    if err != nil || token[0] == '+' || token[0] == '-' || year < 1900 ||
+        year > 2049 {
+      continue
+    }
+    matches = append(matches, &match{pattern: yearPattern, i: i, j: i + 3,
+                                     token: token, year: year})
+    i += 3
+  }
+  return matches
+}
+
+/*
+ * Returns the dates in pw with or without separators, like "13/5/1987" or
+ * "130587".  Dates inside longer dates aren't returned.
+ */
+func dateMatch(pw []rune) []*match {
+  var matches []*match
+  now := referenceYear()
+  for i := 0; i + 4 <= len(pw); i++ {
+    for j := i + 3; j <= i + 7 && j < len(pw); j++ {
+      token := string(pw[i:j + 1])
+      if !dateNoSeparator.MatchString(token) {
+        continue
+      }
+      found := false
+      bestYear, bestDistance := 0, 0
+      for _, split := range dateSplits[len(token)] {
+        a, _ := strconv.Atoi(token[:split[0]])
+        b, _ := strconv.Atoi(token[split[0]:split[1]])
+        c, _ := strconv.Atoi(token[split[1]:])
+        year, ok := dateYear(a, b, c)
+        if !ok {
+          continue
+        }
+        distance := year - now
+        if distance < 0 {
+          distance = -distance
+        }
+        if !found || distance < bestDistance {
+          found, bestYear, bestDistance = true, year, distance
+        }
+      }
+      if found {
+        matches = append(matches, &match{pattern: datePattern, i: i, j: j,
+                                         token: token, year: bestYear})
+      }
+    }
+  }
+  for i := 0; i + 6 <= len(pw); i++ {
+    for j := i + 5; j <= i + 9 && j < len(pw); j++ {
+      token := string(pw[i:j + 1])
+      parts := dateSeparator.FindStringSubmatch(token)
+      if parts == nil || parts[2] != parts[4] {
+        continue
+      }
+      a, _ := strconv.Atoi(parts[1])
+      b, _ := strconv.Atoi(parts[3])
+      c, _ := strconv.Atoi(parts[5])
+      if year, ok := dateYear(a, b, c); ok {
+        matches = append(matches, &match{pattern: datePattern, i: i, j: j,
+                                         token: token, year: year,
+                                         separator: parts[2]})
+      }
+    }
+  }
+  var dates []*match
+  for _, m := range matches {
+    inside := false
+    for _, other := range matches {
+      if m != other && other.i <= m.i && other.j >= m.j {
+        inside = true
+        break
+      }
+    }
+    if !inside {
+      dates = append(dates, m)
+    }
+  }
+  return dates
+}
+
+/*
+ * Returns the year of the date made of the numbers a, b and c, in the order
+ * they are in the password, and whether they make a date at all.  The year
+ * is either first or last and two digit years are between 1951 and 2050.
+ */
+func dateYear(a, b, c int) (int, bool) {
+  if b > 31 || b <= 0 {
+    return 0, false
+  }
+  var over12, over31, under1 int
+  for _, n := range []int{a, b, c} {
+    if n > 99 && n < minDateYear || n > maxDateYear {
+      return 0, false
+    }
+    if n > 31 {
+      over31++
+    }
+    if n > 12 {
+      over12++
+    }
+    if n <= 0 {
+      under1++
+    }
+  }
+  if over31 >= 2 || over12 == 3 || under1 >= 2 {
+    return 0, false
+  }
+  splits := [][3]int{{c, a, b}, {a, b, c}}
+  for _, s := range splits {
+    if s[0] >= minDateYear && s[0] <= maxDateYear {
+      return s[0], dayMonth(s[1], s[2])
+    }
+  }
+  for _, s := range splits {
+    if dayMonth(s[1], s[2]) {
+      if s[0] > 99 {
+        return s[0], true
+      } else if s[0] > 50 {
+        return s[0] + 1900, true
+      }
+      return s[0] + 2000, true
+    }
+  }
+  return 0, false
+}
+
+/* Returns whether a and b are a day and a month in either order. */
+func dayMonth(a, b int) bool {
+  return a >= 1 && a <= 31 && b >= 1 && b <= 12 ||
+    b >= 1 && b <= 31 && a >= 1 && a <= 12
+}
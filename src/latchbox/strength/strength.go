@@ -0,0 +1,420 @@
+/*-
+ * Copyright (C) 2014-2017, Vi Grey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY AUTHOR AND CONTRIBUTORS "AS IS" AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL AUTHOR OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+/*
+ * Estimates how many guesses it takes to crack a password the way zxcvbn
+ * does.  A password is split into the sequence of patterns (dictionary
+ * words, keyboard walks, repeats, sequences, years, dates and bruteforced
+ * characters in between) that is the easiest to guess, and the guesses of
+ * the patterns are multiplied together.
+ */
+
+package strength
+
+import (
+  "math"
+  "strconv"
+  "time"
+  "unicode"
+)
+
+const (
+  /* Only the first maxLength characters of a password are looked at. */
+  maxLength = 100
+  bruteforceCardinality = 10
+  minGuessesBeforeGrowingSequence = 10000
+  minSubmatchGuessesSingleChar = 10
+  minSubmatchGuessesMultiChar = 50
+  minYearSpace = 20
+)
+
+/* Names of the scores of Result, from 0 to 4. */
+var scoreNames = []string{"Very Weak", "Weak", "Fair", "Strong",
+                          "Very Strong"}
+
+/* How hard a password is to guess. */
+type Result struct {
+  /* Estimated number of guesses it takes to crack the password. */
+  Guesses float64
+  /* Score from 0 (too guessable) to 4 (very unguessable). */
+  Score int
+}
+
+/*
+ * Returns how hard password is to guess.  userInputs are words an attacker
+ * would try first, like the name and username of an entry.
+ */
+func Estimate(password string, userInputs []string) Result {
+  pw := []rune(password)
+  if len(pw) > maxLength {
+    pw = pw[:maxLength]
+  }
+  loadDictionaries()
+  dicts := append([]*dictionary{userDictionary(userInputs)},
+                  dictionaries...)
+  guesses := mostGuessable(pw, omnimatch(pw, dicts))
+  return Result{Guesses: guesses, Score: Score(guesses)}
+}
+
+/* Returns the name of r's score, like "Very Weak". */
+func (r Result) Rating() string {
+  return ScoreName(r.Score)
+}
+
+/* Returns the name of score, which is from 0 to 4. */
+func ScoreName(score int) string {
+  return scoreNames[score]
+}
+
+/*
+ * Returns about how long it takes to crack the password of r at
+ * guessesPerSecond guesses per second, like "3 Hours".
+ */
+func (r Result) CrackTime(guessesPerSecond float64) string {
+  return displayTime(r.Guesses / guessesPerSecond)
+}
+
+/* Returns the year years in passwords are compared to. */
+func referenceYear() int {
+  return time.Now().Year()
+}
+
+/* Returns the score from 0 to 4 of a password that takes guesses guesses. */
+func Score(guesses float64) int {
+  const delta = 5
+  if guesses < 1e3 + delta {
+    return 0
+  } else if guesses < 1e6 + delta {
+    return 1
+  } else if guesses < 1e8 + delta {
+    return 2
+  } else if guesses < 1e10 + delta {
+    return 3
+  }
+  return 4
+}
+
+/* Returns seconds as a rounded amount of the largest fitting time unit. */
+func displayTime(seconds float64) string {
+  units := []struct {
+    seconds float64
+    name string
+  }{
+    {60 * 60 * 24 * 365 * 100, ""},
+    {60 * 60 * 24 * 365, "Year"},
+    {60 * 60 * 24 * 31, "Month"},
+    {60 * 60 * 24, "Day"},
+    {60 * 60, "Hour"},
+    {60, "Minute"},
+    {1, "Second"},
+  }
+  if seconds < 1 {
+    return "Less Than a Second"
+  } else if seconds >= units[0].seconds {
+    return "Centuries"
+  }
+  for _, u := range units[1:] {
+    if seconds >= u.seconds {
+      n := int(math.Floor(seconds / u.seconds + 0.5))
+      if n == 1 {
+        return "1 " + u.name
+      }
+      return strconv.Itoa(n) + " " + u.name + "s"
+    }
+  }
+  return ""
+}
+
+/*
+ * Returns the fewest guesses it takes to guess pw as a sequence of
+ * non-overlapping matches from matches with bruteforced characters between
+ * them.  Like zxcvbn, sequences of more matches are penalized so one long
+ * match is preferred over several short ones.
+ */
+func mostGuessable(pw []rune, matches []*match) float64 {
+  n := len(pw)
+  year := referenceYear()
+  if n == 0 {
+    return 1
+  }
+  byEnd := make([][]*match, n)
+  for _, m := range matches {
+    byEnd[m.j] = append(byEnd[m.j], m)
+  }
+  /*
+   * best[k][l] is the last match of the best sequence of l matches that
+   * covers pw[:k + 1], pi[k][l] is the product of their guesses and
+   * g[k][l] is the guesses of the whole sequence.
+   */
+  best := make([]map[int]*match, n)
+  pi := make([]map[int]float64, n)
+  g := make([]map[int]float64, n)
+  for k := range best {
+    best[k] = make(map[int]*match)
+    pi[k] = make(map[int]float64)
+    g[k] = make(map[int]float64)
+  }
+  update := func(m *match, l int) {
+    k := m.j
+    p := m.estimate(n, year)
+    if l > 1 {
+      p *= pi[m.i - 1][l - 1]
+    }
+    guesses := factorial(l) * p +
+      math.Pow(minGuessesBeforeGrowingSequence, float64(l - 1))
+    for otherL, otherG := range g[k] {
+      if otherL <= l && otherG <= guesses {
+        return
+      }
+    }
+    best[k][l], pi[k][l], g[k][l] = m, p, guesses
+  }
+  for k := 0; k < n; k++ {
+    for _, m := range byEnd[k] {
+      if m.i > 0 {
+        for l := range best[m.i - 1] {
+          update(m, l + 1)
+        }
+      } else {
+        update(m, 1)
+      }
+    }
+    update(bruteforceMatch(pw, 0, k), 1)
+    for i := 1; i <= k; i++ {
+      m := bruteforceMatch(pw, i, k)
+      for l, last := range best[i - 1] {
+        /* Bruteforce matches next to each other are one match. */
+        if last.pattern != bruteforcePattern {
+          update(m, l + 1)
+        }
+      }
+    }
+  }
+  guesses := math.Inf(1)
+  for _, otherG := range g[n - 1] {
+    guesses = math.Min(guesses, otherG)
+  }
+  return guesses
+}
+
+/*
+ * Returns the guesses it takes to guess m by itself in a password that is n
+ * characters long when it is year.
+ */
+func (m *match) estimate(n, year int) float64 {
+  if m.guesses != 0 {
+    return m.guesses
+  }
+  length := m.j - m.i + 1
+  minGuesses := 1.0
+  if length < n {
+    if length == 1 {
+      minGuesses = minSubmatchGuessesSingleChar
+    } else {
+      minGuesses = minSubmatchGuessesMultiChar
+    }
+  }
+  var guesses float64
+  switch m.pattern {
+  case bruteforcePattern:
+    guesses = bruteforceGuesses(length)
+  case dictionaryPattern:
+    guesses = float64(m.rank) * uppercaseVariations(m.token) *
+      l33tVariations(m)
+    if m.reversed {
+      guesses *= 2
+    }
+  case spatialPattern:
+    guesses = spatialGuesses(m)
+  case repeatPattern:
+    guesses = m.baseGuesses * float64(m.repeatCount)
+  case sequencePattern:
+    guesses = sequenceGuesses(m)
+  case yearPattern:
+    guesses = yearSpace(m.year, year)
+  case datePattern:
+    guesses = yearSpace(m.year, year) * 365
+    if m.separator != "" {
+      guesses *= 4
+    }
+  }
+  m.guesses = math.Max(guesses, minGuesses)
+  return m.guesses
+}
+
+/* Returns the guesses it takes to bruteforce length characters. */
+func bruteforceGuesses(length int) float64 {
+  guesses := math.Pow(bruteforceCardinality, float64(length))
+  if length == 1 {
+    return math.Max(guesses, minSubmatchGuessesSingleChar + 1)
+  }
+  return math.Max(guesses, minSubmatchGuessesMultiChar + 1)
+}
+
+/* Returns how many years from year to guess from the current year now. */
+func yearSpace(year, now int) float64 {
+  space := year - now
+  if space < 0 {
+    space = -space
+  }
+  if space < minYearSpace {
+    space = minYearSpace
+  }
+  return float64(space)
+}
+
+/*
+ * Returns how many ways the letters of a dictionary word could be
+ * capitalized to make token.  Capitalizing the first or last letter or all
+ * letters is guessed first.
+ */
+func uppercaseVariations(token string) float64 {
+  var upper, lower int
+  for _, r := range token {
+    if unicode.IsUpper(r) {
+      upper++
+    } else if unicode.IsLower(r) {
+      lower++
+    }
+  }
+  if upper == 0 {
+    return 1
+  }
+  runes := []rune(token)
+  first, last := runes[0], runes[len(runes) - 1]
+  if lower == 0 || upper == 1 && (unicode.IsUpper(first) &&
+      len(runes) > 1 || unicode.IsUpper(last)) {
+    return 2
+  }
+  return variations(upper, lower)
+}
+
+/* Returns how many ways l33t substitutions could have made the token of m. */
+func l33tVariations(m *match) float64 {
+  if !m.l33t {
+    return 1
+  }
+  result := 1.0
+  for subbed, unsubbed := range m.sub {
+    var s, u int
+    for _, r := range m.token {
+      r = unicode.ToLower(r)
+      if r == subbed {
+        s++
+      } else if r == unsubbed {
+        u++
+      }
+    }
+    if s == 0 || u == 0 {
+      result *= 2
+    } else {
+      result *= variations(s, u)
+    }
+  }
+  return result
+}
+
+/*
+ * Returns how many ways up to min(a, b) of a + b things can be chosen, which
+ * is how many ways a things could have been changed from b things without
+ * changing more than half of them.
+ */
+func variations(a, b int) float64 {
+  result := 0.0
+  for i := 1; i <= a && i <= b; i++ {
+    result += nCk(a + b, i)
+  }
+  return result
+}
+
+/* Returns the guesses it takes to guess the keyboard walk m. */
+func spatialGuesses(m *match) float64 {
+  layout := m.keyboard
+  length := m.j - m.i + 1
+  guesses := 0.0
+  for i := 2; i <= length; i++ {
+    possibleTurns := m.turns
+    if possibleTurns > i - 1 {
+      possibleTurns = i - 1
+    }
+    for j := 1; j <= possibleTurns; j++ {
+      guesses += nCk(i - 1, j - 1) * layout.startingPositions *
+        math.Pow(layout.averageDegree, float64(j))
+    }
+  }
+  if m.shifted > 0 {
+    unshifted := length - m.shifted
+    if unshifted == 0 {
+      guesses *= 2
+    } else {
+      guesses *= variations(m.shifted, unshifted)
+    }
+  }
+  return guesses
+}
+
+/* Returns the guesses it takes to guess the character sequence m. */
+func sequenceGuesses(m *match) float64 {
+  first := []rune(m.token)[0]
+  var base float64
+  switch {
+  case first == 'a' || first == 'A' || first == 'z' || first == 'Z' ||
+       first == '0' || first == '1' || first == '9':
+    base = 4
+  case unicode.IsDigit(first):
+    base = 10
+  default:
+    base = 26
+  }
+  if !m.ascending {
+    base *= 2
+  }
+  return base * float64(m.j - m.i + 1)
+}
+
+/* Returns how many ways k things can be chosen from n things. */
+func nCk(n, k int) float64 {
+  if k > n {
+    return 0
+  }
+  result := 1.0
+  for d := 1; d <= k; d++ {
+    result *= float64(n)
+    result /= float64(d)
+    n--
+  }
+  return result
+}
+
+/* Returns n factorial. */
+func factorial(n int) float64 {
+  result := 1.0
+  for i := 2; i <= n; i++ {
+    result *= float64(i)
+  }
+  return result
+}
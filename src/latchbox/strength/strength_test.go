@@ -0,0 +1,178 @@
+/*-
+ * Copyright (C) 2014-2017, Vi Grey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY AUTHOR AND CONTRIBUTORS "AS IS" AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL AUTHOR OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+/*
+ * Tests that pin the guesses and scores of the estimator for passwords that
+ * each depend on a different matcher, so a change to a matcher or to the
+ * scoring shows up as a failure.
+ */
+
+package strength
+
+import (
+  "math"
+  "testing"
+)
+
+/* Returns whether a is within 0.1% of b. */
+func closeGuesses(a, b float64) bool {
+  return math.Abs(a - b) <= b / 1000
+}
+
+func TestEstimate(t *testing.T) {
+  tests := []struct {
+    password string
+    guesses float64
+    score int
+  }{
+    /* Common passwords. */
+    {"password", 2, 0},
+    {"123456", 3, 0},
+    /* Reversed and l33t dictionary words. */
+    {"drowssap", 3, 0},
+    {"p@ssw0rd", 5, 0},
+    {"P4ssw0rd", 9, 0},
+    {"Tr0ub4dour&3", 7.081e11, 4},
+    /* Keyboard walks. */
+    {"zxcvbnm", 177, 0},
+    {"1qaz2wsx", 504, 0},
+    {"qwertyuiop", 1545, 1},
+    /* Repeats. */
+    {"aaaaaaaa", 49, 0},
+    {"abababab", 37, 0},
+    /* Sequences. */
+    {"abcdefgh", 33, 0},
+    {"zyxwv", 41, 0},
+    {"13579", 21, 0},
+    /* Names. */
+    {"jessica", 27, 0},
+    /* Words that aren't passwords on their own. */
+    {"correcthorsebatterystaple", 5.394e16, 4},
+    /* Random characters. */
+    {"x9#Lq2!vZ7@mK4$w", 1e16, 4},
+    {"", 1, 0},
+  }
+  for _, test := range tests {
+    r := Estimate(test.password, nil)
+    if !closeGuesses(r.Guesses, test.guesses) || r.Score != test.score {
+      t.Errorf("Estimate(%q) = %g guesses, score %d, want %g guesses, " +
+               "score %d", test.password, r.Guesses, r.Score, test.guesses,
+               test.score)
+    }
+  }
+}
+
+/*
+ * Dates and years are guessed by how far they are from the current year, so
+ * their guesses are checked against a fixed year.
+ */
+func TestDates(t *testing.T) {
+  tests := []struct {
+    password string
+    pattern int
+    guesses float64
+  }{
+    {"11/05/1991", datePattern, 26 * 365 * 4},
+    {"1991-05-11", datePattern, 26 * 365 * 4},
+    {"110591", datePattern, 26 * 365},
+    {"1991", yearPattern, 26},
+    {"2015", yearPattern, 20},
+  }
+  loadDictionaries()
+  for _, test := range tests {
+    pw := []rune(test.password)
+    var found bool
+    for _, m := range omnimatch(pw, dictionaries) {
+      if m.pattern == test.pattern && m.i == 0 && m.j == len(pw) - 1 {
+        found = true
+        if guesses := m.estimate(len(pw), 2017); guesses != test.guesses {
+          t.Errorf("%q guessed in %g, want %g", test.password, guesses,
+                   test.guesses)
+        }
+      }
+    }
+    if !found {
+      t.Errorf("%q not matched as a date or year", test.password)
+    }
+  }
+  if r := Estimate("11/05/1991", nil); r.Score > 1 {
+    t.Errorf("Estimate(\"11/05/1991\") score = %d, want at most 1", r.Score)
+  }
+}
+
+/* The matchers each find the whole of a password made for them. */
+func TestMatchers(t *testing.T) {
+  tests := []struct {
+    password string
+    pattern int
+  }{
+    {"p@ssw0rd", dictionaryPattern},
+    {"qwertyuiop", spatialPattern},
+    {"7896321", spatialPattern},
+    {"aaaaaaaa", repeatPattern},
+    {"abcdefgh", sequencePattern},
+  }
+  loadDictionaries()
+  for _, test := range tests {
+    pw := []rune(test.password)
+    var found bool
+    for _, m := range omnimatch(pw, dictionaries) {
+      if m.pattern == test.pattern && m.i == 0 && m.j == len(pw) - 1 {
+        found = true
+      }
+    }
+    if !found {
+      t.Errorf("%q not matched whole by pattern %d", test.password,
+               test.pattern)
+    }
+  }
+}
+
+/* Words of the user inputs are guessed before anything else. */
+func TestUserInputs(t *testing.T) {
+  without := Estimate("hunter2vaultkeeper", nil)
+  with := Estimate("hunter2vaultkeeper", []string{"vaultkeeper"})
+  if with.Guesses >= without.Guesses {
+    t.Errorf("user input vaultkeeper didn't make hunter2vaultkeeper " +
+             "weaker: %g >= %g", with.Guesses, without.Guesses)
+  }
+}
+
+func TestScore(t *testing.T) {
+  tests := []struct {
+    guesses float64
+    score int
+  }{
+    {1, 0}, {1e3 + 5, 1}, {1e6 + 5, 2}, {1e8 + 5, 3}, {1e10 + 5, 4},
+    {1e3 + 4, 0}, {1e10 + 4, 3},
+  }
+  for _, test := range tests {
+    if score := Score(test.guesses); score != test.score {
+      t.Errorf("Score(%g) = %d, want %d", test.guesses, score, test.score)
+    }
+  }
+}
@@ -0,0 +1,242 @@
+/*-
+ * Copyright (C) 2014-2017, Vi Grey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY AUTHOR AND CONTRIBUTORS "AS IS" AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL AUTHOR OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+/*
+ * Keeps several unlocked password files open at once as tabs.  The globals
+ * fPath, vlt, backupContents, backupSaved, dirty, readOnly, undoSteps,
+ * redoSteps, tagFilter and favoritesOnly always hold the state of the
+ * current tab; storeTab and loadTab move that state in and out of tabs when
+ * switching.  Also keeps the list of recently opened password
+ * files.
+ */
+
+package main
+
+import (
+  "errors"
+  "io/ioutil"
+  "latchbox/vault"
+  "path/filepath"
+  "strconv"
+  "strings"
+)
+
+/* Most recently opened password files shown on the Welcome screen. */
+const maxRecent = 9
+
+type tab struct {
+  path string
+  vault *vault.Vault
+  backupContents []byte
+  backupSaved, dirty, readOnly bool
+  undoSteps, redoSteps []undoStep
+  tagFilter string
+  favoritesOnly bool
+}
+
+/* Copy the state of the current tab from the globals into tabs. */
+func storeTab() {
+  if currentTab >= len(tabs) {
+    return
+  }
+  t := tabs[currentTab]
+  t.path = fPath
+  t.vault = vlt
+  t.backupContents = backupContents
+  t.backupSaved = backupSaved
+  t.dirty = dirty
+  t.readOnly = readOnly
+  t.undoSteps = undoSteps
+  t.redoSteps = redoSteps
+  t.tagFilter = tagFilter
+  t.favoritesOnly = favoritesOnly
+}
+
+/* Make tab x the current tab and copy its state into the globals. */
+func loadTab(x int) {
+  currentTab = x
+  t := tabs[x]
+  fPath = t.path
+  vlt = t.vault
+  backupContents = t.backupContents
+  backupSaved = t.backupSaved
+  dirty = t.dirty
+  readOnly = t.readOnly
+  undoSteps = t.undoSteps
+  redoSteps = t.redoSteps
+  tagFilter = t.tagFilter
+  favoritesOnly = t.favoritesOnly
+}
+
+/*
+ * Add the password file that was just opened or created as a new tab and
+ * remember it in the recent files list.
+ */
+func addTab() {
+  tabs = append(tabs, &tab{})
+  currentTab = len(tabs) - 1
+  storeTab()
+  openingTab = false
+  addRecent(fPath)
+}
+
+/* Store the current tab and clear the globals to open another file. */
+func openAnotherTab() {
+  storeTab()
+  openingTab = true
+  backupContents = make([]byte, 0)
+  backupSaved = false
+  dirty = false
+  readOnly = false
+  undoSteps = nil
+  redoSteps = nil
+  tagFilter = ""
+  favoritesOnly = false
+}
+
+/* Go back to the current tab if opening another file was cancelled. */
+func cancelOpenTab() {
+  openingTab = false
+  loadTab(currentTab)
+}
+
+/* Returns the number of the tab path is open in, or -1. */
+func tabOpen(path string) int {
+  path, _ = filepath.Abs(path)
+  for x, t := range tabs {
+    if tPath, _ := filepath.Abs(t.path); tPath == path {
+      return x
+    }
+  }
+  return -1
+}
+
+/*
+ * Lock the current tab and switch to the one next to it.  Locking the last
+ * tab brings the user back to the Welcome menu.
+ */
+func lockTab() {
+  path := fPath
+  tabs = append(tabs[:currentTab], tabs[currentTab + 1:]...)
+  if len(tabs) == 0 {
+    lock()
+    return
+  }
+  if currentTab >= len(tabs) {
+    currentTab = len(tabs) - 1
+  }
+  loadTab(currentTab)
+  contentString = path + " Was Locked"
+}
+
+/* Returns a line listing the open tabs with the current one in brackets. */
+func tabBar() string {
+  labels := make([]string, 0)
+  for x, t := range tabs {
+    label := strconv.Itoa(x + 1) + ":" + filepath.Base(t.path)
+    if x == currentTab && !openingTab && dirty || x != currentTab && t.dirty {
+      label += "*"
+    }
+    if x == currentTab {
+      label = "[" + label + "]"
+    }
+    labels = append(labels, label)
+  }
+  return strings.Join(labels, "  ")
+}
+
+/*
+ * Copy e into the password file open in tab target and save it.  If move is
+ * true, e is then removed from the current password file.
+ */
+func transferEntry(e *vault.Entry, target int, move bool) error {
+  if tabs[target].readOnly {
+    return errors.New(tabs[target].path + " Is Read-Only")
+  }
+  source := currentTab
+  entry := e.Copy()
+  storeTab()
+  loadTab(target)
+  before := vlt.Snapshot()
+  err := vlt.Add(entry)
+  if err == nil {
+    if writeData() == nil {
+      addUndo("Copy of " + entry.NameGroup(), before)
+    } else {
+      vlt.Revert(before)
+      err = errors.New("Unable to Modify " + fPath + " (Write Error)")
+    }
+  } else if err == vault.ErrDuplicate {
+    err = errors.New("Duplicate Name/Group Combination in " + fPath)
+  }
+  storeTab()
+  loadTab(source)
+  if err != nil || !move {
+    return err
+  }
+  before = vlt.Snapshot()
+  vlt.Remove(e)
+  if writeData() != nil {
+    vlt.Revert(before)
+    return errors.New("Unable to Modify Password File (Write Error)")
+  }
+  addUndo("Move of " + e.NameGroup(), before)
+  return nil
+}
+
+/* Returns the recently opened password files, most recent first. */
+func readRecent() []string {
+  recent := make([]string, 0)
+  content, err := ioutil.ReadFile(configDir + "recent")
+  if err != nil {
+    return recent
+  }
+  for _, line := range strings.Split(string(content), "\n") {
+    if line != "" && len(recent) < maxRecent {
+      recent = append(recent, line)
+    }
+  }
+  return recent
+}
+
+/* Move path to the top of the recently opened password files. */
+func addRecent(path string) {
+  if path == "" {
+    return
+  }
+  if abs, err := filepath.Abs(path); err == nil {
+    path = abs
+  }
+  recent := []string{path}
+  for _, r := range readRecent() {
+    if r != path && len(recent) < maxRecent {
+      recent = append(recent, r)
+    }
+  }
+  ioutil.WriteFile(configDir + "recent",
+    []byte(strings.Join(recent, "\n") + "\n"), 0600)
+}
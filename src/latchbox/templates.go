@@ -0,0 +1,312 @@
+/*-
+ * Copyright (C) 2014-2017, Vi Grey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY AUTHOR AND CONTRIBUTORS "AS IS" AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL AUTHOR OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+/*
+ * Entry types and the templates that say which values are asked for when
+ * making an entry of each type and how those values are checked.
+ */
+
+package main
+
+import (
+  "errors"
+  "latchbox/vault"
+  "strconv"
+  "strings"
+  "time"
+)
+
+/*
+ * Built in templates.  A template is its name followed by a colon and a
+ * comma separated list of the values it asks for in order.  username,
+ * password, email and url are the values every entry has.  Any other value
+ * is a custom field, which is concealed if it ends with "*", required if it
+ * ends with "!" and checked by one of templateChecks if it ends with ":"
+ * followed by the name of the check.
+ */
+var builtinTemplates = []string{
+  "login: username, password, email, url",
+  "note:",
+  "card: Cardholder!, Number*!:luhn, Expiry!:expiry, CVV*:digits",
+  "identity: Full Name!, email, Address, Phone",
+  "server: Host!:host, Port:port, username, password, url",
+}
+
+/* Values every entry has that a template can ask for and their labels. */
+var standardValues = []string{"username", "password", "email", "url"}
+var standardLabels = []string{"Username", "Password", "Email", "URL"}
+
+/* A custom field that a template asks for. */
+type templateField struct {
+  name, check string
+  concealed, required bool
+}
+
+/* A template for an entry type. */
+type template struct {
+  name string
+  standard map[string]bool
+  fields []templateField
+}
+
+/* Checks of custom field values that templates can use. */
+var templateChecks = map[string]func(string) error{
+  "digits": checkDigits,
+  "expiry": checkExpiry,
+  "host": checkHost,
+  "luhn": checkLuhn,
+  "port": checkPort,
+}
+
+/* Parses the template definition (see builtinTemplates). */
+func parseTemplate(definition string) (*template, error) {
+  colon := strings.Index(definition, ":")
+  if colon < 1 {
+    return nil, errors.New("Template Name Required")
+  }
+  t := &template{name: strings.ToLower(strings.TrimSpace(
+    definition[:colon])), standard: make(map[string]bool)}
+  if len(t.name) > 255 {
+    return nil, errors.New("Template Name Too Long")
+  }
+  seen := make(map[string]bool)
+  for _, value := range strings.Split(definition[colon + 1:], ",") {
+    value = strings.TrimSpace(value)
+    if value == "" {
+      continue
+    }
+    if inList(standardValues, strings.ToLower(value)) {
+      t.standard[strings.ToLower(value)] = true
+      continue
+    }
+    f := templateField{}
+    if check := strings.LastIndex(value, ":"); check >= 0 {
+      f.check = strings.ToLower(strings.TrimSpace(value[check + 1:]))
+      if templateChecks[f.check] == nil {
+        return nil, errors.New("Unknown Check \"" + f.check + "\"")
+      }
+      value = strings.TrimSpace(value[:check])
+    }
+    for len(value) > 0 && strings.ContainsAny(value[len(value) - 1:],
+                                              "*!") {
+      if value[len(value) - 1] == '*' {
+        f.concealed = true
+      } else {
+        f.required = true
+      }
+      value = strings.TrimSpace(value[:len(value) - 1])
+    }
+    f.name = value
+    if err := (vault.Field{Name: f.name}).Validate(); err != nil {
+      return nil, err
+    } else if seen[f.name] {
+      return nil, errors.New("Duplicate Field Name " + f.name)
+    }
+    seen[f.name] = true
+    t.fields = append(t.fields, f)
+  }
+  return t, nil
+}
+
+/*
+ * Makes templates the built in templates.  Templates from the config file
+ * are added after this with addTemplate.
+ */
+func loadTemplates() {
+  templates = make([]*template, 0)
+  for _, definition := range builtinTemplates {
+    t, _ := parseTemplate(definition)
+    templates = append(templates, t)
+  }
+}
+
+/* Adds t to templates, replacing the template with the same name. */
+func addTemplate(t *template) {
+  for x := range templates {
+    if templates[x].name == t.name {
+      templates[x] = t
+      return
+    }
+  }
+  templates = append(templates, t)
+}
+
+/*
+ * Returns the template of entry type entryType, which is the login template
+ * for an empty type, or nil if there is no such template.
+ */
+func templateNamed(entryType string) *template {
+  if entryType == "" {
+    entryType = "login"
+  }
+  for _, t := range templates {
+    if t.name == entryType {
+      return t
+    }
+  }
+  return nil
+}
+
+/* Returns the type of e to show. */
+func typeName(e *vault.Entry) string {
+  if e.Type == "" {
+    return "login"
+  }
+  return e.Type
+}
+
+/* Returns the entry type to save for template t. */
+func (t *template) entryType() string {
+  if t.name == "login" {
+    return ""
+  }
+  return t.name
+}
+
+/* Returns the custom field of t called name, or nil if there isn't one. */
+func (t *template) field(name string) *templateField {
+  if t == nil {
+    return nil
+  }
+  for x := range t.fields {
+    if t.fields[x].name == name {
+      return &t.fields[x]
+    }
+  }
+  return nil
+}
+
+/*
+ * Returns a line describing the values t asks for in the order the NEW menu
+ * asks for them.  Custom fields are asked for after the group.
+ */
+func (t *template) describe() string {
+  var values []string
+  for x, s := range standardValues {
+    if t.standard[s] {
+      values = append(values, standardLabels[x])
+    }
+  }
+  for _, f := range t.fields {
+    values = append(values, f.name)
+  }
+  if len(values) == 0 {
+    return t.name + " (Comment Only)"
+  }
+  return t.name + " (" + strings.Join(values, ", ") + ")"
+}
+
+/*
+ * Returns an error if value can't be used for custom field f.  Empty values
+ * are only checked for being required.
+ */
+func (f *templateField) checkValue(value string) error {
+  if value == "" {
+    if f.required {
+      return errors.New(f.name + " Required")
+    }
+    return nil
+  } else if len(value) > 65535 {
+    return errors.New("Field Value Too Long")
+  } else if f.check != "" {
+    return templateChecks[f.check](value)
+  }
+  return nil
+}
+
+/*
+ * Returns an error if value can't be used for the custom field called name
+ * of an entry of type entryType.
+ */
+func checkTemplateField(entryType, name, value string) error {
+  if f := templateNamed(entryType).field(name); f != nil {
+    return f.checkValue(value)
+  }
+  return nil
+}
+
+/* Returns an error unless value is only the ASCII digits 0 to 9. */
+func checkDigits(value string) error {
+  for _, r := range value {
+    if r < '0' || r > '9' {
+      return errors.New("Only Digits Allowed")
+    }
+  }
+  return nil
+}
+
+/*
+ * Checks a card number with the Luhn algorithm.  Spaces and dashes between
+ * digits are ignored.
+ */
+func checkLuhn(value string) error {
+  number := strings.NewReplacer(" ", "", "-", "").Replace(value)
+  digits := []rune(number)
+  if len(digits) < 12 || len(digits) > 19 || checkDigits(number) != nil {
+    return errors.New("Card Number Must be 12 to 19 Digits")
+  }
+  var sum int
+  for x := 0; x < len(digits); x++ {
+    digit := int(digits[len(digits) - 1 - x] - '0')
+    if x % 2 == 1 {
+      digit *= 2
+      if digit > 9 {
+        digit -= 9
+      }
+    }
+    sum += digit
+  }
+  if sum % 10 != 0 {
+    return errors.New("Invalid Card Number (Luhn Check Failed)")
+  }
+  return nil
+}
+
+/* Checks an expiry date in the form MM/YY or MM/YYYY. */
+func checkExpiry(value string) error {
+  for _, layout := range []string{"01/06", "01/2006"} {
+    if _, err := time.Parse(layout, value); err == nil {
+      return nil
+    }
+  }
+  return errors.New("Expiry Date Must be MM/YY or MM/YYYY")
+}
+
+func checkHost(value string) error {
+  if strings.ContainsAny(value, " \t/") {
+    return errors.New("Invalid Host")
+  }
+  return nil
+}
+
+func checkPort(value string) error {
+  port, err := strconv.Atoi(value)
+  if err != nil || port < 1 || port > 65535 {
+    return errors.New("Port Must be Between 1 and 65535")
+  }
+  return nil
+}
@@ -0,0 +1,94 @@
+/*-
+ * Copyright (C) 2014-2017, Vi Grey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY AUTHOR AND CONTRIBUTORS "AS IS" AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL AUTHOR OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+/*
+ * Handles the trash of the open password file, which keeps deleted entries
+ * until they are restored or purged.
+ */
+
+package main
+
+import (
+  "latchbox/vault"
+  "sort"
+  "strconv"
+  "strings"
+  "time"
+)
+
+/* Returns the entries in the trash, most recently deleted first. */
+func sortedTrash() []*vault.Entry {
+  trash := vault.Sort(vlt.Trash)
+  sort.SliceStable(trash, func(x, y int) bool {
+    return trash[x].Deleted.After(trash[y].Deleted)
+  })
+  return trash
+}
+
+/* Returns the entry with number trashNumber in the list of the TRASH menu. */
+func selectedTrash() *vault.Entry {
+  return sortedTrash()[trashNumber - 1]
+}
+
+/*
+ * Purges the entries that have been in the trash for more than
+ * trashPurgeDays days and saves the password file if any were purged.
+ * Nothing is purged if trashPurgeDays is 0 or the password file is
+ * read-only.  Returns a message saying how many entries were purged.
+ */
+func purgeOldTrash() string {
+  if trashPurgeDays == 0 || readOnly {
+    return ""
+  }
+  purged := vlt.PurgeTrash(time.Now().AddDate(0, 0, -trashPurgeDays))
+  if purged == 0 {
+    return ""
+  }
+  if err := writeData(); err != nil {
+    return "Unable to Purge Old Entries From the Trash (" + err.Error() + ")"
+  }
+  return "Purged " + strconv.Itoa(purged) + " Entries Deleted More Than " +
+    strconv.Itoa(trashPurgeDays) + " Days Ago From the Trash"
+}
+
+/* Returns the values of the entry e in the trash for the TRASH menu. */
+func trashContent(e *vault.Entry) string {
+  content := "Name: " + e.Name + "\n"
+  content += "Type: " + typeName(e) + "\n"
+  content += "Username: " + e.Username + "\n"
+  content += "Email: " + e.Email + "\n"
+  content += "URL: " + e.URL + "\n"
+  content += "Group: " + e.Group + "\n"
+  if len(e.Tags) > 0 {
+    content += "Tags: " + strings.Join(e.Tags, ", ") + "\n"
+  }
+  content += "Comment: " + e.Comment + "\n"
+  content += "\nCreated: " + e.Created.Format(timeLayout) + "\n"
+  content += "Modified: " + e.Modified.Format(timeLayout) + "\n"
+  content += "Deleted: " + e.Deleted.Format(timeLayout) + "\n"
+  return content
+}
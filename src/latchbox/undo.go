@@ -0,0 +1,106 @@
+/*-
+ * Copyright (C) 2014-2017, Vi Grey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY AUTHOR AND CONTRIBUTORS "AS IS" AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL AUTHOR OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+/*
+ * Undo and redo of changes to the entries of the open password file.  Each
+ * change saves a snapshot of the password file from before it, and undoing
+ * or redoing goes back to a snapshot and saves the password file like any
+ * other change.  Every tab has its own undo and redo steps, which are
+ * forgotten when the password file is locked.
+ */
+
+package main
+
+import (
+  "latchbox/vault"
+)
+
+/* Most changes that can be undone in a row. */
+const maxUndo = 100
+
+/* A change to the password file and the snapshot from before it. */
+type undoStep struct {
+  label string
+  snapshot vault.Snapshot
+}
+
+/*
+ * Remembers the password file as it is now so the change described by
+ * label that is about to be made can be undone.  Makes redoing impossible
+ * until something is undone again.
+ */
+func recordUndo(label string) {
+  addUndo(label, vlt.Snapshot())
+}
+
+/*
+ * Remembers snapshot as the password file from before the change described
+ * by label so it can be undone.
+ */
+func addUndo(label string, snapshot vault.Snapshot) {
+  undoSteps = append(undoSteps, undoStep{label, snapshot})
+  if len(undoSteps) > maxUndo {
+    undoSteps = undoSteps[len(undoSteps) - maxUndo:]
+  }
+  redoSteps = nil
+}
+
+/*
+ * Undoes the last change to the password file and saves it.  Returns a
+ * message saying what was undone.
+ */
+func undo() string {
+  if len(undoSteps) == 0 {
+    return "Nothing to Undo"
+  }
+  step := undoSteps[len(undoSteps) - 1]
+  undoSteps = undoSteps[:len(undoSteps) - 1]
+  redoSteps = append(redoSteps, undoStep{step.label, vlt.Snapshot()})
+  vlt.Revert(step.snapshot)
+  if err := writeData(); err != nil {
+    return "Undid " + step.label + " (Unable to Modify Password File)"
+  }
+  return "Undid " + step.label
+}
+
+/*
+ * Redoes the last change to the password file that was undone and saves
+ * it.  Returns a message saying what was redone.
+ */
+func redo() string {
+  if len(redoSteps) == 0 {
+    return "Nothing to Redo"
+  }
+  step := redoSteps[len(redoSteps) - 1]
+  redoSteps = redoSteps[:len(redoSteps) - 1]
+  undoSteps = append(undoSteps, undoStep{step.label, vlt.Snapshot()})
+  vlt.Revert(step.snapshot)
+  if err := writeData(); err != nil {
+    return "Redid " + step.label + " (Unable to Modify Password File)"
+  }
+  return "Redid " + step.label
+}
@@ -0,0 +1,130 @@
+/*-
+ * Copyright (C) 2014-2017, Vi Grey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY AUTHOR AND CONTRIBUTORS "AS IS" AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL AUTHOR OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+/*
+ * Files attached to entries, which are saved encrypted with the rest of the
+ * password file in extension records of their entries.
+ */
+
+package vault
+
+import (
+  "errors"
+  "strings"
+)
+
+/* Extension record type of an attachment. */
+const attachmentRecord = 3
+
+/* Most bytes a data packet can have, which is the largest 3 byte length. */
+const maxPacket = 0xFFFFFF
+
+/* A file attached to an entry, like a TLS client certificate. */
+type Attachment struct {
+  Name string
+  Data []byte
+}
+
+/* Returns the attachment of e called name, or nil if there isn't one. */
+func (e *Entry) Attachment(name string) *Attachment {
+  for x := range e.Attachments {
+    if e.Attachments[x].Name == name {
+      return &e.Attachments[x]
+    }
+  }
+  return nil
+}
+
+/*
+ * Removes the attachment of e called name and returns false if there isn't
+ * one.
+ */
+func (e *Entry) Detach(name string) bool {
+  for x := range e.Attachments {
+    if e.Attachments[x].Name == name {
+      e.Attachments = append(e.Attachments[:x], e.Attachments[x + 1:]...)
+      return true
+    }
+  }
+  return false
+}
+
+/* Returns an error if a can't be saved as an attachment. */
+func (a Attachment) Validate() error {
+  if len(a.Name) == 0 {
+    return errors.New("Attachment Name Required")
+  } else if len(a.Name) > 255 {
+    return errors.New("Attachment Name Too Long")
+  } else if strings.Contains(a.Name, "/") {
+    return errors.New("Invalid Character \"/\"")
+  }
+  return nil
+}
+
+/*
+ * Returns an error if the attachments of e can't be saved or if e is too big
+ * to fit in a single data packet.
+ */
+func (e *Entry) validateAttachments() error {
+  names := make(map[string]bool)
+  for _, a := range e.Attachments {
+    if err := a.Validate(); err != nil {
+      return err
+    }
+    if names[a.Name] {
+      return errors.New("Duplicate Attachment Name " + a.Name)
+    }
+    names[a.Name] = true
+  }
+  /* 26 bytes of lengths, group pointer and timestamps. */
+  size := len(e.Name) + len(e.Username) + len(e.Password) + len(e.Email) +
+      len(e.URL) + len(e.Comment) + 26 + len(e.encodeRecords())
+  if size > maxPacket {
+    return errors.New("Entry Too Large")
+  }
+  return nil
+}
+
+/* Returns the extension record value of a. */
+func (a Attachment) encode() []byte {
+  value := strLenAppend([]byte(a.Name), 1)
+  return append(value, a.Data...)
+}
+
+/* Parses the extension record value of an attachment. */
+func parseAttachment(value []byte) (Attachment, error) {
+  var err bool
+  var pointer int
+  nameLen, name := parseInfo(value, 1, &pointer, &err)
+  if err || nameLen == 0 {
+    return Attachment{}, errors.New("Invalid Attachment")
+  }
+  return Attachment{
+    Name: string(name),
+    Data: append([]byte{}, value[pointer:]...),
+  }, nil
+}
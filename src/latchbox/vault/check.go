@@ -0,0 +1,277 @@
+/*-
+ * Copyright (C) 2014-2017, Vi Grey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY AUTHOR AND CONTRIBUTORS "AS IS" AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL AUTHOR OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+/*
+ * Checks that the decrypted content of a password file follows the LatchBox
+ * file protocol in docs/latchbox-spec.txt.
+ */
+
+package vault
+
+import (
+  "fmt"
+  "strconv"
+  "strings"
+  "time"
+)
+
+/*
+ * A problem found in the decrypted content of a password file and the
+ * offset of the content it was found at, or -1 if it isn't at an offset.
+ */
+type Problem struct {
+  Offset int
+  Message string
+}
+
+func (p Problem) String() string {
+  if p.Offset < 0 {
+    return p.Message
+  }
+  return "Offset " + strconv.Itoa(p.Offset) + ": " + p.Message
+}
+
+/*
+ * Checks the decrypted content of a password file that was decrypted by
+ * Unlock against the LatchBox file protocol and returns every problem
+ * found.  Timestamps after now are reported as being in the future.
+ */
+func (v *Vault) Check(now time.Time) []Problem {
+  if v.content == nil {
+    return []Problem{{-1, ErrLocked.Error()}}
+  }
+  return checkContent(v.content, now)
+}
+
+/*
+ * Checks the decrypted password file content against the LatchBox file
+ * protocol and returns every problem found.
+ */
+func checkContent(content []byte, now time.Time) []Problem {
+  var problems []Problem
+  add := func(offset int, format string, a ...interface{}) {
+    problems = append(problems, Problem{offset, fmt.Sprintf(format, a...)})
+  }
+  if len(content) < 6 {
+    add(0, "Content Is %d Bytes, Too Short for a Version and Group " +
+        "Header Length", len(content))
+    return problems
+  }
+  version := uint16(bytesToNum(content[:2]))
+  if version < 2 || version > ProtocolVersion {
+    add(0, "Protocol Version Is %d, Expected 2 to %d", version,
+        ProtocolVersion)
+  }
+  headerLen := int(bytesToNum(content[2:6]))
+  if 6 + headerLen > len(content) {
+    add(2, "Group Header Length %d Goes Past the End of the Content " +
+        "(%d Bytes Left)", headerLen, len(content) - 6)
+    return problems
+  }
+  /* Group header */
+  pointerNames := make(map[string]string)
+  namePointers := make(map[string]string)
+  groupOffsets := make(map[string]int)
+  usedPointers := make(map[string]bool)
+  for pointer := 6; pointer < 6 + headerLen; {
+    start := pointer
+    if 6 + headerLen - pointer < 2 {
+      add(start, "Group Packet Length Is Cut Off by the End of the Group " +
+          "Header")
+      break
+    }
+    nameLen := int(bytesToNum(content[pointer: pointer + 2]))
+    pointer += 2
+    if pointer + nameLen + 2 > 6 + headerLen {
+      add(start, "Group Packet of Length %d Goes Past the End of the Group " +
+          "Header", nameLen)
+      break
+    }
+    name := string(content[pointer: pointer + nameLen])
+    pointer += nameLen
+    groupPointer := string(content[pointer: pointer + 2])
+    pointer += 2
+    if !ValidGroup(name) {
+      add(start, "Group Name %q Is Not a Valid Group Path", name)
+    }
+    if _, ok := groupOffsets[name]; ok {
+      add(start, "Group Name %q Is Already in the Group Header at Offset %d",
+          name, groupOffsets[name])
+    }
+    if other, ok := pointerNames[groupPointer]; ok {
+      add(start, "Group Pointer %s of Group %q Is Already Used by Group %q",
+          hexBytes(groupPointer), name, other)
+    }
+    if groupPointer == "\x00\x00" && name != "" {
+      add(start, "Group %q Uses Pointer [00 00], Which Means No Group",
+          name)
+    }
+    groupOffsets[name] = start
+    pointerNames[groupPointer] = name
+    namePointers[name] = groupPointer
+  }
+  /* Data content */
+  nameGroupOffsets := make(map[string]int)
+  packetNum := 0
+  pointer := 6 + headerLen
+  for pointer < len(content) {
+    start := pointer
+    packetNum++
+    if len(content) - pointer < 3 {
+      add(start, "%d Bytes of Trailing Garbage After the Last Data Packet",
+          len(content) - pointer)
+      break
+    }
+    packetLen := int(bytesToNum(content[pointer: pointer + 3]))
+    pointer += 3
+    if pointer + packetLen > len(content) {
+      add(start, "Data Packet %d of Length %d Goes Past the End of the " +
+          "Content (%d Bytes of Trailing Garbage)", packetNum, packetLen,
+          len(content) - start)
+      break
+    }
+    packet := content[pointer: pointer + packetLen]
+    pointer += packetLen
+    e, err := parseEntryPacket(packet, namePointers, version)
+    label := "Data Packet " + strconv.Itoa(packetNum)
+    if e.Name != "" {
+      label += " (" + e.Name + ")"
+    }
+    if err != nil && err != errBadGroup && err != errUnknownGroup {
+      add(start, "%s: %s", label, err)
+      continue
+    }
+    groupPointer := groupPointerOf(packet)
+    if err == errUnknownGroup {
+      add(start, "%s: Group Pointer %s Is Not in the Group Header", label,
+          hexBytes(groupPointer))
+    } else if err == errBadGroup {
+      add(start, "%s: Group Pointer %s Points to Invalid Group %q", label,
+          hexBytes(groupPointer), pointerNames[groupPointer])
+    }
+    usedPointers[groupPointer] = true
+    /* Entries in the trash can share a name/group combination. */
+    if other, ok := nameGroupOffsets[e.NameGroup()]; ok &&
+        e.Deleted.IsZero() {
+      add(start, "%s: Name/Group Combination %q Is Already Used by the " +
+          "Data Packet at Offset %d", label, e.NameGroup(), other)
+    } else if e.Deleted.IsZero() {
+      nameGroupOffsets[e.NameGroup()] = start
+    }
+    made, edited := packetTimes(packet)
+    for _, t := range []struct {
+      label string
+      value uint64
+    }{{"Created", made}, {"Modified", edited}} {
+      if t.value == 0 {
+        add(start, "%s: %s Timestamp Is 0", label, t.label)
+      } else if t.value > 253402300799 {
+        add(start, "%s: %s Timestamp %d Is After the Year 9999", label,
+            t.label, t.value)
+      } else if int64(t.value) > now.Unix() {
+        add(start, "%s: %s Timestamp %s Is in the Future", label, t.label,
+            time.Unix(int64(t.value), 0).Format(TimeLayout))
+      }
+    }
+    fieldNames := make(map[string]bool)
+    for _, f := range e.Fields {
+      if fieldNames[f.Name] {
+        add(start, "%s: Custom Field %q Is Used More Than Once", label,
+            f.Name)
+      }
+      fieldNames[f.Name] = true
+    }
+    attachmentNames := make(map[string]bool)
+    for _, a := range e.Attachments {
+      if attachmentNames[a.Name] {
+        add(start, "%s: Attachment %q Is Used More Than Once", label,
+            a.Name)
+      } else if strings.Contains(a.Name, "/") {
+        add(start, "%s: Attachment Name %q Has a \"/\" in It", label,
+            a.Name)
+      }
+      attachmentNames[a.Name] = true
+    }
+    if edited < made {
+      add(start, "%s: Modified Timestamp Is Before Created Timestamp",
+          label)
+    }
+  }
+  for groupPointer, name := range pointerNames {
+    if name != "" && !usedPointers[groupPointer] {
+      add(groupOffsets[name], "Group %q (Pointer %s) Is Not Used by Any " +
+          "Data Packet", name, hexBytes(groupPointer))
+    }
+  }
+  sortProblems(problems)
+  return problems
+}
+
+/* Sorts problems by offset so they are listed in the order of the content. */
+func sortProblems(problems []Problem) {
+  for x := 1; x < len(problems); x++ {
+    for y := x; y > 0 && problems[y].Offset < problems[y - 1].Offset; y-- {
+      problems[y], problems[y - 1] = problems[y - 1], problems[y]
+    }
+  }
+}
+
+/* Returns the group pointer of the data packet packet, which parses. */
+func groupPointerOf(packet []byte) string {
+  pointer := 0
+  pointer += 1 + int(packet[pointer])
+  pointer += 1 + int(packet[pointer])
+  pointer += 2 + int(bytesToNum(packet[pointer: pointer + 2]))
+  pointer += 1 + int(packet[pointer])
+  pointer += 1 + int(packet[pointer])
+  return string(packet[pointer: pointer + 2])
+}
+
+/* Returns the created and modified timestamps of the data packet packet. */
+func packetTimes(packet []byte) (uint64, uint64) {
+  pointer := 0
+  pointer += 1 + int(packet[pointer])
+  pointer += 1 + int(packet[pointer])
+  pointer += 2 + int(bytesToNum(packet[pointer: pointer + 2]))
+  pointer += 1 + int(packet[pointer])
+  pointer += 1 + int(packet[pointer])
+  pointer += 2
+  return bytesToNum(packet[pointer: pointer + 8]),
+    bytesToNum(packet[pointer + 8: pointer + 16])
+}
+
+/* Returns b as hex bytes in the notation of the spec, like [00 7F]. */
+func hexBytes(b string) string {
+  hexString := "["
+  for x := range b {
+    if x > 0 {
+      hexString += " "
+    }
+    hexString += fmt.Sprintf("%02X", b[x])
+  }
+  return hexString + "]"
+}
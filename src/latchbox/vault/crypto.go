@@ -0,0 +1,208 @@
+/*-
+ * Copyright (C) 2014-2017, Vi Grey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY AUTHOR AND CONTRIBUTORS "AS IS" AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL AUTHOR OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+/*
+ * Encrypts and decrypts the content of password files and derives their
+ * keys from passphrases and keyfiles.
+ */
+
+package vault
+
+import (
+  "golang.org/x/crypto/pbkdf2"
+  "golang.org/x/crypto/chacha20poly1305"
+  "crypto/aes"
+  "crypto/cipher"
+  "crypto/hmac"
+  "crypto/rand"
+  "crypto/sha256"
+  "crypto/sha512"
+  "errors"
+  "time"
+)
+
+/*
+ * Generates a key from auth using HMAC-SHA256 based PBKDF2 with iter number of
+ * iterations
+ */
+func DeriveKey(auth, salt []byte, iter uint32) []byte {
+  return pbkdf2.Key(auth, salt, int(iter), 32, sha256.New)
+}
+
+/*
+ * Returns the passphrase to use if a keyfile is included, which is the
+ * HMAC-SHA512 of passphrase with the keyfile content as the secret key.
+ */
+func KeyfilePassphrase(passphrase string, keyfile []byte) string {
+  sig := hmac.New(sha512.New, keyfile)
+  sig.Write([]byte(passphrase))
+  return string(sig.Sum(nil))
+}
+
+/*
+ * Tests to see how many iterations of PBKDF2 need to happen to equal 0.5
+ * seconds.  MinIterations is the lowest possible amount of iterations.
+ */
+func CalibrateIterations() uint32 {
+  var testIter uint32 = 10000
+  var goalTime int64 = 500000000
+  startTime := time.Now()
+  DeriveKey([]byte("Test"), []byte("salt"), testIter)
+  endTime := time.Since(startTime).Nanoseconds()
+  factor := goalTime / endTime
+  var finalIter uint32 = testIter * uint32(factor)
+  if finalIter < MinIterations {
+    finalIter = MinIterations
+  }
+  return finalIter
+}
+
+/*
+ * Encrypts the decrypted password file content with the key derived from
+ * passphrase, cipher ciph and iter PBKDF2 iterations and returns the
+ * encrypted password file content.
+ */
+func Encrypt(content []byte, passphrase string, ciph int,
+             iter uint32) []byte {
+  return encryptFile(content, passphrase, ciph, iter,
+                     bytesToNum(RandomBytes(8)))
+}
+
+/*
+ * Decrypts the encrypted password file content fc with the key derived
+ * from passphrase and returns the decrypted password file content.
+ */
+func Decrypt(fc []byte, passphrase string) ([]byte, error) {
+  ciph, iter, salt, ct, old, err := parseCt(fc)
+  if err != nil {
+    return nil, ErrInvalid
+  }
+  content, _, decrypted := decrypt(ct, DeriveKey([]byte(passphrase), salt,
+                                                 iter), ciph, old)
+  if !decrypted {
+    return nil, ErrPassphrase
+  }
+  return content, nil
+}
+
+/* Generates a random byte array of size length */
+func RandomBytes(size int) []byte {
+  randValue := make([]byte, size)
+  if _, err := rand.Read(randValue); err != nil {
+    panic(err)
+  }
+  return randValue
+}
+
+/*
+ * Encrypts content with the key derived from passphrase and a new salt,
+ * using nonce for the first 8 bytes of the nonce, and prefixes the cipher,
+ * iterations and salt.
+ */
+func encryptFile(content []byte, passphrase string, ciph int, iter uint32,
+                 nonce uint64) []byte {
+  salt := RandomBytes(32)
+  key := DeriveKey([]byte(passphrase), salt, iter)
+  fc := append(numToBytes(ciph, 2), numToBytes(uint64(iter), 4)...)
+  fc = append(fc, salt...)
+  return append(fc, encrypt(content, key, ciph, nonce)...)
+}
+
+/* Returns the AEAD mode of cipher ciph using key. */
+func aead(key []byte, ciph int) cipher.AEAD {
+  if ciph == AES256GCM {
+    block, err := aes.NewCipher(key)
+    if err != nil {
+      panic(err)
+    }
+    mode, err := cipher.NewGCM(block)
+    if err != nil {
+      panic(err)
+    }
+    return mode
+  } else if ciph == CHACHA20POLY1305 {
+    mode, err := chacha20poly1305.New(key)
+    if err != nil {
+      panic(err)
+    }
+    return mode
+  }
+  panic("Invalid Cipher")
+}
+
+// Encrypts plaintext using key and ciph and returns the ciphertext
+func encrypt(message, key []byte, ciph int, nonce uint64) []byte {
+  // Pad nonce value with 4 random bytes to create the iv value
+  iv := append(numToBytes(nonce, 8), RandomBytes(4)...)
+  additionalData := []byte("LatchBox")
+  ciphertext := aead(key, ciph).Seal(nil, iv, message, additionalData)
+  return append(iv, ciphertext...)
+}
+
+/*
+ * Decrypts ciphertext using key and ciph and returns the plaintext, the
+ * nonce it was encrypted with and whether or not the content was decrypted
+ */
+func decrypt(ciphertext, key []byte, ciph int, old bool) ([]byte, uint64,
+                                                          bool) {
+  if len(ciphertext) < 12 || (ciph != AES256GCM &&
+                              ciph != CHACHA20POLY1305) {
+    return nil, 0, false
+  }
+  iv := ciphertext[:12]
+  var additionalData []byte = nil
+  if !old {
+    additionalData = []byte("LatchBox")
+  }
+  plaintext, err := aead(key, ciph).Open(nil, iv, ciphertext[12:],
+                                         additionalData)
+  if err != nil {
+    return nil, 0, false
+  }
+  return plaintext, bytesToNum(iv[:8]), true
+}
+
+/*
+ * Checks to see if the encrypted password file (fc) looks legitimate for
+ * length, then parses out the iterations and ciphertext (ct).  If the length
+ * of fc isn't at least 36 (length of iteration bytes, salt and AES256-GCM IV),
+ * an error is returned.
+ */
+func parseCt(fc []byte) (ciph int, iter uint32, salt, ct []byte, old bool,
+                         err error) {
+  if len(fc) < 36 {
+    return 0, 0, nil, nil, false, errors.New("latchbox file content too short")
+  }
+  if bytesToNum(fc[:4]) == 100000 {
+    return 0, uint32(bytesToNum(fc[:4])), fc[4: 36], fc[36:], true, nil
+  }
+  if len(fc) < 38 {
+    return 0, 0, nil, nil, false, errors.New("latchbox file content too short")
+  }
+  return int(bytesToNum(fc[:2])), uint32(bytesToNum(fc[2:6])), fc[6: 38],
+         fc[38:], false, nil
+}
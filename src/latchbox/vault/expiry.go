@@ -26,33 +26,27 @@
  */
 
 /*
- * Organize data into password file protocol, then encrypt the
- * password file and write to file.  If first time writing and backups
- * allowed, make a backup.  Also does anything that involves reading and
- * writing to a file.
+ * Expiry dates of entries, which are saved in an extension record of their
+ * entries so passwords can be rotated on time.
  */
 
-package main
+package vault
 
 import (
   "time"
 )
 
+/* Extension record type of when the password of an entry expires. */
+const expiresRecord = 10
+
 /*
- * Tests to see how many iterations of PBKDF2 need to happen to equal 0.5
- * seconds.  100000 is the lowest possible amount of iterations.
+ * Returns when the current password of e was set, which is when the newest
+ * previous password was replaced or when e was made if it has no password
+ * history.
  */
-func getIterationsFromPBKDF2Test() {
-  var iter uint32 = 100000
-  var testIter uint32 = 10000
-  var goalTime int64 = 500000000
-  startTime := time.Now()
-  generatePBKDF2Key([]byte("Test"), []byte("salt"), testIter)
-  endTime := time.Since(startTime).Nanoseconds()
-  factor := goalTime / endTime
-  var finalIter uint32 = testIter * uint32(factor)
-  if finalIter < 100000 {
-    finalIter = iter
+func (e *Entry) PasswordSet() time.Time {
+  if len(e.History) > 0 {
+    return e.History[0].Replaced
   }
-  iterations = finalIter
+  return e.Created
 }
@@ -0,0 +1,273 @@
+/*-
+ * Copyright (C) 2014-2017, Vi Grey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY AUTHOR AND CONTRIBUTORS "AS IS" AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL AUTHOR OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+/*
+ * Custom fields and types of entries and the extension records they are
+ * saved in under protocol version 3.  Extension records with a type this
+ * version of LatchBox doesn't know are kept as they are and saved back
+ * unchanged.
+ */
+
+package vault
+
+import (
+  "errors"
+  "strings"
+  "time"
+)
+
+const (
+  /* Extension record type of a custom field. */
+  fieldRecord = 1
+  /* Extension record type of the type of an entry. */
+  typeRecord = 2
+  /* Custom field flag for values that are hidden unless asked for. */
+  concealedFlag = 1
+  /* Most custom fields a single entry can have. */
+  MaxFields = 100
+)
+
+/* A custom field of an entry, like an account number or a PIN. */
+type Field struct {
+  Name, Value string
+  Concealed bool
+}
+
+/* An extension record of a data packet that isn't a custom field. */
+type record struct {
+  kind byte
+  value []byte
+}
+
+/* Returns the custom field of e called name, or nil if there isn't one. */
+func (e *Entry) Field(name string) *Field {
+  for x := range e.Fields {
+    if e.Fields[x].Name == name {
+      return &e.Fields[x]
+    }
+  }
+  return nil
+}
+
+/* Returns an error if f can't be saved as a custom field. */
+func (f Field) Validate() error {
+  if len(f.Name) == 0 {
+    return errors.New("Field Name Required")
+  } else if len(f.Name) > 255 {
+    return errors.New("Field Name Too Long")
+  } else if len(f.Value) > 65535 {
+    return errors.New("Field Value Too Long")
+  }
+  return nil
+}
+
+/* Returns an error if the custom fields of e can't be saved. */
+func (e *Entry) validateFields() error {
+  if len(e.Fields) > MaxFields {
+    return errors.New("Too Many Custom Fields")
+  }
+  names := make(map[string]bool)
+  for _, f := range e.Fields {
+    if err := f.Validate(); err != nil {
+      return err
+    }
+    if names[f.Name] {
+      return errors.New("Duplicate Field Name " + f.Name)
+    }
+    names[f.Name] = true
+  }
+  return nil
+}
+
+/*
+ * Returns the extension records of e, which are its type, custom fields,
+ * attachments, previous passwords, one-time password settings, recovery
+ * codes, tags, favorite flag, when it expires, generator settings and when
+ * it was deleted followed by the unknown records it was read with.
+ */
+func (e *Entry) encodeRecords() []byte {
+  var records []byte
+  if e.Type != "" {
+    records = append(records, typeRecord)
+    records = append(records, strLenAppend([]byte(e.Type), 3)...)
+  }
+  for _, f := range e.Fields {
+    var flags byte
+    if f.Concealed {
+      flags |= concealedFlag
+    }
+    value := append([]byte{flags}, strLenAppend([]byte(f.Name), 1)...)
+    value = append(value, []byte(f.Value)...)
+    records = append(records, fieldRecord)
+    records = append(records, strLenAppend(value, 3)...)
+  }
+  for _, a := range e.Attachments {
+    records = append(records, attachmentRecord)
+    records = append(records, strLenAppend(a.encode(), 3)...)
+  }
+  for _, old := range e.History {
+    records = append(records, historyRecord)
+    records = append(records, strLenAppend(old.encode(), 3)...)
+  }
+  if e.OTP != nil {
+    records = append(records, otpRecord)
+    records = append(records, strLenAppend([]byte(e.OTP.URI()), 3)...)
+  }
+  for _, code := range e.RecoveryCodes {
+    records = append(records, recoveryRecord)
+    records = append(records, strLenAppend(code.encode(), 3)...)
+  }
+  for _, tag := range e.Tags {
+    records = append(records, tagRecord)
+    records = append(records, strLenAppend([]byte(tag), 3)...)
+  }
+  if e.Favorite {
+    records = append(records, favoriteRecord)
+    records = append(records, strLenAppend(nil, 3)...)
+  }
+  if !e.Expires.IsZero() {
+    records = append(records, expiresRecord)
+    records = append(records, strLenAppend(numToBytes(e.Expires.Unix(), 8),
+                                           3)...)
+  }
+  if e.Generator != nil {
+    records = append(records, generatorRecord)
+    records = append(records, strLenAppend(e.Generator.encode(), 3)...)
+  }
+  if !e.Deleted.IsZero() {
+    records = append(records, deletedRecord)
+    records = append(records, strLenAppend(numToBytes(e.Deleted.Unix(), 8),
+                                           3)...)
+  }
+  for _, r := range e.records {
+    records = append(records, r.kind)
+    records = append(records, strLenAppend(r.value, 3)...)
+  }
+  return records
+}
+
+/* Parses the extension records at the end of a data packet into e. */
+func (e *Entry) parseRecords(records []byte) error {
+  var pointer int
+  for pointer < len(records) {
+    var err bool
+    kind := records[pointer]
+    pointer++
+    _, value := parseInfo(records, 3, &pointer, &err)
+    if err {
+      return errors.New("Invalid Extension Record")
+    }
+    if kind == typeRecord {
+      if len(value) == 0 || len(value) > 255 {
+        return errors.New("Invalid Entry Type")
+      }
+      e.Type = string(value)
+      continue
+    } else if kind == attachmentRecord {
+      a, aErr := parseAttachment(value)
+      if aErr != nil {
+        return aErr
+      }
+      e.Attachments = append(e.Attachments, a)
+      continue
+    } else if kind == historyRecord {
+      old, oldErr := parseOldPassword(value)
+      if oldErr != nil {
+        return oldErr
+      }
+      e.History = append(e.History, old)
+      continue
+    } else if kind == otpRecord {
+      if !strings.HasPrefix(string(value), "otpauth://") {
+        return errors.New("Invalid OTP")
+      }
+      o, otpErr := ParseOTP(string(value))
+      if otpErr != nil {
+        return otpErr
+      }
+      e.OTP = o
+      continue
+    } else if kind == recoveryRecord {
+      code, codeErr := parseRecoveryCode(value)
+      if codeErr != nil {
+        return codeErr
+      }
+      e.RecoveryCodes = append(e.RecoveryCodes, code)
+      continue
+    } else if kind == tagRecord {
+      e.Tags = append(e.Tags, string(value))
+      if validateTags(e.Tags) != nil {
+        return errors.New("Invalid Tag")
+      }
+      continue
+    } else if kind == favoriteRecord {
+      if len(value) != 0 || e.Favorite {
+        return errors.New("Invalid Favorite")
+      }
+      e.Favorite = true
+      continue
+    } else if kind == expiresRecord {
+      if len(value) != 8 || bytesToNum(value) == 0 || !e.Expires.IsZero() {
+        return errors.New("Invalid Expiry Timestamp")
+      }
+      e.Expires = time.Unix(int64(bytesToNum(value)), 0)
+      continue
+    } else if kind == generatorRecord {
+      g, genErr := parseGenerator(value)
+      if genErr != nil || e.Generator != nil {
+        return errors.New("Invalid Generator Settings")
+      }
+      e.Generator = g
+      continue
+    } else if kind == deletedRecord {
+      if len(value) != 8 || bytesToNum(value) == 0 {
+        return errors.New("Invalid Deleted Timestamp")
+      }
+      e.Deleted = time.Unix(int64(bytesToNum(value)), 0)
+      continue
+    } else if kind != fieldRecord {
+      e.records = append(e.records, record{kind, append([]byte{}, value...)})
+      continue
+    }
+    var fieldPointer int
+    if len(value) < 1 {
+      return errors.New("Invalid Custom Field")
+    }
+    flags := value[0]
+    fieldPointer++
+    nameLen, name := parseInfo(value, 1, &fieldPointer, &err)
+    if err || nameLen == 0 || len(value) - fieldPointer > 65535 {
+      return errors.New("Invalid Custom Field")
+    }
+    e.Fields = append(e.Fields, Field{
+      Name: string(name),
+      Value: string(value[fieldPointer:]),
+      Concealed: flags & concealedFlag != 0,
+    })
+  }
+  return nil
+}
@@ -0,0 +1,338 @@
+/*-
+ * Copyright (C) 2014-2017, Vi Grey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY AUTHOR AND CONTRIBUTORS "AS IS" AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL AUTHOR OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+/*
+ * Encodes entries into the decrypted content of a password file and parses
+ * them back out in accordance to the protocol.
+ */
+
+package vault
+
+import (
+  "errors"
+  "math"
+  "strings"
+  "time"
+)
+
+/*
+ * Returned by parseEntryPacket if the group pointer of an entry points to a
+ * group with an invalid name or isn't in the group header.
+ */
+var (
+  errBadGroup = errors.New("Invalid Group Name")
+  errUnknownGroup = errors.New("Group Pointer Not in Group Header")
+)
+
+/*
+ * Returns the decrypted content of the password file under the current
+ * protocol version.
+ */
+func (v *Vault) encode() []byte {
+  /* Make map of [groupName]groupPointer (group pointer can be up to 65535). */
+  groupDict := make(map[string]string)
+  var groupData []byte
+  entries := append(append([]*Entry{}, v.Entries...), v.Trash...)
+  for _, e := range entries {
+    if _, ok := groupDict[e.Group]; !ok {
+      groupDict[e.Group] = string(numToBytes(len(groupDict) + 1, 2))
+      groupData = append(groupData, strLenAppend([]byte(e.Group), 2)...)
+      groupData = append(groupData, []byte(groupDict[e.Group])...)
+    }
+  }
+  data := numToBytes(uint64(ProtocolVersion), 2)
+  data = append(data, strLenAppend(groupData, 4)...)
+  for _, e := range entries {
+    var packet []byte
+    packet = append(packet, strLenAppend([]byte(e.Name), 1)...)
+    packet = append(packet, strLenAppend([]byte(e.Username), 1)...)
+    packet = append(packet, strLenAppend([]byte(e.Password), 2)...)
+    packet = append(packet, strLenAppend([]byte(e.Email), 1)...)
+    packet = append(packet, strLenAppend([]byte(e.URL), 1)...)
+    if e.Group != "" {
+      packet = append(packet, []byte(groupDict[e.Group])...)
+    } else {
+      packet = append(packet, []byte{0, 0}...)
+    }
+    packet = append(packet, numToBytes(e.Created.Unix(), 8)...)
+    packet = append(packet, numToBytes(e.Modified.Unix(), 8)...)
+    packet = append(packet, strLenAppend([]byte(e.Comment), 2)...)
+    packet = append(packet, e.encodeRecords()...)
+    data = append(data, strLenAppend(packet, 3)...)
+  }
+  return data
+}
+
+/*
+ * Parses the decrypted password file content and returns the protocol
+ * version and the entries of content.
+ */
+func parseContent(content []byte) (uint16, []*Entry, error) {
+  var err bool
+  var pointer int
+  var version uint16
+  var entries []*Entry
+  if len(content) >= 2 {
+    version = uint16(bytesToNum(content[pointer: pointer + 2]))
+  } else {
+    err = true
+  }
+  pointer += 2
+  pointers, groupErr := parseGroupHeader(content, &pointer, &err)
+  if groupErr >= 0 {
+    err = true
+  }
+  nameGroups := make(map[string]bool)
+  for pointer < len(content) && !err {
+    _, packet := parseInfo(content, 3, &pointer, &err)
+    if err {
+      break
+    }
+    e, packetErr := parseEntryPacket(packet, pointers, version)
+    /* Entries with unknown group pointers have always been ungrouped. */
+    if packetErr != nil && packetErr != errUnknownGroup {
+      err = true
+    } else if e.Deleted.IsZero() {
+      /* Entries in the trash can share a name/group combination. */
+      if nameGroups[e.NameGroup()] {
+        err = true
+      }
+      nameGroups[e.NameGroup()] = true
+    }
+    entries = append(entries, e)
+  }
+  if err {
+    return 0, nil, ErrCorrupted
+  }
+  return version, entries, nil
+}
+
+/*
+ * Parses the group header of content starting at pointer and returns the
+ * group pointers in it.  Also returns the offset of the first group packet
+ * that couldn't be parsed, or -1 if every group packet was parsed.
+ */
+func parseGroupHeader(content []byte, pointer *int, err *bool) (
+    map[string]string, int) {
+  var packetPointer int
+  pointers := make(map[string]string)
+  headerStart := *pointer + 4
+  groupPacketLen, groupPacket := parseInfo(content, 4, pointer, err)
+  if *err {
+    return pointers, headerStart - 4
+  }
+  for packetPointer < groupPacketLen {
+    var packetErr bool
+    groupStart := packetPointer
+    _, hGroup := parseInfo(groupPacket, 2, &packetPointer, &packetErr)
+    if len(groupPacket) < 2 + packetPointer || packetErr {
+      return pointers, headerStart + groupStart
+    }
+    pointers[string(hGroup)] = string(groupPacket[
+      packetPointer: packetPointer + 2])
+    packetPointer += 2
+  }
+  return pointers, -1
+}
+
+/*
+ * Parses a single data packet (without its length) of protocol version
+ * version into an entry using the group pointers in pointers.  If only the
+ * group pointer of the packet is bad, the entry is returned with an empty
+ * group along with errBadGroup or errUnknownGroup.
+ */
+func parseEntryPacket(packet []byte, pointers map[string]string,
+                      version uint16) (*Entry, error) {
+  var err bool
+  var packetPointer int
+  e := &Entry{}
+  var times []time.Time
+  nameLen, name := parseInfo(packet, 1, &packetPointer, &err)
+  if nameLen > 0 && !strings.Contains(string(name), "/") && !err {
+    e.Name = string(name)
+  } else {
+    return e, errors.New("Invalid Name")
+  }
+  _, username := parseInfo(packet, 1, &packetPointer, &err)
+  if len(packet) > packetPointer && !err {
+    e.Username = string(username)
+  } else {
+    return e, errors.New("Invalid Username")
+  }
+  _, password := parseInfo(packet, 2, &packetPointer, &err)
+  if len(packet) > packetPointer && !err {
+    e.Password = string(password)
+  } else {
+    return e, errors.New("Invalid Password")
+  }
+  _, email := parseInfo(packet, 1, &packetPointer, &err)
+  if len(packet) > packetPointer && !err {
+    e.Email = string(email)
+  } else {
+    return e, errors.New("Invalid Email")
+  }
+  _, url := parseInfo(packet, 1, &packetPointer, &err)
+  if len(packet) > packetPointer && !err {
+    e.URL = string(url)
+  } else {
+    return e, errors.New("Invalid URL")
+  }
+  var groupErr error
+  if len(packet) - packetPointer >= 2 {
+    groupPointer := string(packet[packetPointer: packetPointer + 2])
+    if groupPointer != string([]byte{0, 0}) {
+      groupErr = errUnknownGroup
+    }
+    for path, point := range pointers {
+      if point == groupPointer {
+        e.Group = path
+        groupErr = nil
+        if path == "" || !ValidGroup(path) {
+          groupErr = errBadGroup
+        }
+      }
+    }
+  } else {
+    return e, errors.New("Invalid Group Pointer")
+  }
+  packetPointer += 2
+  getTime(packet, &times, &packetPointer, &err)
+  getTime(packet, &times, &packetPointer, &err)
+  if err {
+    return e, errors.New("Invalid Timestamp")
+  }
+  e.Created, e.Modified = times[0], times[1]
+  /* Before version 3, the comment is the rest of the data packet. */
+  if version < 3 {
+    if len(packet) - packetPointer >= 0 &&
+        len(packet) - packetPointer < 65536 {
+      e.Comment = string(packet[packetPointer:])
+    } else {
+      return e, errors.New("Invalid Comment")
+    }
+  } else {
+    _, comment := parseInfo(packet, 2, &packetPointer, &err)
+    if err {
+      return e, errors.New("Invalid Comment")
+    }
+    e.Comment = string(comment)
+    if recordErr := e.parseRecords(packet[packetPointer:]);
+        recordErr != nil {
+      return e, recordErr
+    }
+  }
+  if groupErr != nil {
+    e.Group = ""
+  }
+  return e, groupErr
+}
+
+/*
+ * Returns the length of the next packet section along with the content of
+ * the packet section.
+ */
+func parseInfo(packet []byte, byteLen int, pointer *int, err *bool) (
+    packetLen int, content []byte) {
+  var pLen int
+  var pContent []byte
+  if len(packet) - *pointer >= byteLen && !*err {
+    pLen = int(bytesToNum(packet[*pointer: *pointer + byteLen]))
+  } else {
+    *err = true
+  }
+  *pointer += byteLen
+  if len(packet) >= *pointer + pLen && !*err {
+    pContent = packet[*pointer: *pointer + pLen]
+  } else {
+    *err = true
+  }
+  *pointer += pLen
+  return pLen, pContent
+}
+
+/* Get timestamp out of 8 bytes */
+func getTime(packet []byte, entryList *[]time.Time, pointer *int,
+             err *bool) {
+  if len(packet) - *pointer >= 8 && !*err {
+    timestamp := bytesToNum(packet[*pointer: *pointer + 8])
+    *entryList = append(*entryList, time.Unix(int64(timestamp), 0))
+  } else {
+    *err = true
+  }
+  *pointer += 8
+}
+
+/*
+ * Returns byteNum bytes that signify the length of s and appends s
+ * to the bytes.
+ */
+func strLenAppend(s []byte, byteNum int) []byte {
+  return append(numToBytes(uint64(len(s)), byteNum), s...)
+}
+
+// Converts bytes b to an integer
+func bytesToNum(b []byte) uint64 {
+  var length uint64
+  y := len(b) - 1
+  for x := 0; x < len(b); x++ {
+    length += uint64(b[x]) * uint64(math.Pow(256, float64(y)))
+    y--
+  }
+  return length
+}
+
+// Converts num to a byte array of size int
+func numToBytes(num interface{}, size int) []byte {
+  var numBytes []byte
+  y := size - 1
+  switch numVal := num.(type) {
+  case uint64:
+    for x := 0; x < size; x++ {
+      byteVal := byte(numVal / uint64(math.Pow(256, float64(y))))
+      numVal -= uint64(byteVal) * uint64(math.Pow(256, float64(y)))
+      numBytes = append(numBytes, byteVal)
+      y--
+    }
+  case int64:
+    for x := 0; x < size; x++ {
+      byteVal := byte(numVal / int64(math.Pow(256, float64(y))))
+      numVal -= int64(byteVal) * int64(math.Pow(256, float64(y)))
+      numBytes = append(numBytes, byteVal)
+      y--
+    }
+  case int:
+    for x := 0; x < size; x++ {
+      byteVal := byte(numVal / int(math.Pow(256, float64(y))))
+      numVal -= int(byteVal) * int(math.Pow(256, float64(y)))
+      numBytes = append(numBytes, byteVal)
+      y--
+    }
+  default:
+    panic("Type of num must be int, int64, or uint64")
+  }
+  return numBytes
+}
@@ -0,0 +1,203 @@
+/*-
+ * Copyright (C) 2014-2017, Vi Grey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY AUTHOR AND CONTRIBUTORS "AS IS" AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL AUTHOR OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+/*
+ * Remembered password generator settings of entries, which are saved in an
+ * extension record of their entries so passwords can be generated the same
+ * way again when they are rotated.
+ */
+
+package vault
+
+import (
+  "errors"
+  "unicode/utf8"
+)
+
+const (
+  /* Extension record type of the generator settings of an entry. */
+  generatorRecord = 11
+  uppercaseFlag = 1
+  lowercaseFlag = 2
+  digitsFlag = 4
+  punctuationFlag = 8
+  noLookAlikesFlag = 16
+  noRepeatsFlag = 32
+  noSequencesFlag = 64
+  /* Length of generator settings that have no rules. */
+  basicGeneratorLength = 3
+  /* Length of generator settings with rules before the allowed symbols. */
+  rulesGeneratorLength = 9
+)
+
+/* Kinds of characters generated passwords can be made to start with. */
+const (
+  FirstAny = iota
+  FirstUppercase
+  FirstLowercase
+  FirstDigit
+  FirstPunctuation
+  FirstLetter
+)
+
+/*
+ * How the password of an entry was generated.  Length is the number of
+ * characters and Uppercase, Lowercase, Digits and Punctuation say which
+ * kinds of characters were allowed.  The rest are the rules the password
+ * was generated with.
+ */
+type Generator struct {
+  Length uint16
+  Uppercase, Lowercase, Digits, Punctuation bool
+  /*
+   * Fewest characters of each kind as [uppercase, lowercase, digits,
+   * punctuation].  Kinds that aren't allowed have 0.
+   */
+  Minimums [4]uint8
+  /* Punctuation characters that are allowed, or "" for all of them. */
+  Symbols string
+  /* Whether 0, O, 1, l and I are left out. */
+  NoLookAlikes bool
+  /* Whether the same character can't be next to itself, like "aa". */
+  NoRepeats bool
+  /* Whether 3 characters in a row can't be in sequence, like "abc". */
+  NoSequences bool
+  /* Kind of character the password starts with (FirstAny and so on). */
+  First uint8
+  /* Name of the generator profile the rules came from, or "". */
+  Profile string
+}
+
+/*
+ * Returns the generator settings of password length length and the allowed
+ * kinds of characters ulds ([uppercase, lowercase, digits, punctuation]),
+ * with at least one character of each allowed kind.
+ */
+func NewGenerator(length uint16, ulds []bool) *Generator {
+  g := &Generator{Length: length, Uppercase: ulds[0], Lowercase: ulds[1],
+                  Digits: ulds[2], Punctuation: ulds[3]}
+  g.Minimums = g.basicMinimums()
+  return g
+}
+
+/*
+ * Returns which kinds of characters g allows as [uppercase, lowercase,
+ * digits, punctuation].
+ */
+func (g *Generator) Classes() []bool {
+  return []bool{g.Uppercase, g.Lowercase, g.Digits, g.Punctuation}
+}
+
+/* Returns minimums of 1 for each kind of character g allows. */
+func (g *Generator) basicMinimums() [4]uint8 {
+  var minimums [4]uint8
+  for x, allowed := range g.Classes() {
+    if allowed {
+      minimums[x] = 1
+    }
+  }
+  return minimums
+}
+
+/*
+ * Returns whether g has no rules besides at least one character of each
+ * allowed kind.
+ */
+func (g *Generator) basic() bool {
+  return g.Minimums == g.basicMinimums() && g.Symbols == "" &&
+    !g.NoLookAlikes && !g.NoRepeats && !g.NoSequences &&
+    g.First == FirstAny && g.Profile == ""
+}
+
+/* Returns the extension record value of g. */
+func (g *Generator) encode() []byte {
+  var flags byte
+  if g.Uppercase {
+    flags |= uppercaseFlag
+  }
+  if g.Lowercase {
+    flags |= lowercaseFlag
+  }
+  if g.Digits {
+    flags |= digitsFlag
+  }
+  if g.Punctuation {
+    flags |= punctuationFlag
+  }
+  value := append(numToBytes(int64(g.Length), 2), flags)
+  if g.basic() {
+    return value
+  }
+  if g.NoLookAlikes {
+    value[2] |= noLookAlikesFlag
+  }
+  if g.NoRepeats {
+    value[2] |= noRepeatsFlag
+  }
+  if g.NoSequences {
+    value[2] |= noSequencesFlag
+  }
+  value = append(value, g.Minimums[:]...)
+  value = append(value, g.First, byte(len(g.Symbols)))
+  value = append(value, g.Symbols...)
+  return append(value, g.Profile...)
+}
+
+/* Parses the extension record value of generator settings. */
+func parseGenerator(value []byte) (*Generator, error) {
+  if (len(value) != basicGeneratorLength &&
+      len(value) < rulesGeneratorLength) || bytesToNum(value[:2]) < 4 {
+    return nil, errors.New("Invalid Generator Settings")
+  }
+  flags := value[2]
+  g := &Generator{
+    Length: uint16(bytesToNum(value[:2])),
+    Uppercase: flags & uppercaseFlag != 0,
+    Lowercase: flags & lowercaseFlag != 0,
+    Digits: flags & digitsFlag != 0,
+    Punctuation: flags & punctuationFlag != 0,
+  }
+  if len(value) == basicGeneratorLength {
+    g.Minimums = g.basicMinimums()
+    return g, nil
+  }
+  g.NoLookAlikes = flags & noLookAlikesFlag != 0
+  g.NoRepeats = flags & noRepeatsFlag != 0
+  g.NoSequences = flags & noSequencesFlag != 0
+  copy(g.Minimums[:], value[3:7])
+  g.First = value[7]
+  symbolsEnd := rulesGeneratorLength + int(value[8])
+  if g.First > FirstLetter || symbolsEnd > len(value) {
+    return nil, errors.New("Invalid Generator Settings")
+  }
+  g.Symbols = string(value[rulesGeneratorLength:symbolsEnd])
+  g.Profile = string(value[symbolsEnd:])
+  if !utf8.ValidString(g.Profile) {
+    return nil, errors.New("Invalid Generator Settings")
+  }
+  return g, nil
+}
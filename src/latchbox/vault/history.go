@@ -0,0 +1,113 @@
+/*-
+ * Copyright (C) 2014-2017, Vi Grey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY AUTHOR AND CONTRIBUTORS "AS IS" AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL AUTHOR OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+/*
+ * Previous passwords of entries, which are saved in extension records of
+ * their entries so a password that was changed can still be found.
+ */
+
+package vault
+
+import (
+  "errors"
+  "time"
+)
+
+const (
+  /* Extension record type of a previous password. */
+  historyRecord = 4
+  /* Most previous passwords a single entry can keep. */
+  MaxHistory = 100
+)
+
+/* A previous password of an entry and when it was replaced. */
+type OldPassword struct {
+  Password string
+  Replaced time.Time
+}
+
+/*
+ * Sets the password of e to password.  If it's different from the current
+ * password, the current password is added to the start of the history of e,
+ * which is cut down to the newest depth previous passwords.
+ */
+func (e *Entry) ChangePassword(password string, now time.Time, depth int) {
+  if password == e.Password {
+    return
+  }
+  if e.Password != "" {
+    old := OldPassword{e.Password, time.Unix(now.Unix(), 0)}
+    e.History = append([]OldPassword{old}, e.History...)
+  }
+  if len(e.History) > depth {
+    e.History = e.History[:depth]
+  }
+  e.Password = password
+}
+
+/*
+ * Removes the previous passwords of every entry of v and returns how many
+ * were removed.
+ */
+func (v *Vault) PurgeHistory() int {
+  var purged int
+  for _, e := range v.Entries {
+    purged += len(e.History)
+    e.History = nil
+  }
+  return purged
+}
+
+/* Returns an error if the password history of e can't be saved. */
+func (e *Entry) validateHistory() error {
+  if len(e.History) > MaxHistory {
+    return errors.New("Too Many Old Passwords")
+  }
+  for _, old := range e.History {
+    if len(old.Password) > 65535 {
+      return errors.New("Old Password Too Long")
+    }
+  }
+  return nil
+}
+
+/* Returns the extension record value of old. */
+func (old OldPassword) encode() []byte {
+  value := numToBytes(old.Replaced.Unix(), 8)
+  return append(value, []byte(old.Password)...)
+}
+
+/* Parses the extension record value of a previous password. */
+func parseOldPassword(value []byte) (OldPassword, error) {
+  if len(value) < 8 || len(value) - 8 > 65535 {
+    return OldPassword{}, errors.New("Invalid Old Password")
+  }
+  return OldPassword{
+    Password: string(value[8:]),
+    Replaced: time.Unix(int64(bytesToNum(value[:8])), 0),
+  }, nil
+}
@@ -0,0 +1,212 @@
+/*-
+ * Copyright (C) 2014-2017, Vi Grey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY AUTHOR AND CONTRIBUTORS "AS IS" AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL AUTHOR OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+/*
+ * One-time passwords of entries, which are TOTP (RFC 6238) or HOTP
+ * (RFC 4226) codes made from a secret saved with the entry as an otpauth://
+ * URI in an extension record.
+ */
+
+package vault
+
+import (
+  "crypto/hmac"
+  "crypto/sha1"
+  "crypto/sha256"
+  "crypto/sha512"
+  "encoding/base32"
+  "encoding/binary"
+  "errors"
+  "fmt"
+  "hash"
+  "net/url"
+  "strconv"
+  "strings"
+  "time"
+)
+
+/* Extension record type of the one-time password settings of an entry. */
+const otpRecord = 6
+
+/*
+ * The settings of the one-time passwords of an entry.  Kind is "totp" or
+ * "hotp" and Algorithm is "SHA1", "SHA256" or "SHA512".  Period is only used
+ * for TOTP and Counter is only used for HOTP, where it is the counter of the
+ * next code.
+ */
+type OTP struct {
+  Kind, Label, Issuer, Algorithm string
+  Secret []byte
+  Digits, Period int
+  Counter uint64
+}
+
+/*
+ * Parses s, which is either an otpauth:// URI or a base32 secret for a TOTP
+ * with the default settings of 6 digit SHA1 codes every 30 seconds.
+ */
+func ParseOTP(s string) (*OTP, error) {
+  s = strings.TrimSpace(s)
+  o := &OTP{Kind: "totp", Algorithm: "SHA1", Digits: 6, Period: 30}
+  if !strings.HasPrefix(strings.ToLower(s), "otpauth://") {
+    secret, err := decodeSecret(s)
+    if err != nil {
+      return nil, err
+    }
+    o.Secret = secret
+    return o, o.Validate()
+  }
+  u, err := url.Parse(s)
+  if err != nil {
+    return nil, errors.New("Invalid otpauth:// URI")
+  }
+  o.Kind = strings.ToLower(u.Host)
+  o.Label = strings.TrimPrefix(u.Path, "/")
+  query := u.Query()
+  o.Issuer = query.Get("issuer")
+  if o.Secret, err = decodeSecret(query.Get("secret")); err != nil {
+    return nil, err
+  }
+  if algorithm := query.Get("algorithm"); algorithm != "" {
+    o.Algorithm = strings.ToUpper(algorithm)
+  }
+  for _, number := range []struct {
+    name string
+    value *int
+  }{{"digits", &o.Digits}, {"period", &o.Period}} {
+    if value := query.Get(number.name); value != "" {
+      if *number.value, err = strconv.Atoi(value); err != nil {
+        return nil, errors.New("Invalid OTP " + strings.Title(number.name))
+      }
+    }
+  }
+  if counter := query.Get("counter"); counter != "" {
+    if o.Counter, err = strconv.ParseUint(counter, 10, 64); err != nil {
+      return nil, errors.New("Invalid OTP Counter")
+    }
+  } else if o.Kind == "hotp" {
+    return nil, errors.New("HOTP Counter Required")
+  }
+  return o, o.Validate()
+}
+
+/* Decodes a base32 secret, ignoring case, spaces and missing padding. */
+func decodeSecret(s string) ([]byte, error) {
+  s = strings.ToUpper(strings.Replace(s, " ", "", -1))
+  s = strings.TrimRight(s, "=")
+  secret, err := base32.StdEncoding.WithPadding(base32.NoPadding).
+      DecodeString(s)
+  if err != nil || len(secret) == 0 {
+    return nil, errors.New("Invalid OTP Secret")
+  }
+  return secret, nil
+}
+
+/* Returns an error if o can't be used to make codes. */
+func (o *OTP) Validate() error {
+  if o.Kind != "totp" && o.Kind != "hotp" {
+    return errors.New("OTP Type Must be TOTP or HOTP")
+  } else if len(o.Secret) == 0 || len(o.Secret) > 255 {
+    return errors.New("Invalid OTP Secret")
+  } else if o.Algorithm != "SHA1" && o.Algorithm != "SHA256" &&
+      o.Algorithm != "SHA512" {
+    return errors.New("OTP Algorithm Must be SHA1, SHA256 or SHA512")
+  } else if o.Digits < 6 || o.Digits > 8 {
+    return errors.New("OTP Digits Must be Between 6 and 8")
+  } else if o.Kind == "totp" && (o.Period < 1 || o.Period > 86400) {
+    return errors.New("OTP Period Must be Between 1 and 86400 Seconds")
+  } else if len(o.Label) > 255 || len(o.Issuer) > 255 {
+    return errors.New("OTP Label Too Long")
+  }
+  return nil
+}
+
+/* Returns o as an otpauth:// URI. */
+func (o *OTP) URI() string {
+  query := url.Values{}
+  query.Set("secret", base32.StdEncoding.WithPadding(base32.NoPadding).
+      EncodeToString(o.Secret))
+  if o.Issuer != "" {
+    query.Set("issuer", o.Issuer)
+  }
+  query.Set("algorithm", o.Algorithm)
+  query.Set("digits", strconv.Itoa(o.Digits))
+  if o.Kind == "hotp" {
+    query.Set("counter", strconv.FormatUint(o.Counter, 10))
+  } else {
+    query.Set("period", strconv.Itoa(o.Period))
+  }
+  u := url.URL{Scheme: "otpauth", Host: o.Kind, Path: "/" + o.Label,
+               RawQuery: query.Encode()}
+  return u.String()
+}
+
+/*
+ * Returns the TOTP code for the time now, or the HOTP code for the current
+ * counter, which should be moved on with Advance once the code is used.
+ */
+func (o *OTP) Code(now time.Time) string {
+  counter := o.Counter
+  if o.Kind == "totp" {
+    counter = uint64(now.Unix()) / uint64(o.Period)
+  }
+  return HOTP(o.Secret, counter, o.Digits, o.Algorithm)
+}
+
+/* Returns how many seconds the TOTP code for the time now is good for. */
+func (o *OTP) Remaining(now time.Time) int {
+  return o.Period - int(now.Unix() % int64(o.Period))
+}
+
+/* Moves the HOTP counter of o on to the next code. */
+func (o *OTP) Advance() {
+  o.Counter++
+}
+
+/*
+ * Returns the digits long HOTP code for counter made from secret with the
+ * HMAC algorithm algorithm ("SHA1", "SHA256" or "SHA512") as described in
+ * RFC 4226.
+ */
+func HOTP(secret []byte, counter uint64, digits int, algorithm string) string {
+  var hashFunc func() hash.Hash = sha1.New
+  if algorithm == "SHA256" {
+    hashFunc = sha256.New
+  } else if algorithm == "SHA512" {
+    hashFunc = sha512.New
+  }
+  mac := hmac.New(hashFunc, secret)
+  binary.Write(mac, binary.BigEndian, counter)
+  sum := mac.Sum(nil)
+  offset := sum[len(sum) - 1] & 0xf
+  code := binary.BigEndian.Uint32(sum[offset: offset + 4]) & 0x7fffffff
+  modulo := uint32(1)
+  for x := 0; x < digits; x++ {
+    modulo *= 10
+  }
+  return fmt.Sprintf("%0*d", digits, code % modulo)
+}
@@ -0,0 +1,164 @@
+/*-
+ * Copyright (C) 2014-2017, Vi Grey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY AUTHOR AND CONTRIBUTORS "AS IS" AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL AUTHOR OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+/*
+ * Tests of the one-time passwords against the test vectors of RFC 4226
+ * Appendix D and RFC 6238 Appendix B.
+ */
+
+package vault
+
+import (
+  "bytes"
+  "testing"
+  "time"
+)
+
+/* Secret of the RFC 4226 and RFC 6238 SHA1 test vectors. */
+var rfcSecret = []byte("12345678901234567890")
+
+func TestHOTP(t *testing.T) {
+  codes := []string{"755224", "287082", "359152", "969429", "338314",
+                    "254676", "287922", "162583", "399871", "520489"}
+  for counter, want := range codes {
+    if got := HOTP(rfcSecret, uint64(counter), 6, "SHA1"); got != want {
+      t.Errorf("HOTP counter %d = %s, want %s", counter, got, want)
+    }
+  }
+}
+
+func TestTOTP(t *testing.T) {
+  secrets := map[string][]byte{
+    "SHA1": rfcSecret,
+    "SHA256": []byte("12345678901234567890123456789012"),
+    "SHA512": []byte("1234567890123456789012345678901234567890" +
+                     "123456789012345678901234"),
+  }
+  vectors := []struct {
+    time int64
+    sha1, sha256, sha512 string
+  }{
+    {59, "94287082", "46119246", "90693936"},
+    {1111111109, "07081804", "68084774", "25091201"},
+    {1111111111, "14050471", "67062674", "99943326"},
+    {1234567890, "89005924", "91819424", "93441116"},
+    {2000000000, "69279037", "90698825", "38618901"},
+    {20000000000, "65353130", "77737706", "47863826"},
+  }
+  for _, v := range vectors {
+    for algorithm, want := range map[string]string{
+        "SHA1": v.sha1, "SHA256": v.sha256, "SHA512": v.sha512} {
+      o := &OTP{Kind: "totp", Algorithm: algorithm,
+                Secret: secrets[algorithm], Digits: 8, Period: 30}
+      if got := o.Code(time.Unix(v.time, 0)); got != want {
+        t.Errorf("TOTP %s at %d = %s, want %s", algorithm, v.time, got,
+                 want)
+      }
+    }
+  }
+}
+
+func TestHOTPCode(t *testing.T) {
+  o := &OTP{Kind: "hotp", Algorithm: "SHA1", Secret: rfcSecret, Digits: 6,
+            Counter: 3}
+  if got := o.Code(time.Now()); got != "969429" {
+    t.Errorf("HOTP code = %s, want 969429", got)
+  }
+  o.Advance()
+  if got := o.Code(time.Now()); got != "338314" {
+    t.Errorf("HOTP code after Advance = %s, want 338314", got)
+  }
+}
+
+func TestParseOTP(t *testing.T) {
+  /* JBSWY3DPEHPK3PXP is the base32 encoding of "Hello!\xde\xad\xbe\xef". */
+  secret := []byte("Hello!\xde\xad\xbe\xef")
+  tests := []struct {
+    in string
+    want OTP
+  }{
+    {"otpauth://totp/Example:alice@google.com?secret=JBSWY3DPEHPK3PXP&" +
+     "issuer=Example",
+     OTP{Kind: "totp", Label: "Example:alice@google.com", Issuer: "Example",
+         Algorithm: "SHA1", Secret: secret, Digits: 6, Period: 30}},
+    {"otpauth://TOTP/bob?secret=jbswy3dpehpk3pxp&algorithm=sha512&" +
+     "digits=8&period=60",
+     OTP{Kind: "totp", Label: "bob", Algorithm: "SHA512", Secret: secret,
+         Digits: 8, Period: 60}},
+    {"otpauth://hotp/carol?secret=JBSWY3DPEHPK3PXP&counter=42",
+     OTP{Kind: "hotp", Label: "carol", Algorithm: "SHA1", Secret: secret,
+         Digits: 6, Period: 30, Counter: 42}},
+    {"JBSWY3DPEHPK3PXP",
+     OTP{Kind: "totp", Algorithm: "SHA1", Secret: secret, Digits: 6,
+         Period: 30}},
+    {" jbsw y3dp ehpk 3pxp ",
+     OTP{Kind: "totp", Algorithm: "SHA1", Secret: secret, Digits: 6,
+         Period: 30}},
+    {"GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ",
+     OTP{Kind: "totp", Algorithm: "SHA1", Secret: rfcSecret, Digits: 6,
+         Period: 30}},
+  }
+  for _, test := range tests {
+    o, err := ParseOTP(test.in)
+    if err != nil {
+      t.Errorf("ParseOTP(%q) returned error %v", test.in, err)
+      continue
+    }
+    if o.Kind != test.want.Kind || o.Label != test.want.Label ||
+        o.Issuer != test.want.Issuer || o.Algorithm != test.want.Algorithm ||
+        !bytes.Equal(o.Secret, test.want.Secret) ||
+        o.Digits != test.want.Digits || o.Period != test.want.Period ||
+        o.Counter != test.want.Counter {
+      t.Errorf("ParseOTP(%q) = %+v, want %+v", test.in, *o, test.want)
+    }
+    /* The URI of a parsed OTP parses back to the same settings. */
+    again, err := ParseOTP(o.URI())
+    if err != nil || again.URI() != o.URI() {
+      t.Errorf("ParseOTP(%q) does not round trip through %s", test.in,
+               o.URI())
+    }
+  }
+}
+
+func TestParseOTPInvalid(t *testing.T) {
+  for _, in := range []string{
+    "",
+    "not base32!",
+    "otpauth://totp/alice",
+    "otpauth://totp/alice?secret=JBSWY3DPEHPK3PXP&algorithm=MD5",
+    "otpauth://totp/alice?secret=JBSWY3DPEHPK3PXP&digits=9",
+    "otpauth://totp/alice?secret=JBSWY3DPEHPK3PXP&digits=six",
+    "otpauth://totp/alice?secret=JBSWY3DPEHPK3PXP&period=0",
+    "otpauth://hotp/alice?secret=JBSWY3DPEHPK3PXP",
+    "otpauth://hotp/alice?secret=JBSWY3DPEHPK3PXP&counter=-1",
+    "otpauth://motp/alice?secret=JBSWY3DPEHPK3PXP",
+  } {
+    if o, err := ParseOTP(in); err == nil {
+      t.Errorf("ParseOTP(%q) = %+v, want an error", in, *o)
+    }
+  }
+}
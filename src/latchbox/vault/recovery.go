@@ -0,0 +1,132 @@
+/*-
+ * Copyright (C) 2014-2017, Vi Grey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY AUTHOR AND CONTRIBUTORS "AS IS" AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL AUTHOR OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+/*
+ * One-time recovery codes of entries, which are saved in extension records
+ * of their entries along with when each code was used.
+ */
+
+package vault
+
+import (
+  "errors"
+  "strings"
+  "time"
+)
+
+const (
+  /* Extension record type of a recovery code. */
+  recoveryRecord = 7
+  /* Most recovery codes a single entry can keep. */
+  MaxRecoveryCodes = 100
+)
+
+/* A recovery code of an entry and when it was used (zero if it wasn't). */
+type RecoveryCode struct {
+  Code string
+  Used time.Time
+}
+
+/*
+ * Replaces the recovery codes of e with the unused codes in s, which are
+ * separated by spaces, commas or new lines.
+ */
+func (e *Entry) SetRecoveryCodes(s string) error {
+  var codes []RecoveryCode
+  for _, code := range strings.FieldsFunc(s, func(r rune) bool {
+    return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r'
+  }) {
+    codes = append(codes, RecoveryCode{Code: code})
+  }
+  if err := validateRecoveryCodes(codes); err != nil {
+    return err
+  }
+  e.RecoveryCodes = codes
+  return nil
+}
+
+/*
+ * Returns the index in the recovery codes of e of the first code that
+ * wasn't used, or -1 if every code was used.
+ */
+func (e *Entry) NextRecoveryCode() int {
+  for x, code := range e.RecoveryCodes {
+    if code.Used.IsZero() {
+      return x
+    }
+  }
+  return -1
+}
+
+/* Returns how many recovery codes of e weren't used. */
+func (e *Entry) RecoveryCodesLeft() int {
+  var left int
+  for _, code := range e.RecoveryCodes {
+    if code.Used.IsZero() {
+      left++
+    }
+  }
+  return left
+}
+
+/* Returns an error if codes can't be saved as recovery codes. */
+func validateRecoveryCodes(codes []RecoveryCode) error {
+  if len(codes) > MaxRecoveryCodes {
+    return errors.New("Too Many Recovery Codes")
+  }
+  seen := make(map[string]bool)
+  for _, code := range codes {
+    if len(code.Code) == 0 || len(code.Code) > 255 {
+      return errors.New("Recovery Code is Not an Expected Length")
+    } else if seen[code.Code] {
+      return errors.New("Duplicate Recovery Code " + code.Code)
+    }
+    seen[code.Code] = true
+  }
+  return nil
+}
+
+/* Returns the extension record value of code. */
+func (code RecoveryCode) encode() []byte {
+  var used int64
+  if !code.Used.IsZero() {
+    used = code.Used.Unix()
+  }
+  return append(numToBytes(used, 8), []byte(code.Code)...)
+}
+
+/* Parses the extension record value of a recovery code. */
+func parseRecoveryCode(value []byte) (RecoveryCode, error) {
+  if len(value) < 9 || len(value) - 8 > 255 {
+    return RecoveryCode{}, errors.New("Invalid Recovery Code")
+  }
+  code := RecoveryCode{Code: string(value[8:])}
+  if used := bytesToNum(value[:8]); used != 0 {
+    code.Used = time.Unix(int64(used), 0)
+  }
+  return code, nil
+}
@@ -0,0 +1,131 @@
+/*-
+ * Copyright (C) 2014-2017, Vi Grey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY AUTHOR AND CONTRIBUTORS "AS IS" AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL AUTHOR OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+/*
+ * Salvages the entries of a corrupted password file by parsing the
+ * decrypted content one data packet at a time and keeping every entry that
+ * can still be parsed.
+ */
+
+package vault
+
+import (
+  "fmt"
+  "strconv"
+)
+
+/* Group that salvaged entries with bad group pointers are put in. */
+const SalvageGroup = "recovered"
+
+/*
+ * Replaces the entries of a password file that decrypted but couldn't be
+ * parsed with every entry that can be salvaged from it and unlocks it.
+ * Returns a description of each problem found, including the byte offsets
+ * of content that couldn't be interpreted.  Entries with bad group pointers
+ * are put in the recovered group and entries with duplicate name/group
+ * combinations are renamed.
+ */
+func (v *Vault) Salvage() []string {
+  if v.content == nil {
+    return []string{ErrLocked.Error()}
+  }
+  entries, problems := salvageContent(v.content)
+  v.Entries, v.Trash = splitTrash(entries)
+  v.unlocked = true
+  return problems
+}
+
+/*
+ * Parses the decrypted password file content one data packet at a time and
+ * returns every entry that could be parsed along with the problems found.
+ */
+func salvageContent(content []byte) ([]*Entry, []string) {
+  var entries []*Entry
+  var problems []string
+  var err bool
+  if len(content) < 2 {
+    return entries, []string{"Offset 0: Content Too Short for a Version " +
+      "Number"}
+  }
+  version := uint16(bytesToNum(content[:2]))
+  pointer := 2
+  pointers, groupErr := parseGroupHeader(content, &pointer, &err)
+  if err {
+    return entries, []string{fmt.Sprintf("Offset %d: Group Header Length " +
+      "Goes Past the End of the Content (Bytes %d-%d Could Not be " +
+      "Interpreted)", groupErr, groupErr, len(content) - 1)}
+  }
+  if groupErr >= 0 {
+    problems = append(problems, fmt.Sprintf("Offset %d: Group Packet Could " +
+      "Not be Parsed (Bytes %d-%d Could Not be Interpreted)", groupErr,
+      groupErr, pointer - 1))
+  }
+  for pointer < len(content) {
+    var packetErr bool
+    start := pointer
+    _, packet := parseInfo(content, 3, &pointer, &packetErr)
+    if packetErr {
+      problems = append(problems, fmt.Sprintf("Offset %d: Data Packet " +
+        "Length Goes Past the End of the Content (Bytes %d-%d Could Not " +
+        "be Interpreted)", start, start, len(content) - 1))
+      break
+    }
+    e, err := parseEntryPacket(packet, pointers, version)
+    if err == errBadGroup || err == errUnknownGroup {
+      e.Group = SalvageGroup
+      problems = append(problems, fmt.Sprintf("Offset %d: %s (%s, Moved " +
+        "to Group %s)", start, e.Name, err, SalvageGroup))
+    } else if err != nil {
+      problems = append(problems, fmt.Sprintf("Offset %d: Data Packet " +
+        "Could Not be Parsed (%s, Bytes %d-%d Could Not be Interpreted)",
+        start, err, start, pointer - 1))
+      continue
+    }
+    entries = append(entries, e)
+  }
+  seen := make(map[string]bool)
+  for _, e := range entries {
+    if !e.Deleted.IsZero() {
+      continue
+    }
+    nameGroup := e.NameGroup()
+    name := e.Name
+    for y := 2; seen[e.NameGroup()]; y++ {
+      suffix := " (" + strconv.Itoa(y) + ")"
+      if len(name) + len(suffix) > 255 {
+        name = name[:255 - len(suffix)]
+      }
+      e.Name = name + suffix
+    }
+    if nameGroup != e.NameGroup() {
+      problems = append(problems, "Duplicate Name/Group Combination " +
+        nameGroup + " Renamed to " + e.NameGroup())
+    }
+    seen[e.NameGroup()] = true
+  }
+  return entries, problems
+}
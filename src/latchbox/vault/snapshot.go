@@ -0,0 +1,85 @@
+/*-
+ * Copyright (C) 2014-2017, Vi Grey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY AUTHOR AND CONTRIBUTORS "AS IS" AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL AUTHOR OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+/*
+ * Snapshots of the entries of a password file so changes to it can be
+ * undone and redone.
+ */
+
+package vault
+
+/* A copy of the entries and trash of a password file at one point in time. */
+type Snapshot struct {
+  entries, trash []*Entry
+}
+
+/*
+ * Returns a copy of e so changing one doesn't change the other.  The data
+ * of attachments is shared since it is only ever replaced, never changed.
+ */
+func (e *Entry) Copy() *Entry {
+  c := *e
+  c.Fields = append([]Field(nil), e.Fields...)
+  c.Attachments = append([]Attachment(nil), e.Attachments...)
+  c.History = append([]OldPassword(nil), e.History...)
+  c.RecoveryCodes = append([]RecoveryCode(nil), e.RecoveryCodes...)
+  c.Tags = append([]string(nil), e.Tags...)
+  c.records = append([]record(nil), e.records...)
+  if e.OTP != nil {
+    o := *e.OTP
+    o.Secret = append([]byte(nil), e.OTP.Secret...)
+    c.OTP = &o
+  }
+  if e.Generator != nil {
+    g := *e.Generator
+    c.Generator = &g
+  }
+  return &c
+}
+
+/* Returns a snapshot of the entries and trash of v as they are now. */
+func (v *Vault) Snapshot() Snapshot {
+  return Snapshot{copyEntries(v.Entries), copyEntries(v.Trash)}
+}
+
+/*
+ * Replaces the entries and trash of v with the ones in s.  s can be used
+ * again afterwards.
+ */
+func (v *Vault) Revert(s Snapshot) {
+  v.Entries = copyEntries(s.entries)
+  v.Trash = copyEntries(s.trash)
+}
+
+/* Returns copies of entries made with Copy. */
+func copyEntries(entries []*Entry) []*Entry {
+  var copies []*Entry
+  for _, e := range entries {
+    copies = append(copies, e.Copy())
+  }
+  return copies
+}
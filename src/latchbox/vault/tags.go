@@ -0,0 +1,157 @@
+/*-
+ * Copyright (C) 2014-2017, Vi Grey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY AUTHOR AND CONTRIBUTORS "AS IS" AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL AUTHOR OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+/*
+ * Tags and the favorite flag of entries, which are saved in extension
+ * records of their entries so entries can be listed across groups.
+ */
+
+package vault
+
+import (
+  "errors"
+  "sort"
+  "strings"
+)
+
+const (
+  /* Extension record type of a tag. */
+  tagRecord = 8
+  /* Extension record type that marks an entry as a favorite. */
+  favoriteRecord = 9
+  /* Most tags a single entry can have. */
+  MaxTags = 100
+)
+
+/* Checks if r separates tags from each other. */
+func tagSeparator(r rune) bool {
+  return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r'
+}
+
+/*
+ * Replaces the tags of e with the tags in s, which are separated by spaces
+ * or commas.  Tags that are already in s, ignoring case, are left out.
+ */
+func (e *Entry) SetTags(s string) error {
+  var tags []string
+  for _, tag := range strings.FieldsFunc(s, tagSeparator) {
+    if !hasTag(tags, tag) {
+      tags = append(tags, tag)
+    }
+  }
+  if err := validateTags(tags); err != nil {
+    return err
+  }
+  e.Tags = tags
+  return nil
+}
+
+/* Checks if e has the tag tag, ignoring case. */
+func (e *Entry) HasTag(tag string) bool {
+  return hasTag(e.Tags, tag)
+}
+
+/*
+ * Checks if e has the tag tag (or tag is empty) and is a favorite if
+ * favorites is true.
+ */
+func (e *Entry) Matches(tag string, favorites bool) bool {
+  return (tag == "" || e.HasTag(tag)) && (e.Favorite || !favorites)
+}
+
+/* Checks if tags has the tag tag, ignoring case. */
+func hasTag(tags []string, tag string) bool {
+  for _, t := range tags {
+    if strings.EqualFold(t, tag) {
+      return true
+    }
+  }
+  return false
+}
+
+/*
+ * Returns every tag used by the entries of the password file sorted case
+ * insensitively, with tags that only differ by case listed once.
+ */
+func (v *Vault) Tags() []string {
+  var tags []string
+  for _, e := range v.Sorted() {
+    for _, tag := range e.Tags {
+      if !hasTag(tags, tag) {
+        tags = append(tags, tag)
+      }
+    }
+  }
+  sort.SliceStable(tags, func(x, y int) bool {
+    return strings.ToLower(tags[x]) < strings.ToLower(tags[y])
+  })
+  return tags
+}
+
+/*
+ * Returns the entries of the password file that have the tag tag (every
+ * entry if tag is empty) and are favorites if favorites is true, in the
+ * order of FilterEntries.
+ */
+func (v *Vault) Filter(tag string, favorites bool) []*Entry {
+  return FilterEntries(v.Entries, tag, favorites)
+}
+
+/*
+ * Returns the entries in entries that have the tag tag (every entry if tag
+ * is empty) and are favorites if favorites is true, sorted like Sort but
+ * with favorites first.
+ */
+func FilterEntries(entries []*Entry, tag string, favorites bool) []*Entry {
+  var filtered []*Entry
+  for _, e := range Sort(entries) {
+    if e.Matches(tag, favorites) {
+      filtered = append(filtered, e)
+    }
+  }
+  sort.SliceStable(filtered, func(x, y int) bool {
+    return filtered[x].Favorite && !filtered[y].Favorite
+  })
+  return filtered
+}
+
+/* Returns an error if tags can't be saved as the tags of an entry. */
+func validateTags(tags []string) error {
+  if len(tags) > MaxTags {
+    return errors.New("Too Many Tags")
+  }
+  for x, tag := range tags {
+    if len(tag) == 0 || len(tag) > 255 {
+      return errors.New("Tag is Not an Expected Length")
+    } else if strings.IndexFunc(tag, tagSeparator) > -1 {
+      return errors.New("Tags Can't Have Spaces or Commas")
+    } else if hasTag(tags[:x], tag) {
+      return errors.New("Duplicate Tag " + tag)
+    }
+  }
+  return nil
+}
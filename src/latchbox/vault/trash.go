@@ -0,0 +1,161 @@
+/*-
+ * Copyright (C) 2014-2017, Vi Grey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY AUTHOR AND CONTRIBUTORS "AS IS" AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL AUTHOR OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+/*
+ * The trash of a password file.  Deleted entries are kept in the trash with
+ * the time they were deleted until they are restored or purged, and are
+ * saved as data packets with a Deleted extension record.
+ */
+
+package vault
+
+import (
+  "errors"
+  "strings"
+  "time"
+)
+
+/* Extension record type of the time an entry was moved to the trash. */
+const deletedRecord = 5
+
+/* Returned when an entry isn't in the trash. */
+var ErrNotTrashed = errors.New("Entry Not in Trash")
+
+/*
+ * Moves e from the entries of v to the trash and records now as the time it
+ * was deleted.
+ */
+func (v *Vault) Delete(e *Entry, now time.Time) error {
+  if err := v.Remove(e); err != nil {
+    return err
+  }
+  e.Deleted = time.Unix(now.Unix(), 0)
+  v.Trash = append(v.Trash, e)
+  return nil
+}
+
+/*
+ * Moves e from the trash back to the entries of v.  e isn't restored if its
+ * name/group combination is already used by another entry.
+ */
+func (v *Vault) Restore(e *Entry) error {
+  if !v.unlocked {
+    return ErrLocked
+  }
+  x := trashIndex(v.Trash, e)
+  if x < 0 {
+    return ErrNotTrashed
+  } else if v.Find(e.NameGroup()) != nil {
+    return ErrDuplicate
+  }
+  v.Trash = append(v.Trash[:x:x], v.Trash[x + 1:]...)
+  e.Deleted = time.Time{}
+  v.Entries = append(v.Entries, e)
+  return nil
+}
+
+/* Deletes e from the trash for good. */
+func (v *Vault) Purge(e *Entry) error {
+  if !v.unlocked {
+    return ErrLocked
+  }
+  x := trashIndex(v.Trash, e)
+  if x < 0 {
+    return ErrNotTrashed
+  }
+  v.Trash = append(v.Trash[:x:x], v.Trash[x + 1:]...)
+  return nil
+}
+
+/*
+ * Deletes every entry that was moved to the trash before before for good
+ * and returns how many were deleted.
+ */
+func (v *Vault) PurgeTrash(before time.Time) int {
+  var kept []*Entry
+  for _, e := range v.Trash {
+    if !e.Deleted.Before(before) {
+      kept = append(kept, e)
+    }
+  }
+  purged := len(v.Trash) - len(kept)
+  v.Trash = kept
+  return purged
+}
+
+/*
+ * Returns the entries of v sorted by name/group combination whose name,
+ * group, username, email, URL or one of whose tags has query in it,
+ * ignoring case.  Entries in the trash are only searched if trash is true
+ * and come after the others.
+ */
+func (v *Vault) Search(query string, trash bool) []*Entry {
+  matches := searchEntries(v.Entries, query)
+  if trash {
+    matches = append(matches, searchEntries(v.Trash, query)...)
+  }
+  return matches
+}
+
+/* Returns the entries that match query sorted by name/group combination. */
+func searchEntries(entries []*Entry, query string) []*Entry {
+  var matches []*Entry
+  query = strings.ToLower(query)
+  for _, e := range Sort(entries) {
+    for _, value := range append([]string{e.Name, e.Group, e.Username,
+                                          e.Email, e.URL}, e.Tags...) {
+      if strings.Contains(strings.ToLower(value), query) {
+        matches = append(matches, e)
+        break
+      }
+    }
+  }
+  return matches
+}
+
+/* Returns the index of e in trash, or -1 if it isn't in trash. */
+func trashIndex(trash []*Entry, e *Entry) int {
+  for x := range trash {
+    if trash[x] == e {
+      return x
+    }
+  }
+  return -1
+}
+
+/* Splits entries into the entries that are in the trash and the others. */
+func splitTrash(entries []*Entry) ([]*Entry, []*Entry) {
+  var kept, trash []*Entry
+  for _, e := range entries {
+    if e.Deleted.IsZero() {
+      kept = append(kept, e)
+    } else {
+      trash = append(trash, e)
+    }
+  }
+  return kept, trash
+}
@@ -0,0 +1,408 @@
+/*-
+ * Copyright (C) 2014-2017, Vi Grey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * 1. Redistributions of source code must retain the above copyright
+ *    notice, this list of conditions and the following disclaimer.
+ * 2. Redistributions in binary form must reproduce the above copyright
+ *    notice, this list of conditions and the following disclaimer in the
+ *    documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY AUTHOR AND CONTRIBUTORS "AS IS" AND
+ * ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+ * IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ * ARE DISCLAIMED. IN NO EVENT SHALL AUTHOR OR CONTRIBUTORS BE LIABLE
+ * FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR CONSEQUENTIAL
+ * DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS
+ * OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS INTERRUPTION)
+ * HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT
+ * LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY
+ * OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF
+ * SUCH DAMAGE.
+ */
+
+/*
+ * Package vault reads, writes and changes LatchBox password files, as
+ * described in docs/latchbox-spec.txt.  A Vault is opened with Open or made
+ * with New, unlocked with its passphrase and saved with Save.  Every method
+ * that can fail returns an error with a message that can be shown to the
+ * user as is.
+ */
+
+package vault
+
+import (
+  "crypto/hmac"
+  "errors"
+  "io/ioutil"
+  "sort"
+  "strings"
+  "time"
+)
+
+const (
+  /* Protocol Version to save password files under. */
+  ProtocolVersion = 3
+  /* Lowest amount of PBKDF2 iterations a password file can be saved with. */
+  MinIterations = 100000
+  /* YYYY-MM-DD hh:mm:ss 24-hour time (computer's localtime) */
+  TimeLayout = "2006-01-02 15:04:05"
+  AES256GCM = 0
+  CHACHA20POLY1305 = 1
+)
+
+var (
+  ErrCorrupted = errors.New("Corrupted Password File")
+  ErrDuplicate = errors.New("Duplicate Name/Group Combination")
+  ErrInvalid = errors.New("Password File Invalid/Corrupted")
+  ErrLocked = errors.New("Password File is Locked")
+  ErrNotFound = errors.New("Entry Not Found")
+  ErrPassphrase = errors.New("Incorrect Passphrase/Keyfile Combination")
+)
+
+/*
+ * An entry of a password file.  Type is the name of the template the entry
+ * was made with, which is empty for logins.  History has the previous
+ * passwords of the entry, newest first.  Deleted is when the entry was
+ * moved to the trash, or the zero time if it isn't in the trash.  OTP is
+ * nil if the entry has no one-time passwords.  RecoveryCodes are in the
+ * order they should be used.  Favorite entries are listed first.  Expires is
+ * when the password should be changed, or the zero time if it doesn't have
+ * to be.  Generator has the settings the password of the entry was last
+ * generated with, or is nil if it never was.
+ */
+type Entry struct {
+  Name, Username, Password, Email, URL, Group, Comment, Type string
+  Created, Modified time.Time
+  Fields []Field
+  Attachments []Attachment
+  History []OldPassword
+  Deleted time.Time
+  OTP *OTP
+  RecoveryCodes []RecoveryCode
+  Tags []string
+  Favorite bool
+  Expires time.Time
+  Generator *Generator
+  records []record
+}
+
+/* A group of a password file and the entries in it. */
+type Group struct {
+  Path string
+  Entries []*Entry
+}
+
+/*
+ * A password file.  Cipher and Iterations are used the next time the
+ * password file is saved.  Version is the protocol version the password
+ * file was last read or saved under.  Trash has the deleted entries, which
+ * aren't in Entries.
+ */
+type Vault struct {
+  Path string
+  Cipher int
+  Iterations uint32
+  Version uint16
+  Entries, Trash []*Entry
+  passphrase string
+  ciphertext, content []byte
+  nonce uint64
+  unlocked bool
+}
+
+/*
+ * Makes a new unlocked password file with no entries that will be saved to
+ * path and encrypted with passphrase.
+ */
+func New(path, passphrase string) *Vault {
+  return &Vault{
+    Path: path,
+    Cipher: CHACHA20POLY1305,
+    Iterations: MinIterations,
+    Version: ProtocolVersion,
+    passphrase: passphrase,
+    nonce: bytesToNum(RandomBytes(8)),
+    unlocked: true,
+  }
+}
+
+/*
+ * Reads the encrypted password file in path.  The returned Vault is locked
+ * until Unlock is called.
+ */
+func Open(path string) (*Vault, error) {
+  ciphertext, err := ioutil.ReadFile(path)
+  if err != nil {
+    return nil, errors.New("Unable to Read File \"" + path + "\"")
+  }
+  ciph, iter, _, _, _, err := parseCt(ciphertext)
+  if err != nil {
+    return nil, ErrInvalid
+  }
+  return &Vault{Path: path, Cipher: ciph, Iterations: iter,
+                ciphertext: ciphertext}, nil
+}
+
+/*
+ * Decrypts the password file with passphrase and parses its entries.  If
+ * the password file decrypts but can't be parsed, ErrCorrupted is returned
+ * and the password file stays locked, but Check and Salvage can still be
+ * used on it.
+ */
+func (v *Vault) Unlock(passphrase string) error {
+  ciph, iter, salt, ct, old, err := parseCt(v.ciphertext)
+  if err != nil {
+    return ErrInvalid
+  }
+  content, nonce, decrypted := decrypt(ct, DeriveKey([]byte(passphrase), salt,
+                                                     iter), ciph, old)
+  if !decrypted {
+    return ErrPassphrase
+  }
+  v.passphrase = passphrase
+  v.content = content
+  v.nonce = nonce
+  version, entries, err := parseContent(content)
+  if err != nil {
+    return err
+  }
+  v.Version = version
+  v.Entries, v.Trash = splitTrash(entries)
+  v.unlocked = true
+  return nil
+}
+
+/*
+ * Forgets the passphrase, decrypted content and entries of the password
+ * file.  It can be unlocked again with Unlock.
+ */
+func (v *Vault) Lock() {
+  v.passphrase = ""
+  v.content = nil
+  v.Entries = nil
+  v.Trash = nil
+  v.unlocked = false
+}
+
+/* Returns true if the password file hasn't been unlocked. */
+func (v *Vault) Locked() bool {
+  return !v.unlocked
+}
+
+/*
+ * Returns the encrypted content of the password file as it was when it was
+ * opened, or nil if it was made with New.
+ */
+func (v *Vault) Ciphertext() []byte {
+  return v.ciphertext
+}
+
+/* Returns the passphrase the password file is encrypted with. */
+func (v *Vault) Passphrase() string {
+  return v.passphrase
+}
+
+/* Makes the password file be encrypted with passphrase when it is saved. */
+func (v *Vault) SetPassphrase(passphrase string) {
+  v.passphrase = passphrase
+}
+
+/* Returns true if passphrase is the passphrase of the password file. */
+func (v *Vault) CheckPassphrase(passphrase string) bool {
+  return hmac.Equal([]byte(passphrase), []byte(v.passphrase))
+}
+
+/*
+ * Encrypts the entries of the password file under the current protocol
+ * version and writes them to Path.
+ */
+func (v *Vault) Save() error {
+  if !v.unlocked {
+    return ErrLocked
+  }
+  nameGroups := make(map[string]bool)
+  for _, e := range v.Entries {
+    if err := e.Validate(); err != nil {
+      return errors.New(err.Error() + " (" + e.NameGroup() + ")")
+    }
+    if nameGroups[e.NameGroup()] {
+      return ErrDuplicate
+    }
+    nameGroups[e.NameGroup()] = true
+  }
+  for _, e := range v.Trash {
+    if err := e.Validate(); err != nil {
+      return errors.New(err.Error() + " (" + e.NameGroup() + ")")
+    }
+  }
+  content := v.encode()
+  v.nonce++
+  ciphertext := encryptFile(content, v.passphrase, v.Cipher, v.Iterations,
+                            v.nonce)
+  if err := ioutil.WriteFile(v.Path, ciphertext, 0644); err != nil {
+    return err
+  }
+  v.Version = ProtocolVersion
+  v.content = content
+  return nil
+}
+
+/*
+ * Adds e to the password file.  e isn't added if it isn't valid or its
+ * name/group combination is already used.
+ */
+func (v *Vault) Add(e *Entry) error {
+  if !v.unlocked {
+    return ErrLocked
+  }
+  if err := e.Validate(); err != nil {
+    return err
+  }
+  if v.Find(e.NameGroup()) != nil {
+    return ErrDuplicate
+  }
+  v.Entries = append(v.Entries, e)
+  return nil
+}
+
+/* Removes e from the password file. */
+func (v *Vault) Remove(e *Entry) error {
+  if !v.unlocked {
+    return ErrLocked
+  }
+  for x := range v.Entries {
+    if v.Entries[x] == e {
+      v.Entries = append(v.Entries[:x:x], v.Entries[x + 1:]...)
+      return nil
+    }
+  }
+  return ErrNotFound
+}
+
+/*
+ * Returns the entry with the name/group combination nameGroup, or nil if
+ * there is no such entry.
+ */
+func (v *Vault) Find(nameGroup string) *Entry {
+  for _, e := range v.Entries {
+    if e.NameGroup() == nameGroup {
+      return e
+    }
+  }
+  return nil
+}
+
+/*
+ * Returns the entries of the password file sorted case insensitively by
+ * name/group combination.
+ */
+func (v *Vault) Sorted() []*Entry {
+  return Sort(v.Entries)
+}
+
+/* Returns the groups of the password file sorted case insensitively. */
+func (v *Vault) Groups() []*Group {
+  var groups []*Group
+  groupDict := make(map[string]*Group)
+  for _, e := range v.Sorted() {
+    if e.Group == "" {
+      continue
+    }
+    if groupDict[e.Group] == nil {
+      groupDict[e.Group] = &Group{Path: e.Group}
+      groups = append(groups, groupDict[e.Group])
+    }
+    groupDict[e.Group].Entries = append(groupDict[e.Group].Entries, e)
+  }
+  sort.SliceStable(groups, func(x, y int) bool {
+    return strings.ToLower(groups[x].Path) < strings.ToLower(groups[y].Path)
+  })
+  return groups
+}
+
+/*
+ * Returns a copy of entries sorted case insensitively by name/group
+ * combination, the same order entries are listed in.
+ */
+func Sort(entries []*Entry) []*Entry {
+  sorted := append([]*Entry(nil), entries...)
+  sort.SliceStable(sorted, func(x, y int) bool {
+    return strings.ToLower(sorted[x].NameGroup() + "/:") <
+      strings.ToLower(sorted[y].NameGroup() + "/:")
+  })
+  return sorted
+}
+
+/* Returns the name/group combination of e as shown in the entry list. */
+func (e *Entry) NameGroup() string {
+  return NameGroup(e.Name, e.Group)
+}
+
+/* Returns the name/group combination of name in group. */
+func NameGroup(name, group string) string {
+  if group == "" {
+    return name
+  }
+  return group + "/" + name
+}
+
+/*
+ * Returns an error if a value of e is too long to be saved or if the name
+ * or group of e isn't valid.
+ */
+func (e *Entry) Validate() error {
+  if len(e.Name) == 0 {
+    return errors.New("Name Required")
+  } else if len(e.Name) > 255 {
+    return errors.New("Name Too Long")
+  } else if strings.Contains(e.Name, "/") {
+    return errors.New("Invalid Character \"/\"")
+  } else if len(e.Username) > 255 {
+    return errors.New("Username Too Long")
+  } else if len(e.Password) > 65535 {
+    return errors.New("Password Too Long")
+  } else if len(e.Email) > 255 {
+    return errors.New("Email Too Long")
+  } else if len(e.URL) > 255 {
+    return errors.New("URL Too Long")
+  } else if len(e.Group) > 255 {
+    return errors.New("Group Name Too Long")
+  } else if !ValidGroup(e.Group) {
+    return errBadGroup
+  } else if len(e.Comment) > 65535 {
+    return errors.New("Comment Too Long")
+  } else if len(e.Type) > 255 {
+    return errors.New("Type Too Long")
+  } else if err := e.validateFields(); err != nil {
+    return err
+  } else if err := e.validateHistory(); err != nil {
+    return err
+  } else if err := validateRecoveryCodes(e.RecoveryCodes); err != nil {
+    return err
+  } else if err := validateTags(e.Tags); err != nil {
+    return err
+  } else if e.OTP != nil {
+    if err := e.OTP.Validate(); err != nil {
+      return err
+    }
+  }
+  return e.validateAttachments()
+}
+
+/*
+ * Returns true if group is a valid group name, which can't start with a
+ * space or /, end with / or have // or "/ " in it.
+ */
+func ValidGroup(group string) bool {
+  if group == "" {
+    return true
+  }
+  return group[0] != ' ' && group[0] != '/' &&
+    group[len(group) - 1] != '/' && !strings.Contains(group, "//") &&
+    !strings.Contains(group, "/ ")
+}